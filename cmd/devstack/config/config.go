@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"time"
+
+	notificationconfig "go_example/cmd/notification-service/config"
+	orderconfig "go_example/cmd/order-service/config"
+	paymentconfig "go_example/cmd/payment-service/config"
+	queryconfig "go_example/cmd/query-service/config"
+	userconfig "go_example/cmd/user-service/config"
+)
+
+// Config holds devstack configuration: the shared bus and one config per embedded service.
+type Config struct {
+	Bus           string
+	User          *userconfig.Config
+	Order         *orderconfig.Config
+	Payment       *paymentconfig.Config
+	Query         *queryconfig.Config
+	Notification  *notificationconfig.Config
+	ShutdownGrace time.Duration
+	ShutdownDrain time.Duration
+}
+
+// Load reads configuration from environment. Service configs are loaded as usual and then
+// given distinct ports and databases, defaulting to the Docker Compose Postgres ports. The
+// services share one logger, so only order-service serves the admin port (ADMIN_PORT).
+func Load() *Config {
+	order := orderconfig.Load()
+	order.ServerPort = getEnv("ORDER_SERVER_PORT", "8091")
+	order.DB.Port = getEnv("ORDER_DB_PORT", "5434")
+	order.DB.Database = getEnv("ORDER_DB_NAME", "order_db")
+
+	user := userconfig.Load()
+	user.ServerPort = getEnv("USER_SERVER_PORT", "8081")
+	user.DB.Port = getEnv("USER_DB_PORT", "5433")
+	user.DB.Database = getEnv("USER_DB_NAME", "user_db")
+	user.OrderServiceURL = getEnv("ORDER_SERVICE_URL", "http://localhost:"+order.ServerPort)
+
+	payment := paymentconfig.Load()
+	payment.ServerPort = getEnv("PAYMENT_SERVER_PORT", "8097")
+	payment.DB.Port = getEnv("PAYMENT_DB_PORT", "5437")
+	payment.DB.Database = getEnv("PAYMENT_DB_NAME", "payment_db")
+
+	query := queryconfig.Load()
+	query.ServerPort = getEnv("QUERY_SERVER_PORT", "8095")
+	query.DB.Port = getEnv("QUERY_DB_PORT", "5435")
+	query.DB.Database = getEnv("QUERY_DB_NAME", "query_db")
+
+	notification := notificationconfig.Load()
+	notification.ServerPort = getEnv("NOTIFICATION_SERVER_PORT", "8096")
+	notification.DB.Port = getEnv("NOTIFICATION_DB_PORT", "5436")
+	notification.DB.Database = getEnv("NOTIFICATION_DB_NAME", "notification_db")
+
+	user.AdminPort, payment.AdminPort, query.AdminPort, notification.AdminPort = "", "", "", ""
+
+	bus := getEnv("BUS", "memory")
+	order.Bus, user.Bus, payment.Bus, query.Bus, notification.Bus = bus, bus, bus, bus, bus
+
+	return &Config{Bus: bus, User: user, Order: order, Payment: payment, Query: query, Notification: notification, ShutdownGrace: order.ShutdownGrace, ShutdownDrain: order.ShutdownDrain}
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
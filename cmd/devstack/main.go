@@ -0,0 +1,81 @@
+// Devstack: runs user-service, order-service, payment-service, query-service and notification-service in one process on a shared bus (in-memory by default).
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go_example/internal/bus"
+	"go_example/internal/lifecycle"
+	"go_example/internal/logging"
+	"go_example/internal/topics"
+	"go_example/internal/tracing"
+	"go_example/cmd/devstack/config"
+	notificationapp "go_example/cmd/notification-service/app"
+	orderapp "go_example/cmd/order-service/app"
+	orderkafka "go_example/cmd/order-service/kafka"
+	paymentapp "go_example/cmd/payment-service/app"
+	paymentkafka "go_example/cmd/payment-service/kafka"
+	queryapp "go_example/cmd/query-service/app"
+	userapp "go_example/cmd/user-service/app"
+	userkafka "go_example/cmd/user-service/kafka"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := logging.Setup("devstack", cfg.Order.Logging); err != nil {
+		log.Fatalf("devstack: %v", err)
+	}
+
+	if cfg.Bus == bus.KindKafka {
+		specs := append(userkafka.Topics(cfg.User.Kafka.ReplicationFactor), orderkafka.Topics(cfg.Order.Kafka.ReplicationFactor)...)
+		specs = append(specs, paymentkafka.Topics(cfg.Payment.Kafka.ReplicationFactor)...)
+		if err := topics.Run(context.Background(), cfg.Order.Kafka.Config, specs, false); err != nil {
+			logging.Fatal("topic setup failed", logging.Err(err))
+		}
+	}
+
+	b, err := bus.New(cfg.Bus, cfg.Order.Kafka.Config)
+	if err != nil {
+		logging.Fatal("bus setup failed", logging.Err(err))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	lc := lifecycle.New()
+	shutdownTracing, err := tracing.Setup(context.Background(), "devstack", cfg.Order.Tracing)
+	if err != nil {
+		logging.Fatal("tracing setup failed", logging.Err(err))
+	}
+	lc.OnShutdown(lifecycle.PhaseTelemetry, "tracing", shutdownTracing)
+	lc.OnShutdown(lifecycle.PhaseProducers, "bus", func(context.Context) error { return b.Close() })
+	if err := orderapp.Start(cfg.Order, b, lc); err != nil {
+		logging.Fatal("start failed", "app", "order-service", logging.Err(err))
+	}
+	if err := userapp.Start(cfg.User, b, lc); err != nil {
+		logging.Fatal("start failed", "app", "user-service", logging.Err(err))
+	}
+	if err := paymentapp.Start(cfg.Payment, b, lc); err != nil {
+		logging.Fatal("start failed", "app", "payment-service", logging.Err(err))
+	}
+	if err := queryapp.Start(cfg.Query, b, lc); err != nil {
+		logging.Fatal("start failed", "app", "query-service", logging.Err(err))
+	}
+	if err := notificationapp.Start(cfg.Notification, b, lc); err != nil {
+		logging.Fatal("start failed", "app", "notification-service", logging.Err(err))
+	}
+	lc.SetReady()
+	slog.Info("devstack started", "bus", cfg.Bus, "user-service", ":"+cfg.User.ServerPort, "order-service", ":"+cfg.Order.ServerPort,
+		"payment-service", ":"+cfg.Payment.ServerPort, "query-service", ":"+cfg.Query.ServerPort, "notification-service", ":"+cfg.Notification.ServerPort, "admin", ":"+cfg.Order.AdminPort)
+
+	<-ctx.Done()
+	slog.Info("shutting down")
+	if err := lc.Shutdown(cfg.ShutdownDrain, cfg.ShutdownGrace); err != nil {
+		slog.Error("shutdown failed", logging.Err(err))
+	}
+}
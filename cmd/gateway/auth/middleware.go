@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Trusted headers carrying the verified claims to the backends. They are removed from every
+// incoming request, so a backend may rely on them when present.
+const (
+	HeaderUserID     = "X-User-ID"
+	HeaderUserName   = "X-User-Name"
+	HeaderUserScopes = "X-User-Scopes"
+)
+
+// Rule is the authorization of a route: a valid token is required, carrying every scope in
+// Scopes. With OwnerPath the path segment after the route prefix is a user ID; OwnerFields name
+// query parameters and top-level JSON body fields holding one. Each such user ID present must
+// be the token subject. A token with the admin scope passes every check.
+type Rule struct {
+	Scopes      []string `json:"scopes" yaml:"scopes"`
+	OwnerPath   bool     `json:"ownerPath" yaml:"ownerPath"`
+	OwnerFields []string `json:"ownerFields" yaml:"ownerFields"`
+}
+
+// Validate checks that no scope or field is empty.
+func (r *Rule) Validate() error {
+	for _, s := range r.Scopes {
+		if strings.TrimSpace(s) == "" || strings.ContainsAny(s, " \t") {
+			return fmt.Errorf("invalid scope %q", s)
+		}
+	}
+	for _, f := range r.OwnerFields {
+		if strings.TrimSpace(f) == "" {
+			return errors.New("empty owner field")
+		}
+	}
+	return nil
+}
+
+// StripTrustedHeaders removes the trusted headers from incoming requests.
+func StripTrustedHeaders() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		h := &c.Request().Header
+		h.Del(HeaderUserID)
+		h.Del(HeaderUserName)
+		h.Del(HeaderUserScopes)
+		return c.Next()
+	}
+}
+
+// Middleware returns a handler enforcing rule on requests routed under prefix. It answers 401
+// without a valid bearer token and 403 when the token is not authorized; otherwise it sets the
+// trusted headers from the claims.
+func (v *Verifier) Middleware(prefix string, rule Rule) fiber.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return func(c fiber.Ctx) error {
+		token, ok := bearer(c.Get(fiber.HeaderAuthorization))
+		if !ok {
+			return unauthorized(c, "", "bearer token required")
+		}
+		claims, err := v.Verify(token)
+		if err != nil {
+			return unauthorized(c, "invalid_token", err.Error())
+		}
+		if !v.IsAdmin(claims) {
+			for _, s := range rule.Scopes {
+				if !claims.HasScope(s) {
+					return forbidden(c, "insufficient_scope", fmt.Sprintf("scope %q required", s))
+				}
+			}
+			if err := checkOwner(c, prefix, rule, claims.Subject); errors.Is(err, errNotJSON) {
+				return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"error": err.Error()})
+			} else if err != nil {
+				return forbidden(c, "", err.Error())
+			}
+		}
+		h := &c.Request().Header
+		h.Set(HeaderUserID, claims.Subject)
+		if claims.Username != "" {
+			h.Set(HeaderUserName, claims.Username)
+		}
+		if claims.Scope != "" {
+			h.Set(HeaderUserScopes, claims.Scope)
+		}
+		return c.Next()
+	}
+}
+
+var errNotJSON = errors.New("request body must be application/json")
+
+// checkOwner returns an error unless every user ID named by rule in the request is subject.
+// User IDs are UUIDs, compared ignoring case.
+func checkOwner(c fiber.Ctx, prefix string, rule Rule, subject string) error {
+	if rule.OwnerPath {
+		rest := strings.TrimPrefix(c.Path()[min(len(prefix), len(c.Path())):], "/")
+		seg, _, _ := strings.Cut(rest, "/")
+		if seg != "" && !strings.EqualFold(seg, subject) {
+			return errors.New("not the owner of this resource")
+		}
+	}
+	if len(rule.OwnerFields) == 0 {
+		return nil
+	}
+	args := c.Request().URI().QueryArgs()
+	for _, f := range rule.OwnerFields {
+		for _, v := range args.PeekMulti(f) {
+			if !strings.EqualFold(string(v), subject) {
+				return fmt.Errorf("%s must be the token subject", f)
+			}
+		}
+	}
+	body := c.Body()
+	if len(body) == 0 {
+		return nil
+	}
+	// Backends bind other media types too; only JSON is inspected, so only JSON is let through.
+	if !strings.HasPrefix(strings.ToLower(c.Get(fiber.HeaderContentType)), fiber.MIMEApplicationJSON) {
+		return errNotJSON
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return nil // the backend rejects it
+	}
+	// encoding/json matches keys to struct fields ignoring case, so backends would too.
+	for key, raw := range fields {
+		for _, f := range rule.OwnerFields {
+			if !strings.EqualFold(key, f) {
+				continue
+			}
+			var v string
+			if json.Unmarshal(raw, &v) != nil || !strings.EqualFold(v, subject) {
+				return fmt.Errorf("%s must be the token subject", f)
+			}
+		}
+	}
+	return nil
+}
+
+func bearer(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// unauthorized responds 401 with a Bearer challenge (RFC 6750).
+func unauthorized(c fiber.Ctx, code, msg string) error {
+	challenge := `Bearer realm="gateway"`
+	if code != "" {
+		challenge += fmt.Sprintf(`, error=%q`, code)
+	}
+	c.Set(fiber.HeaderWWWAuthenticate, challenge)
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": msg})
+}
+
+func forbidden(c fiber.Ctx, code, msg string) error {
+	if code != "" {
+		c.Set(fiber.HeaderWWWAuthenticate, fmt.Sprintf(`Bearer realm="gateway", error=%q`, code))
+	}
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": msg})
+}
@@ -0,0 +1,176 @@
+// Package auth authenticates gateway requests with JWTs and authorizes them per route. Verified
+// claims are forwarded to the backends as trusted headers.
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"go_example/internal/jwt"
+	"go_example/internal/logging"
+)
+
+// jwksRetry is how soon a failed JWKS fetch is retried.
+const jwksRetry = 5 * time.Second
+
+// Config holds how tokens are verified: HS256 tokens against Secret and RS256 tokens against
+// the keys of JWKSFile and of JWKSURL, fetched every JWKSRefresh. Issuer and Audience are
+// checked when set. Tokens with AdminScope pass every scope and ownership check.
+type Config struct {
+	Secret      string
+	JWKSFile    string
+	JWKSURL     string
+	JWKSRefresh time.Duration
+	Issuer      string
+	Audience    string
+	AdminScope  string
+	Leeway      time.Duration
+}
+
+// Enabled reports whether any verification key is configured.
+func (c Config) Enabled() bool {
+	return c.Secret != "" || c.JWKSFile != "" || c.JWKSURL != ""
+}
+
+// Verifier verifies bearer tokens.
+type Verifier struct {
+	cfg     Config
+	client  *http.Client
+	public  atomic.Pointer[map[string]*rsa.PublicKey]
+	fetched atomic.Bool
+}
+
+// NewVerifier creates a verifier and loads the JWKS file and URL, if any. A JWKS URL that cannot
+// be fetched yet is retried by Run.
+func NewVerifier(cfg Config) (*Verifier, error) {
+	if !cfg.Enabled() {
+		return nil, errors.New("auth: no HS256 secret or JWKS")
+	}
+	v := &Verifier{cfg: cfg, client: &http.Client{Timeout: 5 * time.Second}}
+	v.public.Store(&map[string]*rsa.PublicKey{})
+	if err := v.LoadJWKS(); err != nil {
+		if !errors.Is(err, errFetch) {
+			return nil, err
+		}
+		slog.Warn("JWKS fetch failed, retrying", logging.Err(err))
+	}
+	return v, nil
+}
+
+var errFetch = errors.New("auth: fetch JWKS")
+
+// LoadJWKS re-reads the JWKS file and fetches the JWKS URL. On error the keys in use are kept.
+func (v *Verifier) LoadJWKS() error {
+	keys := map[string]*rsa.PublicKey{}
+	if v.cfg.JWKSFile != "" {
+		data, err := os.ReadFile(v.cfg.JWKSFile)
+		if err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+		fileKeys, err := parseJWKS(data)
+		if err != nil {
+			return fmt.Errorf("auth: %s: %w", v.cfg.JWKSFile, err)
+		}
+		maps.Copy(keys, fileKeys)
+	}
+	if v.cfg.JWKSURL != "" {
+		urlKeys, err := v.fetch()
+		if err != nil {
+			return fmt.Errorf("%w %s: %v", errFetch, v.cfg.JWKSURL, err)
+		}
+		maps.Copy(keys, urlKeys)
+		v.fetched.Store(true)
+	}
+	v.public.Store(&keys)
+	return nil
+}
+
+func (v *Verifier) fetch() (map[string]*rsa.PublicKey, error) {
+	resp, err := v.client.Get(v.cfg.JWKSURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+func parseJWKS(data []byte) (map[string]*rsa.PublicKey, error) {
+	var set jwt.JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys, err := set.RSAKeys()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no RS256 signing keys")
+	}
+	return keys, nil
+}
+
+// Run fetches the JWKS URL every JWKSRefresh, and sooner until a fetch succeeds, until ctx is
+// canceled. It does nothing without a JWKS URL.
+func (v *Verifier) Run(ctx context.Context) {
+	if v.cfg.JWKSURL == "" {
+		return
+	}
+	for {
+		wait := v.cfg.JWKSRefresh
+		if !v.fetched.Load() || wait <= 0 {
+			wait = jwksRetry
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if err := v.LoadJWKS(); err != nil {
+			slog.Error("JWKS refresh failed", logging.Err(err))
+		}
+	}
+}
+
+// Verify checks the signature, times, issuer and audience of token and returns its claims.
+func (v *Verifier) Verify(token string) (*jwt.Claims, error) {
+	c, err := jwt.Parse(token, jwt.Keys{Secret: []byte(v.cfg.Secret), Public: *v.public.Load()})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Valid(time.Now(), v.cfg.Leeway); err != nil {
+		return nil, err
+	}
+	if c.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	if v.cfg.Issuer != "" && c.Issuer != v.cfg.Issuer {
+		return nil, errors.New("token from another issuer")
+	}
+	if v.cfg.Audience != "" && !slices.Contains(c.Audience, v.cfg.Audience) {
+		return nil, errors.New("token for another audience")
+	}
+	return c, nil
+}
+
+// IsAdmin reports whether c carries the admin scope.
+func (v *Verifier) IsAdmin(c *jwt.Claims) bool {
+	return v.cfg.AdminScope != "" && c.HasScope(v.cfg.AdminScope)
+}
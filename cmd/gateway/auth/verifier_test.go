@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"go_example/internal/jwt"
+)
+
+func TestVerify(t *testing.T) {
+	cfg := Config{Secret: "secret", Issuer: "user-service", Audience: "api", Leeway: 5 * time.Second}
+	v, err := NewVerifier(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := func() jwt.Claims {
+		return jwt.Claims{
+			Issuer:    "user-service",
+			Subject:   "alice",
+			Audience:  jwt.Audience{"api"},
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		}
+	}
+	tests := []struct {
+		name   string
+		modify func(*jwt.Claims)
+		secret string
+		ok     bool
+	}{
+		{name: "valid", modify: func(*jwt.Claims) {}, ok: true},
+		{name: "one of several audiences", modify: func(c *jwt.Claims) { c.Audience = jwt.Audience{"web", "api"} }, ok: true},
+		{name: "expired within leeway", modify: func(c *jwt.Claims) { c.ExpiresAt = time.Now().Add(-time.Second).Unix() }, ok: true},
+		{name: "expired", modify: func(c *jwt.Claims) { c.ExpiresAt = time.Now().Add(-time.Minute).Unix() }},
+		{name: "no expiry", modify: func(c *jwt.Claims) { c.ExpiresAt = 0 }},
+		{name: "other audience", modify: func(c *jwt.Claims) { c.Audience = jwt.Audience{"web"} }},
+		{name: "no audience", modify: func(c *jwt.Claims) { c.Audience = nil }},
+		{name: "other issuer", modify: func(c *jwt.Claims) { c.Issuer = "evil" }},
+		{name: "no subject", modify: func(c *jwt.Claims) { c.Subject = "" }},
+		{name: "wrong secret", modify: func(*jwt.Claims) {}, secret: "other"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid()
+			tt.modify(&c)
+			secret := tt.secret
+			if secret == "" {
+				secret = cfg.Secret
+			}
+			tok, err := jwt.Sign(c, jwt.Key{Secret: []byte(secret)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := v.Verify(tok); (err == nil) != tt.ok {
+				t.Errorf("Verify error = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -2,22 +2,96 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"go_example/internal/logging"
+	"go_example/internal/tracing"
+	"go_example/cmd/gateway/auth"
+	"go_example/cmd/gateway/ratelimit"
+	"go_example/cmd/gateway/upstream"
 )
 
-// Config holds gateway configuration.
+// Config holds gateway configuration. RoutesFile, when set, is the route table; without it the
+// routes are built from the service URLs, requiring a token when Auth is enabled.
+// UserServiceURLs and OrderServiceURLs entries may carry a weight, e.g.
+// "http://user-service-1:8081;weight=3"; ORDER_SERVICE_URL is read when ORDER_SERVICE_URLS is
+// unset.
+// RouteTimeout and RouteRetries apply to every route of the default table. OrderRateLimit
+// limits placing orders in it; zero requests disable it. AdminPort serves the /admin endpoints,
+// kept off the public port; empty disables them.
 type Config struct {
-	Port            string
-	UserServiceURLs []string
-	OrderServiceURL string
+	Port                   string
+	AdminPort              string
+	RoutesFile             string
+	RouteTimeout           time.Duration
+	RouteRetries           int
+	UserServiceURLs        []string
+	UserServiceBalancer    string
+	Upstream               upstream.Config
+	OrderServiceURLs       []string
+	OrderServiceBalancer   string
+	PaymentServiceURL      string
+	QueryServiceURL        string
+	NotificationServiceURL string
+	Auth                   auth.Config
+	APIKeyHeader           string
+	APIKeys                []string
+	OrderRateLimit         ratelimit.Rule
+	Tracing                tracing.Config
+	Logging                logging.Config
 }
 
 // Load reads configuration from environment.
 func Load() *Config {
 	return &Config{
-		Port:            getEnv("PORT", "8080"),
-		UserServiceURLs: getEnvSlice("USER_SERVICE_URLS", []string{"http://user-service-1:8081", "http://user-service-2:8082"}),
-		OrderServiceURL: getEnv("ORDER_SERVICE_URL", "http://order-service:8091"),
+		Port:                   getEnv("PORT", "8080"),
+		AdminPort:              getEnv("GATEWAY_ADMIN_PORT", "9080"),
+		RoutesFile:             getEnv("GATEWAY_ROUTES_FILE", ""),
+		RouteTimeout:           getEnvDuration("GATEWAY_ROUTE_TIMEOUT", 30*time.Second),
+		RouteRetries:           getEnvInt("GATEWAY_ROUTE_RETRIES", 2),
+		UserServiceURLs:        getEnvSlice("USER_SERVICE_URLS", []string{"http://user-service-1:8081", "http://user-service-2:8082"}),
+		UserServiceBalancer:    getEnv("USER_SERVICE_BALANCER", upstream.RoundRobin),
+		OrderServiceURLs:       getEnvSlice("ORDER_SERVICE_URLS", getEnvSlice("ORDER_SERVICE_URL", []string{"http://order-service:8091"})),
+		OrderServiceBalancer:   getEnv("ORDER_SERVICE_BALANCER", upstream.RoundRobin),
+		PaymentServiceURL:      getEnv("PAYMENT_SERVICE_URL", "http://payment-service:8097"),
+		QueryServiceURL:        getEnv("QUERY_SERVICE_URL", "http://query-service:8095"),
+		NotificationServiceURL: getEnv("NOTIFICATION_SERVICE_URL", "http://notification-service:8096"),
+		Upstream: upstream.Config{
+			HealthPath:         getEnv("UPSTREAM_HEALTH_PATH", "/ready"),
+			HealthInterval:     getEnvDuration("UPSTREAM_HEALTH_INTERVAL", 5*time.Second),
+			HealthTimeout:      getEnvDuration("UPSTREAM_HEALTH_TIMEOUT", 2*time.Second),
+			HealthyThreshold:   getEnvInt("UPSTREAM_HEALTHY_THRESHOLD", 2),
+			UnhealthyThreshold: getEnvInt("UPSTREAM_UNHEALTHY_THRESHOLD", 2),
+			EjectAfter:         getEnvInt("UPSTREAM_EJECT_AFTER", 5),
+			EjectDuration:      getEnvDuration("UPSTREAM_EJECT_DURATION", 30*time.Second),
+			MaxEjectDuration:   getEnvDuration("UPSTREAM_MAX_EJECT_DURATION", 5*time.Minute),
+
+			BreakerFailures:         getEnvInt("UPSTREAM_BREAKER_FAILURES", 10),
+			BreakerOpenDuration:     getEnvDuration("UPSTREAM_BREAKER_OPEN_DURATION", 30*time.Second),
+			BreakerHalfOpenRequests: getEnvInt("UPSTREAM_BREAKER_HALF_OPEN_REQUESTS", 1),
+		},
+		Auth: auth.Config{
+			Secret:      getEnv("JWT_HS256_SECRET", ""),
+			JWKSFile:    getEnv("JWT_JWKS_FILE", ""),
+			JWKSURL:     getEnv("JWT_JWKS_URL", ""),
+			JWKSRefresh: getEnvDuration("JWT_JWKS_REFRESH", 5*time.Minute),
+			Issuer:      getEnv("JWT_ISSUER", ""),
+			Audience:    getEnv("JWT_AUDIENCE", ""),
+			AdminScope:  getEnv("JWT_ADMIN_SCOPE", "admin"),
+			Leeway:      getEnvDuration("JWT_LEEWAY", 30*time.Second),
+		},
+		APIKeyHeader: getEnv("RATE_LIMIT_API_KEY_HEADER", "X-API-Key"),
+		APIKeys:      getEnvSlice("RATE_LIMIT_API_KEYS", nil),
+		OrderRateLimit: ratelimit.Rule{
+			Requests: getEnvInt("ORDER_RATE_LIMIT_REQUESTS", 60),
+			Per:      getEnv("ORDER_RATE_LIMIT_PER", "1m"),
+			Burst:    getEnvInt("ORDER_RATE_LIMIT_BURST", 10),
+			Key:      getEnv("ORDER_RATE_LIMIT_KEY", ""),
+		},
+		Tracing: tracing.FromEnv(),
+		Logging: logging.FromEnv(),
 	}
 }
 
@@ -28,6 +102,24 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getEnvInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
 func getEnvSlice(key string, fallback []string) []string {
 	if v := os.Getenv(key); v != "" {
 		parts := strings.Split(v, ",")
@@ -0,0 +1,740 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"go_example/cmd/gateway/auth"
+	"go_example/cmd/gateway/config"
+	"go_example/cmd/gateway/upstream"
+	notificationdto "go_example/cmd/notification-service/dto"
+	notificationhandler "go_example/cmd/notification-service/handler"
+	paymentdto "go_example/cmd/payment-service/dto"
+	paymenthandler "go_example/cmd/payment-service/handler"
+	paymentservice "go_example/cmd/payment-service/service"
+	"go_example/internal/jwt"
+	"go_example/internal/logging"
+	"go_example/internal/requestid"
+)
+
+// stub is an upstream answering every request with its name and what it received.
+type stub struct {
+	*httptest.Server
+	name    string
+	status  atomic.Int32
+	healthy atomic.Bool
+
+	mu       sync.Mutex
+	received []received
+}
+
+type received struct {
+	Upstream string      `json:"upstream"`
+	Method   string      `json:"method"`
+	URI      string      `json:"uri"`
+	Header   http.Header `json:"-"`
+}
+
+func newStub(t *testing.T, name string) *stub {
+	s := &stub{name: name}
+	s.status.Store(http.StatusOK)
+	s.healthy.Store(true)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			if !s.healthy.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+		rec := received{Upstream: name, Method: r.Method, URI: r.URL.RequestURI(), Header: r.Header.Clone()}
+		s.mu.Lock()
+		s.received = append(s.received, rec)
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(int(s.status.Load()))
+		json.NewEncoder(w).Encode(rec)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *stub) hits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.received)
+}
+
+func (s *stub) last() received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.received[len(s.received)-1]
+}
+
+// header returns the headers of the last request got by the stub called name.
+func header(name string, stubs ...*stub) http.Header {
+	for _, s := range stubs {
+		if s.name == name {
+			return s.last().Header
+		}
+	}
+	return nil
+}
+
+// upstreams are the stubs behind the default route table.
+type upstreams struct {
+	users, orders                 []*stub
+	payments, query, notification *stub
+}
+
+func newUpstreams(t *testing.T, orders int) *upstreams {
+	u := &upstreams{
+		users:        []*stub{newStub(t, "user-1"), newStub(t, "user-2")},
+		payments:     newStub(t, "payment"),
+		query:        newStub(t, "query"),
+		notification: newStub(t, "notification"),
+	}
+	for i := range orders {
+		u.orders = append(u.orders, newStub(t, "order-"+string(rune('1'+i))))
+	}
+	return u
+}
+
+func (u *upstreams) config() *config.Config {
+	urls := func(stubs []*stub) []string {
+		out := make([]string, len(stubs))
+		for i, s := range stubs {
+			out[i] = s.URL
+		}
+		return out
+	}
+	return &config.Config{
+		UserServiceURLs:        urls(u.users),
+		OrderServiceURLs:       urls(u.orders),
+		PaymentServiceURL:      u.payments.URL,
+		QueryServiceURL:        u.query.URL,
+		NotificationServiceURL: u.notification.URL,
+		Upstream: upstream.Config{
+			HealthPath:         "/health",
+			HealthInterval:     20 * time.Millisecond,
+			HealthTimeout:      time.Second,
+			HealthyThreshold:   1,
+			UnhealthyThreshold: 1,
+		},
+		Auth:         auth.Config{AdminScope: "admin"},
+		APIKeyHeader: "X-API-Key",
+		APIKeys:      []string{"known"},
+	}
+}
+
+func startGateway(t *testing.T, cfg *config.Config) *fiber.App {
+	t.Helper()
+	app, _ := startGatewayAdmin(t, cfg)
+	return app
+}
+
+// startGatewayAdmin starts the gateway and returns it with its admin app.
+func startGatewayAdmin(t *testing.T, cfg *config.Config) (app, admin *fiber.App) {
+	t.Helper()
+	app, router, _, err := newApp(t.Context(), cfg)
+	if err != nil {
+		t.Fatalf("newApp: %v", err)
+	}
+	t.Cleanup(router.Close)
+	return app, newAdminApp(router)
+}
+
+type response struct {
+	status int
+	header http.Header
+	body   string
+	got    received
+}
+
+func send(t *testing.T, app *fiber.App, method, target, body string, header map[string]string) response {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, r)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := app.Test(req, fiber.TestConfig{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, target, err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	out := response{status: resp.StatusCode, header: resp.Header, body: string(b)}
+	json.Unmarshal(b, &out.got)
+	return out
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDefaultRoutes(t *testing.T) {
+	up := newUpstreams(t, 1)
+	app := startGateway(t, up.config())
+
+	tests := []struct {
+		method, target string
+		status         int
+		upstream       string
+	}{
+		{"POST", "/users", 200, "user-"},
+		{"GET", "/users/u1/orders", 200, "user-"},
+		{"POST", "/auth/login", 200, "user-"},
+		{"GET", "/orders?userId=u1&status=COMPLETED", 200, "order-1"},
+		{"POST", "/orders", 200, "order-1"},
+		{"GET", "/orders/o1/timeline", 200, "order-1"},
+		{"DELETE", "/orders/o1", 200, "order-1"},
+		{"PATCH", "/orders/schedules/s1", 200, "order-1"},
+		{"POST", "/webhooks/w1/enable", 200, "order-1"},
+		{"GET", "/payments?orderId=o1", 200, "payment"},
+		{"GET", "/views/users/u1", 200, "query"},
+		{"PUT", "/notifications/preferences/u1", 200, "notification"},
+		{"GET", "/notifications?userId=u1", 200, "notification"},
+		{"DELETE", "/users/u1", 405, ""},
+		{"POST", "/payments", 405, ""},
+		{"GET", "/unknown", 404, ""},
+		{"GET", "/usersx", 404, ""},
+		{"GET", "/admin/upstreams", 404, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			r := send(t, app, tt.method, tt.target, "", nil)
+			if r.status != tt.status {
+				t.Fatalf("status %d, want %d: %s", r.status, tt.status, r.body)
+			}
+			if tt.upstream == "" {
+				return
+			}
+			if !strings.HasPrefix(r.got.Upstream, tt.upstream) {
+				t.Errorf("served by %q, want %q", r.got.Upstream, tt.upstream)
+			}
+			if r.got.Method != tt.method || r.got.URI != tt.target {
+				t.Errorf("upstream got %s %s, want %s %s", r.got.Method, r.got.URI, tt.method, tt.target)
+			}
+		})
+	}
+
+	if r := send(t, app, "GET", "/health", "", nil); r.status != 200 {
+		t.Errorf("GET /health: status %d", r.status)
+	}
+}
+
+func TestOrderServiceBalancing(t *testing.T) {
+	up := newUpstreams(t, 2)
+	app, admin := startGatewayAdmin(t, up.config())
+
+	for range 4 {
+		if r := send(t, app, "GET", "/orders?userId=u1", "", nil); r.status != 200 || r.got.URI != "/orders?userId=u1" {
+			t.Fatalf("status %d, upstream got %q", r.status, r.got.URI)
+		}
+	}
+	if up.orders[0].hits() != 2 || up.orders[1].hits() != 2 {
+		t.Fatalf("hits %d and %d, want 2 each", up.orders[0].hits(), up.orders[1].hits())
+	}
+
+	up.orders[0].healthy.Store(false)
+	waitFor(t, "order-1 to fail its health check", func() bool {
+		r := send(t, admin, "GET", "/admin/upstreams", "", nil)
+		return strings.Contains(r.body, `"healthy":false`)
+	})
+	before := up.orders[0].hits()
+	for range 4 {
+		if r := send(t, app, "GET", "/orders/o1", "", nil); r.got.Upstream != "order-2" {
+			t.Fatalf("served by %q, want order-2", r.got.Upstream)
+		}
+	}
+	if up.orders[0].hits() != before {
+		t.Errorf("unhealthy order-1 got %d requests", up.orders[0].hits()-before)
+	}
+
+	up.orders[1].healthy.Store(false)
+	waitFor(t, "order-2 to fail its health check", func() bool {
+		return send(t, app, "GET", "/orders/o1", "", nil).status == http.StatusServiceUnavailable
+	})
+}
+
+func TestRetries(t *testing.T) {
+	up := newUpstreams(t, 2)
+	cfg := up.config()
+	cfg.RouteRetries = 2
+	app := startGateway(t, cfg)
+	up.orders[0].status.Store(http.StatusServiceUnavailable)
+
+	for range 2 {
+		if r := send(t, app, "GET", "/orders/o1", "", nil); r.status != 200 || r.got.Upstream != "order-2" {
+			t.Fatalf("GET: status %d from %q, want 200 from order-2", r.status, r.got.Upstream)
+		}
+	}
+
+	// Round-robin alternates, so one of two orders lands on the failing instance.
+	hits := up.orders[0].hits() + up.orders[1].hits()
+	statuses := map[int]int{}
+	for range 2 {
+		statuses[send(t, app, "POST", "/orders", `{"userId":"u1","amount":1}`, nil).status]++
+	}
+	if got := up.orders[0].hits() + up.orders[1].hits() - hits; got != 2 {
+		t.Errorf("POST /orders without idempotency key sent %d times for 2 requests", got)
+	}
+	if statuses[http.StatusServiceUnavailable] != 1 {
+		t.Errorf("statuses %v, want one 503 passed through", statuses)
+	}
+
+	for range 2 {
+		r := send(t, app, "POST", "/orders", `{"userId":"u1","amount":1}`, map[string]string{upstream.HeaderIdempotencyKey: "k1"})
+		if r.status != 200 {
+			t.Errorf("POST with idempotency key: status %d, want 200 after retry", r.status)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	up := newUpstreams(t, 1)
+	cfg := up.config()
+	cfg.Upstream.BreakerFailures = 2
+	cfg.Upstream.BreakerOpenDuration = 200 * time.Millisecond
+	cfg.Upstream.BreakerHalfOpenRequests = 1
+	app := startGateway(t, cfg)
+	up.orders[0].status.Store(http.StatusInternalServerError)
+
+	for range 2 {
+		if r := send(t, app, "GET", "/orders/o1", "", nil); r.status != 500 {
+			t.Fatalf("status %d, want the upstream's 500", r.status)
+		}
+	}
+	hits := up.orders[0].hits()
+	r := send(t, app, "GET", "/orders/o1", "", nil)
+	if r.status != http.StatusServiceUnavailable || r.header.Get("Content-Type") != "application/problem+json" || r.header.Get("Retry-After") == "" {
+		t.Fatalf("open breaker: status %d, type %q, Retry-After %q", r.status, r.header.Get("Content-Type"), r.header.Get("Retry-After"))
+	}
+	var problem map[string]any
+	if err := json.Unmarshal([]byte(r.body), &problem); err != nil || problem["status"] != float64(503) || problem["upstream"] != "order-service" {
+		t.Errorf("problem body %s", r.body)
+	}
+	if up.orders[0].hits() != hits {
+		t.Error("open breaker let a request through")
+	}
+	if r := send(t, app, "GET", "/users/u1", "", nil); r.status != 200 {
+		t.Errorf("other pool: status %d", r.status)
+	}
+
+	up.orders[0].status.Store(http.StatusOK)
+	time.Sleep(250 * time.Millisecond)
+	for range 2 {
+		if r := send(t, app, "GET", "/orders/o1", "", nil); r.status != 200 {
+			t.Fatalf("after recovery: status %d", r.status)
+		}
+	}
+}
+
+func TestCircuitBreakerPerUpstream(t *testing.T) {
+	up := newUpstreams(t, 2)
+	cfg := up.config()
+	cfg.Upstream.EjectAfter = 0
+	cfg.Upstream.BreakerFailures = 2
+	cfg.Upstream.BreakerOpenDuration = time.Minute
+	app := startGateway(t, cfg)
+	up.orders[0].status.Store(http.StatusInternalServerError)
+
+	for range 4 {
+		send(t, app, "GET", "/orders/o1", "", nil)
+	}
+	hits := up.orders[0].hits()
+	for range 4 {
+		if r := send(t, app, "GET", "/orders/o1", "", nil); r.status != 200 || r.got.Upstream != "order-2" {
+			t.Fatalf("status %d from %q, want 200 from order-2 while order-1's breaker is open", r.status, r.got.Upstream)
+		}
+	}
+	if up.orders[0].hits() != hits {
+		t.Error("open breaker let a request through to order-1")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	up := newUpstreams(t, 1)
+	cfg := up.config()
+	cfg.OrderRateLimit.Requests = 2
+	cfg.OrderRateLimit.Per = "1m"
+	app := startGateway(t, cfg)
+
+	for i := range 2 {
+		r := send(t, app, "POST", "/orders", `{"userId":"u1","amount":1}`, nil)
+		if r.status != 200 || r.header.Get("RateLimit-Remaining") != string(rune('1'-i)) || r.header.Get("RateLimit-Limit") != "2" {
+			t.Fatalf("request %d: status %d, RateLimit-Limit %q, -Remaining %q", i+1, r.status, r.header.Get("RateLimit-Limit"), r.header.Get("RateLimit-Remaining"))
+		}
+	}
+	r := send(t, app, "POST", "/orders", `{"userId":"u1","amount":1}`, nil)
+	if r.status != http.StatusTooManyRequests || r.header.Get("Retry-After") == "" {
+		t.Fatalf("status %d, Retry-After %q, want 429 with Retry-After", r.status, r.header.Get("Retry-After"))
+	}
+	if up.orders[0].hits() != 2 {
+		t.Errorf("order-service got %d orders, want 2", up.orders[0].hits())
+	}
+	if r := send(t, app, "POST", "/orders", `{"userId":"u1","amount":1}`, map[string]string{"X-API-Key": "random"}); r.status != http.StatusTooManyRequests {
+		t.Errorf("unknown API key: status %d, want 429 by IP", r.status)
+	}
+	if r := send(t, app, "POST", "/orders", `{"userId":"u1","amount":1}`, map[string]string{"X-API-Key": "known"}); r.status != 200 {
+		t.Errorf("known API key: status %d", r.status)
+	}
+	if r := send(t, app, "GET", "/orders/o1", "", nil); r.status != 200 || r.header.Get("RateLimit-Limit") != "" {
+		t.Errorf("GET: status %d, RateLimit-Limit %q, want unlimited", r.status, r.header.Get("RateLimit-Limit"))
+	}
+}
+
+func TestAuth(t *testing.T) {
+	up := newUpstreams(t, 1)
+	cfg := up.config()
+	cfg.Auth.Secret = "test-secret"
+	app := startGateway(t, cfg)
+
+	token := func(sub, scope string) map[string]string {
+		tok, err := jwt.Sign(jwt.Claims{Subject: sub, Scope: scope, ExpiresAt: time.Now().Add(time.Minute).Unix()}, jwt.Key{Secret: []byte(cfg.Auth.Secret)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return map[string]string{"Authorization": "Bearer " + tok}
+	}
+	alice, admin := token("alice", "user"), token("root", "user admin")
+
+	tests := []struct {
+		name, method, target, body string
+		header                     map[string]string
+		status                     int
+	}{
+		{"sign up anonymously", "POST", "/users", `{"username":"alice"}`, nil, 200},
+		{"log in anonymously", "POST", "/auth/login", `{"username":"alice"}`, nil, 200},
+		{"no token", "GET", "/users/alice", "", nil, 401},
+		{"bad token", "GET", "/users/alice", "", map[string]string{"Authorization": "Bearer x.y.z"}, 401},
+		{"own user", "GET", "/users/alice/orders", "", alice, 200},
+		{"other user", "GET", "/users/bob", "", alice, 403},
+		{"own orders", "GET", "/orders?userId=alice", "", alice, 200},
+		{"other's orders", "GET", "/orders?userId=bob", "", alice, 403},
+		{"order for self", "POST", "/orders", `{"userId":"alice","amount":1}`, alice, 200},
+		{"order for other", "POST", "/orders", `{"userId":"bob","amount":1}`, alice, 403},
+		{"webhooks need admin", "GET", "/webhooks", "", alice, 403},
+		{"admin webhooks", "GET", "/webhooks", "", admin, 200},
+		{"admin other user", "GET", "/users/bob", "", admin, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if r := send(t, app, tt.method, tt.target, tt.body, tt.header); r.status != tt.status {
+				t.Errorf("status %d, want %d: %s", r.status, tt.status, r.body)
+			}
+		})
+	}
+
+	r := send(t, app, "GET", "/users/alice", "", map[string]string{"Authorization": alice["Authorization"], auth.HeaderUserScopes: "admin"})
+	if h := header(r.got.Upstream, up.users...); h.Get(auth.HeaderUserID) != "alice" || h.Get(auth.HeaderUserScopes) != "user" {
+		t.Errorf("trusted headers %q, %q, want alice, user", h.Get(auth.HeaderUserID), h.Get(auth.HeaderUserScopes))
+	}
+	r = send(t, app, "POST", "/users", `{"username":"mallory"}`, map[string]string{auth.HeaderUserID: "alice"})
+	if h := header(r.got.Upstream, up.users...); h.Get(auth.HeaderUserID) != "" {
+		t.Errorf("spoofed %s forwarded: %q", auth.HeaderUserID, h.Get(auth.HeaderUserID))
+	}
+}
+
+// payments serves the payments of a map, as payment-service does from its database.
+type payments map[uuid.UUID]*paymentdto.PaymentResponse
+
+func (p payments) GetByID(_ context.Context, id uuid.UUID) (*paymentdto.PaymentResponse, error) {
+	if pay, ok := p[id]; ok {
+		return pay, nil
+	}
+	return nil, paymentservice.ErrPaymentNotFound
+}
+
+func (p payments) GetByOrderID(_ context.Context, orderID uuid.UUID) (*paymentdto.PaymentResponse, error) {
+	for _, pay := range p {
+		if pay.OrderID == orderID {
+			return pay, nil
+		}
+	}
+	return nil, paymentservice.ErrPaymentNotFound
+}
+
+func (p payments) ListByUserID(_ context.Context, userID uuid.UUID) ([]*paymentdto.PaymentResponse, error) {
+	var list []*paymentdto.PaymentResponse
+	for _, pay := range p {
+		if pay.UserID == userID {
+			list = append(list, pay)
+		}
+	}
+	return list, nil
+}
+
+// notifications serves a list of notifications, as notification-service does from its database.
+type notifications []*notificationdto.NotificationResponse
+
+func (n notifications) ListByOrderID(_ context.Context, orderID uuid.UUID) ([]*notificationdto.NotificationResponse, error) {
+	var list []*notificationdto.NotificationResponse
+	for _, x := range n {
+		if x.OrderID == orderID {
+			list = append(list, x)
+		}
+	}
+	return list, nil
+}
+
+func (n notifications) ListByUserID(_ context.Context, userID uuid.UUID) ([]*notificationdto.NotificationResponse, error) {
+	var list []*notificationdto.NotificationResponse
+	for _, x := range n {
+		if x.UserID == userID {
+			list = append(list, x)
+		}
+	}
+	return list, nil
+}
+
+func (n notifications) GetPreferences(_ context.Context, userID uuid.UUID) (*notificationdto.PreferencesResponse, error) {
+	return &notificationdto.PreferencesResponse{UserID: userID, Default: true}, nil
+}
+
+func (n notifications) PutPreferences(_ context.Context, userID uuid.UUID, _ notificationdto.PreferencesRequest) (*notificationdto.PreferencesResponse, error) {
+	return &notificationdto.PreferencesResponse{UserID: userID}, nil
+}
+
+// serve starts app on a local port and returns its URL.
+func serve(t *testing.T, app *fiber.App) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.Get("/health", func(c fiber.Ctx) error { return nil })
+	go app.Listener(ln, fiber.ListenConfig{DisableStartupMessage: true})
+	t.Cleanup(func() { app.Shutdown() })
+	return "http://" + ln.Addr().String()
+}
+
+func TestOwnershipBehindGateway(t *testing.T) {
+	alice, bob := uuid.New(), uuid.New()
+	orderID, paymentID := uuid.New(), uuid.New()
+
+	owners := paymenthandler.Owners{AdminScope: "admin"}
+	ph := paymenthandler.NewPaymentHandler(payments{paymentID: {ID: paymentID, OrderID: orderID, UserID: bob}}, owners)
+	paymentApp := fiber.New()
+	paymentApp.Get("/payments", ph.List)
+	paymentApp.Get("/payments/:id", ph.GetByID)
+	nh := notificationhandler.NewNotificationHandler(notifications{{ID: 1, OrderID: orderID, UserID: bob}}, notificationhandler.Owners{AdminScope: "admin"})
+	notificationApp := fiber.New()
+	notificationApp.Get("/notifications", nh.List)
+
+	up := newUpstreams(t, 1)
+	cfg := up.config()
+	cfg.PaymentServiceURL = serve(t, paymentApp)
+	cfg.NotificationServiceURL = serve(t, notificationApp)
+	cfg.Auth.Secret = "test-secret"
+	app := startGateway(t, cfg)
+
+	token := func(sub uuid.UUID, scope string) map[string]string {
+		tok, err := jwt.Sign(jwt.Claims{Subject: sub.String(), Scope: scope, ExpiresAt: time.Now().Add(time.Minute).Unix()}, jwt.Key{Secret: []byte(cfg.Auth.Secret)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return map[string]string{"Authorization": "Bearer " + tok}
+	}
+	users := map[string]map[string]string{
+		"owner": token(bob, "user"),
+		"other": token(alice, "user"),
+		"admin": token(alice, "user admin"),
+	}
+
+	tests := []struct {
+		target string
+		status map[string]int // by caller
+	}{
+		{"/payments/" + paymentID.String(), map[string]int{"owner": 200, "other": 404, "admin": 200}},
+		{"/payments?orderId=" + orderID.String(), map[string]int{"owner": 200, "other": 404, "admin": 200}},
+		{"/notifications?orderId=" + orderID.String(), map[string]int{"owner": 200, "other": 404, "admin": 200}},
+		// The gateway rejects another user's ID in the query before the service sees it.
+		{"/payments?userId=" + bob.String(), map[string]int{"owner": 200, "other": 403, "admin": 200}},
+	}
+	for _, tt := range tests {
+		for caller, want := range tt.status {
+			t.Run(caller+" "+tt.target, func(t *testing.T) {
+				r := send(t, app, "GET", tt.target, "", users[caller])
+				if r.status != want {
+					t.Errorf("status %d, want %d: %s", r.status, want, r.body)
+				}
+				if r.status == 200 && !strings.Contains(r.body, bob.String()) {
+					t.Errorf("body %s lacks the owner's payment", r.body)
+				}
+			})
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	up := newUpstreams(t, 1)
+	app := startGateway(t, up.config())
+
+	r := send(t, app, "GET", "/users/alice", "", map[string]string{requestid.Header: "checkout-42"})
+	if got := header(r.got.Upstream, up.users...).Get(requestid.Header); got != "checkout-42" {
+		t.Errorf("forwarded %s %q, want checkout-42", requestid.Header, got)
+	}
+	if got := r.header.Get(requestid.Header); got != "checkout-42" {
+		t.Errorf("response %s %q, want checkout-42", requestid.Header, got)
+	}
+
+	for _, sent := range []string{"", "has space", strings.Repeat("x", 129)} {
+		r := send(t, app, "GET", "/users/alice", "", map[string]string{requestid.Header: sent})
+		got := header(r.got.Upstream, up.users...).Get(requestid.Header)
+		if !requestid.Valid(got) || got == sent {
+			t.Errorf("sent %q: forwarded %q, want a new ID", sent, got)
+		}
+		if r.header.Get(requestid.Header) != got {
+			t.Errorf("sent %q: response %s %q, want %q", sent, requestid.Header, r.header.Get(requestid.Header), got)
+		}
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	prev := logging.Level()
+	t.Cleanup(func() { logging.SetLevel(prev) })
+	logging.SetLevel(slog.LevelInfo)
+	up := newUpstreams(t, 1)
+	app, admin := startGatewayAdmin(t, up.config())
+
+	if r := send(t, app, "PUT", "/admin/log-level", `{"level":"debug"}`, nil); r.status != 404 {
+		t.Errorf("PUT on the public port = %d, want 404", r.status)
+	}
+
+	if r := send(t, admin, "GET", "/admin/log-level", "", nil); r.status != 200 || r.body != `{"level":"info"}` {
+		t.Fatalf("GET = %d %s, want info", r.status, r.body)
+	}
+	if r := send(t, admin, "PUT", "/admin/log-level", `{"level":"DEBUG"}`, nil); r.status != 200 || r.body != `{"level":"debug"}` {
+		t.Fatalf("PUT debug = %d %s", r.status, r.body)
+	}
+	if logging.Level() != slog.LevelDebug {
+		t.Errorf("level %s after PUT, want DEBUG", logging.Level())
+	}
+	if r := send(t, admin, "PUT", "/admin/log-level", `{"level":"verbose"}`, nil); r.status != 400 {
+		t.Errorf("PUT verbose = %d, want 400", r.status)
+	}
+	if logging.Level() != slog.LevelDebug {
+		t.Errorf("level %s after invalid PUT, want DEBUG", logging.Level())
+	}
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	up := newUpstreams(t, 1)
+	app := startGateway(t, up.config())
+
+	const traceID = "0af7651916cd43dd8448eb211c80319c"
+	r := send(t, app, "GET", "/users/alice", "", map[string]string{"traceparent": "00-" + traceID + "-b7ad6b7169203331-01"})
+	got := header(r.got.Upstream, up.users...).Get("traceparent")
+	if !strings.HasPrefix(got, "00-"+traceID+"-") {
+		t.Fatalf("forwarded traceparent %q, want trace %s", got, traceID)
+	}
+
+	spans := map[trace.SpanKind]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		if s.SpanContext().TraceID().String() != traceID {
+			t.Errorf("span %q in trace %s, want %s", s.Name(), s.SpanContext().TraceID(), traceID)
+		}
+		spans[s.SpanKind()] = s
+	}
+	server, client := spans[trace.SpanKindServer], spans[trace.SpanKindClient]
+	if server == nil || client == nil {
+		t.Fatalf("got %d spans, want a server and a client span", len(recorder.Ended()))
+	}
+	if client.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Errorf("client span parent %s, want the server span %s", client.Parent().SpanID(), server.SpanContext().SpanID())
+	}
+	if want := "00-" + traceID + "-" + client.SpanContext().SpanID().String() + "-01"; got != want {
+		t.Errorf("forwarded traceparent %q, want the client span's %q", got, want)
+	}
+}
+
+func TestRoutesFile(t *testing.T) {
+	up := newUpstreams(t, 1)
+	path := filepath.Join(t.TempDir(), "routes.yaml")
+	table := `
+pools:
+  orders:
+    targets: ["` + up.orders[0].URL + `"]
+  users:
+    strategy: weighted
+    targets: ["` + up.users[0].URL + `;weight=2", "` + up.users[1].URL + `"]
+routes:
+  - prefix: /api/orders
+    rewritePrefix: /orders
+    methods: [GET]
+    pool: orders
+  - prefix: /u
+    stripPrefix: true
+    pool: users
+    middleware: [helmet]
+`
+	if err := os.WriteFile(path, []byte(table), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := up.config()
+	cfg.RoutesFile = path
+	app := startGateway(t, cfg)
+
+	if r := send(t, app, "GET", "/api/orders/o1?expand=payment", "", nil); r.status != 200 || r.got.URI != "/orders/o1?expand=payment" {
+		t.Errorf("rewrite: status %d, upstream got %q", r.status, r.got.URI)
+	}
+	if r := send(t, app, "POST", "/api/orders", "", nil); r.status != 405 {
+		t.Errorf("unrouted method: status %d", r.status)
+	}
+	if r := send(t, app, "GET", "/orders/o1", "", nil); r.status != 404 {
+		t.Errorf("route not in file: status %d", r.status)
+	}
+	served := map[string]int{}
+	for range 3 {
+		r := send(t, app, "GET", "/u/users/u1", "", nil)
+		if r.got.URI != "/users/u1" || r.header.Get("X-Frame-Options") == "" {
+			t.Fatalf("strip: upstream got %q, X-Frame-Options %q", r.got.URI, r.header.Get("X-Frame-Options"))
+		}
+		served[r.got.Upstream]++
+	}
+	if served["user-1"] != 2 || served["user-2"] != 1 {
+		t.Errorf("weighted: served %v, want user-1 twice and user-2 once", served)
+	}
+}
@@ -1,48 +1,208 @@
-// Gateway: reverse proxy with round-robin for user-service, single upstream for order-service.
+// Gateway: reverse proxy routing requests by a declarative route table to health-checked,
+// load-balanced upstream pools, authenticating them with JWTs and rate limiting them per client.
+// The table is read from GATEWAY_ROUTES_FILE and reloaded on SIGHUP; without it the routes are
+// built from the service URLs.
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
-	"github.com/gofiber/fiber/v3/middleware/logger"
-	"github.com/gofiber/fiber/v3/middleware/proxy"
 	"github.com/gofiber/fiber/v3/middleware/recover"
 
+	"go_example/cmd/gateway/auth"
 	"go_example/cmd/gateway/config"
+	"go_example/cmd/gateway/ratelimit"
+	"go_example/cmd/gateway/routes"
+	"go_example/cmd/gateway/upstream"
+	"go_example/internal/logging"
 	"go_example/internal/metrics"
+	"go_example/internal/requestid"
+	"go_example/internal/tracing"
 )
 
 func main() {
 	cfg := config.Load()
+	if err := logging.Setup("gateway", cfg.Logging); err != nil {
+		log.Fatalf("gateway: %v", err)
+	}
 
 	metrics.RegisterHTTPMetrics("gateway")
+	metrics.RegisterGatewayMetrics()
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "gateway", cfg.Tracing)
+	if err != nil {
+		logging.Fatal("tracing setup failed", logging.Err(err))
+	}
+	app, router, verifier, err := newApp(context.Background(), cfg)
+	if err != nil {
+		logging.Fatal("setup failed", logging.Err(err))
+	}
+	go reloadOnHangup(router, verifier, cfg.RoutesFile)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var admin *fiber.App
+	if cfg.AdminPort != "" {
+		admin = newAdminApp(router)
+		go func() {
+			slog.Info("admin listening", "port", cfg.AdminPort)
+			if err := admin.Listen(":"+cfg.AdminPort, fiber.ListenConfig{DisableStartupMessage: true}); err != nil {
+				logging.Fatal("admin listen failed", logging.Err(err))
+			}
+		}()
+	}
+	go func() {
+		<-ctx.Done()
+		if admin != nil {
+			admin.ShutdownWithTimeout(shutdownTimeout)
+		}
+		app.ShutdownWithTimeout(shutdownTimeout)
+	}()
 
+	slog.Info("listening", "port", cfg.Port)
+	if err := app.Listen(":"+cfg.Port, fiber.ListenConfig{DisableStartupMessage: true}); err != nil {
+		logging.Fatal("listen failed", logging.Err(err))
+	}
+	flushCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("flush spans failed", logging.Err(err))
+	}
+}
+
+// shutdownTimeout bounds waiting for in-flight requests, then for flushing spans, on SIGTERM.
+const shutdownTimeout = 10 * time.Second
+
+// newApp builds the gateway: its own endpoints and, behind them, the router with the route
+// table of cfg applied. JWKS refreshes and rate limit sweeps run until ctx is canceled.
+func newApp(ctx context.Context, cfg *config.Config) (*fiber.App, *routes.Router, *auth.Verifier, error) {
 	app := fiber.New()
 	app.Use(recover.New())
-	app.Use(logger.New())
+	app.Use(requestid.Middleware())
+	app.Use(tracing.Middleware())
+	app.Use(logging.AccessLog())
 	app.Use(metrics.HTTPMiddleware())
 	app.Get("/metrics", metrics.MetricsHandler())
 	app.Get("/health", func(c fiber.Ctx) error {
 		return c.JSON(fiber.Map{"status": "UP"})
 	})
 
-	if len(cfg.UserServiceURLs) == 0 {
-		log.Fatal("gateway: at least one USER_SERVICE_URLS entry required")
+	var verifier *auth.Verifier
+	if cfg.Auth.Enabled() {
+		v, err := auth.NewVerifier(cfg.Auth)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		verifier = v
+		go verifier.Run(ctx)
+	} else {
+		slog.Warn("JWT_HS256_SECRET, JWT_JWKS_FILE and JWT_JWKS_URL unset: every route is anonymous")
+	}
+	buckets := ratelimit.NewMemoryStore()
+	go buckets.Run(ctx, time.Minute)
+	limiter := ratelimit.NewLimiter(buckets, cfg.APIKeyHeader, cfg.APIKeys)
+	router := routes.NewRouter(cfg.Upstream, verifier, limiter, routes.Middleware())
+	table, err := loadRoutes(cfg)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	app.All("/users", proxy.BalancerForward(cfg.UserServiceURLs))
-	app.All("/users/*", proxy.BalancerForward(cfg.UserServiceURLs))
+	if err := router.Apply(table); err != nil {
+		return nil, nil, nil, fmt.Errorf("routes: %w", err)
+	}
+	app.Use(auth.StripTrustedHeaders())
+	app.Use(router.Handler)
+	return app, router, verifier, nil
+}
 
-	orderSvc := cfg.OrderServiceURL
-	app.All("/orders", func(c fiber.Ctx) error {
-		return proxy.Do(c, orderSvc+c.Path())
-	})
-	app.All("/orders/*", func(c fiber.Ctx) error {
-		return proxy.Do(c, orderSvc+c.Path())
-	})
+// newAdminApp builds the admin endpoints, served on their own port so that they are not
+// reachable through the public one.
+func newAdminApp(router *routes.Router) *fiber.App {
+	app := logging.AdminApp()
+	app.Get("/admin/upstreams", upstream.AdminHandler(router.Pools))
+	return app
+}
 
-	log.Printf("gateway listening on :%s", cfg.Port)
-	if err := app.Listen(":"+cfg.Port, fiber.ListenConfig{DisableStartupMessage: true}); err != nil {
-		log.Fatalf("gateway: %v", err)
+// loadRoutes reads the route table from cfg.RoutesFile or, without one, builds the default
+// table from the service URLs. With auth enabled its routes, except signing up and the /auth
+// endpoints, require a token; users may only reach their own resources. Placing orders, which
+// starts a saga each, is rate limited. Every route gets the configured timeout and retries.
+func loadRoutes(cfg *config.Config) (*routes.File, error) {
+	if cfg.RoutesFile != "" {
+		return routes.Load(cfg.RoutesFile)
+	}
+	var owner, ownerPath, admin *auth.Rule
+	if cfg.Auth.Enabled() {
+		owner = &auth.Rule{OwnerFields: []string{"userId"}}
+		ownerPath = &auth.Rule{OwnerPath: true}
+		admin = &auth.Rule{Scopes: []string{cfg.Auth.AdminScope}}
+	}
+	get := []string{fiber.MethodGet}
+	var orderLimit *ratelimit.Rule
+	if cfg.OrderRateLimit.Requests > 0 {
+		orderLimit = &cfg.OrderRateLimit
+	}
+	notPost := slices.DeleteFunc(slices.Clone(fiber.DefaultMethods), func(m string) bool { return m == fiber.MethodPost })
+	f := &routes.File{
+		Pools: map[string]routes.Pool{
+			"user-service":         {Strategy: cfg.UserServiceBalancer, Targets: cfg.UserServiceURLs},
+			"order-service":        {Strategy: cfg.OrderServiceBalancer, Targets: cfg.OrderServiceURLs},
+			"payment-service":      {Targets: []string{cfg.PaymentServiceURL}},
+			"query-service":        {Targets: []string{cfg.QueryServiceURL}},
+			"notification-service": {Targets: []string{cfg.NotificationServiceURL}},
+		},
+		Routes: []routes.Route{
+			{Prefix: "/auth", Methods: []string{fiber.MethodPost}, Pool: "user-service"},
+			{Prefix: "/users", Methods: []string{fiber.MethodPost}, Pool: "user-service"},
+			{Prefix: "/users", Methods: get, Pool: "user-service", Auth: ownerPath},
+			{Prefix: "/orders", Methods: []string{fiber.MethodPost}, Pool: "order-service", Auth: owner, RateLimit: orderLimit},
+			{Prefix: "/orders", Methods: notPost, Pool: "order-service", Auth: owner},
+			{Prefix: "/webhooks", Pool: "order-service", Auth: admin},
+			{Prefix: "/payments", Methods: get, Pool: "payment-service", Auth: owner},
+			{Prefix: "/views/users", Methods: get, Pool: "query-service", Auth: ownerPath},
+			{Prefix: "/notifications/preferences", Pool: "notification-service", Auth: ownerPath},
+			{Prefix: "/notifications", Pool: "notification-service", Auth: owner},
+		},
+	}
+	for i := range f.Routes {
+		if cfg.RouteTimeout > 0 {
+			f.Routes[i].Timeout = cfg.RouteTimeout.String()
+		}
+		f.Routes[i].Retries = cfg.RouteRetries
+	}
+	return f, nil
+}
+
+// reloadOnHangup re-reads the JWKS file and the route table on SIGHUP. A file that fails to load
+// or validate is logged and the current one kept.
+func reloadOnHangup(router *routes.Router, verifier *auth.Verifier, path string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		if verifier != nil {
+			if err := verifier.LoadJWKS(); err != nil {
+				slog.Error("reload JWKS failed, keeping current keys", logging.Err(err))
+			}
+		}
+		if path == "" {
+			continue
+		}
+		table, err := routes.Load(path)
+		if err == nil {
+			err = router.Apply(table)
+		}
+		if err != nil {
+			slog.Error("reload routes failed, keeping current routes", "file", path, logging.Err(err))
+			continue
+		}
+		slog.Info("reloaded routes", "file", path)
 	}
 }
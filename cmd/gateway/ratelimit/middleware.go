@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"go_example/cmd/gateway/auth"
+	"go_example/internal/metrics"
+	"go_example/internal/logging"
+)
+
+// Keys clients are told apart by.
+const (
+	KeyIP      = "ip"
+	KeyAPIKey  = "apiKey"
+	KeySubject = "subject"
+)
+
+// Rule is the rate limit of a route: Requests per Per (a duration such as 1m), with bursts of
+// up to Burst requests (default Requests). Key picks the client: the JWT subject, the API key
+// header or the IP address; by default the subject when authenticated, else the API key when
+// known, else the IP address. An apiKey client without a known key is limited by IP address.
+type Rule struct {
+	Requests int    `json:"requests" yaml:"requests"`
+	Per      string `json:"per" yaml:"per"`
+	Burst    int    `json:"burst" yaml:"burst"`
+	Key      string `json:"key" yaml:"key"`
+}
+
+// Validate checks the numbers and the key. A subject key needs authenticated routes.
+func (r *Rule) Validate(authenticated bool) error {
+	var errs []error
+	if r.Requests <= 0 {
+		errs = append(errs, errors.New("requests must be positive"))
+	}
+	if d, err := time.ParseDuration(r.Per); err != nil || d <= 0 {
+		errs = append(errs, fmt.Errorf("per %q: want a positive duration such as 1m", r.Per))
+	}
+	if r.Burst < 0 {
+		errs = append(errs, errors.New("burst must not be negative"))
+	}
+	switch r.Key {
+	case "", KeyIP, KeyAPIKey:
+	case KeySubject:
+		if !authenticated {
+			errs = append(errs, errors.New("key subject needs auth on the route"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown key %q", r.Key))
+	}
+	return errors.Join(errs...)
+}
+
+// Limiter enforces rules on the buckets of a store.
+type Limiter struct {
+	store        Store
+	apiKeyHeader string
+	apiKeys      map[string]bool
+}
+
+// NewLimiter creates a limiter keeping buckets in store and reading API keys from
+// apiKeyHeader. Only keys in apiKeys tell clients apart: a client choosing any other value
+// would get a fresh bucket per value.
+func NewLimiter(store Store, apiKeyHeader string, apiKeys []string) *Limiter {
+	known := make(map[string]bool, len(apiKeys))
+	for _, k := range apiKeys {
+		known[hashKey(k)] = true
+	}
+	return &Limiter{store: store, apiKeyHeader: apiKeyHeader, apiKeys: known}
+}
+
+// Middleware returns a handler enforcing rule, which must be valid, on the requests of route.
+// Buckets are kept per route and client, so routes named alike keep their counts across
+// reloads. Every response carries RateLimit-Limit, -Remaining, -Reset and -Policy; rejected
+// requests get 429 with Retry-After. Requests pass when the store fails.
+func (l *Limiter) Middleware(route string, rule Rule) fiber.Handler {
+	per, _ := time.ParseDuration(rule.Per)
+	burst := rule.Burst
+	if burst == 0 {
+		burst = rule.Requests
+	}
+	limit := Limit{Rate: float64(rule.Requests) / per.Seconds(), Burst: burst}
+	policy := fmt.Sprintf("%d;w=%d", rule.Requests, int64(math.Ceil(per.Seconds())))
+	if burst != rule.Requests {
+		policy += fmt.Sprintf(";burst=%d", burst)
+	}
+	return func(c fiber.Ctx) error {
+		res, err := l.store.Take(c.Context(), route+"|"+l.client(c, rule.Key), limit)
+		if err != nil {
+			metrics.ObserveRateLimitStoreError()
+			slog.ErrorContext(c.Context(), "rate limit store failed", "route", route, logging.Err(err))
+			return c.Next()
+		}
+		c.Set("RateLimit-Limit", strconv.Itoa(burst))
+		c.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(res.Reset), 10))
+		c.Set("RateLimit-Policy", policy)
+		if !res.Allowed {
+			metrics.ObserveRateLimited(route)
+			c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(max(ceilSeconds(res.RetryAfter), 1), 10))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "rate limit exceeded"})
+		}
+		return c.Next()
+	}
+}
+
+// client returns the bucket key of the client of c. The auth middleware sets the user ID
+// header only for verified tokens, incoming copies being stripped. API keys count only when
+// known, and are hashed so that stores do not hold them.
+func (l *Limiter) client(c fiber.Ctx, key string) string {
+	if key == "" || key == KeySubject {
+		if sub := c.Get(auth.HeaderUserID); sub != "" {
+			return "sub:" + sub
+		}
+	}
+	if key == "" || key == KeyAPIKey {
+		if k := c.Get(l.apiKeyHeader); k != "" {
+			if h := hashKey(k); l.apiKeys[h] {
+				return "key:" + h
+			}
+		}
+	}
+	return "ip:" + c.IP()
+}
+
+// hashKey returns the truncated SHA-256 of an API key, in hex.
+func hashKey(k string) string {
+	sum := sha256.Sum256([]byte(k))
+	return hex.EncodeToString(sum[:16])
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// clock is a settable time source for MemoryStore.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestStore() (*MemoryStore, *clock) {
+	c := &clock{t: time.Unix(1_000_000, 0)}
+	s := NewMemoryStore()
+	s.now = c.now
+	return s, c
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	limit := Limit{Rate: 2, Burst: 3} // a token every 500ms
+	type step struct {
+		after time.Duration // clock advance before the take
+		want  Result
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "burst then deny",
+			steps: []step{
+				{want: Result{Allowed: true, Remaining: 2, Reset: 500 * time.Millisecond}},
+				{want: Result{Allowed: true, Remaining: 1, Reset: time.Second}},
+				{want: Result{Allowed: true, Remaining: 0, Reset: 1500 * time.Millisecond}},
+				{want: Result{Remaining: 0, Reset: 1500 * time.Millisecond, RetryAfter: 500 * time.Millisecond}},
+			},
+		},
+		{
+			name: "partial refill",
+			steps: []step{
+				{want: Result{Allowed: true, Remaining: 2, Reset: 500 * time.Millisecond}},
+				{want: Result{Allowed: true, Remaining: 1, Reset: time.Second}},
+				{want: Result{Allowed: true, Remaining: 0, Reset: 1500 * time.Millisecond}},
+				{after: 250 * time.Millisecond, want: Result{Remaining: 0, Reset: 1250 * time.Millisecond, RetryAfter: 250 * time.Millisecond}},
+				{after: 250 * time.Millisecond, want: Result{Allowed: true, Remaining: 0, Reset: 1500 * time.Millisecond}},
+			},
+		},
+		{
+			name: "refill caps at burst",
+			steps: []step{
+				{want: Result{Allowed: true, Remaining: 2, Reset: 500 * time.Millisecond}},
+				{after: time.Hour, want: Result{Allowed: true, Remaining: 2, Reset: 500 * time.Millisecond}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, c := newTestStore()
+			for i, st := range tt.steps {
+				c.advance(st.after)
+				got, err := s.Take(context.Background(), "k", limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got != st.want {
+					t.Fatalf("take %d: got %+v, want %+v", i, got, st.want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryStoreKeysAreIndependent(t *testing.T) {
+	s, _ := newTestStore()
+	limit := Limit{Rate: 1, Burst: 1}
+	ctx := context.Background()
+	if r, _ := s.Take(ctx, "a", limit); !r.Allowed {
+		t.Fatal("first take of a denied")
+	}
+	if r, _ := s.Take(ctx, "a", limit); r.Allowed {
+		t.Fatal("second take of a allowed")
+	}
+	if r, _ := s.Take(ctx, "b", limit); !r.Allowed {
+		t.Error("b limited by a's bucket")
+	}
+}
+
+func TestMemoryStoreLimitChange(t *testing.T) {
+	s, c := newTestStore()
+	ctx := context.Background()
+	s.Take(ctx, "k", Limit{Rate: 1, Burst: 10})
+	// A smaller burst caps the tokens left: 9 → 2, minus this request.
+	r, _ := s.Take(ctx, "k", Limit{Rate: 1, Burst: 2})
+	if !r.Allowed || r.Remaining != 1 {
+		t.Errorf("after shrinking: %+v, want allowed with 1 remaining", r)
+	}
+	c.advance(time.Hour)
+	r, _ = s.Take(ctx, "k", Limit{Rate: 1, Burst: 5})
+	if !r.Allowed || r.Remaining != 4 {
+		t.Errorf("after growing: %+v, want allowed with 4 remaining", r)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	s, c := newTestStore()
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 2}
+	s.Take(ctx, "idle", limit)
+	c.advance(500 * time.Millisecond)
+	s.Take(ctx, "busy", limit)
+	s.Take(ctx, "busy", limit)
+
+	c.advance(600 * time.Millisecond)
+	s.sweep()
+	if _, ok := s.buckets["idle"]; ok {
+		t.Error("full bucket kept")
+	}
+	if _, ok := s.buckets["busy"]; !ok {
+		t.Error("partly empty bucket dropped")
+	}
+	// A dropped bucket comes back full.
+	if r, _ := s.Take(ctx, "idle", limit); !r.Allowed || r.Remaining != 1 {
+		t.Errorf("after sweep: %+v, want allowed with 1 remaining", r)
+	}
+}
@@ -0,0 +1,187 @@
+// Package routes builds the gateway's routes from a declarative table of upstream pools and
+// routes, read from a YAML or JSON file, and swaps the table at runtime on reload.
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gopkg.in/yaml.v3"
+
+	"go_example/cmd/gateway/auth"
+	"go_example/cmd/gateway/ratelimit"
+	"go_example/cmd/gateway/upstream"
+)
+
+// File is a route table: named pools of upstreams and the routes forwarded to them.
+type File struct {
+	Pools  map[string]Pool `json:"pools" yaml:"pools"`
+	Routes []Route         `json:"routes" yaml:"routes"`
+}
+
+// Pool is a set of upstreams balanced by Strategy (default round-robin). Targets take a
+// weight as "http://user-service-1:8081;weight=3".
+type Pool struct {
+	Strategy string   `json:"strategy" yaml:"strategy"`
+	Targets  []string `json:"targets" yaml:"targets"`
+}
+
+// Route forwards requests under Prefix with one of Methods (any method when empty) to Pool.
+// StripPrefix removes Prefix from the forwarded path; RewritePrefix replaces it. Timeout
+// bounds the upstream response, retries included (none when empty). Idempotent requests are
+// retried up to Retries times, waiting RetryBackoff (default 100ms) before the first retry. Middleware runs in order before forwarding,
+// then Auth, without which the route is anonymous, then RateLimit.
+type Route struct {
+	Prefix        string          `json:"prefix" yaml:"prefix"`
+	Methods       []string        `json:"methods" yaml:"methods"`
+	Pool          string          `json:"pool" yaml:"pool"`
+	StripPrefix   bool            `json:"stripPrefix" yaml:"stripPrefix"`
+	RewritePrefix string          `json:"rewritePrefix" yaml:"rewritePrefix"`
+	Timeout       string          `json:"timeout" yaml:"timeout"`
+	Retries       int             `json:"retries" yaml:"retries"`
+	RetryBackoff  string          `json:"retryBackoff" yaml:"retryBackoff"`
+	Middleware    []string        `json:"middleware" yaml:"middleware"`
+	Auth          *auth.Rule      `json:"auth" yaml:"auth"`
+	RateLimit     *ratelimit.Rule `json:"rateLimit" yaml:"rateLimit"`
+}
+
+// maxRetries bounds Route.Retries, so that a failing upstream is not sent many times the load.
+const maxRetries = 5
+
+// Load reads a route table from path, as JSON for a .json file and YAML otherwise. Unknown
+// fields are rejected.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&f)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(&f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Validate checks the table against the middleware that can be named by routes and whether
+// tokens can be verified, and returns every problem found.
+func (f *File) Validate(middleware map[string]fiber.Handler, canAuth bool) error {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(f.Pools)) {
+		p := f.Pools[name]
+		if len(p.Targets) == 0 {
+			errs = append(errs, fmt.Errorf("pool %s: no targets", name))
+		}
+		for _, t := range p.Targets {
+			if _, err := upstream.ParseTarget(t); err != nil {
+				errs = append(errs, fmt.Errorf("pool %s: %w", name, err))
+			}
+		}
+		switch p.Strategy {
+		case "", upstream.RoundRobin, upstream.Weighted, upstream.LeastConnections:
+		default:
+			errs = append(errs, fmt.Errorf("pool %s: unknown strategy %q", name, p.Strategy))
+		}
+	}
+	if len(f.Routes) == 0 {
+		errs = append(errs, errors.New("no routes"))
+	}
+	// A prefix may be split over several routes by method, but no method may be routed twice.
+	claimed := make(map[string]map[string]int)
+	for i, r := range f.Routes {
+		where := fmt.Sprintf("route %d (%s)", i+1, r.Prefix)
+		if err := validPrefix(r.Prefix); err != nil {
+			errs = append(errs, fmt.Errorf("%s: prefix: %w", where, err))
+		}
+		if r.RewritePrefix != "" {
+			if err := validPrefix(r.RewritePrefix); err != nil {
+				errs = append(errs, fmt.Errorf("%s: rewritePrefix: %w", where, err))
+			}
+		}
+		if _, ok := f.Pools[r.Pool]; !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown pool %q", where, r.Pool))
+		}
+		if r.Timeout != "" {
+			if d, err := time.ParseDuration(r.Timeout); err != nil || d <= 0 {
+				errs = append(errs, fmt.Errorf("%s: timeout %q: want a positive duration such as 10s", where, r.Timeout))
+			}
+		}
+		if r.Retries < 0 || r.Retries > maxRetries {
+			errs = append(errs, fmt.Errorf("%s: retries %d: want 0 to %d", where, r.Retries, maxRetries))
+		}
+		if r.RetryBackoff != "" {
+			if d, err := time.ParseDuration(r.RetryBackoff); err != nil || d <= 0 {
+				errs = append(errs, fmt.Errorf("%s: retryBackoff %q: want a positive duration such as 100ms", where, r.RetryBackoff))
+			}
+		}
+		for _, m := range r.Middleware {
+			if _, ok := middleware[m]; !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown middleware %q", where, m))
+			}
+		}
+		if r.Auth != nil {
+			if !canAuth {
+				errs = append(errs, fmt.Errorf("%s: auth needs JWT_HS256_SECRET, JWT_JWKS_FILE or JWT_JWKS_URL", where))
+			} else if err := r.Auth.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: auth: %w", where, err))
+			}
+		}
+		if r.RateLimit != nil {
+			if err := r.RateLimit.Validate(r.Auth != nil); err != nil {
+				errs = append(errs, fmt.Errorf("%s: rateLimit: %w", where, err))
+			}
+		}
+		methods := r.Methods
+		if len(methods) == 0 {
+			methods = []string{"*"}
+		}
+		if claimed[r.Prefix] == nil {
+			claimed[r.Prefix] = make(map[string]int)
+		}
+		byMethod := claimed[r.Prefix]
+		for _, m := range methods {
+			if m != "*" && !slices.Contains(fiber.DefaultMethods, m) {
+				errs = append(errs, fmt.Errorf("%s: unknown method %q", where, m))
+				continue
+			}
+			for other, j := range byMethod {
+				if other == m || other == "*" || m == "*" {
+					errs = append(errs, fmt.Errorf("%s: %s already routed by route %d", where, r.Prefix, j))
+					break
+				}
+			}
+			byMethod[m] = i + 1
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validPrefix accepts "/" and static paths such as "/users" without a trailing slash.
+func validPrefix(p string) error {
+	switch {
+	case !strings.HasPrefix(p, "/"):
+		return fmt.Errorf("%q must start with /", p)
+	case p != "/" && strings.HasSuffix(p, "/"):
+		return fmt.Errorf("%q must not end with /", p)
+	case strings.ContainsAny(p, ":*+?#"):
+		return fmt.Errorf("%q must be a static path", p)
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package routes
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/compress"
+	"github.com/gofiber/fiber/v3/middleware/cors"
+	"github.com/gofiber/fiber/v3/middleware/etag"
+	"github.com/gofiber/fiber/v3/middleware/helmet"
+	"github.com/valyala/fasthttp"
+
+	"go_example/cmd/gateway/auth"
+	"go_example/cmd/gateway/ratelimit"
+	"go_example/cmd/gateway/upstream"
+	"go_example/internal/metrics"
+)
+
+// Middleware returns the built-in middleware routes can name: compress, cors, etag and helmet.
+func Middleware() map[string]fiber.Handler {
+	return map[string]fiber.Handler{
+		"compress": compress.New(),
+		"cors":     cors.New(),
+		"etag":     etag.New(),
+		"helmet":   helmet.New(),
+	}
+}
+
+// defaultRetryBackoff is the wait before the first retry of routes without retryBackoff.
+const defaultRetryBackoff = 100 * time.Millisecond
+
+// Router serves requests by the current route table. Each table is built into its own Fiber
+// app; Apply swaps in a new one while requests in flight finish on the one they started on, so
+// the listener and its connections are never touched.
+type Router struct {
+	cfg        upstream.Config
+	verifier   *auth.Verifier
+	limiter    *ratelimit.Limiter
+	middleware map[string]fiber.Handler
+
+	mu      sync.Mutex // serializes Apply
+	current atomic.Pointer[table]
+}
+
+type table struct {
+	handler fasthttp.RequestHandler
+	pools   map[string]*pool
+}
+
+type pool struct {
+	*upstream.Pool
+	key     string
+	targets []upstream.Target
+	stop    context.CancelFunc
+}
+
+// NewRouter creates a router without routes. Pools get the health check and ejection settings
+// of cfg; routes may name the given middleware. Without a verifier no route may require auth.
+// Rate limits are enforced by limiter.
+func NewRouter(cfg upstream.Config, verifier *auth.Verifier, limiter *ratelimit.Limiter, middleware map[string]fiber.Handler) *Router {
+	return &Router{cfg: cfg, verifier: verifier, limiter: limiter, middleware: middleware}
+}
+
+// Apply validates f and makes it the route table. Pools whose strategy and targets are
+// unchanged are kept with their health and ejection state; the health checks of pools that
+// are replaced or removed stop. On error the current table stays in place.
+func (r *Router) Apply(f *File) error {
+	if err := f.Validate(r.middleware, r.verifier != nil); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := r.current.Load()
+
+	pools := make(map[string]*pool, len(f.Pools))
+	var started []*pool
+	for name, def := range f.Pools {
+		key := def.Strategy + " " + strings.Join(def.Targets, ",")
+		if old != nil {
+			if p, ok := old.pools[name]; ok && p.key == key {
+				pools[name] = p
+				continue
+			}
+		}
+		targets := make([]upstream.Target, len(def.Targets))
+		for i, s := range def.Targets {
+			targets[i], _ = upstream.ParseTarget(s) // checked by Validate
+		}
+		up, err := upstream.NewPool(name, targets, def.Strategy, r.cfg)
+		if err != nil {
+			return err
+		}
+		p := &pool{Pool: up, key: key, targets: targets}
+		pools[name] = p
+		started = append(started, p)
+	}
+
+	app := fiber.New()
+	app.Use(restoreContext)
+	// Fiber matches in registration order, so longer prefixes go first.
+	routes := slices.SortedStableFunc(slices.Values(f.Routes), func(a, b Route) int {
+		return cmp.Compare(len(b.Prefix), len(a.Prefix))
+	})
+	for _, rt := range routes {
+		opts := upstream.ForwardOptions{Retries: rt.Retries, Backoff: defaultRetryBackoff}
+		if rt.Timeout != "" {
+			opts.Timeout, _ = time.ParseDuration(rt.Timeout)
+		}
+		if rt.RetryBackoff != "" {
+			opts.Backoff, _ = time.ParseDuration(rt.RetryBackoff)
+		}
+		if rt.StripPrefix || rt.RewritePrefix != "" {
+			opts.Rewrite = rewrite(rt.Prefix, rt.RewritePrefix)
+		}
+		handlers := make([]any, 0, len(rt.Middleware)+3)
+		for _, m := range rt.Middleware {
+			handlers = append(handlers, r.middleware[m])
+		}
+		if rt.Auth != nil {
+			handlers = append(handlers, r.verifier.Middleware(rt.Prefix, *rt.Auth))
+		}
+		if rt.RateLimit != nil {
+			handlers = append(handlers, r.limiter.Middleware(routeName(rt), *rt.RateLimit))
+		}
+		handlers = append(handlers, upstream.Forward(pools[rt.Pool].Pool, opts))
+		for _, path := range []string{rt.Prefix, strings.TrimSuffix(rt.Prefix, "/") + "/*"} {
+			if len(rt.Methods) == 0 {
+				app.All(path, handlers[0], handlers[1:]...)
+			} else {
+				app.Add(rt.Methods, path, handlers[0], handlers[1:]...)
+			}
+		}
+	}
+
+	for _, p := range started {
+		ctx, stop := context.WithCancel(context.Background())
+		p.stop = stop
+		go p.Run(ctx)
+	}
+	r.current.Store(&table{handler: app.Handler(), pools: pools})
+
+	if old != nil {
+		for name, p := range old.pools {
+			if pools[name] == p {
+				continue
+			}
+			p.stop()
+			if _, ok := pools[name]; !ok {
+				metrics.ForgetPool(name)
+			}
+			for _, t := range p.targets {
+				if np, ok := pools[name]; !ok || !slices.Contains(np.targets, t) {
+					metrics.ForgetUpstream(name, t.URL)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Close stops the health checks of the current table's pools.
+func (r *Router) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t := r.current.Load(); t != nil {
+		for _, p := range t.pools {
+			p.stop()
+		}
+	}
+}
+
+// Handler serves the request by the current route table.
+func (r *Router) Handler(c fiber.Ctx) error {
+	t := r.current.Load()
+	if t == nil {
+		return fiber.ErrNotFound
+	}
+	// The table app starts the request over with an empty context; the one built in front,
+	// with the request ID and the server span, is handed over in a local.
+	ctx := c.Context()
+	c.Locals(contextKey{}, ctx)
+	t.handler(c.RequestCtx())
+	c.SetContext(ctx)
+	return nil
+}
+
+type contextKey struct{}
+
+// restoreContext puts the context handed over by Handler back on the table app's request.
+func restoreContext(c fiber.Ctx) error {
+	if ctx, ok := c.Locals(contextKey{}).(context.Context); ok {
+		c.SetContext(ctx)
+	}
+	return c.Next()
+}
+
+// Pools returns the pools of the current route table, by name.
+func (r *Router) Pools() []*upstream.Pool {
+	t := r.current.Load()
+	if t == nil {
+		return nil
+	}
+	out := make([]*upstream.Pool, 0, len(t.pools))
+	for _, name := range slices.Sorted(maps.Keys(t.pools)) {
+		out = append(out, t.pools[name].Pool)
+	}
+	return out
+}
+
+// routeName names rt by its methods and prefix, as "POST /orders" or "* /users".
+func routeName(rt Route) string {
+	methods := "*"
+	if len(rt.Methods) > 0 {
+		methods = strings.Join(rt.Methods, ",")
+	}
+	return methods + " " + rt.Prefix
+}
+
+// rewrite returns a function replacing prefix at the start of a request URI with to, which is
+// empty to strip it.
+func rewrite(prefix, to string) func(string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	to = strings.TrimSuffix(to, "/")
+	return func(uri string) string {
+		// Routing ignores case, so the URI may spell the prefix differently.
+		if len(uri) < len(prefix) || !strings.EqualFold(uri[:len(prefix)], prefix) {
+			return uri
+		}
+		out := to + uri[len(prefix):]
+		if !strings.HasPrefix(out, "/") {
+			out = "/" + out
+		}
+		return out
+	}
+}
@@ -0,0 +1,108 @@
+package upstream
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"go_example/internal/metrics"
+)
+
+// Circuit breaker states.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// ErrBreakerOpen is returned by Pick while the circuit breaker of every available upstream of
+// the pool is open.
+var ErrBreakerOpen = errors.New("circuit breaker open")
+
+// breaker fails requests to one upstream fast once BreakerFailures requests to it in a row
+// failed, so the pool sends them to its other upstreams. It stays open for
+// BreakerOpenDuration, then lets BreakerHalfOpenRequests requests through: the first to
+// succeed closes it, the first to fail opens it again. Guarded by the pool's mutex.
+type breaker struct {
+	state     string
+	failures  int
+	openUntil time.Time
+	probes    int
+}
+
+// admits reports whether u's breaker lets a request through, moving an open breaker whose
+// time is up to half-open. p.mu must be held.
+func (p *Pool) admits(u *Upstream, now time.Time) bool {
+	b := &u.breaker
+	if p.cfg.BreakerFailures < 1 {
+		return true
+	}
+	switch b.state {
+	case BreakerOpen:
+		if now.Before(b.openUntil) {
+			return false
+		}
+		p.setBreaker(u, BreakerHalfOpen)
+		b.probes = 0
+		fallthrough
+	case BreakerHalfOpen:
+		return b.probes < max(p.cfg.BreakerHalfOpenRequests, 1)
+	}
+	return true
+}
+
+// sent counts a request picked for u as a probe of a half-open breaker.
+func (p *Pool) sent(u *Upstream) {
+	if u.breaker.state == BreakerHalfOpen {
+		u.breaker.probes++
+	}
+}
+
+// record counts the outcome of a request in u's breaker.
+func (p *Pool) record(u *Upstream, failed bool, now time.Time) {
+	b := &u.breaker
+	if p.cfg.BreakerFailures < 1 {
+		return
+	}
+	if !failed {
+		b.failures = 0
+		if b.state == BreakerHalfOpen {
+			p.setBreaker(u, BreakerClosed)
+		}
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= p.cfg.BreakerFailures) {
+		b.openUntil = now.Add(p.cfg.BreakerOpenDuration)
+		b.failures = 0
+		p.setBreaker(u, BreakerOpen)
+	}
+}
+
+func (p *Pool) setBreaker(u *Upstream, state string) {
+	u.breaker.state = state
+	slog.Warn("circuit breaker changed state", "pool", p.name, "upstream", u.target.URL, "state", state)
+	metrics.SetBreakerState(p.name, u.target.URL, state)
+	metrics.ObserveBreakerTransition(p.name, u.target.URL, state)
+}
+
+// RetryAfter returns how long until the first open breaker of the pool's available upstreams
+// lets a request through, zero when one is not open.
+func (p *Pool) RetryAfter() time.Duration {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var wait time.Duration
+	for _, u := range p.upstreams {
+		if !p.available(u, now) {
+			continue
+		}
+		if u.breaker.state != BreakerOpen {
+			return 0
+		}
+		if d := u.breaker.openUntil.Sub(now); wait == 0 || d < wait {
+			wait = d
+		}
+	}
+	return max(wait, 0)
+}
@@ -0,0 +1,199 @@
+package upstream
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/proxy"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"go_example/internal/metrics"
+	"go_example/internal/tracing"
+)
+
+// HeaderIdempotencyKey marks a POST or PATCH request as safe to repeat.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// ForwardOptions adjust how Forward proxies a request.
+type ForwardOptions struct {
+	// Timeout bounds the wait for the upstream response, retries included. Zero waits
+	// indefinitely.
+	Timeout time.Duration
+	// Rewrite maps the request URI (path and query) to the one sent upstream. Nil keeps it.
+	Rewrite func(uri string) string
+	// Retries is how many times an idempotent request is sent again after no response or a
+	// 502, 503 or 504. Backoff is the wait before the first retry, doubling for each next one,
+	// with jitter.
+	Retries int
+	Backoff time.Duration
+}
+
+// Forward returns a handler that proxies the request, with its full URI, to an upstream picked
+// from p, and retries it on another pick as opts allow. Each attempt is a client span whose
+// trace context the upstream receives. A response of 500 or above, or none at all, counts as a
+// failure of the upstream. Without an available upstream it responds 503, with
+// a problem body while the circuit breakers are open, and 504 when the upstream times out.
+func Forward(p *Pool, opts ForwardOptions) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		u, err := p.Pick()
+		if err != nil {
+			return unavailable(c, p, err)
+		}
+		uri := c.OriginalURL()
+		if opts.Rewrite != nil {
+			uri = opts.Rewrite(uri)
+		}
+		c.Request().Header.Add("X-Real-IP", c.IP())
+		// The upstream response replaces the one built so far, so headers set by middleware
+		// before forwarding (CORS, security headers) are put back on top of it.
+		var set fasthttp.ResponseHeader
+		c.Response().Header.CopyTo(&set)
+		var deadline time.Time
+		if opts.Timeout > 0 {
+			deadline = time.Now().Add(opts.Timeout)
+		}
+		retries := 0
+		if idempotent(c) {
+			retries = opts.Retries
+		}
+		for attempt := 0; ; attempt++ {
+			span := startAttempt(c, p, u.URL()+uri, attempt)
+			if deadline.IsZero() {
+				err = proxy.Do(c, u.URL()+uri)
+			} else {
+				err = proxy.DoDeadline(c, u.URL()+uri, deadline)
+			}
+			status := c.Response().StatusCode()
+			endAttempt(span, status, err)
+			p.Done(u, err != nil || status >= fiber.StatusInternalServerError)
+			if attempt == retries || !retryable(err, status) {
+				break
+			}
+			wait := backoff(opts.Backoff, attempt)
+			if !deadline.IsZero() && time.Now().Add(wait).After(deadline) {
+				break
+			}
+			time.Sleep(wait)
+			// Without another upstream to try, the last outcome stands.
+			next, perr := p.Pick()
+			if perr != nil {
+				break
+			}
+			u = next
+			metrics.ObserveUpstreamRetry(p.name)
+		}
+		if err == nil {
+			for k, v := range set.All() {
+				switch string(k) {
+				case fiber.HeaderContentType, fiber.HeaderContentLength, fiber.HeaderServer, fiber.HeaderDate:
+				default:
+					c.Response().Header.SetBytesKV(k, v)
+				}
+			}
+		}
+		if errors.Is(err, fasthttp.ErrTimeout) {
+			return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{"error": "upstream timed out", "upstream": p.name})
+		}
+		return err
+	}
+}
+
+// startAttempt starts the client span of an attempt to send the request to url and passes its
+// trace context on in the request headers.
+func startAttempt(c fiber.Ctx, p *Pool, url string, attempt int) trace.Span {
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(c.Method()),
+		semconv.URLFull(url),
+		attribute.String("gateway.pool", p.name),
+	}
+	if attempt > 0 {
+		attrs = append(attrs, semconv.HTTPRequestResendCount(attempt))
+	}
+	ctx, span := tracing.Tracer().Start(c.Context(), c.Method(), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	tracing.Inject(ctx, &c.Request().Header)
+	return span
+}
+
+// endAttempt ends the span of an attempt, failed without a response or with a 5xx.
+func endAttempt(span trace.Span, status int, err error) {
+	switch {
+	case err != nil:
+		tracing.Fail(span, err)
+	case status >= fiber.StatusInternalServerError:
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		span.SetStatus(codes.Error, "")
+	default:
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+	}
+	span.End()
+}
+
+// unavailable responds 503 to a request Pick found no upstream for. Open circuit breakers
+// get a problem body (RFC 9457) and Retry-After.
+func unavailable(c fiber.Ctx, p *Pool, err error) error {
+	if !errors.Is(err, ErrBreakerOpen) {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": err.Error(), "upstream": p.name})
+	}
+	c.Set(fiber.HeaderRetryAfter, strconv.FormatInt(max(int64(math.Ceil(p.RetryAfter().Seconds())), 1), 10))
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"type":     "about:blank",
+		"title":    "Service Unavailable",
+		"status":   fiber.StatusServiceUnavailable,
+		"detail":   p.name + " is failing; the circuit breakers of its upstreams are open",
+		"instance": c.Path(),
+		"upstream": p.name,
+	}, "application/problem+json")
+}
+
+// idempotent reports whether the request may be sent more than once: its method is idempotent,
+// or it carries an idempotency key.
+func idempotent(c fiber.Ctx) bool {
+	switch c.Method() {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodTrace, fiber.MethodPut, fiber.MethodDelete:
+		return true
+	}
+	return c.Get(HeaderIdempotencyKey) != ""
+}
+
+// retryable reports whether an attempt failed in a way another attempt may not: no response
+// for another reason than the deadline passing, or a bad gateway, unavailable or timeout status.
+func retryable(err error, status int) bool {
+	if err != nil {
+		return !errors.Is(err, fasthttp.ErrTimeout)
+	}
+	switch status {
+	case fiber.StatusBadGateway, fiber.StatusServiceUnavailable, fiber.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the wait before retry attempt+1: base doubled per attempt, the upper half of
+// it picked at random.
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base << min(attempt, 16)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// AdminHandler returns a handler listing the state of the pools in use. GET /admin/upstreams
+func AdminHandler(pools func() []*Pool) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		pools := pools()
+		states := make([]PoolState, len(pools))
+		for i, p := range pools {
+			states[i] = p.State()
+		}
+		return c.JSON(fiber.Map{"pools": states})
+	}
+}
@@ -0,0 +1,89 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"go_example/internal/logging"
+	"go_example/internal/metrics"
+)
+
+// Run checks the health of every upstream each HealthInterval until ctx is canceled. It does
+// nothing without a HealthPath.
+func (p *Pool) Run(ctx context.Context) {
+	if p.cfg.HealthPath == "" || p.cfg.HealthInterval <= 0 {
+		return
+	}
+	client := &http.Client{Timeout: p.cfg.HealthTimeout}
+	tick := time.NewTicker(p.cfg.HealthInterval)
+	defer tick.Stop()
+	for {
+		var wg sync.WaitGroup
+		for _, u := range p.upstreams {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := check(ctx, client, u.target.URL+p.cfg.HealthPath)
+				if ctx.Err() == nil {
+					p.recordCheck(u, err)
+				}
+			}()
+		}
+		wg.Wait()
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// check GETs url and reports an error unless it answers 2xx.
+func check(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// recordCheck applies the outcome of a health check to u, marking it unhealthy or healthy once
+// enough checks in a row agree.
+func (p *Pool) recordCheck(u *Upstream, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	u.lastCheck = time.Now()
+	if err != nil {
+		u.lastError = err.Error()
+		u.checkPasses = 0
+		u.checkFails++
+		if u.healthy && u.checkFails >= max(p.cfg.UnhealthyThreshold, 1) {
+			u.healthy = false
+			slog.Warn("upstream unhealthy", "pool", p.name, "upstream", u.target.URL, logging.Err(err))
+			metrics.SetUpstreamAvailable(p.name, u.target.URL, false)
+		}
+		return
+	}
+	u.lastError = ""
+	u.checkFails = 0
+	u.checkPasses++
+	if !u.healthy && u.checkPasses >= max(p.cfg.HealthyThreshold, 1) {
+		u.healthy = true
+		slog.Info("upstream healthy again", "pool", p.name, "upstream", u.target.URL)
+		metrics.SetUpstreamAvailable(p.name, u.target.URL, u.ejectedUntil.IsZero())
+	}
+}
@@ -0,0 +1,330 @@
+// Package upstream balances gateway requests over the instances of a service. Instances are
+// health-checked actively and ejected passively after consecutive failed requests.
+package upstream
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go_example/internal/metrics"
+)
+
+// Balancing strategies.
+const (
+	RoundRobin       = "round-robin"
+	Weighted         = "weighted"
+	LeastConnections = "least-connections"
+)
+
+// ErrNoUpstream is returned by Pick when every upstream is unhealthy or ejected.
+var ErrNoUpstream = errors.New("no healthy upstream")
+
+// Config holds health check and outlier ejection settings. An upstream is marked unhealthy
+// after UnhealthyThreshold failed checks of HealthPath in a row and healthy again after
+// HealthyThreshold passed ones. After EjectAfter failed requests in a row (5xx or no response)
+// it is ejected for EjectDuration, doubling per consecutive ejection up to MaxEjectDuration.
+// The Breaker settings configure the circuit breaker each upstream has; zero BreakerFailures
+// disables them.
+type Config struct {
+	HealthPath         string
+	HealthInterval     time.Duration
+	HealthTimeout      time.Duration
+	HealthyThreshold   int
+	UnhealthyThreshold int
+	EjectAfter         int
+	EjectDuration      time.Duration
+	MaxEjectDuration   time.Duration
+
+	BreakerFailures         int
+	BreakerOpenDuration     time.Duration
+	BreakerHalfOpenRequests int
+}
+
+// Target is an upstream URL with its weight.
+type Target struct {
+	URL    string
+	Weight int
+}
+
+// ParseTarget parses an upstream such as "http://user-service-1:8081" or, with a weight for
+// the weighted and least-connections strategies, "http://user-service-1:8081;weight=3". The
+// weight defaults to 1 and the scheme to http.
+func ParseTarget(s string) (Target, error) {
+	raw, params, _ := strings.Cut(strings.TrimSpace(s), ";")
+	t := Target{URL: strings.TrimRight(raw, "/"), Weight: 1}
+	if t.URL == "" {
+		return t, errors.New("empty upstream URL")
+	}
+	if !strings.HasPrefix(t.URL, "http://") && !strings.HasPrefix(t.URL, "https://") {
+		t.URL = "http://" + t.URL
+	}
+	if params != "" {
+		key, value, _ := strings.Cut(params, "=")
+		w, err := strconv.Atoi(value)
+		if strings.TrimSpace(key) != "weight" || err != nil || w < 1 {
+			return t, fmt.Errorf("upstream %q: want ;weight=<positive integer>", s)
+		}
+		t.Weight = w
+	}
+	return t, nil
+}
+
+// Upstream is one instance of a pool and its health.
+type Upstream struct {
+	target Target
+
+	// Guarded by the pool's mutex.
+	healthy       bool
+	checkFails    int
+	checkPasses   int
+	lastCheck     time.Time
+	lastError     string
+	failures      int
+	ejections     int
+	ejectedUntil  time.Time
+	active        int
+	currentWeight int
+	requests      int64
+	failed        int64
+	breaker       breaker
+}
+
+// URL returns the base URL requests are forwarded to.
+func (u *Upstream) URL() string {
+	return u.target.URL
+}
+
+// Pool is a named set of upstreams and the strategy to pick one for a request.
+type Pool struct {
+	name      string
+	strategy  string
+	cfg       Config
+	upstreams []*Upstream
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewPool creates a pool of targets. Every upstream starts healthy.
+func NewPool(name string, targets []Target, strategy string, cfg Config) (*Pool, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("pool %s: no upstreams", name)
+	}
+	switch strategy {
+	case "":
+		strategy = RoundRobin
+	case RoundRobin, Weighted, LeastConnections:
+	default:
+		return nil, fmt.Errorf("pool %s: unknown strategy %q", name, strategy)
+	}
+	p := &Pool{name: name, strategy: strategy, cfg: cfg}
+	for _, t := range targets {
+		p.upstreams = append(p.upstreams, &Upstream{target: t, healthy: true, breaker: breaker{state: BreakerClosed}})
+		metrics.SetUpstreamAvailable(name, t.URL, true)
+		metrics.SetBreakerState(name, t.URL, BreakerClosed)
+	}
+	return p, nil
+}
+
+// Name returns the pool name.
+func (p *Pool) Name() string {
+	return p.name
+}
+
+// Pick chooses an available upstream by the pool's strategy and counts a request in flight to
+// it; the caller must report its outcome with Done. Upstreams whose circuit breaker is open are
+// skipped; it fails with ErrBreakerOpen when that leaves none.
+func (p *Pool) Pick() (*Upstream, error) {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var u *Upstream
+	switch p.strategy {
+	case Weighted:
+		u = p.pickWeighted(now)
+	case LeastConnections:
+		u = p.pickLeastConnections(now)
+	default:
+		u = p.pickRoundRobin(now)
+	}
+	if u == nil {
+		for _, o := range p.upstreams {
+			if p.available(o, now) {
+				return nil, ErrBreakerOpen
+			}
+		}
+		return nil, ErrNoUpstream
+	}
+	p.sent(u)
+	u.active++
+	u.requests++
+	return u, nil
+}
+
+func (p *Pool) pickRoundRobin(now time.Time) *Upstream {
+	for range p.upstreams {
+		u := p.upstreams[p.next%len(p.upstreams)]
+		p.next++
+		if p.usable(u, now) {
+			return u
+		}
+	}
+	return nil
+}
+
+// pickWeighted is smooth weighted round-robin: each available upstream gains its weight, the
+// one with the most is picked and loses the total, which spreads picks evenly.
+func (p *Pool) pickWeighted(now time.Time) *Upstream {
+	var best *Upstream
+	total := 0
+	for _, u := range p.upstreams {
+		if !p.usable(u, now) {
+			continue
+		}
+		u.currentWeight += u.target.Weight
+		total += u.target.Weight
+		if best == nil || u.currentWeight > best.currentWeight {
+			best = u
+		}
+	}
+	if best != nil {
+		best.currentWeight -= total
+	}
+	return best
+}
+
+// pickLeastConnections picks the available upstream with the fewest requests in flight per
+// unit of weight. Ties go round-robin.
+func (p *Pool) pickLeastConnections(now time.Time) *Upstream {
+	var best *Upstream
+	n := len(p.upstreams)
+	for i := range n {
+		u := p.upstreams[(p.next+i)%n]
+		if !p.usable(u, now) {
+			continue
+		}
+		if best == nil || u.active*best.target.Weight < best.active*u.target.Weight {
+			best = u
+		}
+	}
+	p.next++
+	return best
+}
+
+// available reports whether u may receive requests. An upstream whose ejection ended is
+// re-admitted here. p.mu must be held.
+func (p *Pool) available(u *Upstream, now time.Time) bool {
+	if !u.ejectedUntil.IsZero() && !now.Before(u.ejectedUntil) {
+		u.ejectedUntil = time.Time{}
+		slog.Info("upstream re-admitted after ejection", "pool", p.name, "upstream", u.target.URL)
+		metrics.SetUpstreamAvailable(p.name, u.target.URL, u.healthy)
+	}
+	return u.healthy && u.ejectedUntil.IsZero()
+}
+
+// usable reports whether u is available and its breaker lets a request through. p.mu must be
+// held.
+func (p *Pool) usable(u *Upstream, now time.Time) bool {
+	return p.available(u, now) && p.admits(u, now)
+}
+
+// Done ends a request picked with Pick. A failed request (5xx or no response) counts towards
+// ejecting u and opening its breaker; a successful one resets its failures and ejection backoff.
+func (p *Pool) Done(u *Upstream, failed bool) {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	u.active--
+	p.record(u, failed, now)
+	if !failed {
+		u.failures, u.ejections = 0, 0
+		return
+	}
+	u.failed++
+	u.failures++
+	if p.cfg.EjectAfter < 1 || u.failures < p.cfg.EjectAfter || !u.ejectedUntil.IsZero() {
+		return
+	}
+	// Never eject the last available upstream; active checks take a dead one out.
+	others := 0
+	for _, o := range p.upstreams {
+		if o != u && p.available(o, now) {
+			others++
+		}
+	}
+	if others == 0 {
+		return
+	}
+	d := min(p.cfg.EjectDuration<<u.ejections, p.cfg.MaxEjectDuration)
+	if d <= 0 {
+		d = p.cfg.MaxEjectDuration
+	}
+	u.ejectedUntil = now.Add(d)
+	u.ejections++
+	u.failures = 0
+	slog.Warn("upstream ejected", "pool", p.name, "upstream", u.target.URL, "duration", d.String(), "failed_requests", p.cfg.EjectAfter)
+	metrics.SetUpstreamAvailable(p.name, u.target.URL, false)
+	metrics.ObserveUpstreamEjection(p.name, u.target.URL)
+}
+
+// PoolState is the state of a pool and its upstreams. GET /admin/upstreams
+type PoolState struct {
+	Name      string          `json:"name"`
+	Strategy  string          `json:"strategy"`
+	Upstreams []UpstreamState `json:"upstreams"`
+}
+
+// UpstreamState is the state of one upstream.
+type UpstreamState struct {
+	URL                 string     `json:"url"`
+	Weight              int        `json:"weight"`
+	Available           bool       `json:"available"`
+	Healthy             bool       `json:"healthy"`
+	Breaker             string     `json:"breaker"`
+	EjectedUntil        *time.Time `json:"ejectedUntil,omitempty"`
+	Ejections           int        `json:"ejections"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	ActiveRequests      int        `json:"activeRequests"`
+	Requests            int64      `json:"requests"`
+	FailedRequests      int64      `json:"failedRequests"`
+	LastCheck           *time.Time `json:"lastCheck,omitempty"`
+	LastCheckError      string     `json:"lastCheckError,omitempty"`
+}
+
+// State returns the current state of the pool.
+func (p *Pool) State() PoolState {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := PoolState{Name: p.name, Strategy: p.strategy, Upstreams: make([]UpstreamState, len(p.upstreams))}
+	for i, u := range p.upstreams {
+		us := UpstreamState{
+			URL:                 u.target.URL,
+			Weight:              u.target.Weight,
+			Available:           p.available(u, now),
+			Healthy:             u.healthy,
+			Breaker:             u.breaker.state,
+			Ejections:           u.ejections,
+			ConsecutiveFailures: u.failures,
+			ActiveRequests:      u.active,
+			Requests:            u.requests,
+			FailedRequests:      u.failed,
+			LastCheckError:      u.lastError,
+		}
+		if !u.ejectedUntil.IsZero() {
+			t := u.ejectedUntil
+			us.EjectedUntil = &t
+		}
+		if !u.lastCheck.IsZero() {
+			t := u.lastCheck
+			us.LastCheck = &t
+		}
+		s.Upstreams[i] = us
+	}
+	return s
+}
@@ -0,0 +1,178 @@
+// Package app wires notification-service: database, templates, channels, HTTP API, event
+// consumers and the delivery dispatcher.
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/recover"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go_example/internal/bus"
+	"go_example/internal/lifecycle"
+	"go_example/internal/logging"
+	"go_example/internal/metrics"
+	"go_example/internal/requestid"
+	"go_example/internal/tracing"
+	"go_example/cmd/notification-service/channel"
+	"go_example/cmd/notification-service/config"
+	"go_example/cmd/notification-service/dispatch"
+	"go_example/cmd/notification-service/handler"
+	"go_example/cmd/notification-service/kafka"
+	"go_example/cmd/notification-service/migrations"
+	"go_example/cmd/notification-service/repository"
+	"go_example/cmd/notification-service/service"
+	"go_example/cmd/notification-service/templates"
+)
+
+// Start starts notification-service on b and returns once it is serving. Its HTTP server,
+// consumers, dispatcher and database pool are stopped by lc in shutdown order; b is owned and
+// closed by the caller.
+func Start(cfg *config.Config, b bus.Bus, lc *lifecycle.Manager) error {
+	for _, ch := range cfg.Notification.DefaultChannels {
+		if !slices.Contains(channel.Names, ch) {
+			return fmt.Errorf("unknown NOTIFICATION_DEFAULT_CHANNELS entry %q", ch)
+		}
+	}
+	var templateFS fs.FS = templates.FS
+	if cfg.Notification.TemplateDir != "" {
+		templateFS = os.DirFS(cfg.Notification.TemplateDir)
+	}
+	renderer, err := templates.Load(templateFS)
+	if err != nil {
+		return fmt.Errorf("templates: %w", err)
+	}
+	sink, err := channel.NewSink(cfg.Notification.File)
+	if err != nil {
+		return fmt.Errorf("file channel: %w", err)
+	}
+	channels := map[string]channel.Channel{
+		channel.Email:   channel.NewSMTP(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.From, cfg.SMTP.Username, cfg.SMTP.Password),
+		channel.Webhook: channel.NewHTTPWebhook(cfg.Notification.WebhookTimeout),
+		channel.File:    sink,
+	}
+
+	pool, err := tracing.OpenPool(context.Background(), cfg.DB.DSN())
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	lc.OnShutdown(lifecycle.PhaseStorage, "notification-service db", func(context.Context) error {
+		pool.Close()
+		return nil
+	})
+
+	if err := runMigrations(pool); err != nil {
+		return fmt.Errorf("migrations: %w", err)
+	}
+
+	notificationRepo := repository.NewNotificationRepository(pool)
+	preferenceRepo := repository.NewPreferenceRepository(pool)
+	txManager := repository.NewTxManager(pool)
+	notificationSvc := service.NewNotificationService(notificationRepo, preferenceRepo, txManager, renderer, cfg.Notification.DefaultChannels)
+	notificationHandler := handler.NewNotificationHandler(notificationSvc, handler.Owners{AdminScope: cfg.AdminScope})
+
+	dispatcher := dispatch.NewDispatcher(notificationRepo, channels, cfg.Dispatch)
+	consumer := kafka.NewConsumer(notificationSvc, b, cfg.Consumer, dispatcher.Wake)
+
+	metrics.RegisterHTTPMetrics("notification-service")
+	metrics.RegisterKafkaMetrics()
+
+	app := fiber.New()
+	app.Use(recover.New())
+	app.Use(requestid.Middleware())
+	app.Use(tracing.Middleware())
+	app.Use(logging.AccessLog())
+	app.Use(metrics.HTTPMiddleware())
+	app.Get("/metrics", metrics.MetricsHandler())
+	app.Get("/health", func(c fiber.Ctx) error { return c.JSON(fiber.Map{"status": "UP"}) })
+	app.Get("/ready", lc.ReadinessHandler())
+	app.Get("/notifications", notificationHandler.List)
+	app.Get("/notifications/preferences/:userId", notificationHandler.GetPreferences)
+	app.Put("/notifications/preferences/:userId", notificationHandler.PutPreferences)
+
+	go func() {
+		if err := app.Listen(":"+cfg.ServerPort, fiber.ListenConfig{DisableStartupMessage: true}); err != nil && err != http.ErrServerClosed {
+			logging.Fatal("listen failed", logging.Err(err))
+		}
+	}()
+	lc.OnShutdown(lifecycle.PhaseHTTP, "notification-service http", app.ShutdownWithContext)
+	if cfg.AdminPort != "" {
+		admin := logging.AdminApp()
+		go func() {
+			if err := admin.Listen(":"+cfg.AdminPort, fiber.ListenConfig{DisableStartupMessage: true}); err != nil && err != http.ErrServerClosed {
+				logging.Fatal("admin listen failed", logging.Err(err))
+			}
+		}()
+		lc.OnShutdown(lifecycle.PhaseHTTP, "notification-service admin http", admin.ShutdownWithContext)
+	}
+
+	consumerCtx, stopConsumer := context.WithCancel(context.Background())
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		consumer.Run(consumerCtx)
+	}()
+	dispatchCtx, stopDispatcher := context.WithCancel(context.Background())
+	dispatchDone := make(chan struct{})
+	go func() {
+		defer close(dispatchDone)
+		dispatcher.Run(dispatchCtx)
+	}()
+	// Deliveries still due when the dispatcher stops are sent by the next instance to start.
+	lc.OnShutdown(lifecycle.PhaseConsumers, "notification-service consumers", func(ctx context.Context) error {
+		stopConsumer()
+		if err := wait(ctx, consumerDone); err != nil {
+			return err
+		}
+		stopDispatcher()
+		return wait(ctx, dispatchDone)
+	})
+	return nil
+}
+
+func wait(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// advisoryLockID ensures only one instance runs migrations when multiple share the same DB.
+const advisoryLockID int64 = 0x6e6f74696679 // "notify"
+
+func runMigrations(pool *pgxpool.Pool) error {
+	ctx := context.Background()
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	_, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID)
+	if err != nil {
+		return err
+	}
+	defer conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", advisoryLockID)
+	// Migrations are idempotent and applied in file name order.
+	files, err := fs.Glob(migrations.FS, "*.up.sql")
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		data, err := migrations.FS.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if _, err := conn.Exec(ctx, string(data)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+// Package channel delivers rendered notifications: SMTP email, HTTP webhook and a file/stdout sink.
+package channel
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// Channel names used in preferences and delivery records.
+const (
+	Email   = "email"
+	Webhook = "webhook"
+	File    = "file"
+)
+
+// Names lists every channel.
+var Names = []string{Email, Webhook, File}
+
+// Message is a rendered notification addressed to one recipient of a channel.
+type Message struct {
+	Event     string          `json:"event"`
+	OrderID   uuid.UUID       `json:"orderId"`
+	UserID    uuid.UUID       `json:"userId"`
+	Recipient string          `json:"-"`
+	Subject   string          `json:"subject"`
+	Body      string          `json:"body"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Channel sends messages. Send returns an error if the message may not have been delivered.
+type Channel interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// NeedsRecipient reports whether deliveries through the named channel need a recipient from
+// the user's preferences (an email address or a webhook URL).
+func NeedsRecipient(name string) bool {
+	return name == Email || name == Webhook
+}
@@ -0,0 +1,44 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sink writes each message as a JSON line to a file or stdout.
+type Sink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewSink creates a sink appending to path, or writing to stdout if path is "-" or empty.
+// The file stays open for the life of the process.
+func NewSink(path string) (*Sink, error) {
+	if path == "" || path == "-" {
+		return &Sink{w: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{w: f}, nil
+}
+
+// Send writes m with the time it was written.
+func (s *Sink) Send(_ context.Context, m Message) error {
+	line, err := json.Marshal(struct {
+		Time time.Time `json:"time"`
+		Message
+	}{time.Now(), m})
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,43 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HTTPWebhook POSTs messages as JSON to the recipient URL.
+type HTTPWebhook struct {
+	client *http.Client
+}
+
+// NewHTTPWebhook creates a webhook channel whose requests time out after timeout.
+func NewHTTPWebhook(timeout time.Duration) *HTTPWebhook {
+	return &HTTPWebhook{client: &http.Client{Timeout: timeout}}
+}
+
+// Send posts m to m.Recipient and fails unless the response status is 2xx.
+func (w *HTTPWebhook) Send(ctx context.Context, m Message) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.Recipient, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Notification-Event", m.Event)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: %s returned %d", m.Recipient, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_example/internal/bus"
+	"go_example/internal/kafkaconn"
+	"go_example/internal/logging"
+	"go_example/internal/tracing"
+)
+
+// Config holds notification-service configuration. AdminPort serves GET|PUT /admin/log-level, kept off
+// the public ServerPort; empty disables it.
+type Config struct {
+	ServerPort    string
+	AdminPort     string
+	DB            DBConfig
+	Bus           string
+	Kafka         kafkaconn.Config
+	Consumer      ConsumerConfig
+	Notification  NotificationConfig
+	SMTP          SMTPConfig
+	Dispatch      DispatchConfig
+	AdminScope    string
+	Tracing       tracing.Config
+	Logging       logging.Config
+	ShutdownGrace time.Duration
+	ShutdownDrain time.Duration
+}
+
+// DBConfig holds PostgreSQL configuration.
+type DBConfig struct {
+	Host     string
+	Port     string
+	Database string
+	User     string
+	Password string
+}
+
+// ConsumerConfig holds per-topic consumer processing configuration.
+type ConsumerConfig struct {
+	Concurrency        map[string]int
+	DefaultConcurrency int
+	MaxInFlight        int
+}
+
+// Options returns the processing options for topic.
+func (c ConsumerConfig) Options(topic string) bus.Options {
+	n, ok := c.Concurrency[topic]
+	if !ok {
+		n = c.DefaultConcurrency
+	}
+	return bus.Options{Concurrency: n, MaxInFlight: c.MaxInFlight}
+}
+
+// NotificationConfig holds rendering and channel configuration. DefaultChannels apply to users
+// without preferences; TemplateDir, if set, replaces the built-in templates; File is the file
+// sink path ("-" for stdout).
+type NotificationConfig struct {
+	DefaultChannels []string
+	TemplateDir     string
+	File            string
+	WebhookTimeout  time.Duration
+}
+
+// SMTPConfig holds the SMTP server used by the email channel.
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	From     string
+	Username string
+	Password string
+}
+
+// DispatchConfig holds delivery configuration. A failed delivery is retried after Backoff,
+// doubling per attempt, until MaxAttempts; Lease is how long a claimed delivery stays claimed.
+type DispatchConfig struct {
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+	Backoff     time.Duration
+	Lease       time.Duration
+}
+
+// Load reads configuration from environment.
+func Load() *Config {
+	return &Config{
+		ServerPort: getEnv("SERVER_PORT", "8096"),
+		AdminPort:  getEnv("ADMIN_PORT", "9080"),
+		DB: DBConfig{
+			Host:     getEnv("DB_HOST", "localhost"),
+			Port:     getEnv("DB_PORT", "5432"),
+			Database: getEnv("DB_NAME", "notification_db"),
+			User:     getEnv("DB_USER", "user"),
+			Password: getEnv("DB_PASSWORD", "password"),
+		},
+		Bus:   getEnv("BUS", "kafka"),
+		Kafka: kafkaconn.FromEnv(getEnvSlice("KAFKA_BOOTSTRAP_SERVERS", []string{"localhost:9092"})),
+		Consumer: ConsumerConfig{
+			Concurrency:        getEnvIntMap("CONSUMER_CONCURRENCY"),
+			DefaultConcurrency: getEnvInt("CONSUMER_DEFAULT_CONCURRENCY", 4),
+			MaxInFlight:        getEnvInt("CONSUMER_MAX_IN_FLIGHT", bus.DefaultMaxInFlight),
+		},
+		Notification: NotificationConfig{
+			DefaultChannels: getEnvSlice("NOTIFICATION_DEFAULT_CHANNELS", []string{"file"}),
+			TemplateDir:     getEnv("NOTIFICATION_TEMPLATE_DIR", ""),
+			File:            getEnv("NOTIFICATION_FILE", "-"),
+			WebhookTimeout:  getEnvDuration("NOTIFICATION_WEBHOOK_TIMEOUT", 5*time.Second),
+		},
+		SMTP: SMTPConfig{
+			Host:     getEnv("SMTP_HOST", "localhost"),
+			Port:     getEnv("SMTP_PORT", "1025"),
+			From:     getEnv("SMTP_FROM", "notifications@go-example.local"),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+		},
+		Dispatch: DispatchConfig{
+			Interval:    getEnvDuration("DISPATCH_INTERVAL", time.Second),
+			BatchSize:   getEnvInt("DISPATCH_BATCH_SIZE", 50),
+			MaxAttempts: getEnvInt("DISPATCH_MAX_ATTEMPTS", 5),
+			Backoff:     getEnvDuration("DISPATCH_BACKOFF", 5*time.Second),
+			Lease:       getEnvDuration("DISPATCH_LEASE", time.Minute),
+		},
+		AdminScope:    getEnv("JWT_ADMIN_SCOPE", "admin"),
+		Tracing:       tracing.FromEnv(),
+		Logging:       logging.FromEnv(),
+		ShutdownGrace: getEnvDuration("SHUTDOWN_GRACE_PERIOD", 20*time.Second),
+		ShutdownDrain: getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
+func getEnvSlice(key string, fallback []string) []string {
+	if v := os.Getenv(key); v != "" {
+		parts := strings.Split(v, ",")
+		out := make([]string, 0, len(parts))
+		for _, p := range parts {
+			if s := strings.TrimSpace(p); s != "" {
+				out = append(out, s)
+			}
+		}
+		if len(out) > 0 {
+			return out
+		}
+	}
+	return fallback
+}
+
+// getEnvIntMap parses key=value pairs such as "order.created=8,order.canceled=2".
+func getEnvIntMap(key string) map[string]int {
+	out := map[string]int{}
+	for _, pair := range getEnvSlice(key, nil) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			out[strings.TrimSpace(k)] = n
+		}
+	}
+	return out
+}
+
+// DSN returns PostgreSQL connection string (password URL-escaped).
+func (c *DBConfig) DSN() string {
+	user := url.UserPassword(c.User, c.Password)
+	u := &url.URL{
+		Scheme:   "postgres",
+		User:     user,
+		Host:     c.Host + ":" + c.Port,
+		Path:     "/" + c.Database,
+		RawQuery: "sslmode=disable",
+	}
+	return u.String()
+}
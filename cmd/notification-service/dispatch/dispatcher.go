@@ -0,0 +1,122 @@
+// Package dispatch sends recorded notifications through their channels and tracks delivery status.
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go_example/internal/logging"
+	"go_example/cmd/notification-service/channel"
+	"go_example/cmd/notification-service/config"
+	"go_example/cmd/notification-service/repository"
+)
+
+var errUnknownChannel = errors.New("channel not configured")
+
+// Dispatcher polls for due deliveries and sends them. A failed delivery is retried with
+// exponential backoff until it succeeds or runs out of attempts.
+type Dispatcher struct {
+	repo     *repository.NotificationRepository
+	channels map[string]channel.Channel
+	cfg      config.DispatchConfig
+	wake     chan struct{}
+}
+
+// NewDispatcher creates a new Dispatcher sending through channels, keyed by channel name.
+func NewDispatcher(repo *repository.NotificationRepository, channels map[string]channel.Channel, cfg config.DispatchConfig) *Dispatcher {
+	return &Dispatcher{repo: repo, channels: channels, cfg: cfg, wake: make(chan struct{}, 1)}
+}
+
+// Wake makes Run look for due deliveries now instead of at the next interval.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due deliveries every interval, or when woken, until ctx is canceled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	tick := time.NewTicker(d.cfg.Interval)
+	defer tick.Stop()
+	for {
+		d.drain(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// drain sends batches until no delivery is due or claiming fails.
+func (d *Dispatcher) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := d.dispatchBatch(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "dispatch failed", logging.Err(err))
+			return
+		}
+		if n < d.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) dispatchBatch(ctx context.Context) (int, error) {
+	now := time.Now()
+	due, err := d.repo.ClaimDue(ctx, now, now.Add(d.cfg.Lease), d.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	var wg sync.WaitGroup
+	for _, dd := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.send(ctx, dd)
+		}()
+	}
+	wg.Wait()
+	return len(due), nil
+}
+
+// send sends one claimed delivery and records the outcome. The outcome is recorded even if ctx
+// is canceled meanwhile; if recording fails, the delivery is retried once its lease expires.
+func (d *Dispatcher) send(ctx context.Context, dd *repository.DueDelivery) {
+	n, del := dd.Notification, dd.Delivery
+	ctx = logging.With(ctx, logging.OrderID(n.OrderID), logging.UserID(n.UserID),
+		slog.Int64("notification_id", n.ID), slog.String("channel", del.Channel))
+	err := errUnknownChannel
+	if ch, ok := d.channels[del.Channel]; ok {
+		err = ch.Send(ctx, channel.Message{
+			Event:     n.EventType,
+			OrderID:   n.OrderID,
+			UserID:    n.UserID,
+			Recipient: del.Recipient,
+			Subject:   n.Subject,
+			Body:      n.Body,
+			Data:      n.Payload,
+		})
+	}
+	rctx, now := context.WithoutCancel(ctx), time.Now()
+	if err == nil {
+		if err := d.repo.MarkSent(rctx, n.ID, del.Channel, now); err != nil {
+			slog.ErrorContext(rctx, "record delivery sent failed", logging.Err(err))
+		}
+		return
+	}
+	var next *time.Time
+	if del.Attempts < d.cfg.MaxAttempts && err != errUnknownChannel {
+		t := now.Add(d.cfg.Backoff << (del.Attempts - 1))
+		next = &t
+	}
+	slog.WarnContext(rctx, "delivery failed", "attempt", del.Attempts, logging.Err(err))
+	if err := d.repo.MarkFailed(rctx, n.ID, del.Channel, err.Error(), next, now); err != nil {
+		slog.ErrorContext(rctx, "record delivery failure failed", logging.Err(err))
+	}
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Delivery statuses. A delivery is pending until its first attempt, retrying after a failed
+// attempt that will be repeated, and failed once attempts are exhausted. Skipped deliveries
+// could not be attempted, e.g. a channel without a recipient.
+const (
+	StatusPending  = "pending"
+	StatusRetrying = "retrying"
+	StatusSent     = "sent"
+	StatusFailed   = "failed"
+	StatusSkipped  = "skipped"
+)
+
+// Notification is a rendered message about one order event, deduplicated on order ID and event type.
+type Notification struct {
+	ID        int64
+	OrderID   uuid.UUID
+	UserID    uuid.UUID
+	EventType string
+	Subject   string
+	Body      string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+// Delivery tracks sending a notification through one channel.
+type Delivery struct {
+	NotificationID int64
+	Channel        string
+	Recipient      string
+	Status         string
+	Attempts       int
+	LastError      string
+	NextAttemptAt  *time.Time
+	SentAt         *time.Time
+	UpdatedAt      time.Time
+}
+
+// Preferences are a user's notification settings. An empty Events list means every event.
+type Preferences struct {
+	UserID     uuid.UUID
+	Email      string
+	WebhookURL string
+	Channels   []string
+	Events     []string
+	UpdatedAt  time.Time
+}
+
+// Wants reports whether the user wants to be notified of eventType.
+func (p *Preferences) Wants(eventType string) bool {
+	return len(p.Events) == 0 || slices.Contains(p.Events, eventType)
+}
@@ -0,0 +1,52 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PreferencesRequest is the request body for setting a user's notification preferences.
+// An empty Events list subscribes to every event.
+type PreferencesRequest struct {
+	Email      string   `json:"email"`
+	WebhookURL string   `json:"webhookUrl"`
+	Channels   []string `json:"channels"`
+	Events     []string `json:"events"`
+}
+
+// PreferencesResponse is the preferences API response. Default is true for a user without
+// preferences, who is notified through the default channels.
+type PreferencesResponse struct {
+	UserID     uuid.UUID  `json:"userId"`
+	Email      string     `json:"email"`
+	WebhookURL string     `json:"webhookUrl"`
+	Channels   []string   `json:"channels"`
+	Events     []string   `json:"events"`
+	Default    bool       `json:"default"`
+	UpdatedAt  *time.Time `json:"updatedAt,omitempty"`
+}
+
+// NotificationResponse is a notification with the status of its deliveries.
+type NotificationResponse struct {
+	ID         int64              `json:"id"`
+	OrderID    uuid.UUID          `json:"orderId"`
+	UserID     uuid.UUID          `json:"userId"`
+	Event      string             `json:"event"`
+	Subject    string             `json:"subject"`
+	Body       string             `json:"body"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	Deliveries []DeliveryResponse `json:"deliveries"`
+}
+
+// DeliveryResponse is the status of a notification delivery through one channel.
+type DeliveryResponse struct {
+	Channel       string     `json:"channel"`
+	Recipient     string     `json:"recipient,omitempty"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"lastError,omitempty"`
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+	SentAt        *time.Time `json:"sentAt,omitempty"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"go_example/cmd/notification-service/dto"
+	"go_example/cmd/notification-service/service"
+)
+
+// Notifications lists notifications and manages preferences. Implemented by
+// service.NotificationService.
+type Notifications interface {
+	ListByOrderID(ctx context.Context, orderID uuid.UUID) ([]*dto.NotificationResponse, error)
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]*dto.NotificationResponse, error)
+	GetPreferences(ctx context.Context, userID uuid.UUID) (*dto.PreferencesResponse, error)
+	PutPreferences(ctx context.Context, userID uuid.UUID, req dto.PreferencesRequest) (*dto.PreferencesResponse, error)
+}
+
+// NotificationHandler handles HTTP requests for notifications and preferences.
+type NotificationHandler struct {
+	svc    Notifications
+	owners Owners
+}
+
+// NewNotificationHandler creates a new NotificationHandler that lets callers see only their own
+// notifications and preferences, as restricted by owners.
+func NewNotificationHandler(svc Notifications, owners Owners) *NotificationHandler {
+	return &NotificationHandler{svc: svc, owners: owners}
+}
+
+// List returns notifications with their delivery status; asking for another user's answers 404.
+// GET /notifications?orderId=xxx or ?userId=xxx
+func (h *NotificationHandler) List(c fiber.Ctx) error {
+	var (
+		list []*dto.NotificationResponse
+		err  error
+	)
+	switch {
+	case c.Query("orderId") != "":
+		id, perr := uuid.Parse(c.Query("orderId"))
+		if perr != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid orderId"})
+		}
+		list, err = h.svc.ListByOrderID(c.Context(), id)
+	case c.Query("userId") != "":
+		id, perr := uuid.Parse(c.Query("userId"))
+		if perr != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid userId"})
+		}
+		if !h.owners.allows(c, id) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "notifications not found"})
+		}
+		list, err = h.svc.ListByUserID(c.Context(), id)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "orderId or userId query parameter is required"})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	for _, n := range list {
+		if !h.owners.allows(c, n.UserID) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "notifications not found"})
+		}
+	}
+	return c.JSON(list)
+}
+
+// GetPreferences returns a user's notification preferences. GET /notifications/preferences/:userId
+func (h *NotificationHandler) GetPreferences(c fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+	}
+	if !h.owners.allows(c, id) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "preferences not found"})
+	}
+	prefs, err := h.svc.GetPreferences(c.Context(), id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(prefs)
+}
+
+// PutPreferences sets a user's notification preferences. PUT /notifications/preferences/:userId
+func (h *NotificationHandler) PutPreferences(c fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+	}
+	if !h.owners.allows(c, id) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "preferences not found"})
+	}
+	var req dto.PreferencesRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	prefs, err := h.svc.PutPreferences(c.Context(), id, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPreferences) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(prefs)
+}
@@ -0,0 +1,90 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"go_example/internal/bus"
+	"go_example/internal/events"
+	"go_example/internal/logging"
+	"go_example/cmd/notification-service/config"
+	"go_example/cmd/notification-service/service"
+)
+
+// Group is the consumer group notification-service subscribes with.
+const Group = "notification-service-group"
+
+// Topics lists the events customers are notified of.
+var Topics = []string{
+	events.TopicPaymentSucceeded,
+	events.TopicUserCreditReservationFailed,
+	events.TopicPaymentFailed,
+	events.TopicOrderCanceled,
+	events.TopicPaymentRefunded,
+}
+
+// Consumer records notifications for order events and wakes the dispatcher to send them.
+type Consumer struct {
+	notificationSvc *service.NotificationService
+	sub             bus.Subscriber
+	cfg             config.ConsumerConfig
+	wake            func()
+}
+
+// NewConsumer creates a new Consumer that calls wake after recording a notification.
+func NewConsumer(notificationSvc *service.NotificationService, sub bus.Subscriber, cfg config.ConsumerConfig, wake func()) *Consumer {
+	return &Consumer{notificationSvc: notificationSvc, sub: sub, cfg: cfg, wake: wake}
+}
+
+// Run starts consuming the notified topics and blocks until ctx is canceled.
+func (c *Consumer) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, topic := range Topics {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts := c.cfg.Options(topic)
+			opts.RetryForever = true
+			if err := c.sub.Subscribe(ctx, topic, Group, c.handle, opts); err != nil {
+				slog.Error("subscribe failed", logging.KeyTopic, topic, logging.Err(err))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// handle records the notification of an event. Delivery failures are retried by the
+// dispatcher, so this only fails while the database is unavailable and then retries instead
+// of dead-lettering into the saga DLQs.
+func (c *Consumer) handle(ctx context.Context, msg bus.Message) error {
+	evt, err := events.Decode(msg.Topic, msg.Value)
+	if err != nil {
+		return bus.Skip(err)
+	}
+	var env events.Envelope
+	if err := json.Unmarshal(msg.Value, &env); err != nil {
+		return bus.Skip(err)
+	}
+	if env.OrderID == uuid.Nil || env.UserID == uuid.Nil {
+		return bus.Skip(fmt.Errorf("%s: no order or user ID in payload", msg.Topic))
+	}
+	ctx = logging.With(ctx, logging.OrderID(env.OrderID), logging.UserID(env.UserID))
+	created, err := c.notificationSvc.Notify(ctx, msg.Topic, env, evt, msg.Value)
+	if err != nil {
+		if errors.Is(err, service.ErrRender) {
+			return bus.Skip(err)
+		}
+		return err
+	}
+	if created {
+		slog.InfoContext(ctx, "notification recorded")
+		c.wake()
+	}
+	return nil
+}
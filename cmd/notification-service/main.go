@@ -0,0 +1,51 @@
+// Notification-service: notifies customers of order outcomes by email, webhook or file.
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go_example/internal/bus"
+	"go_example/internal/lifecycle"
+	"go_example/internal/logging"
+	"go_example/internal/tracing"
+	"go_example/cmd/notification-service/app"
+	"go_example/cmd/notification-service/config"
+)
+
+func main() {
+	cfg := config.Load()
+	if err := logging.Setup("notification-service", cfg.Logging); err != nil {
+		log.Fatalf("notification-service: %v", err)
+	}
+
+	b, err := bus.New(cfg.Bus, cfg.Kafka)
+	if err != nil {
+		logging.Fatal("bus setup failed", logging.Err(err))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	lc := lifecycle.New()
+	shutdownTracing, err := tracing.Setup(context.Background(), "notification-service", cfg.Tracing)
+	if err != nil {
+		logging.Fatal("tracing setup failed", logging.Err(err))
+	}
+	lc.OnShutdown(lifecycle.PhaseTelemetry, "tracing", shutdownTracing)
+	lc.OnShutdown(lifecycle.PhaseProducers, "bus", func(context.Context) error { return b.Close() })
+	if err := app.Start(cfg, b, lc); err != nil {
+		logging.Fatal("start failed", logging.Err(err))
+	}
+	lc.SetReady()
+
+	<-ctx.Done()
+	slog.Info("shutting down")
+	if err := lc.Shutdown(cfg.ShutdownDrain, cfg.ShutdownGrace); err != nil {
+		slog.Error("shutdown failed", logging.Err(err))
+	}
+}
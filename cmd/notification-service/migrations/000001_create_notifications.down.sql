@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_notification_deliveries_due;
+DROP TABLE IF EXISTS notification_deliveries;
+DROP INDEX IF EXISTS idx_notifications_user_id;
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS notification_preferences;
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go_example/cmd/notification-service/domain"
+)
+
+// NotificationRepository handles notification and delivery persistence.
+type NotificationRepository struct {
+	db DBTX
+}
+
+// NewNotificationRepository creates a new NotificationRepository.
+func NewNotificationRepository(pool *pgxpool.Pool) *NotificationRepository {
+	return &NotificationRepository{db: pool}
+}
+
+// DueDelivery is a claimed delivery with the notification it sends.
+type DueDelivery struct {
+	Notification domain.Notification
+	Delivery     domain.Delivery
+}
+
+// Create inserts n and sets its ID. It returns false, inserting nothing, if a notification for
+// the same order and event type already exists.
+func (r *NotificationRepository) Create(ctx context.Context, n *domain.Notification) (bool, error) {
+	query := `INSERT INTO notifications (order_id, user_id, event_type, subject, body, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (order_id, event_type) DO NOTHING RETURNING id`
+	err := r.db.QueryRow(ctx, query, n.OrderID, n.UserID, n.EventType, n.Subject, n.Body, n.Payload, n.CreatedAt).Scan(&n.ID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// AddDelivery inserts a delivery of a notification through one channel.
+func (r *NotificationRepository) AddDelivery(ctx context.Context, d *domain.Delivery) error {
+	query := `INSERT INTO notification_deliveries (notification_id, channel, recipient, status, last_error, next_attempt_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err := r.db.Exec(ctx, query, d.NotificationID, d.Channel, d.Recipient, d.Status, d.LastError, d.NextAttemptAt, d.UpdatedAt)
+	return err
+}
+
+// ClaimDue claims up to limit pending or retrying deliveries due at now and counts an attempt
+// for each. A claimed delivery is not due again before leaseUntil, so concurrent dispatchers do
+// not send it twice and one that crashes mid-send is retried after the lease.
+func (r *NotificationRepository) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*DueDelivery, error) {
+	query := `UPDATE notification_deliveries d
+		SET attempts = d.attempts + 1, next_attempt_at = $2, updated_at = $1
+		FROM notifications n
+		WHERE n.id = d.notification_id AND (d.notification_id, d.channel) IN (
+			SELECT notification_id, channel FROM notification_deliveries
+			WHERE status IN ('pending', 'retrying') AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED)
+		RETURNING d.notification_id, d.channel, d.recipient, d.status, d.attempts,
+			n.order_id, n.user_id, n.event_type, n.subject, n.body, n.payload, n.created_at`
+	rows, err := r.db.Query(ctx, query, now, leaseUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*DueDelivery
+	for rows.Next() {
+		var due DueDelivery
+		d, n := &due.Delivery, &due.Notification
+		if err := rows.Scan(&d.NotificationID, &d.Channel, &d.Recipient, &d.Status, &d.Attempts,
+			&n.OrderID, &n.UserID, &n.EventType, &n.Subject, &n.Body, &n.Payload, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		n.ID = d.NotificationID
+		list = append(list, &due)
+	}
+	return list, rows.Err()
+}
+
+// MarkSent records that a delivery succeeded.
+func (r *NotificationRepository) MarkSent(ctx context.Context, notificationID int64, channel string, at time.Time) error {
+	query := `UPDATE notification_deliveries SET status = $3, last_error = '', next_attempt_at = NULL, sent_at = $4, updated_at = $4
+		WHERE notification_id = $1 AND channel = $2`
+	_, err := r.db.Exec(ctx, query, notificationID, channel, domain.StatusSent, at)
+	return err
+}
+
+// MarkFailed records a failed attempt. With a next attempt time the delivery is retried then;
+// without one it is given up.
+func (r *NotificationRepository) MarkFailed(ctx context.Context, notificationID int64, channel, lastError string, next *time.Time, at time.Time) error {
+	status := domain.StatusRetrying
+	if next == nil {
+		status = domain.StatusFailed
+	}
+	query := `UPDATE notification_deliveries SET status = $3, last_error = $4, next_attempt_at = $5, updated_at = $6
+		WHERE notification_id = $1 AND channel = $2`
+	_, err := r.db.Exec(ctx, query, notificationID, channel, status, lastError, next, at)
+	return err
+}
+
+// ListByOrderID returns the notifications of an order, oldest first.
+func (r *NotificationRepository) ListByOrderID(ctx context.Context, orderID uuid.UUID) ([]*domain.Notification, error) {
+	return r.list(ctx, `WHERE order_id = $1 ORDER BY created_at, id`, orderID)
+}
+
+// ListByUserID returns the latest limit notifications of a user, newest first.
+func (r *NotificationRepository) ListByUserID(ctx context.Context, userID uuid.UUID, limit int) ([]*domain.Notification, error) {
+	return r.list(ctx, `WHERE user_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2`, userID, limit)
+}
+
+func (r *NotificationRepository) list(ctx context.Context, where string, args ...any) ([]*domain.Notification, error) {
+	query := `SELECT id, order_id, user_id, event_type, subject, body, payload, created_at FROM notifications ` + where
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*domain.Notification
+	for rows.Next() {
+		var n domain.Notification
+		if err := rows.Scan(&n.ID, &n.OrderID, &n.UserID, &n.EventType, &n.Subject, &n.Body, &n.Payload, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, &n)
+	}
+	return list, rows.Err()
+}
+
+// DeliveriesOf returns the deliveries of the given notifications.
+func (r *NotificationRepository) DeliveriesOf(ctx context.Context, notificationIDs []int64) ([]*domain.Delivery, error) {
+	query := `SELECT notification_id, channel, recipient, status, attempts, last_error, next_attempt_at, sent_at, updated_at
+		FROM notification_deliveries WHERE notification_id = ANY($1) ORDER BY notification_id, channel`
+	rows, err := r.db.Query(ctx, query, notificationIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*domain.Delivery
+	for rows.Next() {
+		var d domain.Delivery
+		if err := rows.Scan(&d.NotificationID, &d.Channel, &d.Recipient, &d.Status, &d.Attempts, &d.LastError,
+			&d.NextAttemptAt, &d.SentAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, &d)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go_example/cmd/notification-service/domain"
+)
+
+// PreferenceRepository handles notification preference persistence.
+type PreferenceRepository struct {
+	db DBTX
+}
+
+// NewPreferenceRepository creates a new PreferenceRepository.
+func NewPreferenceRepository(pool *pgxpool.Pool) *PreferenceRepository {
+	return &PreferenceRepository{db: pool}
+}
+
+// Get returns the preferences of a user.
+func (r *PreferenceRepository) Get(ctx context.Context, userID uuid.UUID) (*domain.Preferences, error) {
+	query := `SELECT user_id, email, webhook_url, channels, events, updated_at FROM notification_preferences WHERE user_id = $1`
+	var p domain.Preferences
+	err := r.db.QueryRow(ctx, query, userID).Scan(&p.UserID, &p.Email, &p.WebhookURL, &p.Channels, &p.Events, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Put creates or replaces the preferences of a user.
+func (r *PreferenceRepository) Put(ctx context.Context, p *domain.Preferences) error {
+	query := `INSERT INTO notification_preferences (user_id, email, webhook_url, channels, events, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET email = EXCLUDED.email, webhook_url = EXCLUDED.webhook_url,
+			channels = EXCLUDED.channels, events = EXCLUDED.events, updated_at = EXCLUDED.updated_at`
+	_, err := r.db.Exec(ctx, query, p.UserID, p.Email, p.WebhookURL, p.Channels, p.Events, p.UpdatedAt)
+	return err
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"go_example/internal/events"
+	"go_example/cmd/notification-service/channel"
+	"go_example/cmd/notification-service/domain"
+	"go_example/cmd/notification-service/dto"
+	"go_example/cmd/notification-service/repository"
+	"go_example/cmd/notification-service/templates"
+)
+
+var (
+	ErrInvalidPreferences = errors.New("invalid preferences")
+	// ErrRender is returned when an event cannot be rendered; retrying does not help.
+	ErrRender = errors.New("render notification")
+)
+
+// recentNotifications bounds the notifications listed per user.
+const recentNotifications = 50
+
+// NotificationService records notifications for order events according to user preferences.
+type NotificationService struct {
+	repo            *repository.NotificationRepository
+	prefs           *repository.PreferenceRepository
+	tx              *repository.TxManager
+	renderer        *templates.Renderer
+	defaultChannels []string
+}
+
+// NewNotificationService creates a new NotificationService. Users without preferences are
+// notified through defaultChannels.
+func NewNotificationService(repo *repository.NotificationRepository, prefs *repository.PreferenceRepository,
+	tx *repository.TxManager, renderer *templates.Renderer, defaultChannels []string) *NotificationService {
+	return &NotificationService{repo: repo, prefs: prefs, tx: tx, renderer: renderer, defaultChannels: defaultChannels}
+}
+
+// Notify renders evt, the event of type eventType decoded from payload, and records it with a
+// pending delivery per channel the user has chosen. It reports false if the user does not
+// want eventType or the order was already notified of it.
+func (s *NotificationService) Notify(ctx context.Context, eventType string, env events.Envelope, evt any, payload []byte) (bool, error) {
+	prefs, _, err := s.preferences(ctx, env.UserID)
+	if err != nil {
+		return false, err
+	}
+	if !prefs.Wants(eventType) {
+		return false, nil
+	}
+	subject, body, err := s.renderer.Render(eventType, evt)
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrRender, err)
+	}
+	now := time.Now()
+	n := &domain.Notification{
+		OrderID:   env.OrderID,
+		UserID:    env.UserID,
+		EventType: eventType,
+		Subject:   subject,
+		Body:      body,
+		Payload:   json.RawMessage(payload),
+		CreatedAt: now,
+	}
+	created := false
+	err = s.tx.Run(ctx, func(notifications *repository.NotificationRepository) error {
+		var err error
+		if created, err = notifications.Create(ctx, n); err != nil || !created {
+			return err
+		}
+		for _, ch := range prefs.Channels {
+			if err := notifications.AddDelivery(ctx, newDelivery(n.ID, ch, prefs, now)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return created, err
+}
+
+// newDelivery returns the delivery of a notification through ch, due now, or skipped if the
+// preferences lack the recipient ch needs.
+func newDelivery(notificationID int64, ch string, prefs *domain.Preferences, now time.Time) *domain.Delivery {
+	d := &domain.Delivery{NotificationID: notificationID, Channel: ch, Status: domain.StatusPending, NextAttemptAt: &now, UpdatedAt: now}
+	switch ch {
+	case channel.Email:
+		d.Recipient = prefs.Email
+	case channel.Webhook:
+		d.Recipient = prefs.WebhookURL
+	}
+	if channel.NeedsRecipient(ch) && d.Recipient == "" {
+		d.Status, d.LastError, d.NextAttemptAt = domain.StatusSkipped, "no "+ch+" recipient in preferences", nil
+	}
+	return d
+}
+
+// GetPreferences returns the preferences of a user, or the defaults if none were set.
+func (s *NotificationService) GetPreferences(ctx context.Context, userID uuid.UUID) (*dto.PreferencesResponse, error) {
+	p, isDefault, err := s.preferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return toPreferencesResponse(p, isDefault), nil
+}
+
+// PutPreferences validates and stores the preferences of a user.
+func (s *NotificationService) PutPreferences(ctx context.Context, userID uuid.UUID, req dto.PreferencesRequest) (*dto.PreferencesResponse, error) {
+	if req.Channels == nil {
+		req.Channels = []string{}
+	}
+	if req.Events == nil {
+		req.Events = []string{}
+	}
+	for _, ch := range req.Channels {
+		if !slices.Contains(channel.Names, ch) {
+			return nil, fmt.Errorf("%w: unknown channel %q", ErrInvalidPreferences, ch)
+		}
+	}
+	for _, e := range req.Events {
+		if !s.renderer.Has(e) {
+			return nil, fmt.Errorf("%w: unknown event %q", ErrInvalidPreferences, e)
+		}
+	}
+	if req.WebhookURL != "" {
+		u, err := url.Parse(req.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%w: webhookUrl must be an http(s) URL", ErrInvalidPreferences)
+		}
+	}
+	p := &domain.Preferences{
+		UserID:     userID,
+		Email:      req.Email,
+		WebhookURL: req.WebhookURL,
+		Channels:   req.Channels,
+		Events:     req.Events,
+		UpdatedAt:  time.Now(),
+	}
+	if err := s.prefs.Put(ctx, p); err != nil {
+		return nil, err
+	}
+	return toPreferencesResponse(p, false), nil
+}
+
+// preferences returns the preferences of a user and whether they are the defaults.
+func (s *NotificationService) preferences(ctx context.Context, userID uuid.UUID) (*domain.Preferences, bool, error) {
+	p, err := s.prefs.Get(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &domain.Preferences{UserID: userID, Channels: s.defaultChannels, Events: []string{}}, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return p, false, nil
+}
+
+// ListByOrderID returns the notifications of an order with their delivery status.
+func (s *NotificationService) ListByOrderID(ctx context.Context, orderID uuid.UUID) ([]*dto.NotificationResponse, error) {
+	list, err := s.repo.ListByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	return s.withDeliveries(ctx, list)
+}
+
+// ListByUserID returns the latest notifications of a user with their delivery status.
+func (s *NotificationService) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*dto.NotificationResponse, error) {
+	list, err := s.repo.ListByUserID(ctx, userID, recentNotifications)
+	if err != nil {
+		return nil, err
+	}
+	return s.withDeliveries(ctx, list)
+}
+
+func (s *NotificationService) withDeliveries(ctx context.Context, list []*domain.Notification) ([]*dto.NotificationResponse, error) {
+	out := make([]*dto.NotificationResponse, 0, len(list))
+	if len(list) == 0 {
+		return out, nil
+	}
+	ids := make([]int64, len(list))
+	byID := make(map[int64]*dto.NotificationResponse, len(list))
+	for i, n := range list {
+		ids[i] = n.ID
+		resp := &dto.NotificationResponse{
+			ID:         n.ID,
+			OrderID:    n.OrderID,
+			UserID:     n.UserID,
+			Event:      n.EventType,
+			Subject:    n.Subject,
+			Body:       n.Body,
+			CreatedAt:  n.CreatedAt,
+			Deliveries: []dto.DeliveryResponse{},
+		}
+		byID[n.ID] = resp
+		out = append(out, resp)
+	}
+	deliveries, err := s.repo.DeliveriesOf(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deliveries {
+		resp := byID[d.NotificationID]
+		resp.Deliveries = append(resp.Deliveries, dto.DeliveryResponse{
+			Channel:       d.Channel,
+			Recipient:     d.Recipient,
+			Status:        d.Status,
+			Attempts:      d.Attempts,
+			LastError:     d.LastError,
+			NextAttemptAt: d.NextAttemptAt,
+			SentAt:        d.SentAt,
+			UpdatedAt:     d.UpdatedAt,
+		})
+	}
+	return out, nil
+}
+
+func toPreferencesResponse(p *domain.Preferences, isDefault bool) *dto.PreferencesResponse {
+	resp := &dto.PreferencesResponse{
+		UserID:     p.UserID,
+		Email:      p.Email,
+		WebhookURL: p.WebhookURL,
+		Channels:   p.Channels,
+		Events:     p.Events,
+		Default:    isDefault,
+	}
+	if !isDefault {
+		resp.UpdatedAt = &p.UpdatedAt
+	}
+	return resp
+}
@@ -0,0 +1,68 @@
+// Package templates renders notification subjects and bodies from one template file per event
+// type, named <event type>.tmpl, that defines a "subject" and a "body" template.
+package templates
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"strings"
+	"text/template"
+)
+
+// FS holds the built-in templates.
+//
+//go:embed *.tmpl
+var FS embed.FS
+
+// Renderer renders notifications with the templates of each event type.
+type Renderer struct {
+	byEvent map[string]*template.Template
+}
+
+// Load parses every *.tmpl file in fsys. Each must define "subject" and "body".
+func Load(fsys fs.FS) (*Renderer, error) {
+	files, err := fs.Glob(fsys, "*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	r := &Renderer{byEvent: map[string]*template.Template{}}
+	for _, name := range files {
+		t, err := template.ParseFS(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, def := range []string{"subject", "body"} {
+			if t.Lookup(def) == nil {
+				return nil, fmt.Errorf("%s: no %q template", name, def)
+			}
+		}
+		r.byEvent[strings.TrimSuffix(name, ".tmpl")] = t
+	}
+	return r, nil
+}
+
+// Has reports whether there is a template for eventType.
+func (r *Renderer) Has(eventType string) bool {
+	_, ok := r.byEvent[eventType]
+	return ok
+}
+
+// Render renders the subject and body of eventType with data.
+func (r *Renderer) Render(eventType string, data any) (subject, body string, err error) {
+	t, ok := r.byEvent[eventType]
+	if !ok {
+		return "", "", fmt.Errorf("no template for %s", eventType)
+	}
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "subject", data); err != nil {
+		return "", "", err
+	}
+	subject = strings.TrimSpace(buf.String())
+	buf.Reset()
+	if err := t.ExecuteTemplate(&buf, "body", data); err != nil {
+		return "", "", err
+	}
+	return subject, strings.TrimSpace(buf.String()), nil
+}
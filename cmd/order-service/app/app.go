@@ -0,0 +1,216 @@
+// Package app wires order-service: database, HTTP API, event producer, saga consumers, webhook
+// dispatcher and order scheduler.
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/recover"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go_example/internal/bus"
+	"go_example/internal/lifecycle"
+	"go_example/internal/logging"
+	"go_example/internal/metrics"
+	"go_example/internal/requestid"
+	"go_example/internal/tracing"
+	"go_example/cmd/order-service/config"
+	"go_example/cmd/order-service/handler"
+	"go_example/cmd/order-service/kafka"
+	"go_example/cmd/order-service/migrations"
+	"go_example/cmd/order-service/repository"
+	"go_example/cmd/order-service/schedule"
+	"go_example/cmd/order-service/service"
+	"go_example/cmd/order-service/webhook"
+)
+
+// Start starts order-service on b and returns once it is serving. Its HTTP server, consumers and
+// database pool are stopped by lc in shutdown order; b is owned and closed by the caller.
+func Start(cfg *config.Config, b bus.Bus, lc *lifecycle.Manager) error {
+	pool, err := tracing.OpenPool(context.Background(), cfg.DB.DSN())
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	lc.OnShutdown(lifecycle.PhaseStorage, "order-service db", func(context.Context) error {
+		pool.Close()
+		return nil
+	})
+
+	if err := runMigrations(pool); err != nil {
+		return fmt.Errorf("migrations: %w", err)
+	}
+
+	producer := kafka.NewProducer(b)
+
+	orderRepo, err := newOrderRepository(cfg.OrderStore, pool)
+	if err != nil {
+		return err
+	}
+	orderEventRepo := repository.NewOrderEventRepository(pool)
+	webhookRepo := repository.NewWebhookRepository(pool)
+	dispatcher := webhook.NewDispatcher(webhookRepo, cfg.Webhook)
+	webhookSvc := service.NewWebhookService(webhookRepo, dispatcher.Wake)
+	orderSvc := service.NewOrderService(orderRepo, orderEventRepo, producer, webhookSvc)
+	scheduleRepo := repository.NewScheduleRepository(pool)
+	scheduleSvc := service.NewScheduleService(scheduleRepo, cfg.Schedule.PauseAfter)
+	scheduler := schedule.NewScheduler(scheduleRepo, pool, orderSvc, cfg.Schedule)
+	owners := handler.Owners{AdminScope: cfg.AdminScope}
+	orderHandler := handler.NewOrderHandler(orderSvc, owners)
+	webhookHandler := handler.NewWebhookHandler(webhookSvc)
+	scheduleHandler := handler.NewScheduleHandler(scheduleSvc, owners)
+
+	consumer := kafka.NewConsumer(orderSvc, scheduleSvc, b, cfg.Consumer)
+
+	metrics.RegisterHTTPMetrics("order-service")
+	metrics.RegisterKafkaMetrics()
+
+	app := fiber.New()
+	app.Use(recover.New())
+	app.Use(requestid.Middleware())
+	app.Use(tracing.Middleware())
+	app.Use(logging.AccessLog())
+	app.Use(metrics.HTTPMiddleware())
+	app.Get("/metrics", metrics.MetricsHandler())
+	app.Get("/health", func(c fiber.Ctx) error { return c.JSON(fiber.Map{"status": "UP"}) })
+	app.Get("/ready", lc.ReadinessHandler())
+	app.Post("/orders", orderHandler.CreateOrder)
+	app.Get("/orders", orderHandler.ListByUserID)
+	app.Post("/orders/schedules", scheduleHandler.Create)
+	app.Get("/orders/schedules", scheduleHandler.ListByUserID)
+	app.Get("/orders/schedules/:id", scheduleHandler.Get)
+	app.Patch("/orders/schedules/:id", scheduleHandler.Update)
+	app.Delete("/orders/schedules/:id", scheduleHandler.Delete)
+	app.Get("/orders/:id/timeline", orderHandler.Timeline)
+	app.Get("/orders/:id", orderHandler.GetByID)
+	app.Delete("/orders/:id", orderHandler.CancelOrder)
+	app.Post("/webhooks", webhookHandler.Create)
+	app.Get("/webhooks", webhookHandler.List)
+	app.Get("/webhooks/:id", webhookHandler.Get)
+	app.Delete("/webhooks/:id", webhookHandler.Delete)
+	app.Post("/webhooks/:id/enable", webhookHandler.Enable)
+	app.Get("/webhooks/:id/deliveries", webhookHandler.Deliveries)
+	app.Post("/webhooks/:id/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
+
+	go func() {
+		if err := app.Listen(":"+cfg.ServerPort, fiber.ListenConfig{DisableStartupMessage: true}); err != nil && err != http.ErrServerClosed {
+			logging.Fatal("listen failed", logging.Err(err))
+		}
+	}()
+	lc.OnShutdown(lifecycle.PhaseHTTP, "order-service http", app.ShutdownWithContext)
+	if cfg.AdminPort != "" {
+		admin := logging.AdminApp()
+		go func() {
+			if err := admin.Listen(":"+cfg.AdminPort, fiber.ListenConfig{DisableStartupMessage: true}); err != nil && err != http.ErrServerClosed {
+				logging.Fatal("admin listen failed", logging.Err(err))
+			}
+		}()
+		lc.OnShutdown(lifecycle.PhaseHTTP, "order-service admin http", admin.ShutdownWithContext)
+	}
+
+	consumerCtx, stopConsumer := context.WithCancel(context.Background())
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		consumer.Run(consumerCtx)
+	}()
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		scheduler.Run(schedulerCtx)
+	}()
+	dispatchCtx, stopDispatcher := context.WithCancel(context.Background())
+	dispatchDone := make(chan struct{})
+	go func() {
+		defer close(dispatchDone)
+		dispatcher.Run(dispatchCtx)
+	}()
+	// Consumers and the scheduler stop first: their orders may still queue webhook deliveries.
+	lc.OnShutdown(lifecycle.PhaseConsumers, "order-service consumers", func(ctx context.Context) error {
+		stopConsumer()
+		stopScheduler()
+		if err := wait(ctx, consumerDone); err != nil {
+			return err
+		}
+		if err := wait(ctx, schedulerDone); err != nil {
+			return err
+		}
+		stopDispatcher()
+		return wait(ctx, dispatchDone)
+	})
+	return nil
+}
+
+func wait(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newOrderRepository returns the order store selected by cfg. The event-sourced store first
+// seeds streams for orders written while the table store was selected.
+func newOrderRepository(cfg config.OrderStoreConfig, pool *pgxpool.Pool) (service.OrderRepository, error) {
+	switch cfg.Kind {
+	case repository.StoreTable, "":
+		return repository.NewOrderRepository(pool), nil
+	case repository.StoreEvents:
+		repo := repository.NewEventSourcedOrderRepository(pool, cfg.SnapshotEvery)
+		n, err := repo.SeedStreams(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("seed order streams: %w", err)
+		}
+		if n > 0 {
+			slog.Info("seeded order streams from the orders table", "streams", n)
+		}
+		n, err = repo.ReconcileStreams(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("reconcile order streams: %w", err)
+		}
+		if n > 0 {
+			slog.Info("reconciled order streams with the orders table", "streams", n)
+		}
+		return repo, nil
+	default:
+		return nil, fmt.Errorf("unknown ORDER_STORE %q", cfg.Kind)
+	}
+}
+
+// advisoryLockID ensures only one instance runs migrations when multiple share the same DB.
+const advisoryLockID int64 = 0x6f72646572 // "order"
+
+func runMigrations(pool *pgxpool.Pool) error {
+	ctx := context.Background()
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	_, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID)
+	if err != nil {
+		return err
+	}
+	defer conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", advisoryLockID)
+	// Migrations are idempotent and applied in file name order.
+	files, err := fs.Glob(migrations.FS, "*.up.sql")
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		data, err := migrations.FS.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if _, err := conn.Exec(ctx, string(data)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -3,14 +3,33 @@ package config
 import (
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"go_example/internal/bus"
+	"go_example/internal/kafkaconn"
+	"go_example/internal/logging"
+	"go_example/internal/tracing"
 )
 
-// Config holds order-service configuration.
+// Config holds order-service configuration. AdminPort serves GET|PUT /admin/log-level, kept off
+// the public ServerPort; empty disables it.
 type Config struct {
-	ServerPort string
-	DB         DBConfig
-	Kafka      KafkaConfig
+	ServerPort    string
+	AdminPort     string
+	DB            DBConfig
+	Bus           string
+	Kafka         KafkaConfig
+	Consumer      ConsumerConfig
+	OrderStore    OrderStoreConfig
+	Webhook       WebhookConfig
+	Schedule      ScheduleConfig
+	AdminScope    string
+	Tracing       tracing.Config
+	Logging       logging.Config
+	ShutdownGrace time.Duration
+	ShutdownDrain time.Duration
 }
 
 // DBConfig holds PostgreSQL configuration.
@@ -22,15 +41,64 @@ type DBConfig struct {
 	Password string
 }
 
-// KafkaConfig holds Kafka broker configuration.
+// KafkaConfig holds Kafka connection (brokers, TLS, SASL) and topic configuration.
 type KafkaConfig struct {
-	Brokers []string
+	kafkaconn.Config
+	ReplicationFactor int
+}
+
+// ConsumerConfig holds per-topic consumer processing configuration.
+type ConsumerConfig struct {
+	Concurrency        map[string]int
+	DefaultConcurrency int
+	MaxInFlight        int
+}
+
+// Options returns the processing options for topic.
+func (c ConsumerConfig) Options(topic string) bus.Options {
+	n, ok := c.Concurrency[topic]
+	if !ok {
+		n = c.DefaultConcurrency
+	}
+	return bus.Options{Concurrency: n, MaxInFlight: c.MaxInFlight}
+}
+
+// OrderStoreConfig selects how orders are stored: "table" (orders table) or "events" (event
+// streams with snapshots every SnapshotEvery versions, the table kept as a projection).
+type OrderStoreConfig struct {
+	Kind          string
+	SnapshotEvery int
+}
+
+// WebhookConfig holds webhook delivery configuration. A failed delivery is retried after
+// Backoff, doubling per attempt up to MaxBackoff, until MaxAttempts; a webhook is disabled after
+// DisableAfter consecutive failed attempts. Lease is how long a claimed delivery stays claimed
+// and must exceed Timeout.
+type WebhookConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	Timeout      time.Duration
+	MaxAttempts  int
+	Backoff      time.Duration
+	MaxBackoff   time.Duration
+	DisableAfter int
+	Lease        time.Duration
+}
+
+// ScheduleConfig holds scheduled order configuration. The elected scheduler claims up to
+// BatchSize due schedules every PollInterval; a schedule is paused after PauseAfter runs in a
+// row whose credit reservation failed.
+type ScheduleConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	PauseAfter   int
 }
 
 // Load reads configuration from environment.
 func Load() *Config {
 	return &Config{
 		ServerPort: getEnv("SERVER_PORT", "8091"),
+		AdminPort:  getEnv("ADMIN_PORT", "9080"),
 		DB: DBConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
 			Port:     getEnv("DB_PORT", "5432"),
@@ -38,9 +106,40 @@ func Load() *Config {
 			User:     getEnv("DB_USER", "user"),
 			Password: getEnv("DB_PASSWORD", "password"),
 		},
+		Bus: getEnv("BUS", "kafka"),
 		Kafka: KafkaConfig{
-			Brokers: getEnvSlice("KAFKA_BOOTSTRAP_SERVERS", []string{"localhost:9092"}),
+			Config:            kafkaconn.FromEnv(getEnvSlice("KAFKA_BOOTSTRAP_SERVERS", []string{"localhost:9092"})),
+			ReplicationFactor: getEnvInt("KAFKA_TOPIC_REPLICATION_FACTOR", 1),
+		},
+		Consumer: ConsumerConfig{
+			Concurrency:        getEnvIntMap("CONSUMER_CONCURRENCY"),
+			DefaultConcurrency: getEnvInt("CONSUMER_DEFAULT_CONCURRENCY", 4),
+			MaxInFlight:        getEnvInt("CONSUMER_MAX_IN_FLIGHT", bus.DefaultMaxInFlight),
 		},
+		OrderStore: OrderStoreConfig{
+			Kind:          getEnv("ORDER_STORE", "table"),
+			SnapshotEvery: getEnvInt("ORDER_SNAPSHOT_EVERY", 3),
+		},
+		Webhook: WebhookConfig{
+			PollInterval: getEnvDuration("WEBHOOK_POLL_INTERVAL", time.Second),
+			BatchSize:    getEnvInt("WEBHOOK_BATCH_SIZE", 50),
+			Timeout:      getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+			MaxAttempts:  getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+			Backoff:      getEnvDuration("WEBHOOK_BACKOFF", 10*time.Second),
+			MaxBackoff:   getEnvDuration("WEBHOOK_MAX_BACKOFF", time.Hour),
+			DisableAfter: getEnvInt("WEBHOOK_DISABLE_AFTER", 20),
+			Lease:        getEnvDuration("WEBHOOK_LEASE", time.Minute),
+		},
+		Schedule: ScheduleConfig{
+			PollInterval: getEnvDuration("SCHEDULE_POLL_INTERVAL", 10*time.Second),
+			BatchSize:    getEnvInt("SCHEDULE_BATCH_SIZE", 50),
+			PauseAfter:   getEnvInt("SCHEDULE_PAUSE_AFTER", 3),
+		},
+		AdminScope:    getEnv("JWT_ADMIN_SCOPE", "admin"),
+		Tracing:       tracing.FromEnv(),
+		Logging:       logging.FromEnv(),
+		ShutdownGrace: getEnvDuration("SHUTDOWN_GRACE_PERIOD", 20*time.Second),
+		ShutdownDrain: getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
 	}
 }
 
@@ -51,6 +150,24 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getEnvInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
 func getEnvSlice(key string, fallback []string) []string {
 	if v := os.Getenv(key); v != "" {
 		parts := strings.Split(v, ",")
@@ -67,6 +184,21 @@ func getEnvSlice(key string, fallback []string) []string {
 	return fallback
 }
 
+// getEnvIntMap parses key=value pairs such as "order.created=8,order.canceled=2".
+func getEnvIntMap(key string) map[string]int {
+	out := map[string]int{}
+	for _, pair := range getEnvSlice(key, nil) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			out[strings.TrimSpace(k)] = n
+		}
+	}
+	return out
+}
+
 // DSN returns PostgreSQL connection string (password URL-escaped).
 func (c *DBConfig) DSN() string {
 	user := url.UserPassword(c.User, c.Password)
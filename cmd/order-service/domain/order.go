@@ -8,11 +8,13 @@ import (
 	"go_example/internal/events"
 )
 
-// Order represents an order entity.
+// Order represents an order entity. Version counts the changes applied to it and is used for
+// optimistic concurrency.
 type Order struct {
 	ID        uuid.UUID
 	UserID    uuid.UUID
 	Amount    int64
 	Status    events.OrderStatus
 	CreatedAt time.Time
+	Version   int64
 }
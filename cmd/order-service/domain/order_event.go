@@ -0,0 +1,21 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrderEvent is a saga event about an order, as read from its topic.
+type OrderEvent struct {
+	ID         int64
+	OrderID    uuid.UUID
+	Topic      string
+	Partition  int
+	Offset     int64
+	Service    string
+	Payload    json.RawMessage
+	EventTime  time.Time
+	RecordedAt time.Time
+}
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go_example/internal/events"
+)
+
+// StreamEventType is the type of an event in an order's event stream.
+type StreamEventType string
+
+const (
+	// StreamOrderCreated starts a stream; its data is OrderCreatedData.
+	StreamOrderCreated StreamEventType = "created"
+	// StreamOrderConfirmed marks credit reserved.
+	StreamOrderConfirmed StreamEventType = "confirmed"
+	// StreamOrderCanceled marks the order canceled.
+	StreamOrderCanceled StreamEventType = "canceled"
+	// StreamOrderRefunded follows the cancellation of a confirmed order whose credit is returned.
+	StreamOrderRefunded StreamEventType = "refunded"
+)
+
+// StreamEvent is one event in an order's event stream. Versions start at 1 and have no gaps.
+type StreamEvent struct {
+	OrderID    uuid.UUID
+	Version    int64
+	Type       StreamEventType
+	Data       json.RawMessage
+	OccurredAt time.Time
+}
+
+// OrderCreatedData is the data of a StreamOrderCreated event.
+type OrderCreatedData struct {
+	UserID uuid.UUID `json:"userId"`
+	Amount int64     `json:"amount"`
+}
+
+// Apply folds e into o.
+func (o *Order) Apply(e StreamEvent) error {
+	switch e.Type {
+	case StreamOrderCreated:
+		var d OrderCreatedData
+		if err := json.Unmarshal(e.Data, &d); err != nil {
+			return fmt.Errorf("order %s v%d: %w", e.OrderID, e.Version, err)
+		}
+		*o = Order{ID: e.OrderID, UserID: d.UserID, Amount: d.Amount, Status: events.OrderStatusPending, CreatedAt: e.OccurredAt}
+	case StreamOrderConfirmed:
+		o.Status = events.OrderStatusConfirmed
+	case StreamOrderCanceled, StreamOrderRefunded:
+		o.Status = events.OrderStatusCanceled
+	default:
+		return fmt.Errorf("order %s v%d: unknown event type %q", e.OrderID, e.Version, e.Type)
+	}
+	o.Version = e.Version
+	return nil
+}
+
+// StatusChange returns the events that move o to status; none if it already has it.
+func (o *Order) StatusChange(status events.OrderStatus) []StreamEventType {
+	if o.Status == status {
+		return nil
+	}
+	switch status {
+	case events.OrderStatusConfirmed:
+		return []StreamEventType{StreamOrderConfirmed}
+	case events.OrderStatusCanceled:
+		if o.Status == events.OrderStatusConfirmed {
+			return []StreamEventType{StreamOrderCanceled, StreamOrderRefunded}
+		}
+		return []StreamEventType{StreamOrderCanceled}
+	}
+	return nil
+}
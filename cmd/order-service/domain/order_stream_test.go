@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go_example/internal/events"
+)
+
+func TestApply(t *testing.T) {
+	id, user := uuid.New(), uuid.New()
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	data, err := json.Marshal(OrderCreatedData{UserID: user, Amount: 150})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := func(v int64, typ StreamEventType) StreamEvent {
+		e := StreamEvent{OrderID: id, Version: v, Type: typ, Data: json.RawMessage("{}"), OccurredAt: created.Add(time.Duration(v) * time.Minute)}
+		if typ == StreamOrderCreated {
+			e.Data, e.OccurredAt = data, created
+		}
+		return e
+	}
+	tests := []struct {
+		name    string
+		stream  []StreamEventType
+		want    events.OrderStatus
+		wantErr bool
+	}{
+		{"created", []StreamEventType{StreamOrderCreated}, events.OrderStatusPending, false},
+		{"confirmed", []StreamEventType{StreamOrderCreated, StreamOrderConfirmed}, events.OrderStatusConfirmed, false},
+		{"canceled", []StreamEventType{StreamOrderCreated, StreamOrderCanceled}, events.OrderStatusCanceled, false},
+		{"refunded", []StreamEventType{StreamOrderCreated, StreamOrderConfirmed, StreamOrderCanceled, StreamOrderRefunded}, events.OrderStatusCanceled, false},
+		{"unknown type", []StreamEventType{StreamOrderCreated, "shipped"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var o Order
+			for i, typ := range tt.stream {
+				if err := o.Apply(event(int64(i+1), typ)); err != nil {
+					if !tt.wantErr {
+						t.Fatalf("Apply v%d: %v", i+1, err)
+					}
+					return
+				}
+			}
+			if tt.wantErr {
+				t.Fatal("Apply succeeded, want an error")
+			}
+			want := Order{ID: id, UserID: user, Amount: 150, Status: tt.want, CreatedAt: created, Version: int64(len(tt.stream))}
+			if o != want {
+				t.Errorf("folded %+v, want %+v", o, want)
+			}
+		})
+	}
+
+	var o Order
+	if err := o.Apply(StreamEvent{OrderID: id, Version: 1, Type: StreamOrderCreated, Data: json.RawMessage(`{"amount":"x"}`)}); err == nil {
+		t.Error("Apply with malformed created data succeeded")
+	}
+}
+
+func TestStatusChange(t *testing.T) {
+	tests := []struct {
+		from, to events.OrderStatus
+		want     []StreamEventType
+	}{
+		{events.OrderStatusPending, events.OrderStatusPending, nil},
+		{events.OrderStatusPending, events.OrderStatusConfirmed, []StreamEventType{StreamOrderConfirmed}},
+		{events.OrderStatusPending, events.OrderStatusCanceled, []StreamEventType{StreamOrderCanceled}},
+		{events.OrderStatusConfirmed, events.OrderStatusCanceled, []StreamEventType{StreamOrderCanceled, StreamOrderRefunded}},
+		{events.OrderStatusCanceled, events.OrderStatusCanceled, nil},
+		{events.OrderStatusConfirmed, events.OrderStatusPending, nil},
+	}
+	for _, tt := range tests {
+		o := Order{Status: tt.from}
+		if got := o.StatusChange(tt.to); !slices.Equal(got, tt.want) {
+			t.Errorf("%s -> %s: %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
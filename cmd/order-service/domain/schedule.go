@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How a schedule repeats. A once schedule places one order at RunAt; daily, weekly and monthly
+// schedules repeat at the wall-clock time, weekday or day of month of RunAt in Timezone; a cron
+// schedule follows Cron in Timezone, not before RunAt if set.
+const (
+	RepeatOnce    = "once"
+	RepeatDaily   = "daily"
+	RepeatWeekly  = "weekly"
+	RepeatMonthly = "monthly"
+	RepeatCron    = "cron"
+)
+
+// Repeats lists every way a schedule can repeat.
+var Repeats = []string{RepeatOnce, RepeatDaily, RepeatWeekly, RepeatMonthly, RepeatCron}
+
+// Schedule statuses. Only active schedules place orders; a schedule is completed once it has no
+// further run.
+const (
+	ScheduleActive    = "active"
+	SchedulePaused    = "paused"
+	ScheduleCompleted = "completed"
+)
+
+// Schedule places orders for a user at future times. NextRunAt is set while the schedule is
+// active. ConsecutiveFailures counts runs in a row whose credit reservation failed; the schedule
+// is paused when it reaches the configured limit.
+type Schedule struct {
+	ID                  uuid.UUID
+	UserID              uuid.UUID
+	Amount              int64
+	Repeat              string
+	RunAt               *time.Time
+	Cron                string
+	Timezone            string
+	Status              string
+	NextRunAt           *time.Time
+	ConsecutiveFailures int
+	PausedAt            *time.Time
+	PausedReason        string
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+// Schedule run statuses. A run is pending until its order is created, then follows the
+// order's saga to confirmed or credit_failed.
+const (
+	RunPending      = "pending"
+	RunCreated      = "created"
+	RunConfirmed    = "confirmed"
+	RunCreditFailed = "credit_failed"
+)
+
+// ScheduleRun is one order placed by a schedule. OrderID is chosen when the run is claimed, so
+// a run retried after a failure or crash does not place a second order. Error is the last
+// failure to place it or the reason its credit reservation failed.
+type ScheduleRun struct {
+	ID           int64
+	ScheduleID   uuid.UUID
+	ScheduledFor time.Time
+	OrderID      uuid.UUID
+	UserID       uuid.UUID
+	Amount       int64
+	Status       string
+	Error        string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
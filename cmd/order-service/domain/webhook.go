@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go_example/internal/events"
+)
+
+// Webhook event types, one per order state change.
+const (
+	WebhookOrderCreated   = "order.created"
+	WebhookOrderConfirmed = "order.confirmed"
+	WebhookOrderCanceled  = "order.canceled"
+)
+
+// WebhookEventTypes lists every webhook event type.
+var WebhookEventTypes = []string{WebhookOrderCreated, WebhookOrderConfirmed, WebhookOrderCanceled}
+
+// WebhookEventType returns the event type of an order moving to status, or "" for none.
+func WebhookEventType(status events.OrderStatus) string {
+	switch status {
+	case events.OrderStatusPending:
+		return WebhookOrderCreated
+	case events.OrderStatusConfirmed:
+		return WebhookOrderConfirmed
+	case events.OrderStatusCanceled:
+		return WebhookOrderCanceled
+	default:
+		return ""
+	}
+}
+
+// Webhook delivery statuses. A delivery is retrying after a failed attempt that will be
+// repeated and failed once its attempts are exhausted.
+const (
+	DeliveryPending   = "pending"
+	DeliveryRetrying  = "retrying"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is a partner subscription to order state changes. An empty Events list means every
+// event type. A webhook is disabled after too many consecutive failed attempts.
+type Webhook struct {
+	ID                  uuid.UUID
+	URL                 string
+	Events              []string
+	Secret              string
+	Active              bool
+	ConsecutiveFailures int
+	DisabledAt          *time.Time
+	DisabledReason      string
+	CreatedAt           time.Time
+}
+
+// WebhookDelivery is one event to deliver to one webhook.
+type WebhookDelivery struct {
+	ID            int64
+	WebhookID     uuid.UUID
+	EventID       uuid.UUID
+	EventType     string
+	OrderID       uuid.UUID
+	Payload       json.RawMessage
+	Status        string
+	Attempts      int
+	NextAttemptAt *time.Time
+	LastError     string
+	DeliveredAt   *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	AttemptLog    []*WebhookAttempt
+}
+
+// WebhookAttempt is the outcome of one delivery attempt. StatusCode is nil if no response was received.
+type WebhookAttempt struct {
+	DeliveryID  int64
+	Attempt     int
+	StatusCode  *int
+	Error       string
+	Duration    time.Duration
+	AttemptedAt time.Time
+}
@@ -1,6 +1,7 @@
 package dto
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,10 +9,14 @@ import (
 	"go_example/internal/events"
 )
 
-// CreateOrderRequest is the request body for creating an order.
+// CreateOrderRequest is the request body for creating an order. OrderID is not part of the body:
+// the scheduler sets it so that placing a scheduled run again does not create a second order.
+// IdempotencyKey comes from the Idempotency-Key header and names the order the same way.
 type CreateOrderRequest struct {
-	UserID uuid.UUID `json:"userId"`
-	Amount int64     `json:"amount"`
+	UserID         uuid.UUID `json:"userId"`
+	Amount         int64     `json:"amount"`
+	OrderID        uuid.UUID `json:"-"`
+	IdempotencyKey string    `json:"-"`
 }
 
 // OrderResponse is the order API response.
@@ -22,3 +27,134 @@ type OrderResponse struct {
 	Status    events.OrderStatus `json:"status"`
 	CreatedAt time.Time       `json:"createdAt"`
 }
+
+// TimelineResponse is the saga history of an order. GET /orders/:id/timeline
+type TimelineResponse struct {
+	OrderID        uuid.UUID       `json:"orderId"`
+	Events         []TimelineEvent `json:"events"`
+	TotalElapsedMs int64           `json:"totalElapsedMs"`
+}
+
+// TimelineEvent is one step of an order's saga. SincePreviousMs is the time since the previous
+// step (0 for the first).
+type TimelineEvent struct {
+	Topic           string          `json:"topic"`
+	Service         string          `json:"service"`
+	Partition       int             `json:"partition"`
+	Offset          int64           `json:"offset"`
+	Time            time.Time       `json:"time"`
+	RecordedAt      time.Time       `json:"recordedAt"`
+	SincePreviousMs int64           `json:"sincePreviousMs"`
+	Payload         json.RawMessage `json:"payload"`
+}
+
+// CreateWebhookRequest is the request body for subscribing a webhook. POST /webhooks
+// An empty Events list subscribes to every event type; an empty Secret has one generated.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+// WebhookResponse is the webhook API response. Secret is only returned on creation.
+type WebhookResponse struct {
+	ID                  uuid.UUID  `json:"id"`
+	URL                 string     `json:"url"`
+	Events              []string   `json:"events"`
+	Secret              string     `json:"secret,omitempty"`
+	Active              bool       `json:"active"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	DisabledAt          *time.Time `json:"disabledAt,omitempty"`
+	DisabledReason      string     `json:"disabledReason,omitempty"`
+	CreatedAt           time.Time  `json:"createdAt"`
+}
+
+// WebhookDeliveryResponse is one delivery of an event to a webhook with its attempts.
+// GET /webhooks/:id/deliveries
+type WebhookDeliveryResponse struct {
+	ID            int64                    `json:"id"`
+	EventID       uuid.UUID                `json:"eventId"`
+	Event         string                   `json:"event"`
+	OrderID       uuid.UUID                `json:"orderId"`
+	Status        string                   `json:"status"`
+	Attempts      int                      `json:"attempts"`
+	NextAttemptAt *time.Time               `json:"nextAttemptAt,omitempty"`
+	LastError     string                   `json:"lastError,omitempty"`
+	DeliveredAt   *time.Time               `json:"deliveredAt,omitempty"`
+	CreatedAt     time.Time                `json:"createdAt"`
+	UpdatedAt     time.Time                `json:"updatedAt"`
+	Payload       json.RawMessage          `json:"payload"`
+	AttemptLog    []WebhookAttemptResponse `json:"attemptLog"`
+}
+
+// WebhookAttemptResponse is one delivery attempt. StatusCode is omitted if no response was received.
+type WebhookAttemptResponse struct {
+	Attempt     int       `json:"attempt"`
+	StatusCode  *int      `json:"statusCode,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int64     `json:"durationMs"`
+	AttemptedAt time.Time `json:"attemptedAt"`
+}
+
+// WebhookEvent is the body POSTed to a webhook when an order changes state. PreviousStatus is
+// empty for order.created.
+type WebhookEvent struct {
+	ID             uuid.UUID          `json:"id"`
+	Type           string             `json:"type"`
+	OccurredAt     time.Time          `json:"occurredAt"`
+	PreviousStatus events.OrderStatus `json:"previousStatus,omitempty"`
+	Data           *OrderResponse     `json:"data"`
+}
+
+// CreateScheduleRequest is the request body for scheduling orders. POST /orders/schedules
+// Repeat is once (the default), daily, weekly, monthly or cron. RunAt is the first run, required
+// unless Repeat is cron, where it is the earliest run. Timezone defaults to UTC.
+type CreateScheduleRequest struct {
+	UserID   uuid.UUID  `json:"userId"`
+	Amount   int64      `json:"amount"`
+	Repeat   string     `json:"repeat"`
+	RunAt    *time.Time `json:"runAt"`
+	Cron     string     `json:"cron"`
+	Timezone string     `json:"timezone"`
+}
+
+// UpdateScheduleRequest changes the given fields of a schedule. PATCH /orders/schedules/:id
+// Status pauses (paused) or resumes (active) it; resuming resets its failure count.
+type UpdateScheduleRequest struct {
+	Amount   *int64     `json:"amount"`
+	Repeat   *string    `json:"repeat"`
+	RunAt    *time.Time `json:"runAt"`
+	Cron     *string    `json:"cron"`
+	Timezone *string    `json:"timezone"`
+	Status   *string    `json:"status"`
+}
+
+// ScheduleResponse is the schedule API response. Runs, the latest runs first, are only
+// returned for a single schedule.
+type ScheduleResponse struct {
+	ID                  uuid.UUID             `json:"id"`
+	UserID              uuid.UUID             `json:"userId"`
+	Amount              int64                 `json:"amount"`
+	Repeat              string                `json:"repeat"`
+	RunAt               *time.Time            `json:"runAt,omitempty"`
+	Cron                string                `json:"cron,omitempty"`
+	Timezone            string                `json:"timezone"`
+	Status              string                `json:"status"`
+	NextRunAt           *time.Time            `json:"nextRunAt,omitempty"`
+	ConsecutiveFailures int                   `json:"consecutiveFailures"`
+	PausedAt            *time.Time            `json:"pausedAt,omitempty"`
+	PausedReason        string                `json:"pausedReason,omitempty"`
+	CreatedAt           time.Time             `json:"createdAt"`
+	UpdatedAt           time.Time             `json:"updatedAt"`
+	Runs                []ScheduleRunResponse `json:"runs,omitempty"`
+}
+
+// ScheduleRunResponse is one order placed by a schedule.
+type ScheduleRunResponse struct {
+	ScheduledFor time.Time `json:"scheduledFor"`
+	OrderID      uuid.UUID `json:"orderId"`
+	Status       string    `json:"status"`
+	Error        string    `json:"error,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"errors"
+
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
 
@@ -8,17 +10,27 @@ import (
 	"go_example/cmd/order-service/service"
 )
 
-// OrderHandler handles HTTP requests for orders.
+// headerIdempotencyKey names an order creation that may be repeated, by a client or by the
+// gateway's retries, without creating a second order.
+const headerIdempotencyKey = "Idempotency-Key"
+
+// maxIdempotencyKeyLen bounds the Idempotency-Key header.
+const maxIdempotencyKeyLen = 255
+
+// OrderHandler handles HTTP requests for orders. An order addressed by its ID that belongs to
+// another user than the caller is answered as not found.
 type OrderHandler struct {
-	svc *service.OrderService
+	svc    *service.OrderService
+	owners Owners
 }
 
 // NewOrderHandler creates a new OrderHandler.
-func NewOrderHandler(svc *service.OrderService) *OrderHandler {
-	return &OrderHandler{svc: svc}
+func NewOrderHandler(svc *service.OrderService, owners Owners) *OrderHandler {
+	return &OrderHandler{svc: svc, owners: owners}
 }
 
 // CreateOrder creates a new order (starts saga). POST /orders
+// A request repeated with the same Idempotency-Key header returns the order the first created.
 func (h *OrderHandler) CreateOrder(c fiber.Ctx) error {
 	var req dto.CreateOrderRequest
 	if err := c.Bind().Body(&req); err != nil {
@@ -27,7 +39,14 @@ func (h *OrderHandler) CreateOrder(c fiber.Ctx) error {
 	if req.Amount < 1 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "amount must be positive"})
 	}
+	req.IdempotencyKey = c.Get(headerIdempotencyKey)
+	if len(req.IdempotencyKey) > maxIdempotencyKeyLen {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "idempotency key too long"})
+	}
 	order, err := h.svc.CreateOrder(c.Context(), req)
+	if errors.Is(err, service.ErrIdempotencyKeyReused) {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -62,6 +81,9 @@ func (h *OrderHandler) GetByID(c fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid order id"})
 	}
 	order, err := h.svc.GetByID(c.Context(), id)
+	if err == nil && !h.owners.allows(c, order.UserID) {
+		err = service.ErrOrderNotFound
+	}
 	if err != nil {
 		if err == service.ErrOrderNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "order not found"})
@@ -71,6 +93,26 @@ func (h *OrderHandler) GetByID(c fiber.Ctx) error {
 	return c.JSON(order)
 }
 
+// Timeline returns the saga event history of an order. GET /orders/:id/timeline
+func (h *OrderHandler) Timeline(c fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid order id"})
+	}
+	var timeline *dto.TimelineResponse
+	err = h.checkOwner(c, id)
+	if err == nil {
+		timeline, err = h.svc.Timeline(c.Context(), id)
+	}
+	if err != nil {
+		if err == service.ErrOrderNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "order not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(timeline)
+}
+
 // CancelOrder cancels an order (triggers compensation). DELETE /orders/:id
 func (h *OrderHandler) CancelOrder(c fiber.Ctx) error {
 	idStr := c.Params("id")
@@ -78,7 +120,11 @@ func (h *OrderHandler) CancelOrder(c fiber.Ctx) error {
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid order id"})
 	}
-	if err := h.svc.CancelOrder(c.Context(), id); err != nil {
+	err = h.checkOwner(c, id)
+	if err == nil {
+		err = h.svc.CancelOrder(c.Context(), id)
+	}
+	if err != nil {
 		if err == service.ErrOrderNotFound {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "order not found"})
 		}
@@ -86,3 +132,18 @@ func (h *OrderHandler) CancelOrder(c fiber.Ctx) error {
 	}
 	return c.SendStatus(fiber.StatusNoContent)
 }
+
+// checkOwner returns service.ErrOrderNotFound if the caller of c may not access order id.
+func (h *OrderHandler) checkOwner(c fiber.Ctx, id uuid.UUID) error {
+	if !h.owners.restricted(c) {
+		return nil
+	}
+	order, err := h.svc.GetByID(c.Context(), id)
+	if err != nil {
+		return err
+	}
+	if !h.owners.allows(c, order.UserID) {
+		return service.ErrOrderNotFound
+	}
+	return nil
+}
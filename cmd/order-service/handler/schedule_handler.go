@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"go_example/cmd/order-service/dto"
+	"go_example/cmd/order-service/service"
+)
+
+// ScheduleHandler handles HTTP requests for scheduled and recurring orders. A schedule addressed
+// by its ID that belongs to another user than the caller is answered as not found.
+type ScheduleHandler struct {
+	svc    *service.ScheduleService
+	owners Owners
+}
+
+// NewScheduleHandler creates a new ScheduleHandler.
+func NewScheduleHandler(svc *service.ScheduleService, owners Owners) *ScheduleHandler {
+	return &ScheduleHandler{svc: svc, owners: owners}
+}
+
+// Create schedules orders. POST /orders/schedules
+func (h *ScheduleHandler) Create(c fiber.Ctx) error {
+	var req dto.CreateScheduleRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	s, err := h.svc.Create(c.Context(), req)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(s)
+}
+
+// ListByUserID returns the schedules of a user. GET /orders/schedules?userId=xxx
+func (h *ScheduleHandler) ListByUserID(c fiber.Ctx) error {
+	userIDStr := c.Query("userId")
+	if userIDStr == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "userId query parameter is required"})
+	}
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid userId"})
+	}
+	list, err := h.svc.ListByUserID(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
+
+// Get returns a schedule with its latest runs. GET /orders/schedules/:id
+func (h *ScheduleHandler) Get(c fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid schedule id"})
+	}
+	s, err := h.svc.Get(c.Context(), id)
+	if err == nil && !h.owners.allows(c, s.UserID) {
+		err = service.ErrScheduleNotFound
+	}
+	if err != nil {
+		return scheduleError(c, err)
+	}
+	return c.JSON(s)
+}
+
+// Update changes, pauses or resumes a schedule. PATCH /orders/schedules/:id
+func (h *ScheduleHandler) Update(c fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid schedule id"})
+	}
+	var req dto.UpdateScheduleRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	if err := h.checkOwner(c, id); err != nil {
+		return scheduleError(c, err)
+	}
+	s, err := h.svc.Update(c.Context(), id, req)
+	if err != nil {
+		return scheduleError(c, err)
+	}
+	return c.JSON(s)
+}
+
+// Delete removes a schedule. DELETE /orders/schedules/:id
+func (h *ScheduleHandler) Delete(c fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid schedule id"})
+	}
+	if err := h.checkOwner(c, id); err != nil {
+		return scheduleError(c, err)
+	}
+	if err := h.svc.Delete(c.Context(), id); err != nil {
+		return scheduleError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// checkOwner returns service.ErrScheduleNotFound if the caller of c may not access schedule id.
+func (h *ScheduleHandler) checkOwner(c fiber.Ctx, id uuid.UUID) error {
+	if !h.owners.restricted(c) {
+		return nil
+	}
+	s, err := h.svc.Get(c.Context(), id)
+	if err != nil {
+		return err
+	}
+	if !h.owners.allows(c, s.UserID) {
+		return service.ErrScheduleNotFound
+	}
+	return nil
+}
+
+func scheduleError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrScheduleNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidSchedule):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
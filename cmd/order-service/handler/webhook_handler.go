@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"go_example/cmd/order-service/dto"
+	"go_example/cmd/order-service/service"
+)
+
+// WebhookHandler handles HTTP requests for webhook subscriptions and their deliveries.
+type WebhookHandler struct {
+	svc *service.WebhookService
+}
+
+// NewWebhookHandler creates a new WebhookHandler.
+func NewWebhookHandler(svc *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{svc: svc}
+}
+
+// Create subscribes a webhook. The response carries the signing secret, shown only once. POST /webhooks
+func (h *WebhookHandler) Create(c fiber.Ctx) error {
+	var req dto.CreateWebhookRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	w, err := h.svc.Create(c.Context(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWebhook) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(w)
+}
+
+// List returns all webhooks. GET /webhooks
+func (h *WebhookHandler) List(c fiber.Ctx) error {
+	list, err := h.svc.List(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(list)
+}
+
+// Get returns a webhook. GET /webhooks/:id
+func (h *WebhookHandler) Get(c fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid webhook id"})
+	}
+	w, err := h.svc.Get(c.Context(), id)
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(w)
+}
+
+// Delete unsubscribes a webhook. DELETE /webhooks/:id
+func (h *WebhookHandler) Delete(c fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid webhook id"})
+	}
+	if err := h.svc.Delete(c.Context(), id); err != nil {
+		return webhookError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Enable re-enables a webhook disabled after repeated failures. POST /webhooks/:id/enable
+func (h *WebhookHandler) Enable(c fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid webhook id"})
+	}
+	w, err := h.svc.Enable(c.Context(), id)
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(w)
+}
+
+// Deliveries returns the delivery log of a webhook. GET /webhooks/:id/deliveries
+func (h *WebhookHandler) Deliveries(c fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid webhook id"})
+	}
+	list, err := h.svc.Deliveries(c.Context(), id)
+	if err != nil {
+		return webhookError(c, err)
+	}
+	return c.JSON(list)
+}
+
+// Redeliver sends a delivery again. POST /webhooks/:id/deliveries/:deliveryId/redeliver
+func (h *WebhookHandler) Redeliver(c fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid webhook id"})
+	}
+	deliveryID, err := strconv.ParseInt(c.Params("deliveryId"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid delivery id"})
+	}
+	if err := h.svc.Redeliver(c.Context(), id, deliveryID); err != nil {
+		return webhookError(c, err)
+	}
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+func webhookError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrWebhookNotFound), errors.Is(err, service.ErrDeliveryNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, service.ErrWebhookDisabled):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
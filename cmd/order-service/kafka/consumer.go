@@ -3,107 +3,152 @@ package kafka
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
 	"sync"
+	"time"
 
-	"github.com/segmentio/kafka-go"
+	"github.com/google/uuid"
 
+	"go_example/internal/bus"
 	"go_example/internal/events"
+	"go_example/internal/logging"
+	"go_example/cmd/order-service/config"
+	"go_example/cmd/order-service/domain"
 	"go_example/cmd/order-service/service"
 )
 
-// Consumer runs Kafka consumers for order-service (credit-reserved, credit-reservation-failed topics).
+// Group is the consumer group order-service subscribes with.
+const Group = "order-service-group"
+
+// EventStoreGroup is the consumer group that records every saga event in the order event store.
+const EventStoreGroup = "order-service-event-store"
+
+// Consumer runs consumers for order-service (credit-reservation-failed, payment.succeeded,
+// payment.failed topics) and records all saga topics in the order event store.
 type Consumer struct {
-	orderSvc *service.OrderService
-	brokers  []string
+	orderSvc    *service.OrderService
+	scheduleSvc *service.ScheduleService
+	sub         bus.Subscriber
+	cfg         config.ConsumerConfig
 }
 
-// NewConsumer creates a new Consumer.
-func NewConsumer(orderSvc *service.OrderService, brokers []string) *Consumer {
-	return &Consumer{orderSvc: orderSvc, brokers: brokers}
+// NewConsumer creates a new Consumer. Outcomes of scheduled orders are reported to scheduleSvc.
+func NewConsumer(orderSvc *service.OrderService, scheduleSvc *service.ScheduleService, sub bus.Subscriber, cfg config.ConsumerConfig) *Consumer {
+	return &Consumer{orderSvc: orderSvc, scheduleSvc: scheduleSvc, sub: sub, cfg: cfg}
 }
 
-// Run starts consuming credit events.
+// Run starts consuming credit and payment outcomes and recording saga events, and blocks until ctx is canceled.
 func (c *Consumer) Run(ctx context.Context) {
 	var wg sync.WaitGroup
-	wg.Add(2)
+	for _, topic := range events.SagaTopics {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			opts := c.cfg.Options(topic)
+			opts.RetryForever = true
+			if err := c.sub.Subscribe(ctx, topic, EventStoreGroup, c.recordEvent, opts); err != nil {
+				slog.Error("event store subscribe failed", logging.KeyTopic, topic, logging.Err(err))
+			}
+		}()
+	}
+	wg.Add(3)
 	go func() {
 		defer wg.Done()
-		c.consumeCreditReserved(ctx)
+		c.subscribe(ctx, events.TopicUserCreditReservationFailed, c.handleCreditReservationFailed)
 	}()
 	go func() {
 		defer wg.Done()
-		c.consumeCreditReservationFailed(ctx)
+		c.subscribe(ctx, events.TopicPaymentSucceeded, c.handlePaymentSucceeded)
+	}()
+	go func() {
+		defer wg.Done()
+		c.subscribe(ctx, events.TopicPaymentFailed, c.handlePaymentFailed)
 	}()
 	wg.Wait()
 }
 
-func (c *Consumer) consumeCreditReserved(ctx context.Context) {
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  c.brokers,
-		Topic:    "user.credit-reserved",
-		GroupID:  "order-service-group",
-		MinBytes: 1,
-		MaxBytes: 10e6,
-	})
-	defer r.Close()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			msg, err := r.ReadMessage(ctx)
-			if err != nil {
-				if ctx.Err() != nil {
-					return
-				}
-				log.Printf("[order-service] user.credit-reserved read error: %v", err)
-				continue
-			}
-			var evt events.UserCreditReservedEvent
-			if err := json.Unmarshal(msg.Value, &evt); err != nil {
-				log.Printf("[order-service] user.credit-reserved unmarshal error: %v", err)
-				continue
-			}
-			log.Printf("[order-service] Received UserCreditReservedEvent: orderId=%s", evt.OrderID)
-			if err := c.orderSvc.ConfirmOrder(ctx, evt.OrderID); err != nil {
-				log.Printf("[order-service] confirm order error: %v", err)
-			}
-		}
+func (c *Consumer) subscribe(ctx context.Context, topic string, h bus.Handler) {
+	if err := c.sub.Subscribe(ctx, topic, Group, h, c.cfg.Options(topic)); err != nil {
+		slog.Error("subscribe failed", logging.KeyTopic, topic, logging.Err(err))
 	}
 }
 
-func (c *Consumer) consumeCreditReservationFailed(ctx context.Context) {
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  c.brokers,
-		Topic:    "user.credit-reservation-failed",
-		GroupID:  "order-service-group",
-		MinBytes: 1,
-		MaxBytes: 10e6,
-	})
-	defer r.Close()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			msg, err := r.ReadMessage(ctx)
-			if err != nil {
-				if ctx.Err() != nil {
-					return
-				}
-				log.Printf("[order-service] user.credit-reservation-failed read error: %v", err)
-				continue
-			}
-			var evt events.UserCreditReservationFailedEvent
-			if err := json.Unmarshal(msg.Value, &evt); err != nil {
-				log.Printf("[order-service] user.credit-reservation-failed unmarshal error: %v", err)
-				continue
-			}
-			log.Printf("[order-service] Received UserCreditReservationFailedEvent: orderId=%s reason=%s", evt.OrderID, evt.Reason)
-			if err := c.orderSvc.CancelOrder(ctx, evt.OrderID); err != nil {
-				log.Printf("[order-service] cancel order error: %v", err)
-			}
-		}
+func (c *Consumer) handleCreditReservationFailed(ctx context.Context, msg bus.Message) error {
+	var evt events.UserCreditReservationFailedEvent
+	if err := json.Unmarshal(msg.Value, &evt); err != nil {
+		slog.ErrorContext(ctx, "unmarshal failed", logging.Err(err))
+		return bus.Permanent(err)
+	}
+	ctx = logging.With(ctx, logging.OrderID(evt.OrderID), logging.UserID(evt.UserID))
+	slog.InfoContext(ctx, "received UserCreditReservationFailedEvent", "reason", evt.Reason)
+	if err := c.orderSvc.CancelOrder(ctx, evt.OrderID); err != nil {
+		slog.ErrorContext(ctx, "cancel order failed", logging.Err(err))
+		return err
 	}
+	if err := c.scheduleSvc.CreditFailed(ctx, evt.OrderID, evt.Reason); err != nil {
+		slog.ErrorContext(ctx, "record schedule credit failure failed", logging.Err(err))
+		return err
+	}
+	return nil
+}
+
+func (c *Consumer) handlePaymentSucceeded(ctx context.Context, msg bus.Message) error {
+	var evt events.PaymentSucceededEvent
+	if err := json.Unmarshal(msg.Value, &evt); err != nil {
+		slog.ErrorContext(ctx, "unmarshal failed", logging.Err(err))
+		return bus.Permanent(err)
+	}
+	ctx = logging.With(ctx, logging.OrderID(evt.OrderID), logging.UserID(evt.UserID))
+	slog.InfoContext(ctx, "received PaymentSucceededEvent", "payment_id", evt.PaymentID.String())
+	if err := c.orderSvc.ConfirmOrder(ctx, evt.OrderID); err != nil {
+		slog.ErrorContext(ctx, "confirm order failed", logging.Err(err))
+		return err
+	}
+	if err := c.scheduleSvc.OrderConfirmed(ctx, evt.OrderID); err != nil {
+		slog.ErrorContext(ctx, "record schedule confirmation failed", logging.Err(err))
+		return err
+	}
+	return nil
+}
+
+func (c *Consumer) handlePaymentFailed(ctx context.Context, msg bus.Message) error {
+	var evt events.PaymentFailedEvent
+	if err := json.Unmarshal(msg.Value, &evt); err != nil {
+		slog.ErrorContext(ctx, "unmarshal failed", logging.Err(err))
+		return bus.Permanent(err)
+	}
+	ctx = logging.With(ctx, logging.OrderID(evt.OrderID), logging.UserID(evt.UserID))
+	slog.InfoContext(ctx, "received PaymentFailedEvent", "reason", evt.Reason)
+	if err := c.orderSvc.CancelOrder(ctx, evt.OrderID); err != nil {
+		slog.ErrorContext(ctx, "cancel order failed", logging.Err(err))
+		return err
+	}
+	return nil
+}
+
+// recordEvent appends a saga event to the order event store. The history is a projection, so
+// it retries until the store is available instead of dead-lettering into the saga DLQs.
+func (c *Consumer) recordEvent(ctx context.Context, msg bus.Message) error {
+	var env events.Envelope
+	if err := json.Unmarshal(msg.Value, &env); err != nil {
+		return bus.Skip(err)
+	}
+	if env.OrderID == uuid.Nil {
+		return bus.Skip(fmt.Errorf("%s: no order ID in payload", msg.Topic))
+	}
+	eventTime := msg.Time
+	if eventTime.IsZero() {
+		eventTime = time.Now()
+	}
+	return c.orderSvc.RecordEvent(ctx, &domain.OrderEvent{
+		OrderID:    env.OrderID,
+		Topic:      msg.Topic,
+		Partition:  msg.Partition,
+		Offset:     msg.Offset,
+		Service:    events.ProducerOf(msg.Topic),
+		Payload:    msg.Value,
+		EventTime:  eventTime,
+		RecordedAt: time.Now(),
+	})
 }
@@ -4,29 +4,18 @@ import (
 	"context"
 	"encoding/json"
 
-	"github.com/segmentio/kafka-go"
-
+	"go_example/internal/bus"
 	"go_example/internal/events"
 )
 
-// Producer publishes order events to Kafka.
+// Producer publishes order events to the bus, keyed by order ID.
 type Producer struct {
-	writer *kafka.Writer
+	pub bus.Publisher
 }
 
 // NewProducer creates a new Producer.
-func NewProducer(brokers []string) *Producer {
-	return &Producer{
-		writer: &kafka.Writer{
-			Addr:     kafka.TCP(brokers[0]),
-			Balancer: &kafka.LeastBytes{},
-		},
-	}
-}
-
-// Close closes the producer.
-func (p *Producer) Close() error {
-	return p.writer.Close()
+func NewProducer(pub bus.Publisher) *Producer {
+	return &Producer{pub: pub}
 }
 
 // PublishOrderCreated publishes OrderCreatedEvent to order.created.
@@ -35,7 +24,7 @@ func (p *Producer) PublishOrderCreated(ctx context.Context, evt events.OrderCrea
 	if err != nil {
 		return err
 	}
-	return p.writer.WriteMessages(ctx, kafka.Message{Topic: "order.created", Value: body})
+	return p.pub.Publish(ctx, bus.Message{Topic: events.TopicOrderCreated, Key: []byte(evt.OrderID.String()), Value: body})
 }
 
 // PublishOrderCanceled publishes OrderCanceledEvent to order.canceled.
@@ -44,5 +33,5 @@ func (p *Producer) PublishOrderCanceled(ctx context.Context, evt events.OrderCan
 	if err != nil {
 		return err
 	}
-	return p.writer.WriteMessages(ctx, kafka.Message{Topic: "order.canceled", Value: body})
+	return p.pub.Publish(ctx, bus.Message{Topic: events.TopicOrderCanceled, Key: []byte(evt.OrderID.String()), Value: body})
 }
@@ -0,0 +1,51 @@
+package kafka
+
+import (
+	"time"
+
+	"go_example/internal/bus"
+	"go_example/internal/events"
+	"go_example/internal/topics"
+)
+
+// Topics declares the topics order-service owns: the ones it publishes to and the
+// dead-letter topics of the ones it consumes.
+func Topics(replicationFactor int) []topics.Spec {
+	return []topics.Spec{
+		{
+			Name:              events.TopicOrderCreated,
+			Partitions:        3,
+			ReplicationFactor: replicationFactor,
+			Retention:         7 * 24 * time.Hour,
+			CleanupPolicy:     topics.CleanupDelete,
+		},
+		{
+			Name:              events.TopicOrderCanceled,
+			Partitions:        3,
+			ReplicationFactor: replicationFactor,
+			Retention:         7 * 24 * time.Hour,
+			CleanupPolicy:     topics.CleanupDelete,
+		},
+		{
+			Name:              bus.DeadLetterTopic(events.TopicUserCreditReservationFailed),
+			Partitions:        1,
+			ReplicationFactor: replicationFactor,
+			Retention:         30 * 24 * time.Hour,
+			CleanupPolicy:     topics.CleanupDelete,
+		},
+		{
+			Name:              bus.DeadLetterTopic(events.TopicPaymentSucceeded),
+			Partitions:        1,
+			ReplicationFactor: replicationFactor,
+			Retention:         30 * 24 * time.Hour,
+			CleanupPolicy:     topics.CleanupDelete,
+		},
+		{
+			Name:              bus.DeadLetterTopic(events.TopicPaymentFailed),
+			Partitions:        1,
+			ReplicationFactor: replicationFactor,
+			Retention:         30 * 24 * time.Hour,
+			CleanupPolicy:     topics.CleanupDelete,
+		},
+	}
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS order_events (
+    id BIGSERIAL PRIMARY KEY,
+    order_id UUID NOT NULL,
+    topic VARCHAR(255) NOT NULL,
+    partition INT NOT NULL,
+    message_offset BIGINT NOT NULL,
+    service VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    event_time TIMESTAMP NOT NULL,
+    recorded_at TIMESTAMP NOT NULL,
+    UNIQUE (topic, partition, message_offset)
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_events_order_id ON order_events(order_id, event_time);
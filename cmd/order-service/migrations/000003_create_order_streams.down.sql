@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS order_snapshots;
+DROP TABLE IF EXISTS order_stream_events;
+ALTER TABLE orders DROP COLUMN IF EXISTS version;
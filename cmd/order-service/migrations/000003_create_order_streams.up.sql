@@ -0,0 +1,17 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS order_stream_events (
+    order_id UUID NOT NULL,
+    version BIGINT NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    data JSONB NOT NULL,
+    occurred_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (order_id, version)
+);
+
+CREATE TABLE IF NOT EXISTS order_snapshots (
+    order_id UUID PRIMARY KEY,
+    version BIGINT NOT NULL,
+    state JSONB NOT NULL,
+    taken_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_order_schedule_runs_pending;
+DROP TABLE IF EXISTS order_schedule_runs;
+DROP INDEX IF EXISTS idx_order_schedules_due;
+DROP INDEX IF EXISTS idx_order_schedules_user_id;
+DROP TABLE IF EXISTS order_schedules;
@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS order_schedules (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    amount BIGINT NOT NULL,
+    repeat VARCHAR(20) NOT NULL,
+    run_at TIMESTAMP,
+    cron TEXT NOT NULL DEFAULT '',
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    status VARCHAR(20) NOT NULL,
+    next_run_at TIMESTAMP,
+    consecutive_failures INT NOT NULL DEFAULT 0,
+    paused_at TIMESTAMP,
+    paused_reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_schedules_user_id ON order_schedules(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_order_schedules_due ON order_schedules(next_run_at) WHERE status = 'active';
+
+CREATE TABLE IF NOT EXISTS order_schedule_runs (
+    id BIGSERIAL PRIMARY KEY,
+    schedule_id UUID NOT NULL REFERENCES order_schedules(id) ON DELETE CASCADE,
+    scheduled_for TIMESTAMP NOT NULL,
+    order_id UUID NOT NULL UNIQUE,
+    user_id UUID NOT NULL,
+    amount BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    UNIQUE (schedule_id, scheduled_for)
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_schedule_runs_pending ON order_schedule_runs(created_at) WHERE status = 'pending';
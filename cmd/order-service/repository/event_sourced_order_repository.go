@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go_example/internal/events"
+	"go_example/cmd/order-service/domain"
+)
+
+// uniqueViolation is the PostgreSQL error code for a duplicate key.
+const uniqueViolation = "23505"
+
+// seedLockID serializes SeedStreams across instances.
+const seedLockID int64 = 0x73747265616d // "stream"
+
+// EventSourcedOrderRepository stores each order as a stream of events in order_stream_events
+// and rebuilds it by folding them onto the latest snapshot. Appends carry the expected stream
+// version, so concurrent writers conflict instead of overwriting each other. The orders table
+// is kept as a projection in the same transaction and serves list queries.
+type EventSourcedOrderRepository struct {
+	pool          *pgxpool.Pool
+	table         *OrderRepository
+	snapshotEvery int64
+}
+
+// NewEventSourcedOrderRepository creates a new EventSourcedOrderRepository that snapshots an
+// order every snapshotEvery versions.
+func NewEventSourcedOrderRepository(pool *pgxpool.Pool, snapshotEvery int) *EventSourcedOrderRepository {
+	if snapshotEvery < 1 {
+		snapshotEvery = 1
+	}
+	return &EventSourcedOrderRepository{pool: pool, table: NewOrderRepository(pool), snapshotEvery: int64(snapshotEvery)}
+}
+
+// orderSnapshot is the stored form of a snapshot.
+type orderSnapshot struct {
+	UserID    uuid.UUID          `json:"userId"`
+	Amount    int64              `json:"amount"`
+	Status    events.OrderStatus `json:"status"`
+	CreatedAt time.Time          `json:"createdAt"`
+}
+
+// Create starts the stream of o with a created event, inserts its projection and calls changed
+// in the same transaction.
+func (r *EventSourcedOrderRepository) Create(ctx context.Context, o *domain.Order, changed OrderChangeFunc) error {
+	data, err := json.Marshal(domain.OrderCreatedData{UserID: o.UserID, Amount: o.Amount})
+	if err != nil {
+		return err
+	}
+	created := domain.StreamEvent{OrderID: o.ID, Version: 1, Type: domain.StreamOrderCreated, Data: data, OccurredAt: o.CreatedAt}
+	return pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		if err := appendEvents(ctx, tx, created); err != nil {
+			return err
+		}
+		query := `INSERT INTO orders (id, user_id, amount, status, created_at, version) VALUES ($1, $2, $3, $4, $5, $6)`
+		if _, err := tx.Exec(ctx, query, o.ID, o.UserID, o.Amount, string(events.OrderStatusPending), o.CreatedAt, created.Version); err != nil {
+			return err
+		}
+		o.Status, o.Version = events.OrderStatusPending, created.Version
+		return changed(ctx, tx, o, "")
+	})
+}
+
+// GetByID rebuilds an order from its latest snapshot and the events after it.
+// Returns pgx.ErrNoRows if the order has no stream.
+func (r *EventSourcedOrderRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Order, error) {
+	return loadStream(ctx, r.pool, id)
+}
+
+// querier is implemented by *pgxpool.Pool and pgx.Tx.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// loadStream folds the stream of order id onto its latest snapshot.
+func loadStream(ctx context.Context, db querier, id uuid.UUID) (*domain.Order, error) {
+	var o domain.Order
+	var state []byte
+	err := db.QueryRow(ctx, `SELECT version, state FROM order_snapshots WHERE order_id = $1`, id).Scan(&o.Version, &state)
+	switch {
+	case err == nil:
+		var snap orderSnapshot
+		if err := json.Unmarshal(state, &snap); err != nil {
+			return nil, err
+		}
+		o.ID, o.UserID, o.Amount, o.Status, o.CreatedAt = id, snap.UserID, snap.Amount, snap.Status, snap.CreatedAt
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, err
+	}
+
+	query := `SELECT version, type, data, occurred_at FROM order_stream_events WHERE order_id = $1 AND version > $2 ORDER BY version`
+	rows, err := db.Query(ctx, query, id, o.Version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := domain.StreamEvent{OrderID: id}
+		var typ string
+		var data []byte
+		if err := rows.Scan(&e.Version, &typ, &data, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		e.Type, e.Data = domain.StreamEventType(typ), data
+		if err := o.Apply(e); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if o.Version == 0 {
+		return nil, pgx.ErrNoRows
+	}
+	return &o, nil
+}
+
+// UpdateStatus appends the events that move o to status, expecting the stream to still be at
+// o.Version, updates the projection, takes a snapshot when a snapshot interval is crossed and
+// calls changed, all in one transaction. Returns ErrVersionConflict if another writer appended
+// first.
+func (r *EventSourcedOrderRepository) UpdateStatus(ctx context.Context, o *domain.Order, status events.OrderStatus, changed OrderChangeFunc) error {
+	next := *o
+	appended, err := moveTo(&next, status, time.Now())
+	if err != nil || len(appended) == 0 {
+		return err
+	}
+	err = pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		if err := appendEvents(ctx, tx, appended...); err != nil {
+			return err
+		}
+		query := `UPDATE orders SET status = $1, version = $2 WHERE id = $3`
+		if _, err := tx.Exec(ctx, query, string(next.Status), next.Version, next.ID); err != nil {
+			return err
+		}
+		if r.snapshotDue(o.Version, next.Version) {
+			if err := saveSnapshot(ctx, tx, &next); err != nil {
+				return err
+			}
+		}
+		return changed(ctx, tx, &next, o.Status)
+	})
+	if err != nil {
+		return err
+	}
+	*o = next
+	return nil
+}
+
+// ListByUserID returns all orders for a user from the projection.
+func (r *EventSourcedOrderRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*domain.Order, error) {
+	return r.table.ListByUserID(ctx, userID)
+}
+
+// SeedStreams creates streams for orders that exist only in the table (written while the
+// table store was selected): a created event plus one event for a CONFIRMED or CANCELED status.
+// The projection version is aligned with the new streams. Returns how many orders were seeded.
+func (r *EventSourcedOrderRepository) SeedStreams(ctx context.Context) (int64, error) {
+	var n int64
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		// Instances starting together seed one at a time.
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, seedLockID); err != nil {
+			return err
+		}
+		query := `WITH seeded AS (
+			INSERT INTO order_stream_events (order_id, version, type, data, occurred_at)
+			SELECT o.id, 1, $1, jsonb_build_object('userId', o.user_id, 'amount', o.amount), o.created_at
+			FROM orders o
+			WHERE NOT EXISTS (SELECT 1 FROM order_stream_events e WHERE e.order_id = o.id)
+			RETURNING order_id
+		), status_events AS (
+			INSERT INTO order_stream_events (order_id, version, type, data, occurred_at)
+			SELECT o.id, 2, CASE o.status WHEN $2 THEN $3 ELSE $4 END, '{}', now()
+			FROM orders o JOIN seeded s ON s.order_id = o.id
+			WHERE o.status IN ($2, $5)
+			RETURNING order_id
+		)
+		UPDATE orders o SET version = CASE WHEN EXISTS (SELECT 1 FROM status_events se WHERE se.order_id = o.id) THEN 2 ELSE 1 END
+		FROM seeded s WHERE s.order_id = o.id`
+		tag, err := tx.Exec(ctx, query,
+			string(domain.StreamOrderCreated),
+			string(events.OrderStatusConfirmed), string(domain.StreamOrderConfirmed), string(domain.StreamOrderCanceled),
+			string(events.OrderStatusCanceled))
+		if err != nil {
+			return err
+		}
+		n = tag.RowsAffected()
+		return nil
+	})
+	return n, err
+}
+
+// ReconcileStreams brings streams in line with orders changed in the table only (while the table
+// store was selected after the streams were written): the projection's version is ahead of its
+// stream. Events moving the stream to the table's status are appended and the projection
+// version aligned. Returns how many orders were reconciled.
+func (r *EventSourcedOrderRepository) ReconcileStreams(ctx context.Context) (int64, error) {
+	var n int64
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, seedLockID); err != nil {
+			return err
+		}
+		query := `SELECT o.id, o.status FROM orders o
+			JOIN (SELECT order_id, max(version) AS version FROM order_stream_events GROUP BY order_id) e ON e.order_id = o.id
+			WHERE o.version <> e.version`
+		rows, err := tx.Query(ctx, query)
+		if err != nil {
+			return err
+		}
+		diverged := map[uuid.UUID]events.OrderStatus{}
+		for rows.Next() {
+			var id uuid.UUID
+			var status string
+			if err := rows.Scan(&id, &status); err != nil {
+				rows.Close()
+				return err
+			}
+			diverged[id] = events.OrderStatus(status)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		now := time.Now()
+		for id, status := range diverged {
+			o, err := loadStream(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			prev := o.Version
+			appended, err := moveTo(o, status, now)
+			if err != nil {
+				return err
+			}
+			if err := appendEvents(ctx, tx, appended...); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, `UPDATE orders SET version = $1 WHERE id = $2`, o.Version, id); err != nil {
+				return err
+			}
+			if r.snapshotDue(prev, o.Version) {
+				if err := saveSnapshot(ctx, tx, o); err != nil {
+					return err
+				}
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// moveTo folds onto o the events that move it to status, numbered on from o.Version, and
+// returns them; none if o already has status.
+func moveTo(o *domain.Order, status events.OrderStatus, now time.Time) ([]domain.StreamEvent, error) {
+	var evts []domain.StreamEvent
+	for _, t := range o.StatusChange(status) {
+		e := domain.StreamEvent{OrderID: o.ID, Version: o.Version + 1, Type: t, Data: json.RawMessage("{}"), OccurredAt: now}
+		if err := o.Apply(e); err != nil {
+			return nil, err
+		}
+		evts = append(evts, e)
+	}
+	return evts, nil
+}
+
+// snapshotDue reports whether a stream moving from version from to version to crosses a
+// snapshot interval.
+func (r *EventSourcedOrderRepository) snapshotDue(from, to int64) bool {
+	return to/r.snapshotEvery > from/r.snapshotEvery
+}
+
+// appendEvents inserts events; a version already taken means a concurrent append.
+func appendEvents(ctx context.Context, tx pgx.Tx, evts ...domain.StreamEvent) error {
+	query := `INSERT INTO order_stream_events (order_id, version, type, data, occurred_at) VALUES ($1, $2, $3, $4, $5)`
+	for _, e := range evts {
+		if _, err := tx.Exec(ctx, query, e.OrderID, e.Version, string(e.Type), []byte(e.Data), e.OccurredAt); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+				return ErrVersionConflict
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func saveSnapshot(ctx context.Context, tx pgx.Tx, o *domain.Order) error {
+	state, err := json.Marshal(orderSnapshot{UserID: o.UserID, Amount: o.Amount, Status: o.Status, CreatedAt: o.CreatedAt})
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO order_snapshots (order_id, version, state, taken_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (order_id) DO UPDATE SET version = EXCLUDED.version, state = EXCLUDED.state, taken_at = EXCLUDED.taken_at`
+	_, err = tx.Exec(ctx, query, o.ID, o.Version, state, time.Now())
+	return err
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"go_example/internal/events"
+	"go_example/cmd/order-service/domain"
+)
+
+// fakeStream serves loadStream's snapshot and event queries for one order.
+type fakeStream struct {
+	snapshot *orderSnapshot
+	version  int64
+	events   []domain.StreamEvent
+}
+
+func (f *fakeStream) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return rowFunc(func(dest ...any) error {
+		if f.snapshot == nil {
+			return pgx.ErrNoRows
+		}
+		state, err := json.Marshal(f.snapshot)
+		if err != nil {
+			return err
+		}
+		*dest[0].(*int64), *dest[1].(*[]byte) = f.version, state
+		return nil
+	})
+}
+
+func (f *fakeStream) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	after := args[1].(int64)
+	rows := &fakeRows{}
+	for _, e := range f.events {
+		if e.Version > after {
+			rows.events = append(rows.events, e)
+		}
+	}
+	return rows, nil
+}
+
+type rowFunc func(dest ...any) error
+
+func (f rowFunc) Scan(dest ...any) error { return f(dest...) }
+
+type fakeRows struct {
+	pgx.Rows
+	events []domain.StreamEvent
+	cur    domain.StreamEvent
+}
+
+func (r *fakeRows) Next() bool {
+	if len(r.events) == 0 {
+		return false
+	}
+	r.cur, r.events = r.events[0], r.events[1:]
+	return true
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	*dest[0].(*int64) = r.cur.Version
+	*dest[1].(*string) = string(r.cur.Type)
+	*dest[2].(*[]byte) = r.cur.Data
+	*dest[3].(*time.Time) = r.cur.OccurredAt
+	return nil
+}
+
+func (r *fakeRows) Close()     {}
+func (r *fakeRows) Err() error { return nil }
+
+func TestLoadStream(t *testing.T) {
+	id, user := uuid.New(), uuid.New()
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	data, _ := json.Marshal(domain.OrderCreatedData{UserID: user, Amount: 150})
+	stream := []domain.StreamEvent{
+		{OrderID: id, Version: 1, Type: domain.StreamOrderCreated, Data: data, OccurredAt: created},
+		{OrderID: id, Version: 2, Type: domain.StreamOrderConfirmed, Data: json.RawMessage("{}")},
+		{OrderID: id, Version: 3, Type: domain.StreamOrderCanceled, Data: json.RawMessage("{}")},
+		{OrderID: id, Version: 4, Type: domain.StreamOrderRefunded, Data: json.RawMessage("{}")},
+	}
+	tests := []struct {
+		name       string
+		db         *fakeStream
+		wantStatus events.OrderStatus
+		wantAmount int64
+		wantErr    error
+	}{
+		{"events only", &fakeStream{events: stream}, events.OrderStatusCanceled, 150, nil},
+		{"snapshot then events", &fakeStream{
+			snapshot: &orderSnapshot{UserID: user, Amount: 150, Status: events.OrderStatusConfirmed, CreatedAt: created},
+			version:  2,
+			events:   stream,
+		}, events.OrderStatusCanceled, 150, nil},
+		// Events before the snapshot are not read again: its state stands.
+		{"snapshot at head", &fakeStream{
+			snapshot: &orderSnapshot{UserID: user, Amount: 99, Status: events.OrderStatusCanceled, CreatedAt: created},
+			version:  4,
+			events:   stream,
+		}, events.OrderStatusCanceled, 99, nil},
+		{"no stream", &fakeStream{}, "", 0, pgx.ErrNoRows},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, err := loadStream(context.Background(), tt.db, id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("loadStream error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if o.ID != id || o.UserID != user || o.Status != tt.wantStatus || o.Amount != tt.wantAmount || o.Version != 4 || !o.CreatedAt.Equal(created) {
+				t.Errorf("loaded %+v", o)
+			}
+		})
+	}
+}
+
+func TestMoveTo(t *testing.T) {
+	now := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		from        events.OrderStatus
+		version     int64
+		to          events.OrderStatus
+		wantTypes   []domain.StreamEventType
+		wantVersion int64
+	}{
+		{"unchanged", events.OrderStatusPending, 1, events.OrderStatusPending, nil, 1},
+		{"confirm", events.OrderStatusPending, 1, events.OrderStatusConfirmed, []domain.StreamEventType{domain.StreamOrderConfirmed}, 2},
+		{"cancel pending", events.OrderStatusPending, 1, events.OrderStatusCanceled, []domain.StreamEventType{domain.StreamOrderCanceled}, 2},
+		{"cancel confirmed", events.OrderStatusConfirmed, 2, events.OrderStatusCanceled, []domain.StreamEventType{domain.StreamOrderCanceled, domain.StreamOrderRefunded}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &domain.Order{ID: uuid.New(), Status: tt.from, Version: tt.version}
+			evts, err := moveTo(o, tt.to, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(evts) != len(tt.wantTypes) {
+				t.Fatalf("%d events, want %v", len(evts), tt.wantTypes)
+			}
+			for i, e := range evts {
+				if e.Type != tt.wantTypes[i] || e.Version != tt.version+int64(i)+1 || e.OrderID != o.ID || !e.OccurredAt.Equal(now) {
+					t.Errorf("event %d: %+v", i, e)
+				}
+			}
+			if o.Status != tt.to || o.Version != tt.wantVersion {
+				t.Errorf("order at %s v%d, want %s v%d", o.Status, o.Version, tt.to, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestSnapshotDue(t *testing.T) {
+	tests := []struct {
+		every    int
+		from, to int64
+		want     bool
+	}{
+		{1, 1, 2, true},
+		{1, 2, 2, false},
+		{0, 3, 4, true}, // below 1 snapshots every version
+		{3, 1, 2, false},
+		{3, 2, 3, true},
+		{3, 2, 4, true},
+		{3, 3, 5, false},
+		{3, 5, 7, true},
+		{10, 0, 1, false},
+	}
+	for _, tt := range tests {
+		r := NewEventSourcedOrderRepository(nil, tt.every)
+		if got := r.snapshotDue(tt.from, tt.to); got != tt.want {
+			t.Errorf("every %d, v%d -> v%d: snapshotDue = %v, want %v", tt.every, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Leadership is a session-level advisory lock held on a dedicated connection. Only one
+// instance sharing the database holds a given lock; it is released when the holder releases
+// it or its connection ends.
+type Leadership struct {
+	conn   *pgxpool.Conn
+	lockID int64
+}
+
+// TryLead tries to take the advisory lock lockID. It returns nil, without error, if another
+// session holds it.
+func TryLead(ctx context.Context, pool *pgxpool.Pool, lockID int64) (*Leadership, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, lockID).Scan(&locked); err != nil || !locked {
+		conn.Release()
+		return nil, err
+	}
+	return &Leadership{conn: conn, lockID: lockID}, nil
+}
+
+// Check reports an error if the connection holding the lock is gone, and with it the lock.
+func (l *Leadership) Check(ctx context.Context) error {
+	return l.conn.Ping(ctx)
+}
+
+// Release gives up the lock and returns its connection to the pool. A connection that fails to
+// unlock is closed instead, which releases the lock too.
+func (l *Leadership) Release(ctx context.Context) {
+	if _, err := l.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, l.lockID); err != nil {
+		l.conn.Conn().Close(ctx)
+	}
+	l.conn.Release()
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go_example/cmd/order-service/domain"
+)
+
+// OrderEventRepository stores the saga event history of orders.
+type OrderEventRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewOrderEventRepository creates a new OrderEventRepository.
+func NewOrderEventRepository(pool *pgxpool.Pool) *OrderEventRepository {
+	return &OrderEventRepository{pool: pool}
+}
+
+// Append inserts an event. An event already stored (same topic, partition and offset) is ignored.
+func (r *OrderEventRepository) Append(ctx context.Context, e *domain.OrderEvent) error {
+	query := `INSERT INTO order_events (order_id, topic, partition, message_offset, service, payload, event_time, recorded_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (topic, partition, message_offset) DO NOTHING`
+	_, err := r.pool.Exec(ctx, query, e.OrderID, e.Topic, e.Partition, e.Offset, e.Service, []byte(e.Payload), e.EventTime, e.RecordedAt)
+	return err
+}
+
+// ListByOrderID returns the events of an order, oldest first.
+func (r *OrderEventRepository) ListByOrderID(ctx context.Context, orderID uuid.UUID) ([]*domain.OrderEvent, error) {
+	query := `SELECT id, order_id, topic, partition, message_offset, service, payload, event_time, recorded_at
+		FROM order_events WHERE order_id = $1 ORDER BY event_time, id`
+	rows, err := r.pool.Query(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*domain.OrderEvent
+	for rows.Next() {
+		var e domain.OrderEvent
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.OrderID, &e.Topic, &e.Partition, &e.Offset, &e.Service, &payload, &e.EventTime, &e.RecordedAt); err != nil {
+			return nil, err
+		}
+		e.Payload = payload
+		list = append(list, &e)
+	}
+	return list, rows.Err()
+}
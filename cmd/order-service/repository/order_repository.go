@@ -2,15 +2,31 @@ package repository
 
 import (
 	"context"
+	"errors"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"go_example/internal/events"
 	"go_example/cmd/order-service/domain"
 )
 
-// OrderRepository handles order persistence.
+// Order store kinds accepted by ORDER_STORE.
+const (
+	StoreTable  = "table"
+	StoreEvents = "events"
+)
+
+// ErrVersionConflict is returned when an order changed since it was read.
+var ErrVersionConflict = errors.New("order was modified concurrently")
+
+// OrderChangeFunc records a stored state change of o, from status previous (empty for a new
+// order), in tx, the transaction that stores the change, so the record commits or rolls back
+// with it.
+type OrderChangeFunc func(ctx context.Context, tx pgx.Tx, o *domain.Order, previous events.OrderStatus) error
+
+// OrderRepository handles order persistence in the orders table.
 type OrderRepository struct {
 	pool *pgxpool.Pool
 }
@@ -20,19 +36,23 @@ func NewOrderRepository(pool *pgxpool.Pool) *OrderRepository {
 	return &OrderRepository{pool: pool}
 }
 
-// Create inserts a new order.
-func (r *OrderRepository) Create(ctx context.Context, o *domain.Order) error {
-	query := `INSERT INTO orders (id, user_id, amount, status, created_at) VALUES ($1, $2, $3, $4, $5)`
-	_, err := r.pool.Exec(ctx, query, o.ID, o.UserID, o.Amount, string(o.Status), o.CreatedAt)
-	return err
+// Create inserts a new order and calls changed in the same transaction.
+func (r *OrderRepository) Create(ctx context.Context, o *domain.Order, changed OrderChangeFunc) error {
+	return pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		query := `INSERT INTO orders (id, user_id, amount, status, created_at, version) VALUES ($1, $2, $3, $4, $5, $6)`
+		if _, err := tx.Exec(ctx, query, o.ID, o.UserID, o.Amount, string(o.Status), o.CreatedAt, o.Version); err != nil {
+			return err
+		}
+		return changed(ctx, tx, o, "")
+	})
 }
 
 // GetByID returns an order by ID.
 func (r *OrderRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Order, error) {
-	query := `SELECT id, user_id, amount, status, created_at FROM orders WHERE id = $1`
+	query := `SELECT id, user_id, amount, status, created_at, version FROM orders WHERE id = $1`
 	var o domain.Order
 	var status string
-	err := r.pool.QueryRow(ctx, query, id).Scan(&o.ID, &o.UserID, &o.Amount, &status, &o.CreatedAt)
+	err := r.pool.QueryRow(ctx, query, id).Scan(&o.ID, &o.UserID, &o.Amount, &status, &o.CreatedAt, &o.Version)
 	if err != nil {
 		return nil, err
 	}
@@ -40,16 +60,33 @@ func (r *OrderRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Or
 	return &o, nil
 }
 
-// UpdateStatus updates order status.
-func (r *OrderRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status events.OrderStatus) error {
-	query := `UPDATE orders SET status = $1 WHERE id = $2`
-	_, err := r.pool.Exec(ctx, query, string(status), id)
-	return err
+// UpdateStatus updates the status of o if its version is unchanged, calls changed in the same
+// transaction and advances o.
+func (r *OrderRepository) UpdateStatus(ctx context.Context, o *domain.Order, status events.OrderStatus, changed OrderChangeFunc) error {
+	next := *o
+	next.Status = status
+	next.Version++
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		query := `UPDATE orders SET status = $1, version = version + 1 WHERE id = $2 AND version = $3`
+		tag, err := tx.Exec(ctx, query, string(status), o.ID, o.Version)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrVersionConflict
+		}
+		return changed(ctx, tx, &next, o.Status)
+	})
+	if err != nil {
+		return err
+	}
+	*o = next
+	return nil
 }
 
 // ListByUserID returns all orders for a user.
 func (r *OrderRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*domain.Order, error) {
-	query := `SELECT id, user_id, amount, status, created_at FROM orders WHERE user_id = $1 ORDER BY created_at DESC`
+	query := `SELECT id, user_id, amount, status, created_at, version FROM orders WHERE user_id = $1 ORDER BY created_at DESC`
 	rows, err := r.pool.Query(ctx, query, userID)
 	if err != nil {
 		return nil, err
@@ -59,7 +96,7 @@ func (r *OrderRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([
 	for rows.Next() {
 		var o domain.Order
 		var status string
-		if err := rows.Scan(&o.ID, &o.UserID, &o.Amount, &status, &o.CreatedAt); err != nil {
+		if err := rows.Scan(&o.ID, &o.UserID, &o.Amount, &status, &o.CreatedAt, &o.Version); err != nil {
 			return nil, err
 		}
 		o.Status = events.OrderStatus(status)
@@ -0,0 +1,231 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go_example/cmd/order-service/domain"
+)
+
+// ScheduleRepository stores order schedules and the runs that placed their orders.
+type ScheduleRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewScheduleRepository creates a new ScheduleRepository.
+func NewScheduleRepository(pool *pgxpool.Pool) *ScheduleRepository {
+	return &ScheduleRepository{pool: pool}
+}
+
+const scheduleColumns = `id, user_id, amount, repeat, run_at, cron, timezone, status, next_run_at, consecutive_failures,
+	paused_at, paused_reason, created_at, updated_at`
+
+func scanSchedule(row pgx.Row) (*domain.Schedule, error) {
+	var s domain.Schedule
+	err := row.Scan(&s.ID, &s.UserID, &s.Amount, &s.Repeat, &s.RunAt, &s.Cron, &s.Timezone, &s.Status, &s.NextRunAt,
+		&s.ConsecutiveFailures, &s.PausedAt, &s.PausedReason, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Create inserts a schedule.
+func (r *ScheduleRepository) Create(ctx context.Context, s *domain.Schedule) error {
+	query := `INSERT INTO order_schedules (` + scheduleColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+	_, err := r.pool.Exec(ctx, query, s.ID, s.UserID, s.Amount, s.Repeat, s.RunAt, s.Cron, s.Timezone, s.Status, s.NextRunAt,
+		s.ConsecutiveFailures, s.PausedAt, s.PausedReason, s.CreatedAt, s.UpdatedAt)
+	return err
+}
+
+// GetByID returns a schedule by ID, or pgx.ErrNoRows.
+func (r *ScheduleRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Schedule, error) {
+	return scanSchedule(r.pool.QueryRow(ctx, `SELECT `+scheduleColumns+` FROM order_schedules WHERE id = $1`, id))
+}
+
+// ListByUserID returns the schedules of a user, oldest first.
+func (r *ScheduleRepository) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*domain.Schedule, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+scheduleColumns+` FROM order_schedules WHERE user_id = $1 ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*domain.Schedule
+	for rows.Next() {
+		s, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
+
+// Update stores the amount, recurrence, status and next run of s. The failure count is reset
+// when s becomes active again, so a resumed schedule starts counting anew. It reports whether s
+// exists.
+func (r *ScheduleRepository) Update(ctx context.Context, s *domain.Schedule) (bool, error) {
+	query := `UPDATE order_schedules SET amount = $2, repeat = $3, run_at = $4, cron = $5, timezone = $6, status = $7,
+			next_run_at = $8, paused_at = $9, paused_reason = $10, updated_at = $11,
+			consecutive_failures = CASE WHEN $7 = 'active' AND status <> 'active' THEN 0 ELSE consecutive_failures END
+		WHERE id = $1`
+	tag, err := r.pool.Exec(ctx, query, s.ID, s.Amount, s.Repeat, s.RunAt, s.Cron, s.Timezone, s.Status,
+		s.NextRunAt, s.PausedAt, s.PausedReason, s.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Delete removes a schedule with its runs and reports whether it existed. Orders already placed
+// are kept.
+func (r *ScheduleRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM order_schedules WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ClaimDue adds a pending run for up to limit active schedules due at now and moves each to the
+// run next returns for it, or completes it if next returns nil, in one transaction. Concurrent
+// claims skip schedules being claimed.
+func (r *ScheduleRepository) ClaimDue(ctx context.Context, now time.Time, limit int, next func(*domain.Schedule) *time.Time) ([]*domain.ScheduleRun, error) {
+	var runs []*domain.ScheduleRun
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		runs = nil
+		rows, err := tx.Query(ctx, `SELECT `+scheduleColumns+` FROM order_schedules
+			WHERE status = 'active' AND next_run_at <= $1
+			ORDER BY next_run_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED`, now, limit)
+		if err != nil {
+			return err
+		}
+		due, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*domain.Schedule, error) { return scanSchedule(row) })
+		if err != nil {
+			return err
+		}
+		for _, s := range due {
+			run := &domain.ScheduleRun{
+				ScheduleID:   s.ID,
+				ScheduledFor: *s.NextRunAt,
+				OrderID:      uuid.New(),
+				UserID:       s.UserID,
+				Amount:       s.Amount,
+				Status:       domain.RunPending,
+				CreatedAt:    now,
+				UpdatedAt:    now,
+			}
+			insert := `INSERT INTO order_schedule_runs (schedule_id, scheduled_for, order_id, user_id, amount, status, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $7) ON CONFLICT (schedule_id, scheduled_for) DO NOTHING RETURNING id`
+			err := tx.QueryRow(ctx, insert, run.ScheduleID, run.ScheduledFor, run.OrderID, run.UserID, run.Amount, run.Status, now).Scan(&run.ID)
+			switch {
+			case err == nil:
+				runs = append(runs, run)
+			case !errors.Is(err, pgx.ErrNoRows):
+				return err
+			}
+			status, nextRunAt := domain.ScheduleActive, next(s)
+			if nextRunAt == nil {
+				status = domain.ScheduleCompleted
+			}
+			update := `UPDATE order_schedules SET status = $2, next_run_at = $3, updated_at = $4 WHERE id = $1`
+			if _, err := tx.Exec(ctx, update, s.ID, status, nextRunAt, now); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return runs, err
+}
+
+// ListPendingRuns returns up to limit runs whose order has not been created yet, oldest first.
+func (r *ScheduleRepository) ListPendingRuns(ctx context.Context, limit int) ([]*domain.ScheduleRun, error) {
+	return r.listRuns(ctx, `WHERE status = 'pending' ORDER BY created_at, id LIMIT $1`, limit)
+}
+
+// ListRuns returns the latest limit runs of a schedule, newest first.
+func (r *ScheduleRepository) ListRuns(ctx context.Context, scheduleID uuid.UUID, limit int) ([]*domain.ScheduleRun, error) {
+	return r.listRuns(ctx, `WHERE schedule_id = $1 ORDER BY scheduled_for DESC LIMIT $2`, scheduleID, limit)
+}
+
+func (r *ScheduleRepository) listRuns(ctx context.Context, where string, args ...any) ([]*domain.ScheduleRun, error) {
+	query := `SELECT id, schedule_id, scheduled_for, order_id, user_id, amount, status, error, created_at, updated_at
+		FROM order_schedule_runs ` + where
+	rows, err := r.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*domain.ScheduleRun
+	for rows.Next() {
+		var run domain.ScheduleRun
+		if err := rows.Scan(&run.ID, &run.ScheduleID, &run.ScheduledFor, &run.OrderID, &run.UserID, &run.Amount, &run.Status,
+			&run.Error, &run.CreatedAt, &run.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, &run)
+	}
+	return list, rows.Err()
+}
+
+// UpdateRun updates a pending run: to created once its order is placed, or with the error of
+// an attempt that failed and is retried.
+func (r *ScheduleRepository) UpdateRun(ctx context.Context, id int64, status, lastError string, at time.Time) error {
+	query := `UPDATE order_schedule_runs SET status = $2, error = $3, updated_at = $4 WHERE id = $1 AND status = 'pending'`
+	_, err := r.pool.Exec(ctx, query, id, status, lastError, at)
+	return err
+}
+
+// RecordOutcome moves the created run of an order to confirmed, or to credit_failed with
+// reason, and updates its schedule in one transaction. A confirmed run resets the schedule's
+// failure count; a credit failure counts one and pauses the schedule once it has failed
+// pauseAfter times in a row. Orders not placed by a schedule, and outcomes recorded before,
+// change nothing. It returns the schedule if this outcome paused it.
+func (r *ScheduleRepository) RecordOutcome(ctx context.Context, orderID uuid.UUID, confirmed bool, reason string, pauseAfter int, at time.Time) (*domain.Schedule, error) {
+	var paused *domain.Schedule
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		paused = nil
+		status, errMsg := domain.RunConfirmed, ""
+		if !confirmed {
+			status, errMsg = domain.RunCreditFailed, reason
+		}
+		var scheduleID uuid.UUID
+		err := tx.QueryRow(ctx, `UPDATE order_schedule_runs SET status = $2, error = $3, updated_at = $4
+			WHERE order_id = $1 AND status = 'created' RETURNING schedule_id`, orderID, status, errMsg, at).Scan(&scheduleID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if confirmed {
+			_, err := tx.Exec(ctx, `UPDATE order_schedules SET consecutive_failures = 0 WHERE id = $1`, scheduleID)
+			return err
+		}
+		sched, err := scanSchedule(tx.QueryRow(ctx, `SELECT `+scheduleColumns+` FROM order_schedules WHERE id = $1 FOR UPDATE`, scheduleID))
+		if err != nil {
+			return err
+		}
+		sched.ConsecutiveFailures++
+		sched.UpdatedAt = at
+		if sched.Status == domain.ScheduleActive && sched.ConsecutiveFailures >= pauseAfter {
+			sched.Status, sched.NextRunAt = domain.SchedulePaused, nil
+			sched.PausedAt, sched.PausedReason = &at, "paused after consecutive credit failures: "+reason
+			paused = sched
+		}
+		update := `UPDATE order_schedules SET consecutive_failures = $2, status = $3, next_run_at = $4, paused_at = $5,
+				paused_reason = $6, updated_at = $7
+			WHERE id = $1`
+		_, err = tx.Exec(ctx, update, sched.ID, sched.ConsecutiveFailures, sched.Status, sched.NextRunAt, sched.PausedAt,
+			sched.PausedReason, sched.UpdatedAt)
+		return err
+	})
+	return paused, err
+}
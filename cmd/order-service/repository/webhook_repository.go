@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go_example/cmd/order-service/domain"
+)
+
+// WebhookRepository stores webhook subscriptions, their deliveries and the delivery attempt log.
+type WebhookRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewWebhookRepository creates a new WebhookRepository.
+func NewWebhookRepository(pool *pgxpool.Pool) *WebhookRepository {
+	return &WebhookRepository{pool: pool}
+}
+
+// DueWebhookDelivery is a claimed delivery with the URL and secret of its webhook.
+type DueWebhookDelivery struct {
+	Delivery domain.WebhookDelivery
+	URL      string
+	Secret   string
+}
+
+const webhookColumns = `id, url, events, secret, active, consecutive_failures, disabled_at, disabled_reason, created_at`
+
+func scanWebhook(row pgx.Row) (*domain.Webhook, error) {
+	var w domain.Webhook
+	err := row.Scan(&w.ID, &w.URL, &w.Events, &w.Secret, &w.Active, &w.ConsecutiveFailures, &w.DisabledAt, &w.DisabledReason, &w.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// Create inserts a webhook.
+func (r *WebhookRepository) Create(ctx context.Context, w *domain.Webhook) error {
+	query := `INSERT INTO webhooks (id, url, events, secret, active, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := r.pool.Exec(ctx, query, w.ID, w.URL, w.Events, w.Secret, w.Active, w.CreatedAt)
+	return err
+}
+
+// GetByID returns a webhook by ID, or pgx.ErrNoRows.
+func (r *WebhookRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Webhook, error) {
+	return scanWebhook(r.pool.QueryRow(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id))
+}
+
+// List returns all webhooks, oldest first.
+func (r *WebhookRepository) List(ctx context.Context) ([]*domain.Webhook, error) {
+	rows, err := r.pool.Query(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*domain.Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, w)
+	}
+	return list, rows.Err()
+}
+
+// Delete removes a webhook with its deliveries and reports whether it existed.
+func (r *WebhookRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Enable re-enables a webhook and clears its failure count. It reports whether the webhook exists.
+func (r *WebhookRepository) Enable(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `UPDATE webhooks SET active = TRUE, consecutive_failures = 0, disabled_at = NULL, disabled_reason = '' WHERE id = $1`
+	tag, err := r.pool.Exec(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Enqueue adds, in tx, a pending delivery of an event for every active webhook subscribed to
+// eventType and returns how many were added.
+func (r *WebhookRepository) Enqueue(ctx context.Context, tx pgx.Tx, eventID uuid.UUID, eventType string, orderID uuid.UUID, payload json.RawMessage, now time.Time) (int64, error) {
+	query := `INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, order_id, payload, status, next_attempt_at, created_at, updated_at)
+		SELECT id, $1, $2, $3, $4, $5, $6, $6, $6 FROM webhooks
+		WHERE active AND (cardinality(events) = 0 OR $2 = ANY(events))`
+	tag, err := tx.Exec(ctx, query, eventID, eventType, orderID, []byte(payload), domain.DeliveryPending, now)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ClaimDue claims up to limit pending or retrying deliveries of active webhooks due at now and
+// counts an attempt for each. A claimed delivery is not due again before leaseUntil, so
+// concurrent dispatchers do not send it twice and one that crashes mid-send is retried later.
+func (r *WebhookRepository) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]*DueWebhookDelivery, error) {
+	query := `UPDATE webhook_deliveries d
+		SET attempts = d.attempts + 1, next_attempt_at = $2, updated_at = $1
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT dd.id FROM webhook_deliveries dd JOIN webhooks ww ON ww.id = dd.webhook_id
+			WHERE dd.status IN ('pending', 'retrying') AND dd.next_attempt_at <= $1 AND ww.active
+			ORDER BY dd.next_attempt_at
+			LIMIT $3
+			FOR UPDATE OF dd SKIP LOCKED)
+		RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.order_id, d.payload, d.attempts, w.url, w.secret`
+	rows, err := r.pool.Query(ctx, query, now, leaseUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*DueWebhookDelivery
+	for rows.Next() {
+		var due DueWebhookDelivery
+		d := &due.Delivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.OrderID, &d.Payload, &d.Attempts, &due.URL, &due.Secret); err != nil {
+			return nil, err
+		}
+		list = append(list, &due)
+	}
+	return list, rows.Err()
+}
+
+// RecordAttempt logs an attempt of a claimed delivery and updates the delivery and its webhook
+// in one transaction. A successful attempt delivers it and resets the webhook's failure count.
+// A failed one schedules the delivery for next, or fails it if next is nil, and disables the
+// webhook once it has failed disableAfter times in a row. It reports whether the webhook was
+// disabled by this attempt.
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, d *domain.WebhookDelivery, a *domain.WebhookAttempt, next *time.Time, disableAfter int) (bool, error) {
+	disabled := false
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		insert := `INSERT INTO webhook_delivery_attempts (delivery_id, attempt, status_code, error, duration_ms, attempted_at)
+			VALUES ($1, $2, $3, $4, $5, $6)`
+		if _, err := tx.Exec(ctx, insert, d.ID, a.Attempt, a.StatusCode, a.Error, a.Duration.Milliseconds(), a.AttemptedAt); err != nil {
+			return err
+		}
+		if a.Error == "" {
+			update := `UPDATE webhook_deliveries SET status = $2, last_error = '', next_attempt_at = NULL, delivered_at = $3, updated_at = $3
+				WHERE id = $1`
+			if _, err := tx.Exec(ctx, update, d.ID, domain.DeliveryDelivered, a.AttemptedAt); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `UPDATE webhooks SET consecutive_failures = 0 WHERE id = $1`, d.WebhookID)
+			return err
+		}
+		status := domain.DeliveryRetrying
+		if next == nil {
+			status = domain.DeliveryFailed
+		}
+		update := `UPDATE webhook_deliveries SET status = $2, last_error = $3, next_attempt_at = $4, updated_at = $5 WHERE id = $1`
+		if _, err := tx.Exec(ctx, update, d.ID, status, a.Error, next, a.AttemptedAt); err != nil {
+			return err
+		}
+		failures := `UPDATE webhooks SET consecutive_failures = consecutive_failures + 1,
+				active = active AND consecutive_failures + 1 < $2,
+				disabled_at = CASE WHEN active AND consecutive_failures + 1 >= $2 THEN $3 ELSE disabled_at END,
+				disabled_reason = CASE WHEN active AND consecutive_failures + 1 >= $2 THEN $4 ELSE disabled_reason END
+			WHERE id = $1 RETURNING NOT active AND consecutive_failures = $2`
+		err := tx.QueryRow(ctx, failures, d.WebhookID, disableAfter, a.AttemptedAt, "disabled after consecutive failures: "+a.Error).Scan(&disabled)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil // webhook deleted meanwhile
+		}
+		return err
+	})
+	return disabled, err
+}
+
+// ListDeliveries returns the latest limit deliveries of a webhook, newest first, with their attempts.
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]*domain.WebhookDelivery, error) {
+	query := `SELECT id, webhook_id, event_id, event_type, order_id, payload, status, attempts, next_attempt_at, last_error,
+			delivered_at, created_at, updated_at
+		FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2`
+	rows, err := r.pool.Query(ctx, query, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*domain.WebhookDelivery
+	byID := map[int64]*domain.WebhookDelivery{}
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.OrderID, &d.Payload, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.LastError, &d.DeliveredAt, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, &d)
+		byID[d.ID] = &d
+	}
+	if err := rows.Err(); err != nil || len(list) == 0 {
+		return list, err
+	}
+	ids := make([]int64, 0, len(list))
+	for _, d := range list {
+		ids = append(ids, d.ID)
+	}
+	attempts, err := r.pool.Query(ctx, `SELECT delivery_id, attempt, status_code, error, duration_ms, attempted_at
+		FROM webhook_delivery_attempts WHERE delivery_id = ANY($1) ORDER BY id`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer attempts.Close()
+	for attempts.Next() {
+		var a domain.WebhookAttempt
+		var ms int64
+		if err := attempts.Scan(&a.DeliveryID, &a.Attempt, &a.StatusCode, &a.Error, &ms, &a.AttemptedAt); err != nil {
+			return nil, err
+		}
+		a.Duration = time.Duration(ms) * time.Millisecond
+		byID[a.DeliveryID].AttemptLog = append(byID[a.DeliveryID].AttemptLog, &a)
+	}
+	return list, attempts.Err()
+}
+
+// Redeliver makes a delivery of a webhook due now with a fresh set of attempts. It reports
+// whether the delivery exists.
+func (r *WebhookRepository) Redeliver(ctx context.Context, webhookID uuid.UUID, deliveryID int64, now time.Time) (bool, error) {
+	query := `UPDATE webhook_deliveries SET status = $3, attempts = 0, next_attempt_at = $4, updated_at = $4
+		WHERE id = $1 AND webhook_id = $2`
+	tag, err := r.pool.Exec(ctx, query, deliveryID, webhookID, domain.DeliveryPending, now)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
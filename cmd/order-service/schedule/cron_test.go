@@ -0,0 +1,98 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: "* * * * *"},
+		{expr: "*/15 9-17 * * mon-fri"},
+		{expr: "0 0 1,15 jan,JUL *"},
+		{expr: "5/10 * * * 7"},
+		{expr: "@daily"},
+		{expr: " @Hourly "},
+		{expr: "* * * *", wantErr: true},
+		{expr: "* * * * * *", wantErr: true},
+		{expr: "60 * * * *", wantErr: true},
+		{expr: "* 24 * * *", wantErr: true},
+		{expr: "* * 0 * *", wantErr: true},
+		{expr: "* * * 13 *", wantErr: true},
+		{expr: "* * * * 8", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+		{expr: "10-5 * * * *", wantErr: true},
+		{expr: "* * * foo *", wantErr: true},
+		{expr: "@reboot", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseCron(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCron(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	// 2026-03-04 is a Wednesday.
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time // zero: no match
+	}{
+		{"next minute", "* * * * *", at("2026-03-04 10:00"), at("2026-03-04 10:01")},
+		{"seconds truncated", "* * * * *", at("2026-03-04 10:00").Add(30 * time.Second), at("2026-03-04 10:01")},
+		{"step", "*/15 * * * *", at("2026-03-04 10:01"), at("2026-03-04 10:15")},
+		{"offset step", "5/20 * * * *", at("2026-03-04 10:06"), at("2026-03-04 10:25")},
+		{"next day", "30 9 * * *", at("2026-03-04 10:00"), at("2026-03-05 09:30")},
+		{"weekday range skips weekend", "0 9 * * mon-fri", at("2026-03-06 10:00"), at("2026-03-09 09:00")},
+		{"sunday as 7", "0 0 * * 7", at("2026-03-04 00:00"), at("2026-03-08 00:00")},
+		{"month name", "0 0 1 jun *", at("2026-03-04 00:00"), at("2026-06-01 00:00")},
+		{"year wrap", "@yearly", at("2026-03-04 00:00"), at("2027-01-01 00:00")},
+		{"day fields or", "0 0 13 * fri", at("2026-03-04 00:00"), at("2026-03-06 00:00")},
+		{"day of month star and", "0 0 * * fri", at("2026-03-04 00:00"), at("2026-03-06 00:00")},
+		{"leap day", "0 0 29 2 *", at("2026-03-04 00:00"), at("2028-02-29 00:00")},
+		{"never", "0 0 30 2 *", at("2026-03-04 00:00"), time.Time{}},
+		{
+			"spring forward skips missing hour", "30 2 * * *",
+			time.Date(2026, 3, 7, 12, 0, 0, 0, ny), time.Date(2026, 3, 9, 2, 30, 0, 0, ny),
+		},
+		{
+			"fall back fires once per wall hour", "30 1 * * *",
+			time.Date(2026, 11, 1, 1, 30, 0, 0, ny), time.Date(2026, 11, 2, 1, 30, 0, 0, ny),
+		},
+		{
+			"fall back skips repeated hour", "*/30 * * * *",
+			time.Date(2026, 11, 1, 1, 30, 0, 0, ny), time.Date(2026, 11, 1, 2, 0, 0, 0, ny),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := c.Next(tt.from)
+			if ok != !tt.want.IsZero() || !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, %v; want %v", tt.from, got, ok, tt.want)
+			}
+		})
+	}
+}
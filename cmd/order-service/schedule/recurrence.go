@@ -0,0 +1,113 @@
+// Package schedule computes when order schedules run and places their orders from a
+// leader-elected scheduler loop.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	// Schedules name IANA time zones; the runtime image has no zoneinfo.
+	_ "time/tzdata"
+
+	"go_example/cmd/order-service/domain"
+)
+
+// Validate checks the recurrence of s: its repeat kind, time zone, RunAt (required unless
+// s repeats by cron) and cron expression.
+func Validate(s *domain.Schedule) error {
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %q", s.Timezone)
+	}
+	switch s.Repeat {
+	case domain.RepeatOnce, domain.RepeatDaily, domain.RepeatWeekly, domain.RepeatMonthly:
+		if s.RunAt == nil {
+			return errors.New("runAt is required")
+		}
+		if s.Cron != "" {
+			return fmt.Errorf("cron is only allowed with repeat %q", domain.RepeatCron)
+		}
+	case domain.RepeatCron:
+		if _, err := ParseCron(s.Cron); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown repeat %q", s.Repeat)
+	}
+	return nil
+}
+
+// NextRun returns the first run of s after t. It reports false if s has no further run.
+// s must be valid.
+func NextRun(s *domain.Schedule, t time.Time) (time.Time, bool) {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Time{}, false
+	}
+	t = t.In(loc)
+	switch s.Repeat {
+	case domain.RepeatOnce:
+		if s.RunAt.After(t) {
+			return s.RunAt.UTC(), true
+		}
+		return time.Time{}, false
+	case domain.RepeatCron:
+		c, err := ParseCron(s.Cron)
+		if err != nil {
+			return time.Time{}, false
+		}
+		if s.RunAt != nil && !s.RunAt.Before(t) {
+			// Cron.Next is exclusive; let a run at RunAt itself match.
+			t = s.RunAt.In(loc).Add(-time.Nanosecond)
+		}
+		next, ok := c.Next(t)
+		return next.UTC(), ok
+	default:
+		anchor := s.RunAt.In(loc)
+		n := estimateOccurrence(s.Repeat, anchor, t)
+		for {
+			next := occurrence(s.Repeat, anchor, n)
+			if next.After(t) {
+				return next.UTC(), true
+			}
+			n++
+		}
+	}
+}
+
+// occurrence returns the nth run of a daily, weekly or monthly schedule first run at anchor.
+// Monthly runs on days the month lacks move to its last day.
+func occurrence(repeat string, anchor time.Time, n int) time.Time {
+	y, m, d := anchor.Date()
+	hh, mm, ss := anchor.Clock()
+	loc := anchor.Location()
+	switch repeat {
+	case domain.RepeatDaily:
+		return time.Date(y, m, d+n, hh, mm, ss, 0, loc)
+	case domain.RepeatWeekly:
+		return time.Date(y, m, d+7*n, hh, mm, ss, 0, loc)
+	default:
+		first := time.Date(y, m+time.Month(n), 1, hh, mm, ss, 0, loc)
+		if last := first.AddDate(0, 1, -1).Day(); d > last {
+			d = last
+		}
+		return time.Date(first.Year(), first.Month(), d, hh, mm, ss, 0, loc)
+	}
+}
+
+// estimateOccurrence returns an occurrence number whose run is at or before the first one
+// after t, so NextRun need not step through every past run of an old schedule.
+func estimateOccurrence(repeat string, anchor, t time.Time) int {
+	if !t.After(anchor) {
+		return 0
+	}
+	var n int
+	switch repeat {
+	case domain.RepeatDaily:
+		n = int(t.Sub(anchor)/(24*time.Hour)) - 1
+	case domain.RepeatWeekly:
+		n = int(t.Sub(anchor)/(7*24*time.Hour)) - 1
+	default:
+		n = (t.Year()-anchor.Year())*12 + int(t.Month()-anchor.Month()) - 1
+	}
+	return max(n, 0)
+}
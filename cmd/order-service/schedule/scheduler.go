@@ -0,0 +1,142 @@
+package schedule
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go_example/internal/logging"
+	"go_example/internal/requestid"
+	"go_example/cmd/order-service/config"
+	"go_example/cmd/order-service/domain"
+	"go_example/cmd/order-service/dto"
+	"go_example/cmd/order-service/repository"
+)
+
+// leaderLockID is the advisory lock held by the elected scheduler.
+const leaderLockID int64 = 0x7363686564756c65 // "schedule"
+
+// OrderCreator places orders. Implemented by service.OrderService; creating an order whose ID
+// exists returns the existing order, publishing its order.created again while it is PENDING.
+type OrderCreator interface {
+	CreateOrder(ctx context.Context, req dto.CreateOrderRequest) (*dto.OrderResponse, error)
+}
+
+// Scheduler places the orders of due schedules. Every instance runs one, but only the one
+// holding the leader lock polls; claims skip locked schedules and each run has a fixed order
+// ID, so an instance that lost the lock mid-poll places no duplicate order.
+type Scheduler struct {
+	repo   *repository.ScheduleRepository
+	pool   *pgxpool.Pool
+	orders OrderCreator
+	cfg    config.ScheduleConfig
+}
+
+// NewScheduler creates a new Scheduler that campaigns for leadership on pool.
+func NewScheduler(repo *repository.ScheduleRepository, pool *pgxpool.Pool, orders OrderCreator, cfg config.ScheduleConfig) *Scheduler {
+	return &Scheduler{repo: repo, pool: pool, orders: orders, cfg: cfg}
+}
+
+// Run tries to become leader every interval and, while leader, places the orders of due
+// schedules, until ctx is canceled. Leadership is given up on return.
+func (s *Scheduler) Run(ctx context.Context) {
+	var lead *repository.Leadership
+	defer func() {
+		if lead != nil {
+			lead.Release(context.WithoutCancel(ctx))
+			slog.InfoContext(ctx, "scheduler leadership released")
+		}
+	}()
+	tick := time.NewTicker(s.cfg.PollInterval)
+	defer tick.Stop()
+	for {
+		lead = s.campaign(ctx, lead)
+		if lead != nil {
+			s.drain(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// campaign returns lead if it still holds the leader lock, or tries to take the lock.
+func (s *Scheduler) campaign(ctx context.Context, lead *repository.Leadership) *repository.Leadership {
+	if lead != nil {
+		if err := lead.Check(ctx); err == nil {
+			return lead
+		} else if ctx.Err() == nil {
+			slog.WarnContext(ctx, "scheduler leadership lost", logging.Err(err))
+		}
+		lead.Release(context.WithoutCancel(ctx))
+	}
+	lead, err := repository.TryLead(ctx, s.pool, leaderLockID)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "scheduler election failed", logging.Err(err))
+		}
+		return nil
+	}
+	if lead != nil {
+		slog.InfoContext(ctx, "elected scheduler leader")
+	}
+	return lead
+}
+
+// drain claims due schedules and places their orders batch by batch until none is due or
+// claiming fails. Runs left pending by an earlier failure are placed again.
+func (s *Scheduler) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		now := time.Now().UTC()
+		claimed, err := s.repo.ClaimDue(ctx, now, s.cfg.BatchSize, func(sched *domain.Schedule) *time.Time {
+			if next, ok := NextRun(sched, now); ok {
+				return &next
+			}
+			return nil
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "scheduler claim failed", logging.Err(err))
+			return
+		}
+		if err := s.placePending(ctx); err != nil {
+			slog.ErrorContext(ctx, "scheduler failed", logging.Err(err))
+			return
+		}
+		if len(claimed) < s.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+// placePending creates the orders of pending runs, each with a new request ID that the saga of
+// its order carries. A run whose order cannot be created or published stays pending with the
+// error and is tried again at the next poll, which publishes an order stored by the failed try.
+func (s *Scheduler) placePending(ctx context.Context) error {
+	runs, err := s.repo.ListPendingRuns(ctx, s.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	for _, run := range runs {
+		rctx := logging.With(requestid.NewContext(ctx, requestid.New()),
+			logging.OrderID(run.OrderID), logging.UserID(run.UserID), slog.String("schedule_id", run.ScheduleID.String()))
+		_, err := s.orders.CreateOrder(rctx, dto.CreateOrderRequest{OrderID: run.OrderID, UserID: run.UserID, Amount: run.Amount})
+		if ctx.Err() != nil {
+			return nil
+		}
+		status, lastError := domain.RunCreated, ""
+		if err != nil {
+			slog.ErrorContext(rctx, "create scheduled order failed", logging.Err(err))
+			status, lastError = domain.RunPending, err.Error()
+		} else {
+			slog.InfoContext(rctx, "scheduled order placed", "scheduled_for", run.ScheduledFor.Format(time.RFC3339))
+		}
+		if err := s.repo.UpdateRun(ctx, run.ID, status, lastError, time.Now().UTC()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
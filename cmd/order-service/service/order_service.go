@@ -3,24 +3,51 @@ package service
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 
 	"go_example/internal/events"
+	"go_example/internal/logging"
 	"go_example/cmd/order-service/domain"
 	"go_example/cmd/order-service/dto"
 	"go_example/cmd/order-service/repository"
 )
 
-var ErrOrderNotFound = errors.New("order not found")
+var (
+	ErrOrderNotFound        = errors.New("order not found")
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for another order")
+)
+
+// idempotencyNamespace derives order IDs from idempotency keys (UUIDv5), so that a repeated
+// request names the order the first one created.
+var idempotencyNamespace = uuid.MustParse("5b0e1b7c-3f7a-4c7e-9d2a-6f1e0c8a4b1d")
+
+// conflictRetries bounds how often an update is retried after an optimistic concurrency conflict.
+const conflictRetries = 3
+
+// OrderRepository stores orders. Implemented by repository.OrderRepository (orders table) and
+// repository.EventSourcedOrderRepository (event streams, with the table as a projection).
+type OrderRepository interface {
+	// Create stores o and calls changed in the same transaction.
+	Create(ctx context.Context, o *domain.Order, changed repository.OrderChangeFunc) error
+	// GetByID returns pgx.ErrNoRows for an unknown order.
+	GetByID(ctx context.Context, id uuid.UUID) (*domain.Order, error)
+	// UpdateStatus moves o to status, calling changed in the same transaction, and advances
+	// o.Version. It returns repository.ErrVersionConflict if o changed since it was read.
+	UpdateStatus(ctx context.Context, o *domain.Order, status events.OrderStatus, changed repository.OrderChangeFunc) error
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]*domain.Order, error)
+}
 
 // OrderService implements order business logic and saga coordination.
 type OrderService struct {
-	repo   *repository.OrderRepository
-	writer OrderEventWriter
+	repo      OrderRepository
+	eventRepo *repository.OrderEventRepository
+	writer    OrderEventWriter
+	changes   OrderChangeNotifier
 }
 
 // OrderEventWriter publishes order events to Kafka.
@@ -29,30 +56,75 @@ type OrderEventWriter interface {
 	PublishOrderCanceled(ctx context.Context, evt events.OrderCanceledEvent) error
 }
 
+// OrderChangeNotifier is told about every stored order state change. Implemented by WebhookService.
+type OrderChangeNotifier interface {
+	// OrderChanged is called in the transaction storing the change, with the order in its new
+	// state and the status it had before, empty for a new order. An error rolls the change back.
+	OrderChanged(ctx context.Context, tx pgx.Tx, o *domain.Order, previous events.OrderStatus) error
+	// ChangeCommitted is called after a transaction in which OrderChanged was called commits.
+	ChangeCommitted()
+}
+
 // NewOrderService creates a new OrderService.
-func NewOrderService(repo *repository.OrderRepository, writer OrderEventWriter) *OrderService {
-	return &OrderService{repo: repo, writer: writer}
+func NewOrderService(repo OrderRepository, eventRepo *repository.OrderEventRepository, writer OrderEventWriter, changes OrderChangeNotifier) *OrderService {
+	return &OrderService{repo: repo, eventRepo: eventRepo, writer: writer, changes: changes}
 }
 
-// CreateOrder creates an order with PENDING status and publishes OrderCreatedEvent.
+// CreateOrder creates an order with PENDING status and publishes OrderCreatedEvent. With
+// req.OrderID set, or derived from the user and req.IdempotencyKey, an order that already has
+// that ID is returned as it is; while it is still PENDING its OrderCreatedEvent is published
+// again, since an earlier call may have stored the order and failed to publish. Consumers apply
+// order.created once per order. A key repeated with another amount returns
+// ErrIdempotencyKeyReused.
 func (s *OrderService) CreateOrder(ctx context.Context, req dto.CreateOrderRequest) (*dto.OrderResponse, error) {
+	id := req.OrderID
+	if id == uuid.Nil && req.IdempotencyKey != "" {
+		id = uuid.NewSHA1(idempotencyNamespace, []byte(req.UserID.String()+"\x00"+req.IdempotencyKey))
+	}
+	if id == uuid.Nil {
+		id = uuid.New()
+	} else if o, err := s.repo.GetByID(ctx, id); err == nil {
+		if o.UserID != req.UserID || o.Amount != req.Amount {
+			return nil, ErrIdempotencyKeyReused
+		}
+		if o.Status == events.OrderStatusPending {
+			if err := s.publishCreated(ctx, o); err != nil {
+				return nil, err
+			}
+		}
+		return toOrderResponse(o), nil
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
 	o := &domain.Order{
-		ID:        uuid.New(),
+		ID:        id,
 		UserID:    req.UserID,
 		Amount:    req.Amount,
 		Status:    events.OrderStatusPending,
 		CreatedAt: time.Now(),
 	}
-	if err := s.repo.Create(ctx, o); err != nil {
+	if err := s.repo.Create(ctx, o, s.recordChange); err != nil {
+		// A concurrent request with the same ID created it first and publishes it.
+		if req.OrderID != uuid.Nil || req.IdempotencyKey != "" {
+			if existing, gerr := s.repo.GetByID(ctx, id); gerr == nil {
+				return toOrderResponse(existing), nil
+			}
+		}
 		return nil, err
 	}
-	evt := events.OrderCreatedEvent{OrderID: o.ID, UserID: o.UserID, Amount: o.Amount}
-	if err := s.writer.PublishOrderCreated(ctx, evt); err != nil {
+	s.changes.ChangeCommitted()
+	if err := s.publishCreated(ctx, o); err != nil {
 		return nil, err
 	}
 	return toOrderResponse(o), nil
 }
 
+// publishCreated publishes the OrderCreatedEvent of o.
+func (s *OrderService) publishCreated(ctx context.Context, o *domain.Order) error {
+	evt := events.OrderCreatedEvent{OrderID: o.ID, UserID: o.UserID, Amount: o.Amount}
+	return s.writer.PublishOrderCreated(ctx, evt)
+}
+
 // GetByID returns an order by ID.
 func (s *OrderService) GetByID(ctx context.Context, id uuid.UUID) (*dto.OrderResponse, error) {
 	o, err := s.repo.GetByID(ctx, id)
@@ -78,18 +150,19 @@ func (s *OrderService) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*d
 	return out, nil
 }
 
-// ConfirmOrder sets order status to CONFIRMED.
+// ConfirmOrder sets the status of a PENDING order to CONFIRMED once its payment succeeded. An
+// order canceled meanwhile stays canceled; its payment is refunded by payment-service.
 func (s *OrderService) ConfirmOrder(ctx context.Context, orderID uuid.UUID) error {
-	o, err := s.repo.GetByID(ctx, orderID)
-	if err != nil {
-		return err
+	prev, err := s.updateStatus(ctx, orderID, events.OrderStatusConfirmed, events.OrderStatusPending)
+	if err == nil && prev.Status != events.OrderStatusPending {
+		slog.InfoContext(ctx, "order not confirmed", logging.OrderID(orderID), "status", string(prev.Status))
 	}
-	return s.repo.UpdateStatus(ctx, o.ID, events.OrderStatusConfirmed)
+	return err
 }
 
 // CancelOrder sets status to CANCELED and publishes OrderCanceledEvent (compensation).
 func (s *OrderService) CancelOrder(ctx context.Context, orderID uuid.UUID) error {
-	o, err := s.repo.GetByID(ctx, orderID)
+	o, err := s.updateStatus(ctx, orderID, events.OrderStatusCanceled)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return ErrOrderNotFound
@@ -97,19 +170,90 @@ func (s *OrderService) CancelOrder(ctx context.Context, orderID uuid.UUID) error
 		return err
 	}
 	prev := o.Status
-	if err := s.repo.UpdateStatus(ctx, o.ID, events.OrderStatusCanceled); err != nil {
-		return err
-	}
 	if prev == events.OrderStatusPending || prev == events.OrderStatusConfirmed {
 		evt := events.OrderCanceledEvent{OrderID: o.ID, UserID: o.UserID, Amount: o.Amount}
 		if err := s.writer.PublishOrderCanceled(ctx, evt); err != nil {
-			log.Printf("[order-service] PublishOrderCanceled failed (order %s): %v", o.ID, err)
+			slog.ErrorContext(ctx, "publish OrderCanceledEvent failed", logging.OrderID(o.ID), logging.Err(err))
 			return err
 		}
 	}
 	return nil
 }
 
+// RecordEvent appends a saga event to its order's history. Recording the same message again is a no-op.
+func (s *OrderService) RecordEvent(ctx context.Context, e *domain.OrderEvent) error {
+	return s.eventRepo.Append(ctx, e)
+}
+
+// Timeline returns the saga history of an order, oldest first, with the time between steps.
+func (s *OrderService) Timeline(ctx context.Context, orderID uuid.UUID) (*dto.TimelineResponse, error) {
+	if _, err := s.repo.GetByID(ctx, orderID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, err
+	}
+	list, err := s.eventRepo.ListByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	resp := &dto.TimelineResponse{OrderID: orderID, Events: make([]dto.TimelineEvent, len(list))}
+	for i, e := range list {
+		resp.Events[i] = dto.TimelineEvent{
+			Topic:      e.Topic,
+			Service:    e.Service,
+			Partition:  e.Partition,
+			Offset:     e.Offset,
+			Time:       e.EventTime,
+			RecordedAt: e.RecordedAt,
+			Payload:    e.Payload,
+		}
+		if i > 0 {
+			resp.Events[i].SincePreviousMs = e.EventTime.Sub(list[i-1].EventTime).Milliseconds()
+		}
+	}
+	if len(list) > 0 {
+		resp.TotalElapsedMs = list[len(list)-1].EventTime.Sub(list[0].EventTime).Milliseconds()
+	}
+	return resp, nil
+}
+
+// updateStatus reads the order and moves it to status, re-reading it after a concurrent update.
+// With from statuses given, an order in none of them is left unchanged. It returns the order as
+// it was before the update.
+func (s *OrderService) updateStatus(ctx context.Context, orderID uuid.UUID, status events.OrderStatus, from ...events.OrderStatus) (*domain.Order, error) {
+	var err error
+	for range conflictRetries {
+		var o *domain.Order
+		if o, err = s.repo.GetByID(ctx, orderID); err != nil {
+			return nil, err
+		}
+		prev := *o
+		if len(from) > 0 && !slices.Contains(from, o.Status) {
+			return &prev, nil
+		}
+		if err = s.repo.UpdateStatus(ctx, o, status, s.recordChange); err == nil {
+			if o.Status != prev.Status {
+				s.changes.ChangeCommitted()
+			}
+			return &prev, nil
+		}
+		if !errors.Is(err, repository.ErrVersionConflict) {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+// recordChange reports a state change of o in the transaction that stores it, so the change
+// and what the notifier records for it, such as webhook deliveries, commit together.
+func (s *OrderService) recordChange(ctx context.Context, tx pgx.Tx, o *domain.Order, previous events.OrderStatus) error {
+	if o.Status == previous {
+		return nil
+	}
+	return s.changes.OrderChanged(ctx, tx, o, previous)
+}
+
 func toOrderResponse(o *domain.Order) *dto.OrderResponse {
 	return &dto.OrderResponse{
 		ID:        o.ID,
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"go_example/internal/logging"
+	"go_example/cmd/order-service/domain"
+	"go_example/cmd/order-service/dto"
+	"go_example/cmd/order-service/repository"
+	"go_example/cmd/order-service/schedule"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+)
+
+// recentRuns bounds the runs returned with a schedule.
+const recentRuns = 20
+
+// ScheduleService manages order schedules and tracks the saga outcome of the orders they place.
+type ScheduleService struct {
+	repo       *repository.ScheduleRepository
+	pauseAfter int
+}
+
+// NewScheduleService creates a new ScheduleService that pauses a schedule after pauseAfter
+// runs in a row whose credit reservation failed.
+func NewScheduleService(repo *repository.ScheduleRepository, pauseAfter int) *ScheduleService {
+	return &ScheduleService{repo: repo, pauseAfter: pauseAfter}
+}
+
+// Create validates and stores an active schedule.
+func (s *ScheduleService) Create(ctx context.Context, req dto.CreateScheduleRequest) (*dto.ScheduleResponse, error) {
+	if req.UserID == uuid.Nil {
+		return nil, fmt.Errorf("%w: userId is required", ErrInvalidSchedule)
+	}
+	if req.Repeat == "" {
+		req.Repeat = domain.RepeatOnce
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	now := time.Now().UTC()
+	sched := &domain.Schedule{
+		ID:        uuid.New(),
+		UserID:    req.UserID,
+		Amount:    req.Amount,
+		Repeat:    req.Repeat,
+		RunAt:     utc(req.RunAt),
+		Cron:      req.Cron,
+		Timezone:  req.Timezone,
+		Status:    domain.ScheduleActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := prepare(sched, now); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, sched); err != nil {
+		return nil, err
+	}
+	return toScheduleResponse(sched), nil
+}
+
+// Get returns a schedule with its latest runs.
+func (s *ScheduleService) Get(ctx context.Context, id uuid.UUID) (*dto.ScheduleResponse, error) {
+	sched, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := s.repo.ListRuns(ctx, id, recentRuns)
+	if err != nil {
+		return nil, err
+	}
+	resp := toScheduleResponse(sched)
+	resp.Runs = make([]dto.ScheduleRunResponse, len(runs))
+	for i, run := range runs {
+		resp.Runs[i] = dto.ScheduleRunResponse{
+			ScheduledFor: run.ScheduledFor,
+			OrderID:      run.OrderID,
+			Status:       run.Status,
+			Error:        run.Error,
+			CreatedAt:    run.CreatedAt,
+			UpdatedAt:    run.UpdatedAt,
+		}
+	}
+	return resp, nil
+}
+
+// ListByUserID returns the schedules of a user.
+func (s *ScheduleService) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*dto.ScheduleResponse, error) {
+	list, err := s.repo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*dto.ScheduleResponse, len(list))
+	for i, sched := range list {
+		out[i] = toScheduleResponse(sched)
+	}
+	return out, nil
+}
+
+// Update changes the fields of a schedule set in req. A schedule resumed by req, or whose
+// recurrence req changes, runs next at its first run from now on; a completed one can be made
+// active again by giving it a future run.
+func (s *ScheduleService) Update(ctx context.Context, id uuid.UUID, req dto.UpdateScheduleRequest) (*dto.ScheduleResponse, error) {
+	sched, err := s.get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	wasActive, nextRunAt := sched.Status == domain.ScheduleActive, sched.NextRunAt
+	recurrence := req.Repeat != nil || req.RunAt != nil || req.Cron != nil || req.Timezone != nil
+	if req.Amount != nil {
+		sched.Amount = *req.Amount
+	}
+	if req.Repeat != nil {
+		sched.Repeat = *req.Repeat
+		if sched.Repeat != domain.RepeatCron && req.Cron == nil {
+			sched.Cron = ""
+		}
+	}
+	if req.RunAt != nil {
+		sched.RunAt = utc(req.RunAt)
+	}
+	if req.Cron != nil {
+		sched.Cron = *req.Cron
+	}
+	if req.Timezone != nil {
+		sched.Timezone = *req.Timezone
+	}
+	switch {
+	case req.Status != nil && *req.Status != domain.ScheduleActive && *req.Status != domain.SchedulePaused:
+		return nil, fmt.Errorf("%w: status must be %q or %q", ErrInvalidSchedule, domain.ScheduleActive, domain.SchedulePaused)
+	case req.Status != nil:
+		sched.Status = *req.Status
+	case recurrence && sched.Status == domain.ScheduleCompleted:
+		sched.Status = domain.ScheduleActive
+	}
+	now := time.Now().UTC()
+	if sched.Status == domain.SchedulePaused && sched.PausedAt == nil {
+		sched.PausedAt, sched.PausedReason = &now, "paused by request"
+	}
+	sched.UpdatedAt = now
+	if err := prepare(sched, now); err != nil {
+		return nil, err
+	}
+	if wasActive && !recurrence && sched.Status == domain.ScheduleActive {
+		sched.NextRunAt = nextRunAt
+	}
+	ok, err := s.repo.Update(ctx, sched)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	return s.Get(ctx, id)
+}
+
+// Delete removes a schedule. Orders it placed are kept.
+func (s *ScheduleService) Delete(ctx context.Context, id uuid.UUID) error {
+	ok, err := s.repo.Delete(ctx, id)
+	if err == nil && !ok {
+		return ErrScheduleNotFound
+	}
+	return err
+}
+
+// OrderConfirmed records that an order placed by a schedule was confirmed. Other orders are ignored.
+func (s *ScheduleService) OrderConfirmed(ctx context.Context, orderID uuid.UUID) error {
+	_, err := s.repo.RecordOutcome(ctx, orderID, true, "", s.pauseAfter, time.Now().UTC())
+	return err
+}
+
+// CreditFailed records that the credit reservation of an order placed by a schedule failed,
+// pausing the schedule after too many such failures in a row. Other orders are ignored.
+func (s *ScheduleService) CreditFailed(ctx context.Context, orderID uuid.UUID, reason string) error {
+	paused, err := s.repo.RecordOutcome(ctx, orderID, false, reason, s.pauseAfter, time.Now().UTC())
+	if err == nil && paused != nil {
+		slog.WarnContext(ctx, "schedule paused after consecutive credit failures", "schedule_id", paused.ID.String(),
+			logging.UserID(paused.UserID), "failures", paused.ConsecutiveFailures, "reason", reason)
+	}
+	return err
+}
+
+func (s *ScheduleService) get(ctx context.Context, id uuid.UUID) (*domain.Schedule, error) {
+	sched, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrScheduleNotFound
+		}
+		return nil, err
+	}
+	return sched, nil
+}
+
+// prepare validates sched and sets its next run from now on if it is active. Other statuses
+// have no next run; only a paused schedule keeps why it was paused.
+func prepare(sched *domain.Schedule, now time.Time) error {
+	if sched.Amount < 1 {
+		return fmt.Errorf("%w: amount must be positive", ErrInvalidSchedule)
+	}
+	if err := schedule.Validate(sched); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+	}
+	sched.NextRunAt = nil
+	if sched.Status != domain.SchedulePaused {
+		sched.PausedAt, sched.PausedReason = nil, ""
+	}
+	if sched.Status != domain.ScheduleActive {
+		return nil
+	}
+	next, ok := schedule.NextRun(sched, now)
+	if !ok {
+		return fmt.Errorf("%w: no run after now; set a future runAt", ErrInvalidSchedule)
+	}
+	sched.NextRunAt = &next
+	return nil
+}
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+func toScheduleResponse(s *domain.Schedule) *dto.ScheduleResponse {
+	return &dto.ScheduleResponse{
+		ID:                  s.ID,
+		UserID:              s.UserID,
+		Amount:              s.Amount,
+		Repeat:              s.Repeat,
+		RunAt:               s.RunAt,
+		Cron:                s.Cron,
+		Timezone:            s.Timezone,
+		Status:              s.Status,
+		NextRunAt:           s.NextRunAt,
+		ConsecutiveFailures: s.ConsecutiveFailures,
+		PausedAt:            s.PausedAt,
+		PausedReason:        s.PausedReason,
+		CreatedAt:           s.CreatedAt,
+		UpdatedAt:           s.UpdatedAt,
+	}
+}
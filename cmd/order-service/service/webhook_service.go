@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"go_example/internal/events"
+	"go_example/cmd/order-service/domain"
+	"go_example/cmd/order-service/dto"
+	"go_example/cmd/order-service/repository"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrInvalidWebhook   = errors.New("invalid webhook")
+	// ErrWebhookDisabled is returned when redelivering to a disabled webhook; enable it first.
+	ErrWebhookDisabled = errors.New("webhook is disabled")
+)
+
+const (
+	// minSecretLength is the shortest secret accepted for signing deliveries.
+	minSecretLength = 16
+	// recentDeliveries bounds the deliveries listed per webhook.
+	recentDeliveries = 50
+)
+
+// WebhookService manages webhook subscriptions and queues order state changes for delivery.
+type WebhookService struct {
+	repo *repository.WebhookRepository
+	wake func()
+}
+
+// NewWebhookService creates a new WebhookService. wake is called once queued deliveries are committed.
+func NewWebhookService(repo *repository.WebhookRepository, wake func()) *WebhookService {
+	return &WebhookService{repo: repo, wake: wake}
+}
+
+// Create validates and stores a webhook. Its secret is generated if the request has none.
+func (s *WebhookService) Create(ctx context.Context, req dto.CreateWebhookRequest) (*dto.WebhookResponse, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an http(s) URL", ErrInvalidWebhook)
+	}
+	if req.Events == nil {
+		req.Events = []string{}
+	}
+	for _, e := range req.Events {
+		if !slices.Contains(domain.WebhookEventTypes, e) {
+			return nil, fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, e)
+		}
+	}
+	if req.Secret == "" {
+		b := make([]byte, 24)
+		rand.Read(b)
+		req.Secret = "whsec_" + hex.EncodeToString(b)
+	} else if len(req.Secret) < minSecretLength {
+		return nil, fmt.Errorf("%w: secret must be at least %d characters", ErrInvalidWebhook, minSecretLength)
+	}
+	w := &domain.Webhook{
+		ID:        uuid.New(),
+		URL:       req.URL,
+		Events:    req.Events,
+		Secret:    req.Secret,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.Create(ctx, w); err != nil {
+		return nil, err
+	}
+	resp := toWebhookResponse(w)
+	resp.Secret = w.Secret
+	return resp, nil
+}
+
+// Get returns a webhook by ID.
+func (s *WebhookService) Get(ctx context.Context, id uuid.UUID) (*dto.WebhookResponse, error) {
+	w, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return toWebhookResponse(w), nil
+}
+
+// List returns all webhooks.
+func (s *WebhookService) List(ctx context.Context) ([]*dto.WebhookResponse, error) {
+	list, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*dto.WebhookResponse, len(list))
+	for i, w := range list {
+		out[i] = toWebhookResponse(w)
+	}
+	return out, nil
+}
+
+// Delete removes a webhook and its delivery log.
+func (s *WebhookService) Delete(ctx context.Context, id uuid.UUID) error {
+	ok, err := s.repo.Delete(ctx, id)
+	if err == nil && !ok {
+		return ErrWebhookNotFound
+	}
+	return err
+}
+
+// Enable re-enables a webhook disabled after repeated failures. Deliveries still retrying
+// resume; failed ones can be redelivered.
+func (s *WebhookService) Enable(ctx context.Context, id uuid.UUID) (*dto.WebhookResponse, error) {
+	ok, err := s.repo.Enable(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrWebhookNotFound
+	}
+	s.wake()
+	return s.Get(ctx, id)
+}
+
+// Deliveries returns the latest deliveries of a webhook with their attempts.
+func (s *WebhookService) Deliveries(ctx context.Context, id uuid.UUID) ([]*dto.WebhookDeliveryResponse, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	list, err := s.repo.ListDeliveries(ctx, id, recentDeliveries)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*dto.WebhookDeliveryResponse, len(list))
+	for i, d := range list {
+		out[i] = toWebhookDeliveryResponse(d)
+	}
+	return out, nil
+}
+
+// Redeliver sends a delivery of an active webhook again now, with a fresh set of attempts.
+func (s *WebhookService) Redeliver(ctx context.Context, webhookID uuid.UUID, deliveryID int64) error {
+	w, err := s.Get(ctx, webhookID)
+	if err != nil {
+		return err
+	}
+	if !w.Active {
+		return ErrWebhookDisabled
+	}
+	ok, err := s.repo.Redeliver(ctx, webhookID, deliveryID, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrDeliveryNotFound
+	}
+	s.wake()
+	return nil
+}
+
+// OrderChanged queues, in tx, a delivery of o's new state to every active webhook subscribed
+// to it. previous is the status o had before, empty for a new order.
+func (s *WebhookService) OrderChanged(ctx context.Context, tx pgx.Tx, o *domain.Order, previous events.OrderStatus) error {
+	eventType := domain.WebhookEventType(o.Status)
+	if eventType == "" {
+		return nil
+	}
+	evt := dto.WebhookEvent{
+		ID:             uuid.New(),
+		Type:           eventType,
+		OccurredAt:     time.Now(),
+		PreviousStatus: previous,
+		Data:           toOrderResponse(o),
+	}
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	_, err = s.repo.Enqueue(ctx, tx, evt.ID, eventType, o.ID, payload, evt.OccurredAt)
+	return err
+}
+
+// ChangeCommitted wakes the dispatcher to send the deliveries queued by OrderChanged.
+func (s *WebhookService) ChangeCommitted() {
+	s.wake()
+}
+
+func toWebhookResponse(w *domain.Webhook) *dto.WebhookResponse {
+	return &dto.WebhookResponse{
+		ID:                  w.ID,
+		URL:                 w.URL,
+		Events:              w.Events,
+		Active:              w.Active,
+		ConsecutiveFailures: w.ConsecutiveFailures,
+		DisabledAt:          w.DisabledAt,
+		DisabledReason:      w.DisabledReason,
+		CreatedAt:           w.CreatedAt,
+	}
+}
+
+func toWebhookDeliveryResponse(d *domain.WebhookDelivery) *dto.WebhookDeliveryResponse {
+	resp := &dto.WebhookDeliveryResponse{
+		ID:            d.ID,
+		EventID:       d.EventID,
+		Event:         d.EventType,
+		OrderID:       d.OrderID,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		NextAttemptAt: d.NextAttemptAt,
+		LastError:     d.LastError,
+		DeliveredAt:   d.DeliveredAt,
+		CreatedAt:     d.CreatedAt,
+		UpdatedAt:     d.UpdatedAt,
+		Payload:       d.Payload,
+		AttemptLog:    make([]dto.WebhookAttemptResponse, len(d.AttemptLog)),
+	}
+	for i, a := range d.AttemptLog {
+		resp.AttemptLog[i] = dto.WebhookAttemptResponse{
+			Attempt:     a.Attempt,
+			StatusCode:  a.StatusCode,
+			Error:       a.Error,
+			DurationMs:  a.Duration.Milliseconds(),
+			AttemptedAt: a.AttemptedAt,
+		}
+	}
+	return resp
+}
@@ -0,0 +1,167 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go_example/internal/logging"
+	"go_example/cmd/order-service/config"
+	"go_example/cmd/order-service/domain"
+	"go_example/cmd/order-service/repository"
+)
+
+// maxErrorBody bounds how much of a failed response is kept in the attempt log.
+const maxErrorBody = 256
+
+// Dispatcher polls for due webhook deliveries and POSTs them. A failed delivery is retried with
+// exponential backoff until it succeeds or runs out of attempts; a webhook failing too many
+// times in a row is disabled.
+type Dispatcher struct {
+	repo   *repository.WebhookRepository
+	client *http.Client
+	cfg    config.WebhookConfig
+	wake   chan struct{}
+}
+
+// NewDispatcher creates a new Dispatcher.
+func NewDispatcher(repo *repository.WebhookRepository, cfg config.WebhookConfig) *Dispatcher {
+	return &Dispatcher{
+		repo:   repo,
+		client: &http.Client{Timeout: cfg.Timeout},
+		cfg:    cfg,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Wake makes Run look for due deliveries now instead of at the next interval.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due deliveries every poll interval, or when woken, until ctx is canceled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	tick := time.NewTicker(d.cfg.PollInterval)
+	defer tick.Stop()
+	for {
+		d.drain(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// drain sends batches until no delivery is due or claiming fails.
+func (d *Dispatcher) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := d.dispatchBatch(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "webhook dispatch failed", logging.Err(err))
+			return
+		}
+		if n < d.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) dispatchBatch(ctx context.Context) (int, error) {
+	now := time.Now()
+	due, err := d.repo.ClaimDue(ctx, now, now.Add(d.cfg.Lease), d.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	var wg sync.WaitGroup
+	for _, dd := range due {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.send(ctx, dd)
+		}()
+	}
+	wg.Wait()
+	return len(due), nil
+}
+
+// send POSTs one claimed delivery and records the attempt. The attempt is recorded even if ctx
+// is canceled meanwhile; if recording fails, the delivery is retried once its lease expires.
+func (d *Dispatcher) send(ctx context.Context, dd *repository.DueWebhookDelivery) {
+	del := &dd.Delivery
+	ctx = logging.With(ctx, logging.OrderID(del.OrderID), slog.String("webhook_id", del.WebhookID.String()),
+		slog.Int64("delivery_id", del.ID), slog.String("event", del.EventType))
+	start := time.Now()
+	status, err := d.post(ctx, dd, start)
+	a := &domain.WebhookAttempt{
+		DeliveryID:  del.ID,
+		Attempt:     del.Attempts,
+		StatusCode:  status,
+		Duration:    time.Since(start),
+		AttemptedAt: start,
+	}
+	var next *time.Time
+	if err != nil {
+		a.Error = err.Error()
+		if del.Attempts < d.cfg.MaxAttempts {
+			t := time.Now().Add(d.backoff(del.Attempts))
+			next = &t
+		}
+		slog.WarnContext(ctx, "webhook delivery failed", "attempt", del.Attempts, logging.Err(err))
+	}
+	disabled, err := d.repo.RecordAttempt(context.WithoutCancel(ctx), del, a, next, d.cfg.DisableAfter)
+	if err != nil {
+		slog.ErrorContext(ctx, "record webhook delivery attempt failed", logging.Err(err))
+		return
+	}
+	if disabled {
+		slog.WarnContext(ctx, "webhook disabled after consecutive failures", "failures", d.cfg.DisableAfter)
+	}
+}
+
+// post sends the delivery and returns the response status, nil if none was received. Any
+// status other than 2xx is an error.
+func (d *Dispatcher) post(ctx context.Context, dd *repository.DueWebhookDelivery, at time.Time) (*int, error) {
+	del := &dd.Delivery
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dd.URL, bytes.NewReader(del.Payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "order-service-webhooks")
+	req.Header.Set(EventHeader, del.EventType)
+	req.Header.Set(EventIDHeader, del.EventID.String())
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(del.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(dd.Secret, at, del.Payload))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return &resp.StatusCode, fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+	return &resp.StatusCode, nil
+}
+
+// backoff returns the wait after failed attempt n (1-based): Backoff doubled per attempt,
+// capped at MaxBackoff.
+func (d *Dispatcher) backoff(n int) time.Duration {
+	b := d.cfg.Backoff
+	for i := 1; i < n && b < d.cfg.MaxBackoff; i++ {
+		b *= 2
+	}
+	return min(b, d.cfg.MaxBackoff)
+}
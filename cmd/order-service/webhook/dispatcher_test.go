@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go_example/cmd/order-service/config"
+	"go_example/cmd/order-service/domain"
+	"go_example/cmd/order-service/repository"
+)
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil, config.WebhookConfig{Backoff: time.Second, MaxBackoff: 10 * time.Second})
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := d.backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+
+	capped := NewDispatcher(nil, config.WebhookConfig{Backoff: time.Minute, MaxBackoff: 30 * time.Second})
+	if got := capped.backoff(1); got != 30*time.Second {
+		t.Errorf("backoff above MaxBackoff = %v, want 30s", got)
+	}
+}
+
+func TestPost(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	dd := &repository.DueWebhookDelivery{
+		Delivery: domain.WebhookDelivery{
+			ID:        7,
+			EventID:   uuid.New(),
+			EventType: "order.completed",
+			Payload:   []byte(`{"orderId":"o1"}`),
+		},
+		Secret: "whsec_test",
+	}
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"delivered", http.StatusNoContent, false},
+		{"rejected", http.StatusBadRequest, true},
+		{"failing", http.StatusBadGateway, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			var body string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				got, body = r, string(b)
+				w.WriteHeader(tt.status)
+				io.WriteString(w, " bad signature\n")
+			}))
+			defer srv.Close()
+			dd.URL = srv.URL
+
+			status, err := NewDispatcher(nil, config.WebhookConfig{Timeout: time.Second}).post(context.Background(), dd, at)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("post error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if status == nil || *status != tt.status {
+				t.Fatalf("post status = %v, want %d", status, tt.status)
+			}
+			if tt.wantErr && err.Error() != fmt.Sprintf("status %d: bad signature", tt.status) {
+				t.Errorf("post error = %q", err)
+			}
+			if body != string(dd.Delivery.Payload) {
+				t.Errorf("body = %s", body)
+			}
+			if s := got.Header.Get(SignatureHeader); s != Sign(dd.Secret, at, dd.Delivery.Payload) {
+				t.Errorf("%s = %q", SignatureHeader, s)
+			}
+			if got.Header.Get(EventHeader) != "order.completed" || got.Header.Get(EventIDHeader) != dd.Delivery.EventID.String() || got.Header.Get(DeliveryHeader) != "7" {
+				t.Errorf("headers %v", got.Header)
+			}
+		})
+	}
+
+	// No response at all: no status.
+	dd.URL = "http://127.0.0.1:1"
+	if status, err := NewDispatcher(nil, config.WebhookConfig{Timeout: time.Second}).post(context.Background(), dd, at); err == nil || status != nil {
+		t.Errorf("post to closed port: status %v, error %v", status, err)
+	}
+}
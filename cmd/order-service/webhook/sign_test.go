@@ -0,0 +1,38 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	body := []byte(`{"orderId":"o1"}`)
+	want := "t=1767225600,v1=78dd8682b40b0d7077fc206abe981e7c4bf7eed6b6501d5b29f9812f522b0392"
+	if got := Sign("whsec_test", at, body); got != want {
+		t.Fatalf("Sign = %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		at     time.Time
+		body   []byte
+	}{
+		{"other secret", "whsec_other", at, body},
+		{"other time", "whsec_test", at.Add(time.Second), body},
+		{"other body", "whsec_test", at, []byte(`{"orderId":"o2"}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, tt.at, tt.body); got == want {
+				t.Errorf("Sign = %q, want a different signature", got)
+			}
+		})
+	}
+
+	// Sub-second precision is not signed: the header carries unix seconds.
+	if got := Sign("whsec_test", at.Add(500*time.Millisecond), body); got != want {
+		t.Errorf("Sign half a second later = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,173 @@
+// Package app wires payment-service: database, HTTP API, payment provider, saga consumers and
+// outbox relay.
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/recover"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go_example/internal/bus"
+	"go_example/internal/lifecycle"
+	"go_example/internal/logging"
+	"go_example/internal/metrics"
+	"go_example/internal/requestid"
+	"go_example/internal/tracing"
+	"go_example/cmd/payment-service/config"
+	"go_example/cmd/payment-service/handler"
+	"go_example/cmd/payment-service/kafka"
+	"go_example/cmd/payment-service/migrations"
+	"go_example/cmd/payment-service/outbox"
+	"go_example/cmd/payment-service/provider"
+	"go_example/cmd/payment-service/repository"
+	"go_example/cmd/payment-service/service"
+)
+
+// Start starts payment-service on b and returns once it is serving. Its HTTP server, consumers,
+// outbox relay and database pool are stopped by lc in shutdown order; b is owned and closed by
+// the caller.
+func Start(cfg *config.Config, b bus.Bus, lc *lifecycle.Manager) error {
+	paymentProvider, err := newProvider(cfg)
+	if err != nil {
+		return err
+	}
+
+	pool, err := tracing.OpenPool(context.Background(), cfg.DB.DSN())
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	lc.OnShutdown(lifecycle.PhaseStorage, "payment-service db", func(context.Context) error {
+		pool.Close()
+		return nil
+	})
+
+	if err := runMigrations(pool); err != nil {
+		return fmt.Errorf("migrations: %w", err)
+	}
+
+	paymentRepo := repository.NewPaymentRepository(pool)
+	txManager := repository.NewTxManager(pool)
+	paymentSvc := service.NewPaymentService(paymentRepo, txManager, paymentProvider, cfg.Payment)
+	paymentHandler := handler.NewPaymentHandler(paymentSvc, handler.Owners{AdminScope: cfg.AdminScope})
+
+	consumer := kafka.NewConsumer(paymentSvc, b, cfg.Consumer, cfg.Payment.ChargeAfter)
+	relay := outbox.NewRelay(txManager, b, cfg.Outbox.PollInterval, cfg.Outbox.BatchSize)
+
+	metrics.RegisterHTTPMetrics("payment-service")
+	metrics.RegisterKafkaMetrics()
+
+	app := fiber.New()
+	app.Use(recover.New())
+	app.Use(requestid.Middleware())
+	app.Use(tracing.Middleware())
+	app.Use(logging.AccessLog())
+	app.Use(metrics.HTTPMiddleware())
+	app.Get("/metrics", metrics.MetricsHandler())
+	app.Get("/health", func(c fiber.Ctx) error { return c.JSON(fiber.Map{"status": "UP"}) })
+	app.Get("/ready", lc.ReadinessHandler())
+	app.Get("/payments", paymentHandler.List)
+	app.Get("/payments/:id", paymentHandler.GetByID)
+
+	go func() {
+		if err := app.Listen(":"+cfg.ServerPort, fiber.ListenConfig{DisableStartupMessage: true}); err != nil && err != http.ErrServerClosed {
+			logging.Fatal("listen failed", logging.Err(err))
+		}
+	}()
+	lc.OnShutdown(lifecycle.PhaseHTTP, "payment-service http", app.ShutdownWithContext)
+	if cfg.AdminPort != "" {
+		admin := logging.AdminApp()
+		go func() {
+			if err := admin.Listen(":"+cfg.AdminPort, fiber.ListenConfig{DisableStartupMessage: true}); err != nil && err != http.ErrServerClosed {
+				logging.Fatal("admin listen failed", logging.Err(err))
+			}
+		}()
+		lc.OnShutdown(lifecycle.PhaseHTTP, "payment-service admin http", admin.ShutdownWithContext)
+	}
+
+	consumerCtx, stopConsumer := context.WithCancel(context.Background())
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		consumer.Run(consumerCtx)
+	}()
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(relayCtx)
+	}()
+	// The relay stops after the consumers so replies they record while draining are published
+	// before the bus is closed.
+	lc.OnShutdown(lifecycle.PhaseConsumers, "payment-service consumers", func(ctx context.Context) error {
+		stopConsumer()
+		if err := wait(ctx, consumerDone); err != nil {
+			return err
+		}
+		stopRelay()
+		return wait(ctx, relayDone)
+	})
+	return nil
+}
+
+// newProvider returns the payment provider selected by PAYMENT_PROVIDER.
+func newProvider(cfg *config.Config) (provider.PaymentProvider, error) {
+	switch cfg.Payment.Provider {
+	case "fake", "":
+		f := cfg.FakeProvider
+		return provider.NewFake(provider.FakeConfig{
+			Latency:      f.Latency,
+			DeclineAbove: f.DeclineAbove,
+			DeclineRate:  f.DeclineRate,
+			TimeoutRate:  f.TimeoutRate,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown PAYMENT_PROVIDER %q", cfg.Payment.Provider)
+	}
+}
+
+// wait blocks until done is closed or ctx expires.
+func wait(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// advisoryLockID ensures only one instance runs migrations when multiple share the same DB.
+const advisoryLockID int64 = 0x7061796d656e74 // "payment"
+
+func runMigrations(pool *pgxpool.Pool) error {
+	ctx := context.Background()
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	_, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID)
+	if err != nil {
+		return err
+	}
+	defer conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", advisoryLockID)
+	// Migrations are idempotent and applied in file name order.
+	files, err := fs.Glob(migrations.FS, "*.up.sql")
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		data, err := migrations.FS.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if _, err := conn.Exec(ctx, string(data)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_example/internal/bus"
+	"go_example/internal/kafkaconn"
+	"go_example/internal/logging"
+	"go_example/internal/tracing"
+)
+
+// Config holds payment-service configuration. AdminPort serves GET|PUT /admin/log-level, kept off
+// the public ServerPort; empty disables it.
+type Config struct {
+	ServerPort    string
+	AdminPort     string
+	DB            DBConfig
+	Bus           string
+	Kafka         KafkaConfig
+	Consumer      ConsumerConfig
+	Outbox        OutboxConfig
+	Payment       PaymentConfig
+	FakeProvider  FakeProviderConfig
+	AdminScope    string
+	Tracing       tracing.Config
+	Logging       logging.Config
+	ShutdownGrace time.Duration
+	ShutdownDrain time.Duration
+}
+
+// DBConfig holds PostgreSQL configuration.
+type DBConfig struct {
+	Host     string
+	Port     string
+	Database string
+	User     string
+	Password string
+}
+
+// KafkaConfig holds Kafka connection (brokers, TLS, SASL) and topic configuration.
+type KafkaConfig struct {
+	kafkaconn.Config
+	ReplicationFactor int
+}
+
+// ConsumerConfig holds per-topic consumer processing configuration.
+type ConsumerConfig struct {
+	Concurrency        map[string]int
+	DefaultConcurrency int
+	MaxInFlight        int
+}
+
+// OutboxConfig holds outbox relay configuration.
+type OutboxConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// PaymentConfig holds charging configuration. A provider call is abandoned after Timeout; a
+// failed call is retried after RetryBackoff, doubling per attempt, until MaxAttempts. Credit
+// reservations published before ChargeAfter (zero: none) are not charged; set it when enabling
+// payments on a running system, whose earlier orders were confirmed without payment.
+type PaymentConfig struct {
+	Provider     string
+	Timeout      time.Duration
+	MaxAttempts  int
+	RetryBackoff time.Duration
+	ChargeAfter  time.Time
+}
+
+// FakeProviderConfig holds the behavior of the fake payment provider. Charges above
+// DeclineAbove (0: no limit) are declined; other charges are declined with probability
+// DeclineRate and time out with probability TimeoutRate, after taking Latency.
+type FakeProviderConfig struct {
+	Latency      time.Duration
+	DeclineAbove int64
+	DeclineRate  float64
+	TimeoutRate  float64
+}
+
+// Options returns the processing options for topic.
+func (c ConsumerConfig) Options(topic string) bus.Options {
+	n, ok := c.Concurrency[topic]
+	if !ok {
+		n = c.DefaultConcurrency
+	}
+	return bus.Options{Concurrency: n, MaxInFlight: c.MaxInFlight}
+}
+
+// Load reads configuration from environment.
+func Load() *Config {
+	return &Config{
+		ServerPort: getEnv("SERVER_PORT", "8097"),
+		AdminPort:  getEnv("ADMIN_PORT", "9080"),
+		DB: DBConfig{
+			Host:     getEnv("DB_HOST", "localhost"),
+			Port:     getEnv("DB_PORT", "5432"),
+			Database: getEnv("DB_NAME", "payment_db"),
+			User:     getEnv("DB_USER", "user"),
+			Password: getEnv("DB_PASSWORD", "password"),
+		},
+		Bus: getEnv("BUS", "kafka"),
+		Kafka: KafkaConfig{
+			Config:            kafkaconn.FromEnv(getEnvSlice("KAFKA_BOOTSTRAP_SERVERS", []string{"localhost:9092"})),
+			ReplicationFactor: getEnvInt("KAFKA_TOPIC_REPLICATION_FACTOR", 1),
+		},
+		Consumer: ConsumerConfig{
+			Concurrency:        getEnvIntMap("CONSUMER_CONCURRENCY"),
+			DefaultConcurrency: getEnvInt("CONSUMER_DEFAULT_CONCURRENCY", 4),
+			MaxInFlight:        getEnvInt("CONSUMER_MAX_IN_FLIGHT", bus.DefaultMaxInFlight),
+		},
+		Outbox: OutboxConfig{
+			PollInterval: getEnvDuration("OUTBOX_POLL_INTERVAL", 200*time.Millisecond),
+			BatchSize:    getEnvInt("OUTBOX_BATCH_SIZE", 100),
+		},
+		Payment: PaymentConfig{
+			Provider:     getEnv("PAYMENT_PROVIDER", "fake"),
+			Timeout:      getEnvDuration("PAYMENT_PROVIDER_TIMEOUT", 5*time.Second),
+			MaxAttempts:  getEnvInt("PAYMENT_MAX_ATTEMPTS", 3),
+			RetryBackoff: getEnvDuration("PAYMENT_RETRY_BACKOFF", time.Second),
+			ChargeAfter:  getEnvTime("PAYMENT_CHARGE_AFTER"),
+		},
+		FakeProvider: FakeProviderConfig{
+			Latency:      getEnvDuration("FAKE_PAYMENT_LATENCY", 100*time.Millisecond),
+			DeclineAbove: int64(getEnvInt("FAKE_PAYMENT_DECLINE_ABOVE", 10000)),
+			DeclineRate:  getEnvFloat("FAKE_PAYMENT_DECLINE_RATE", 0),
+			TimeoutRate:  getEnvFloat("FAKE_PAYMENT_TIMEOUT_RATE", 0),
+		},
+		AdminScope:    getEnv("JWT_ADMIN_SCOPE", "admin"),
+		Tracing:       tracing.FromEnv(),
+		Logging:       logging.FromEnv(),
+		ShutdownGrace: getEnvDuration("SHUTDOWN_GRACE_PERIOD", 20*time.Second),
+		ShutdownDrain: getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
+// getEnvTime parses an RFC 3339 time, returning the zero time if unset or invalid.
+func getEnvTime(key string) time.Time {
+	if v := os.Getenv(key); v != "" {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func getEnvSlice(key string, fallback []string) []string {
+	if v := os.Getenv(key); v != "" {
+		parts := strings.Split(v, ",")
+		out := make([]string, 0, len(parts))
+		for _, p := range parts {
+			if s := strings.TrimSpace(p); s != "" {
+				out = append(out, s)
+			}
+		}
+		if len(out) > 0 {
+			return out
+		}
+	}
+	return fallback
+}
+
+// getEnvIntMap parses key=value pairs such as "user.credit-reserved=8,order.canceled=2".
+func getEnvIntMap(key string) map[string]int {
+	out := map[string]int{}
+	for _, pair := range getEnvSlice(key, nil) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			out[strings.TrimSpace(k)] = n
+		}
+	}
+	return out
+}
+
+// DSN returns PostgreSQL connection string (password URL-escaped).
+func (c *DBConfig) DSN() string {
+	user := url.UserPassword(c.User, c.Password)
+	u := &url.URL{
+		Scheme:   "postgres",
+		User:     user,
+		Host:     c.Host + ":" + c.Port,
+		Path:     "/" + c.Database,
+		RawQuery: "sslmode=disable",
+	}
+	return u.String()
+}
@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+// OutboxMessage is an event recorded in the same transaction as the change that produced it,
+// waiting to be published. RequestID and TraceContext (W3C header fields) are those of that
+// change, so the published event continues its request and trace.
+type OutboxMessage struct {
+	ID           int64
+	Topic        string
+	Key          string
+	Payload      []byte
+	RequestID    string
+	TraceContext map[string]string
+	CreatedAt    time.Time
+}
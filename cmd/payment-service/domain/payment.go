@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Payment statuses. A payment is pending while its order is being charged and canceled if the
+// order was canceled before that started. A succeeded payment is refunded once its order is
+// canceled.
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusRefunded  = "refunded"
+	StatusCanceled  = "canceled"
+)
+
+// Payment is the charge of one order. ProviderRef and RefundRef are the provider's IDs of the
+// charge and its refund. CancelRequested is set when the order is canceled while the payment is
+// pending or succeeded, so the charge is refunded.
+type Payment struct {
+	ID              uuid.UUID
+	OrderID         uuid.UUID
+	UserID          uuid.UUID
+	Amount          int64
+	Status          string
+	Provider        string
+	ProviderRef     string
+	RefundRef       string
+	Attempts        int
+	Reason          string
+	CancelRequested bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// transitions lists the statuses a payment may move to from each status. Failed, refunded and
+// canceled payments are final.
+var transitions = map[string][]string{
+	StatusPending:   {StatusSucceeded, StatusFailed},
+	StatusSucceeded: {StatusRefunded},
+}
+
+// CanMoveTo reports whether the payment may move from its status to status.
+func (p *Payment) CanMoveTo(status string) bool {
+	return slices.Contains(transitions[p.Status], status)
+}
+
+// NeedsRefund reports whether the payment was charged for an order canceled meanwhile.
+func (p *Payment) NeedsRefund() bool {
+	return p.Status == StatusSucceeded && p.CancelRequested
+}
+
+// RequestCancel marks the payment of a canceled order so that its charge is refunded and
+// reports whether it changed. Only pending and succeeded payments are marked, once.
+func (p *Payment) RequestCancel(now time.Time) bool {
+	if p.CancelRequested || (p.Status != StatusPending && p.Status != StatusSucceeded) {
+		return false
+	}
+	p.CancelRequested, p.UpdatedAt = true, now
+	return true
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+var statuses = []string{StatusPending, StatusSucceeded, StatusFailed, StatusRefunded, StatusCanceled}
+
+func TestCanMoveTo(t *testing.T) {
+	allowed := map[[2]string]bool{
+		{StatusPending, StatusSucceeded}:  true,
+		{StatusPending, StatusFailed}:     true,
+		{StatusSucceeded, StatusRefunded}: true,
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			p := Payment{Status: from}
+			if got, want := p.CanMoveTo(to), allowed[[2]string{from, to}]; got != want {
+				t.Errorf("%s -> %s: CanMoveTo = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestRequestCancel(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		status          string
+		cancelRequested bool
+		want            bool
+		wantRefund      bool
+	}{
+		{StatusPending, false, true, false},
+		{StatusSucceeded, false, true, true},
+		{StatusPending, true, false, false},
+		{StatusSucceeded, true, false, true},
+		{StatusFailed, false, false, false},
+		{StatusRefunded, false, false, false},
+		{StatusCanceled, false, false, false},
+	}
+	for _, tt := range tests {
+		p := Payment{Status: tt.status, CancelRequested: tt.cancelRequested}
+		got := p.RequestCancel(now)
+		if got != tt.want || !p.CancelRequested && tt.cancelRequested {
+			t.Errorf("%s (cancel requested %v): RequestCancel = %v, want %v", tt.status, tt.cancelRequested, got, tt.want)
+		}
+		if got && (!p.CancelRequested || !p.UpdatedAt.Equal(now)) {
+			t.Errorf("%s: marked %v at %v", tt.status, p.CancelRequested, p.UpdatedAt)
+		}
+		if !got && !tt.cancelRequested && p.CancelRequested {
+			t.Errorf("%s: marked though unchanged", tt.status)
+		}
+		if p.NeedsRefund() != tt.wantRefund {
+			t.Errorf("%s: NeedsRefund = %v, want %v", tt.status, p.NeedsRefund(), tt.wantRefund)
+		}
+	}
+}
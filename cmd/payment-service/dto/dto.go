@@ -0,0 +1,24 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PaymentResponse is the payment API response. GET /payments/:id, GET /payments?orderId=xxx
+type PaymentResponse struct {
+	ID              uuid.UUID `json:"id"`
+	OrderID         uuid.UUID `json:"orderId"`
+	UserID          uuid.UUID `json:"userId"`
+	Amount          int64     `json:"amount"`
+	Status          string    `json:"status"`
+	Provider        string    `json:"provider"`
+	ProviderRef     string    `json:"providerRef,omitempty"`
+	RefundRef       string    `json:"refundRef,omitempty"`
+	Attempts        int       `json:"attempts"`
+	Reason          string    `json:"reason,omitempty"`
+	CancelRequested bool      `json:"cancelRequested"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"go_example/cmd/payment-service/dto"
+	"go_example/cmd/payment-service/service"
+)
+
+// Payments reads payments. Implemented by service.PaymentService; the Get methods return
+// service.ErrPaymentNotFound for an unknown payment.
+type Payments interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*dto.PaymentResponse, error)
+	GetByOrderID(ctx context.Context, orderID uuid.UUID) (*dto.PaymentResponse, error)
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]*dto.PaymentResponse, error)
+}
+
+// PaymentHandler handles HTTP requests for payments.
+type PaymentHandler struct {
+	svc    Payments
+	owners Owners
+}
+
+// NewPaymentHandler creates a new PaymentHandler that lets callers see only their own payments,
+// as restricted by owners.
+func NewPaymentHandler(svc Payments, owners Owners) *PaymentHandler {
+	return &PaymentHandler{svc: svc, owners: owners}
+}
+
+// List returns the payment of an order or the latest payments of a user. Asking for another
+// user's payments answers 404.
+// GET /payments?orderId=xxx or ?userId=xxx
+func (h *PaymentHandler) List(c fiber.Ctx) error {
+	switch {
+	case c.Query("orderId") != "":
+		id, err := uuid.Parse(c.Query("orderId"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid orderId"})
+		}
+		p, err := h.svc.GetByOrderID(c.Context(), id)
+		if errors.Is(err, service.ErrPaymentNotFound) {
+			return c.JSON([]*dto.PaymentResponse{})
+		}
+		if err == nil && !h.owners.allows(c, p.UserID) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "payment not found"})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON([]*dto.PaymentResponse{p})
+	case c.Query("userId") != "":
+		id, err := uuid.Parse(c.Query("userId"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid userId"})
+		}
+		if !h.owners.allows(c, id) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "payment not found"})
+		}
+		list, err := h.svc.ListByUserID(c.Context(), id)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(list)
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "orderId or userId query parameter is required"})
+	}
+}
+
+// GetByID returns a payment by ID; another user's payment is not found. GET /payments/:id
+func (h *PaymentHandler) GetByID(c fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid payment id"})
+	}
+	p, err := h.svc.GetByID(c.Context(), id)
+	if err == nil && !h.owners.allows(c, p.UserID) {
+		err = service.ErrPaymentNotFound
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrPaymentNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "payment not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(p)
+}
@@ -0,0 +1,101 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go_example/internal/bus"
+	"go_example/internal/events"
+	"go_example/internal/logging"
+	"go_example/cmd/payment-service/config"
+	"go_example/cmd/payment-service/service"
+)
+
+// Group is the consumer group payment-service subscribes with.
+const Group = "payment-service-group"
+
+// Consumer runs consumers for payment-service: user.credit-reserved charges an order and
+// order.canceled refunds it. Replies are written to the outbox with the payment change and
+// published by the outbox relay.
+type Consumer struct {
+	paymentSvc  *service.PaymentService
+	sub         bus.Subscriber
+	cfg         config.ConsumerConfig
+	chargeAfter time.Time
+}
+
+// NewConsumer creates a new Consumer. Credit reservations published before chargeAfter are
+// skipped.
+func NewConsumer(paymentSvc *service.PaymentService, sub bus.Subscriber, cfg config.ConsumerConfig, chargeAfter time.Time) *Consumer {
+	return &Consumer{paymentSvc: paymentSvc, sub: sub, cfg: cfg, chargeAfter: chargeAfter}
+}
+
+// Run starts consuming user.credit-reserved and order.canceled and blocks until ctx is canceled.
+func (c *Consumer) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		topic := events.TopicUserCreditReserved
+		if err := c.sub.Subscribe(ctx, topic, Group, c.handleCreditReserved, c.cfg.Options(topic)); err != nil {
+			slog.Error("subscribe failed", logging.KeyTopic, topic, logging.Err(err))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		// A refund must not be given up, and order.canceled's dead-letter topic belongs to
+		// user-service, so cancellations are retried until they succeed.
+		topic := events.TopicOrderCanceled
+		opts := c.cfg.Options(topic)
+		opts.RetryForever = true
+		if err := c.sub.Subscribe(ctx, topic, Group, c.handleOrderCanceled, opts); err != nil {
+			slog.Error("subscribe failed", logging.KeyTopic, topic, logging.Err(err))
+		}
+	}()
+	wg.Wait()
+}
+
+func (c *Consumer) handleCreditReserved(ctx context.Context, msg bus.Message) error {
+	var evt events.UserCreditReservedEvent
+	if err := json.Unmarshal(msg.Value, &evt); err != nil {
+		slog.ErrorContext(ctx, "unmarshal failed", logging.Err(err))
+		return bus.Permanent(err)
+	}
+	ctx = logging.With(ctx, logging.OrderID(evt.OrderID), logging.UserID(evt.UserID))
+	if !msg.Time.IsZero() && msg.Time.Before(c.chargeAfter) {
+		slog.InfoContext(ctx, "skipping UserCreditReservedEvent from before PAYMENT_CHARGE_AFTER")
+		return nil
+	}
+	slog.InfoContext(ctx, "received UserCreditReservedEvent", "amount", evt.Amount)
+	p, err := c.paymentSvc.Charge(ctx, evt)
+	if err != nil {
+		slog.ErrorContext(ctx, "charge failed", logging.Err(err))
+		return err
+	}
+	if p.Reason != "" {
+		slog.InfoContext(ctx, "payment settled", "payment_id", p.ID.String(), "status", p.Status, "reason", p.Reason)
+	} else {
+		slog.InfoContext(ctx, "payment settled", "payment_id", p.ID.String(), "status", p.Status)
+	}
+	return nil
+}
+
+func (c *Consumer) handleOrderCanceled(ctx context.Context, msg bus.Message) error {
+	var evt events.OrderCanceledEvent
+	if err := json.Unmarshal(msg.Value, &evt); err != nil {
+		slog.ErrorContext(ctx, "unmarshal failed", logging.Err(err))
+		return bus.Permanent(err)
+	}
+	ctx = logging.With(ctx, logging.OrderID(evt.OrderID), logging.UserID(evt.UserID))
+	slog.InfoContext(ctx, "received OrderCanceledEvent")
+	p, err := c.paymentSvc.Cancel(ctx, evt)
+	if err != nil {
+		slog.ErrorContext(ctx, "cancel payment failed", logging.Err(err))
+		return err
+	}
+	slog.InfoContext(ctx, "payment of canceled order settled", "payment_id", p.ID.String(), "status", p.Status)
+	return nil
+}
@@ -0,0 +1,44 @@
+package kafka
+
+import (
+	"time"
+
+	"go_example/internal/bus"
+	"go_example/internal/events"
+	"go_example/internal/topics"
+)
+
+// Topics declares the topics payment-service owns: the ones it publishes to and the
+// dead-letter topic of user.credit-reserved, whose saga consumer it is.
+func Topics(replicationFactor int) []topics.Spec {
+	return []topics.Spec{
+		{
+			Name:              events.TopicPaymentSucceeded,
+			Partitions:        3,
+			ReplicationFactor: replicationFactor,
+			Retention:         7 * 24 * time.Hour,
+			CleanupPolicy:     topics.CleanupDelete,
+		},
+		{
+			Name:              events.TopicPaymentFailed,
+			Partitions:        3,
+			ReplicationFactor: replicationFactor,
+			Retention:         7 * 24 * time.Hour,
+			CleanupPolicy:     topics.CleanupDelete,
+		},
+		{
+			Name:              events.TopicPaymentRefunded,
+			Partitions:        3,
+			ReplicationFactor: replicationFactor,
+			Retention:         7 * 24 * time.Hour,
+			CleanupPolicy:     topics.CleanupDelete,
+		},
+		{
+			Name:              bus.DeadLetterTopic(events.TopicUserCreditReserved),
+			Partitions:        1,
+			ReplicationFactor: replicationFactor,
+			Retention:         30 * 24 * time.Hour,
+			CleanupPolicy:     topics.CleanupDelete,
+		},
+	}
+}
@@ -0,0 +1,70 @@
+// Payment-service: charges orders after credit reservation and refunds canceled ones.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"go_example/internal/bus"
+	"go_example/internal/lifecycle"
+	"go_example/internal/logging"
+	"go_example/internal/topics"
+	"go_example/internal/tracing"
+	"go_example/cmd/payment-service/app"
+	"go_example/cmd/payment-service/config"
+	"go_example/cmd/payment-service/kafka"
+)
+
+func main() {
+	checkTopics := flag.Bool("check-topics", false, "report Kafka topic drift and exit without changing anything")
+	flag.Parse()
+
+	cfg := config.Load()
+	if err := logging.Setup("payment-service", cfg.Logging); err != nil {
+		log.Fatalf("payment-service: %v", err)
+	}
+	topicSpecs := kafka.Topics(cfg.Kafka.ReplicationFactor)
+	if *checkTopics {
+		if err := topics.Run(context.Background(), cfg.Kafka.Config, topicSpecs, true); err != nil {
+			logging.Fatal("topic check failed", logging.Err(err))
+		}
+		return
+	}
+
+	if cfg.Bus == bus.KindKafka {
+		if err := topics.Run(context.Background(), cfg.Kafka.Config, topicSpecs, false); err != nil {
+			logging.Fatal("topic setup failed", logging.Err(err))
+		}
+	}
+
+	b, err := bus.New(cfg.Bus, cfg.Kafka.Config)
+	if err != nil {
+		logging.Fatal("bus setup failed", logging.Err(err))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	lc := lifecycle.New()
+	shutdownTracing, err := tracing.Setup(context.Background(), "payment-service", cfg.Tracing)
+	if err != nil {
+		logging.Fatal("tracing setup failed", logging.Err(err))
+	}
+	lc.OnShutdown(lifecycle.PhaseTelemetry, "tracing", shutdownTracing)
+	lc.OnShutdown(lifecycle.PhaseProducers, "bus", func(context.Context) error { return b.Close() })
+	if err := app.Start(cfg, b, lc); err != nil {
+		logging.Fatal("start failed", logging.Err(err))
+	}
+	lc.SetReady()
+
+	<-ctx.Done()
+	slog.Info("shutting down")
+	if err := lc.Shutdown(cfg.ShutdownDrain, cfg.ShutdownGrace); err != nil {
+		slog.Error("shutdown failed", logging.Err(err))
+	}
+}
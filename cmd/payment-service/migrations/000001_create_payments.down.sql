@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS outbox;
+DROP INDEX IF EXISTS idx_payments_user_id;
+DROP TABLE IF EXISTS payments;
@@ -0,0 +1,9 @@
+// Package migrations embeds the payment-service SQL migrations.
+package migrations
+
+import "embed"
+
+// FS holds the *.up.sql and *.down.sql files.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,92 @@
+// Package outbox publishes events recorded in the payment-service outbox table.
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go_example/internal/bus"
+	"go_example/internal/logging"
+	"go_example/internal/requestid"
+	"go_example/cmd/payment-service/repository"
+)
+
+// Relay polls the outbox and publishes events in the order they were recorded. Events are
+// deleted in the same transaction once published, so a crash between the two republishes them
+// (at-least-once); consumers deduplicate by order ID.
+type Relay struct {
+	tx        *repository.TxManager
+	pub       bus.Publisher
+	interval  time.Duration
+	batchSize int
+}
+
+// NewRelay creates a new Relay.
+func NewRelay(tx *repository.TxManager, pub bus.Publisher, interval time.Duration, batchSize int) *Relay {
+	return &Relay{tx: tx, pub: pub, interval: interval, batchSize: batchSize}
+}
+
+// Run publishes pending events every interval until ctx is canceled, then makes a final pass
+// so events recorded by draining consumers are not left behind.
+func (r *Relay) Run(ctx context.Context) {
+	tick := time.NewTicker(r.interval)
+	defer tick.Stop()
+	for {
+		r.drain(ctx)
+		select {
+		case <-ctx.Done():
+			r.drain(context.WithoutCancel(ctx))
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// drain publishes batches until the outbox is empty, another instance holds the relay lock or
+// publishing fails.
+func (r *Relay) drain(ctx context.Context) {
+	for {
+		n, err := r.publishBatch(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "outbox relay failed", logging.Err(err))
+			return
+		}
+		if n < r.batchSize {
+			return
+		}
+	}
+}
+
+func (r *Relay) publishBatch(ctx context.Context) (int, error) {
+	n := 0
+	err := r.tx.Run(ctx, func(tx repository.Tx) error {
+		locked, err := tx.Outbox.TryLock(ctx)
+		if err != nil || !locked {
+			return err
+		}
+		pending, err := tx.Outbox.ListOldest(ctx, r.batchSize)
+		if err != nil || len(pending) == 0 {
+			return err
+		}
+		msgs := make([]bus.Message, len(pending))
+		ids := make([]int64, len(pending))
+		for i, m := range pending {
+			headers := make(map[string]string, len(m.TraceContext)+1)
+			for k, v := range m.TraceContext {
+				headers[k] = v
+			}
+			if m.RequestID != "" {
+				headers[requestid.MessageHeader] = m.RequestID
+			}
+			msgs[i] = bus.Message{Topic: m.Topic, Key: []byte(m.Key), Value: m.Payload, Headers: headers}
+			ids[i] = m.ID
+		}
+		if err := r.pub.Publish(ctx, msgs...); err != nil {
+			return err
+		}
+		n = len(pending)
+		return tx.Outbox.Delete(ctx, ids)
+	})
+	return n, err
+}
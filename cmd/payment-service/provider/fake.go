@@ -0,0 +1,115 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Fake is an in-memory PaymentProvider simulating approvals, declines and timeouts. A charge
+// that times out is still applied, like a real provider whose response was lost, so the
+// retry with the same key returns it.
+type Fake struct {
+	cfg FakeConfig
+
+	mu      sync.Mutex
+	seq     int
+	charges map[string]fakeCharge
+	refunds map[string]*Refund
+}
+
+// FakeConfig sets how the fake behaves. Charges above DeclineAbove (0: no limit) are declined;
+// other calls are declined with probability DeclineRate and time out with probability
+// TimeoutRate. Every call takes Latency.
+type FakeConfig struct {
+	Latency      time.Duration
+	DeclineAbove int64
+	DeclineRate  float64
+	TimeoutRate  float64
+}
+
+type fakeCharge struct {
+	charge   *Charge
+	declined *DeclinedError
+}
+
+// NewFake creates a new Fake.
+func NewFake(cfg FakeConfig) *Fake {
+	return &Fake{cfg: cfg, charges: map[string]fakeCharge{}, refunds: map[string]*Refund{}}
+}
+
+// Name returns "fake".
+func (f *Fake) Name() string { return "fake" }
+
+// Charge implements PaymentProvider.
+func (f *Fake) Charge(ctx context.Context, req ChargeRequest) (*Charge, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	c, ok := f.charges[req.IdempotencyKey]
+	if !ok {
+		switch {
+		case f.cfg.DeclineAbove > 0 && req.Amount > f.cfg.DeclineAbove:
+			c.declined = &DeclinedError{Reason: fmt.Sprintf("amount exceeds limit of %d", f.cfg.DeclineAbove)}
+		case rand.Float64() < f.cfg.DeclineRate:
+			c.declined = &DeclinedError{Reason: "card declined"}
+		default:
+			f.seq++
+			c.charge = &Charge{ID: fmt.Sprintf("fake_ch_%d", f.seq)}
+		}
+		f.charges[req.IdempotencyKey] = c
+	}
+	f.mu.Unlock()
+	if err := f.maybeTimeout(ctx); err != nil {
+		return nil, err
+	}
+	if c.declined != nil {
+		return nil, c.declined
+	}
+	return c.charge, nil
+}
+
+// Refund implements PaymentProvider.
+func (f *Fake) Refund(ctx context.Context, req RefundRequest) (*Refund, error) {
+	if err := f.wait(ctx); err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	r, ok := f.refunds[req.ChargeKey]
+	if !ok {
+		if c, charged := f.charges[req.ChargeKey]; !charged || c.charge == nil {
+			f.mu.Unlock()
+			return nil, ErrNoCharge
+		}
+		f.seq++
+		r = &Refund{ID: fmt.Sprintf("fake_re_%d", f.seq)}
+		f.refunds[req.ChargeKey] = r
+	}
+	f.mu.Unlock()
+	if err := f.maybeTimeout(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// wait simulates the provider's latency.
+func (f *Fake) wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(f.cfg.Latency):
+		return nil
+	}
+}
+
+// maybeTimeout simulates a lost response by blocking until ctx is done.
+func (f *Fake) maybeTimeout(ctx context.Context) error {
+	if rand.Float64() >= f.cfg.TimeoutRate {
+		return nil
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
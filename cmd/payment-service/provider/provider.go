@@ -0,0 +1,58 @@
+// Package provider defines the payment provider interface payment-service charges through,
+// with a fake implementation for development and tests.
+package provider
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// ErrNoCharge is returned by Refund when no charge was made with the given key.
+var ErrNoCharge = errors.New("no charge with this key")
+
+// DeclinedError is returned by Charge when the provider refuses the charge. Retrying does not help.
+type DeclinedError struct {
+	Reason string
+}
+
+func (e *DeclinedError) Error() string { return "declined: " + e.Reason }
+
+// ChargeRequest asks to charge a user for an order. IdempotencyKey identifies the charge.
+type ChargeRequest struct {
+	IdempotencyKey string
+	OrderID        uuid.UUID
+	UserID         uuid.UUID
+	Amount         int64
+}
+
+// Charge is a successful charge. ID is the provider's reference.
+type Charge struct {
+	ID string
+}
+
+// RefundRequest asks to refund in full the charge made with ChargeKey.
+type RefundRequest struct {
+	ChargeKey string
+	Amount    int64
+}
+
+// Refund is a successful refund. ID is the provider's reference.
+type Refund struct {
+	ID string
+}
+
+// PaymentProvider charges and refunds payments.
+type PaymentProvider interface {
+	// Name identifies the provider in payments and events.
+	Name() string
+	// Charge charges req.Amount, or returns a *DeclinedError. A request repeated with the same
+	// IdempotencyKey returns the outcome of the first without charging again, so a charge whose
+	// outcome was lost to a timeout can be retried safely.
+	Charge(ctx context.Context, req ChargeRequest) (*Charge, error)
+	// Refund refunds the charge made with req.ChargeKey; repeating it returns the first refund.
+	// It returns ErrNoCharge if there is no successful charge with that key, so it also settles
+	// a charge whose outcome is unknown.
+	Refund(ctx context.Context, req RefundRequest) (*Refund, error)
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go_example/internal/requestid"
+	"go_example/internal/tracing"
+	"go_example/cmd/payment-service/domain"
+)
+
+// OutboxRepository stores events to be published by the outbox relay.
+type OutboxRepository struct {
+	db DBTX
+}
+
+// Add records an event for topic, keyed by key, with the request ID and trace context of ctx.
+func (r *OutboxRepository) Add(ctx context.Context, topic, key string, payload []byte) error {
+	query := `INSERT INTO outbox (topic, message_key, payload, request_id, trace_context, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := r.db.Exec(ctx, query, topic, key, payload, requestid.FromContext(ctx), tracing.Fields(ctx), time.Now())
+	return err
+}
+
+// ListOldest returns up to limit events in the order they were recorded.
+func (r *OutboxRepository) ListOldest(ctx context.Context, limit int) ([]*domain.OutboxMessage, error) {
+	query := `SELECT id, topic, message_key, payload, request_id, trace_context, created_at FROM outbox ORDER BY id LIMIT $1`
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*domain.OutboxMessage
+	for rows.Next() {
+		var m domain.OutboxMessage
+		if err := rows.Scan(&m.ID, &m.Topic, &m.Key, &m.Payload, &m.RequestID, &m.TraceContext, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, &m)
+	}
+	return list, rows.Err()
+}
+
+// Delete removes published events.
+func (r *OutboxRepository) Delete(ctx context.Context, ids []int64) error {
+	query := `DELETE FROM outbox WHERE id = ANY($1)`
+	_, err := r.db.Exec(ctx, query, ids)
+	return err
+}
+
+// TryLock takes a transaction-scoped advisory lock so only one relay publishes at a time,
+// keeping events in order across instances. It reports whether the lock was taken.
+func (r *OutboxRepository) TryLock(ctx context.Context) (bool, error) {
+	var ok bool
+	err := r.db.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, outboxLockID).Scan(&ok)
+	return ok, err
+}
+
+// outboxLockID identifies the relay advisory lock.
+const outboxLockID int64 = 0x6f7574626f78 // "outbox"
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go_example/cmd/payment-service/domain"
+)
+
+// PaymentRepository handles payment persistence.
+type PaymentRepository struct {
+	db DBTX
+}
+
+// NewPaymentRepository creates a new PaymentRepository.
+func NewPaymentRepository(pool *pgxpool.Pool) *PaymentRepository {
+	return &PaymentRepository{db: pool}
+}
+
+const paymentColumns = `id, order_id, user_id, amount, status, provider, provider_ref, refund_ref, attempts, reason,
+	cancel_requested, created_at, updated_at`
+
+func scanPayment(row pgx.Row) (*domain.Payment, error) {
+	var p domain.Payment
+	err := row.Scan(&p.ID, &p.OrderID, &p.UserID, &p.Amount, &p.Status, &p.Provider, &p.ProviderRef, &p.RefundRef,
+		&p.Attempts, &p.Reason, &p.CancelRequested, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Create inserts p. It returns false, inserting nothing, if its order already has a payment.
+func (r *PaymentRepository) Create(ctx context.Context, p *domain.Payment) (bool, error) {
+	query := `INSERT INTO payments (id, order_id, user_id, amount, status, provider, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7) ON CONFLICT (order_id) DO NOTHING`
+	tag, err := r.db.Exec(ctx, query, p.ID, p.OrderID, p.UserID, p.Amount, p.Status, p.Provider, p.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// GetByID returns a payment by ID, or pgx.ErrNoRows.
+func (r *PaymentRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Payment, error) {
+	return scanPayment(r.db.QueryRow(ctx, `SELECT `+paymentColumns+` FROM payments WHERE id = $1`, id))
+}
+
+// GetByOrderID returns the payment of an order, or pgx.ErrNoRows.
+func (r *PaymentRepository) GetByOrderID(ctx context.Context, orderID uuid.UUID) (*domain.Payment, error) {
+	return scanPayment(r.db.QueryRow(ctx, `SELECT `+paymentColumns+` FROM payments WHERE order_id = $1`, orderID))
+}
+
+// LockByOrderID returns the payment of an order locked until the transaction ends, or pgx.ErrNoRows.
+func (r *PaymentRepository) LockByOrderID(ctx context.Context, orderID uuid.UUID) (*domain.Payment, error) {
+	return scanPayment(r.db.QueryRow(ctx, `SELECT `+paymentColumns+` FROM payments WHERE order_id = $1 FOR UPDATE`, orderID))
+}
+
+// ListByUserID returns the latest limit payments of a user, newest first.
+func (r *PaymentRepository) ListByUserID(ctx context.Context, userID uuid.UUID, limit int) ([]*domain.Payment, error) {
+	query := `SELECT ` + paymentColumns + ` FROM payments WHERE user_id = $1 ORDER BY created_at DESC, id LIMIT $2`
+	rows, err := r.db.Query(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*domain.Payment
+	for rows.Next() {
+		p, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+// AddAttempt counts a charge attempt of a payment and returns its attempts so far.
+func (r *PaymentRepository) AddAttempt(ctx context.Context, id uuid.UUID) (int, error) {
+	var n int
+	err := r.db.QueryRow(ctx, `UPDATE payments SET attempts = attempts + 1 WHERE id = $1 RETURNING attempts`, id).Scan(&n)
+	return n, err
+}
+
+// Update stores the status, references, reason and cancellation of p.
+func (r *PaymentRepository) Update(ctx context.Context, p *domain.Payment) error {
+	query := `UPDATE payments SET status = $2, provider_ref = $3, refund_ref = $4, reason = $5, cancel_requested = $6,
+		updated_at = $7 WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, p.ID, p.Status, p.ProviderRef, p.RefundRef, p.Reason, p.CancelRequested, p.UpdatedAt)
+	return err
+}
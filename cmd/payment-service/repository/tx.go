@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DBTX is implemented by *pgxpool.Pool and pgx.Tx, so repositories run with or without a transaction.
+type DBTX interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Tx holds repositories bound to one database transaction.
+type Tx struct {
+	Payments *PaymentRepository
+	Outbox   *OutboxRepository
+}
+
+// TxManager runs functions in database transactions.
+type TxManager struct {
+	pool *pgxpool.Pool
+}
+
+// NewTxManager creates a new TxManager.
+func NewTxManager(pool *pgxpool.Pool) *TxManager {
+	return &TxManager{pool: pool}
+}
+
+// Run calls fn in a transaction that is committed if fn returns nil and rolled back otherwise.
+func (m *TxManager) Run(ctx context.Context, fn func(tx Tx) error) error {
+	return pgx.BeginFunc(ctx, m.pool, func(t pgx.Tx) error {
+		return fn(Tx{
+			Payments: &PaymentRepository{db: t},
+			Outbox:   &OutboxRepository{db: t},
+		})
+	})
+}
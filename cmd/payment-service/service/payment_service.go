@@ -0,0 +1,299 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"go_example/internal/events"
+	"go_example/internal/logging"
+	"go_example/cmd/payment-service/config"
+	"go_example/cmd/payment-service/domain"
+	"go_example/cmd/payment-service/dto"
+	"go_example/cmd/payment-service/provider"
+	"go_example/cmd/payment-service/repository"
+)
+
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// recentPayments bounds the payments listed per user.
+const recentPayments = 50
+
+// PaymentService charges orders through a payment provider and refunds canceled ones. Each
+// order has one payment, whose status makes redelivered events no-ops; replies are recorded in
+// the outbox in the transaction that settles the payment.
+type PaymentService struct {
+	repo     *repository.PaymentRepository
+	tx       *repository.TxManager
+	provider provider.PaymentProvider
+	cfg      config.PaymentConfig
+}
+
+// NewPaymentService creates a new PaymentService.
+func NewPaymentService(repo *repository.PaymentRepository, tx *repository.TxManager, p provider.PaymentProvider, cfg config.PaymentConfig) *PaymentService {
+	return &PaymentService{repo: repo, tx: tx, provider: p, cfg: cfg}
+}
+
+// Charge charges the order of a credit reservation and records payment.succeeded or
+// payment.failed. A declined charge fails at once. A provider error or timeout is retried with
+// backoff until MaxAttempts; the payment then fails, after refunding any charge the provider
+// made meanwhile. If the order was canceled meanwhile, a successful charge is refunded instead
+// of reported. A payment already settled, or of an order canceled before charging, is returned
+// unchanged.
+func (s *PaymentService) Charge(ctx context.Context, evt events.UserCreditReservedEvent) (*domain.Payment, error) {
+	p, err := s.start(ctx, evt)
+	if err != nil {
+		return nil, err
+	}
+	if p.NeedsRefund() {
+		return s.refund(ctx, p)
+	}
+	if !p.CanMoveTo(domain.StatusSucceeded) {
+		return p, nil
+	}
+	req := provider.ChargeRequest{IdempotencyKey: p.ID.String(), OrderID: p.OrderID, UserID: p.UserID, Amount: p.Amount}
+	var charge *provider.Charge
+	for {
+		if p.Attempts, err = s.repo.AddAttempt(ctx, p.ID); err != nil {
+			return nil, err
+		}
+		callCtx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+		charge, err = s.provider.Charge(callCtx, req)
+		cancel()
+		if err == nil || errors.As(err, new(*provider.DeclinedError)) || ctx.Err() != nil || p.Attempts >= s.cfg.MaxAttempts {
+			break
+		}
+		slog.WarnContext(ctx, "charge failed", logging.OrderID(p.OrderID), "attempt", p.Attempts, logging.Err(err))
+		select {
+		case <-ctx.Done():
+		case <-time.After(s.cfg.RetryBackoff << (p.Attempts - 1)):
+		}
+	}
+	if ctx.Err() != nil {
+		// Shutting down: the redelivered reservation resumes with the same idempotency key.
+		return nil, ctx.Err()
+	}
+	var declined *provider.DeclinedError
+	switch {
+	case err == nil:
+		return s.succeed(ctx, p, charge)
+	case errors.As(err, &declined):
+		return s.fail(ctx, p, "payment declined: "+declined.Reason)
+	default:
+		refundCtx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+		defer cancel()
+		if _, rerr := s.provider.Refund(refundCtx, provider.RefundRequest{ChargeKey: req.IdempotencyKey, Amount: p.Amount}); rerr != nil && !errors.Is(rerr, provider.ErrNoCharge) {
+			return nil, fmt.Errorf("settle charge after %w: %w", err, rerr)
+		}
+		return s.fail(ctx, p, "payment provider unavailable: "+err.Error())
+	}
+}
+
+// Cancel handles the cancellation of an order. A succeeded payment is refunded and
+// payment.refunded recorded; a pending one is refunded once its charge succeeds; an order not
+// charged yet gets a canceled payment so it is never charged. Other payments are unchanged.
+func (s *PaymentService) Cancel(ctx context.Context, evt events.OrderCanceledEvent) (*domain.Payment, error) {
+	var p *domain.Payment
+	err := s.tx.Run(ctx, func(tx repository.Tx) error {
+		var err error
+		p, err = tx.Payments.LockByOrderID(ctx, evt.OrderID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			now := time.Now()
+			p = &domain.Payment{
+				ID:        uuid.New(),
+				OrderID:   evt.OrderID,
+				UserID:    evt.UserID,
+				Amount:    evt.Amount,
+				Status:    domain.StatusCanceled,
+				Provider:  s.provider.Name(),
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			created, err := tx.Payments.Create(ctx, p)
+			if err == nil && !created {
+				err = fmt.Errorf("payment of order %s created concurrently", evt.OrderID)
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if !p.RequestCancel(time.Now()) {
+			return nil
+		}
+		return tx.Payments.Update(ctx, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.Status == domain.StatusSucceeded {
+		return s.refund(ctx, p)
+	}
+	return p, nil
+}
+
+// GetByID returns a payment by ID.
+func (s *PaymentService) GetByID(ctx context.Context, id uuid.UUID) (*dto.PaymentResponse, error) {
+	p, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, err
+	}
+	return toPaymentResponse(p), nil
+}
+
+// GetByOrderID returns the payment of an order.
+func (s *PaymentService) GetByOrderID(ctx context.Context, orderID uuid.UUID) (*dto.PaymentResponse, error) {
+	p, err := s.repo.GetByOrderID(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, err
+	}
+	return toPaymentResponse(p), nil
+}
+
+// ListByUserID returns the latest payments of a user.
+func (s *PaymentService) ListByUserID(ctx context.Context, userID uuid.UUID) ([]*dto.PaymentResponse, error) {
+	list, err := s.repo.ListByUserID(ctx, userID, recentPayments)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*dto.PaymentResponse, len(list))
+	for i, p := range list {
+		out[i] = toPaymentResponse(p)
+	}
+	return out, nil
+}
+
+// start returns the payment of the reservation's order, creating it pending if there is none.
+func (s *PaymentService) start(ctx context.Context, evt events.UserCreditReservedEvent) (*domain.Payment, error) {
+	now := time.Now()
+	p := &domain.Payment{
+		ID:        uuid.New(),
+		OrderID:   evt.OrderID,
+		UserID:    evt.UserID,
+		Amount:    evt.Amount,
+		Status:    domain.StatusPending,
+		Provider:  s.provider.Name(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	created, err := s.repo.Create(ctx, p)
+	if err != nil || created {
+		return p, err
+	}
+	return s.repo.GetByOrderID(ctx, evt.OrderID)
+}
+
+// succeed records a successful charge of a pending payment with payment.succeeded, or refunds
+// it if the order was canceled meanwhile.
+func (s *PaymentService) succeed(ctx context.Context, p *domain.Payment, charge *provider.Charge) (*domain.Payment, error) {
+	err := s.tx.Run(ctx, func(tx repository.Tx) error {
+		cur, err := tx.Payments.LockByOrderID(ctx, p.OrderID)
+		if err != nil || !cur.CanMoveTo(domain.StatusSucceeded) {
+			p = cur
+			return err
+		}
+		p = cur
+		p.Status, p.ProviderRef, p.UpdatedAt = domain.StatusSucceeded, charge.ID, time.Now()
+		if err := tx.Payments.Update(ctx, p); err != nil {
+			return err
+		}
+		if p.CancelRequested {
+			return nil
+		}
+		reply := events.PaymentSucceededEvent{OrderID: p.OrderID, UserID: p.UserID, Amount: p.Amount, PaymentID: p.ID,
+			Provider: p.Provider, ProviderRef: p.ProviderRef}
+		return addReply(ctx, tx, events.TopicPaymentSucceeded, p.OrderID, reply)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.NeedsRefund() {
+		return s.refund(ctx, p)
+	}
+	return p, nil
+}
+
+// fail records that a pending payment failed with payment.failed.
+func (s *PaymentService) fail(ctx context.Context, p *domain.Payment, reason string) (*domain.Payment, error) {
+	err := s.tx.Run(ctx, func(tx repository.Tx) error {
+		cur, err := tx.Payments.LockByOrderID(ctx, p.OrderID)
+		if err != nil || !cur.CanMoveTo(domain.StatusFailed) {
+			p = cur
+			return err
+		}
+		p = cur
+		p.Status, p.Reason, p.UpdatedAt = domain.StatusFailed, reason, time.Now()
+		if err := tx.Payments.Update(ctx, p); err != nil {
+			return err
+		}
+		reply := events.PaymentFailedEvent{OrderID: p.OrderID, UserID: p.UserID, Amount: p.Amount, PaymentID: p.ID, Reason: reason}
+		return addReply(ctx, tx, events.TopicPaymentFailed, p.OrderID, reply)
+	})
+	return p, err
+}
+
+// refund refunds a succeeded payment through the provider and records payment.refunded. A
+// provider error is returned, so the event is redelivered and the refund retried.
+func (s *PaymentService) refund(ctx context.Context, p *domain.Payment) (*domain.Payment, error) {
+	callCtx, cancel := context.WithTimeout(ctx, s.cfg.Timeout)
+	defer cancel()
+	r, err := s.provider.Refund(callCtx, provider.RefundRequest{ChargeKey: p.ID.String(), Amount: p.Amount})
+	if err != nil {
+		return nil, fmt.Errorf("refund payment %s: %w", p.ID, err)
+	}
+	err = s.tx.Run(ctx, func(tx repository.Tx) error {
+		cur, err := tx.Payments.LockByOrderID(ctx, p.OrderID)
+		if err != nil || !cur.CanMoveTo(domain.StatusRefunded) {
+			p = cur
+			return err
+		}
+		p = cur
+		p.Status, p.RefundRef, p.UpdatedAt = domain.StatusRefunded, r.ID, time.Now()
+		if err := tx.Payments.Update(ctx, p); err != nil {
+			return err
+		}
+		reply := events.PaymentRefundedEvent{OrderID: p.OrderID, UserID: p.UserID, Amount: p.Amount, PaymentID: p.ID,
+			Provider: p.Provider, ProviderRef: p.RefundRef}
+		return addReply(ctx, tx, events.TopicPaymentRefunded, p.OrderID, reply)
+	})
+	return p, err
+}
+
+// addReply records a saga reply keyed by order ID in the outbox.
+func addReply(ctx context.Context, tx repository.Tx, topic string, orderID uuid.UUID, evt any) error {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	return tx.Outbox.Add(ctx, topic, orderID.String(), body)
+}
+
+func toPaymentResponse(p *domain.Payment) *dto.PaymentResponse {
+	return &dto.PaymentResponse{
+		ID:              p.ID,
+		OrderID:         p.OrderID,
+		UserID:          p.UserID,
+		Amount:          p.Amount,
+		Status:          p.Status,
+		Provider:        p.Provider,
+		ProviderRef:     p.ProviderRef,
+		RefundRef:       p.RefundRef,
+		Attempts:        p.Attempts,
+		Reason:          p.Reason,
+		CancelRequested: p.CancelRequested,
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+	}
+}
@@ -0,0 +1,130 @@
+// Package app wires query-service: database, HTTP API and the user view projection.
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/recover"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go_example/internal/bus"
+	"go_example/internal/lifecycle"
+	"go_example/internal/logging"
+	"go_example/internal/metrics"
+	"go_example/internal/requestid"
+	"go_example/internal/tracing"
+	"go_example/cmd/query-service/config"
+	"go_example/cmd/query-service/handler"
+	"go_example/cmd/query-service/kafka"
+	"go_example/cmd/query-service/migrations"
+	"go_example/cmd/query-service/repository"
+	"go_example/cmd/query-service/service"
+)
+
+// Start starts query-service on b and returns once it is serving. Its HTTP server, consumers and
+// database pool are stopped by lc in shutdown order; b is owned and closed by the caller.
+func Start(cfg *config.Config, b bus.Bus, lc *lifecycle.Manager) error {
+	pool, err := tracing.OpenPool(context.Background(), cfg.DB.DSN())
+	if err != nil {
+		return fmt.Errorf("db: %w", err)
+	}
+	lc.OnShutdown(lifecycle.PhaseStorage, "query-service db", func(context.Context) error {
+		pool.Close()
+		return nil
+	})
+
+	if err := runMigrations(pool); err != nil {
+		return fmt.Errorf("migrations: %w", err)
+	}
+
+	viewRepo := repository.NewViewRepository(pool)
+	txManager := repository.NewTxManager(pool)
+	viewSvc := service.NewViewService(viewRepo, txManager, cfg.View.RecentOrders)
+	viewHandler := handler.NewViewHandler(viewSvc)
+
+	consumer := kafka.NewConsumer(viewSvc, b, cfg.Consumer)
+
+	metrics.RegisterHTTPMetrics("query-service")
+	metrics.RegisterKafkaMetrics()
+
+	app := fiber.New()
+	app.Use(recover.New())
+	app.Use(requestid.Middleware())
+	app.Use(tracing.Middleware())
+	app.Use(logging.AccessLog())
+	app.Use(metrics.HTTPMiddleware())
+	app.Get("/metrics", metrics.MetricsHandler())
+	app.Get("/health", func(c fiber.Ctx) error { return c.JSON(fiber.Map{"status": "UP"}) })
+	app.Get("/ready", lc.ReadinessHandler())
+	app.Get("/views/users/:id", viewHandler.GetUserView)
+	app.Post("/admin/rebuild", viewHandler.Rebuild)
+
+	go func() {
+		if err := app.Listen(":"+cfg.ServerPort, fiber.ListenConfig{DisableStartupMessage: true}); err != nil && err != http.ErrServerClosed {
+			logging.Fatal("listen failed", logging.Err(err))
+		}
+	}()
+	lc.OnShutdown(lifecycle.PhaseHTTP, "query-service http", app.ShutdownWithContext)
+	if cfg.AdminPort != "" {
+		admin := logging.AdminApp()
+		go func() {
+			if err := admin.Listen(":"+cfg.AdminPort, fiber.ListenConfig{DisableStartupMessage: true}); err != nil && err != http.ErrServerClosed {
+				logging.Fatal("admin listen failed", logging.Err(err))
+			}
+		}()
+		lc.OnShutdown(lifecycle.PhaseHTTP, "query-service admin http", admin.ShutdownWithContext)
+	}
+
+	consumerCtx, stopConsumer := context.WithCancel(context.Background())
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		consumer.Run(consumerCtx)
+	}()
+	lc.OnShutdown(lifecycle.PhaseConsumers, "query-service consumers", func(ctx context.Context) error {
+		stopConsumer()
+		select {
+		case <-consumerDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	return nil
+}
+
+// advisoryLockID ensures only one instance runs migrations when multiple share the same DB.
+const advisoryLockID int64 = 0x7175657279 // "query"
+
+func runMigrations(pool *pgxpool.Pool) error {
+	ctx := context.Background()
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	_, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID)
+	if err != nil {
+		return err
+	}
+	defer conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", advisoryLockID)
+	// Migrations are idempotent and applied in file name order.
+	files, err := fs.Glob(migrations.FS, "*.up.sql")
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		data, err := migrations.FS.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if _, err := conn.Exec(ctx, string(data)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
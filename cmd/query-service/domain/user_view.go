@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"go_example/internal/events"
+)
+
+// UserView is the denormalized read model of a user and their orders.
+type UserView struct {
+	UserID         uuid.UUID
+	Username       string
+	InitialBalance *int64 // nil until user.created has been seen
+	BalanceDelta   int64
+	Totals         OrderTotals
+	UpdatedAt      time.Time
+	RecentOrders   []*ViewOrder
+}
+
+// Balance returns the current balance, or false if the initial balance is not known yet.
+func (v *UserView) Balance() (int64, bool) {
+	if v.InitialBalance == nil {
+		return 0, false
+	}
+	return *v.InitialBalance + v.BalanceDelta, true
+}
+
+// OrderTotals counts a user's orders and sums their amounts by status.
+type OrderTotals struct {
+	Pending         int64
+	Confirmed       int64
+	Canceled        int64
+	PendingAmount   int64
+	ConfirmedAmount int64
+	CanceledAmount  int64
+}
+
+// Add adds n orders of amount each in status (n may be negative). Unknown statuses are ignored.
+func (t *OrderTotals) Add(status events.OrderStatus, n, amount int64) {
+	switch status {
+	case events.OrderStatusPending:
+		t.Pending += n
+		t.PendingAmount += n * amount
+	case events.OrderStatusConfirmed:
+		t.Confirmed += n
+		t.ConfirmedAmount += n * amount
+	case events.OrderStatusCanceled:
+		t.Canceled += n
+		t.CanceledAmount += n * amount
+	}
+}
+
+// ViewOrder is an order as seen by the read model. An empty Status means no fact has been
+// merged yet.
+type ViewOrder struct {
+	OrderID        uuid.UUID
+	UserID         uuid.UUID
+	Amount         int64
+	Status         events.OrderStatus
+	CreditReserved bool
+	CreditReleased bool
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// OrderFact is what one saga event tells about an order.
+type OrderFact struct {
+	OrderID        uuid.UUID
+	UserID         uuid.UUID
+	Amount         int64
+	Status         events.OrderStatus
+	CreditReserved bool
+	CreditReleased bool
+	At             time.Time
+}
+
+// OrderFactOf returns the fact carried by a decoded saga event, or false for other events.
+func OrderFactOf(evt any, at time.Time) (OrderFact, bool) {
+	switch e := evt.(type) {
+	case *events.OrderCreatedEvent:
+		return OrderFact{OrderID: e.OrderID, UserID: e.UserID, Amount: e.Amount, Status: events.OrderStatusPending, At: at}, true
+	case *events.UserCreditReservedEvent:
+		return OrderFact{OrderID: e.OrderID, UserID: e.UserID, Amount: e.Amount, Status: events.OrderStatusPending, CreditReserved: true, At: at}, true
+	case *events.PaymentSucceededEvent:
+		return OrderFact{OrderID: e.OrderID, UserID: e.UserID, Amount: e.Amount, Status: events.OrderStatusConfirmed, At: at}, true
+	case *events.PaymentFailedEvent:
+		return OrderFact{OrderID: e.OrderID, UserID: e.UserID, Amount: e.Amount, Status: events.OrderStatusCanceled, At: at}, true
+	case *events.PaymentRefundedEvent:
+		return OrderFact{OrderID: e.OrderID, UserID: e.UserID, Amount: e.Amount, Status: events.OrderStatusCanceled, At: at}, true
+	case *events.UserCreditReservationFailedEvent:
+		return OrderFact{OrderID: e.OrderID, UserID: e.UserID, Amount: e.Amount, Status: events.OrderStatusCanceled, At: at}, true
+	case *events.OrderCanceledEvent:
+		return OrderFact{OrderID: e.OrderID, UserID: e.UserID, Amount: e.Amount, Status: events.OrderStatusCanceled, At: at}, true
+	case *events.UserCreditReleasedEvent:
+		return OrderFact{OrderID: e.OrderID, UserID: e.UserID, Amount: e.Amount, Status: events.OrderStatusCanceled, CreditReleased: true, At: at}, true
+	default:
+		return OrderFact{}, false
+	}
+}
+
+// statusRank orders statuses so that a later saga state wins over an earlier one.
+var statusRank = map[events.OrderStatus]int{
+	events.OrderStatusPending:   1,
+	events.OrderStatusConfirmed: 2,
+	events.OrderStatusCanceled:  3,
+}
+
+// Merge folds f into o and returns the balance change it causes. Merging is commutative and
+// idempotent, so events of different topics may arrive in any order and more than once: the
+// most advanced status wins and each credit movement is counted once per order. A release
+// only gives back a reservation, so one seen without it changes no balance.
+func (o *ViewOrder) Merge(f OrderFact) (balanceDelta int64) {
+	before := o.held()
+	o.Amount = f.Amount
+	if statusRank[f.Status] > statusRank[o.Status] {
+		o.Status = f.Status
+	}
+	o.CreditReserved = o.CreditReserved || f.CreditReserved
+	o.CreditReleased = o.CreditReleased || f.CreditReleased
+	if o.CreatedAt.IsZero() || f.At.Before(o.CreatedAt) {
+		o.CreatedAt = f.At
+	}
+	if f.At.After(o.UpdatedAt) {
+		o.UpdatedAt = f.At
+	}
+	return before - o.held()
+}
+
+// held returns the credit the order holds: its amount while reserved and not released.
+func (o *ViewOrder) held() int64 {
+	if o.CreditReserved && !o.CreditReleased {
+		return o.Amount
+	}
+	return 0
+}
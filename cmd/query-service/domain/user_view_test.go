@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"go_example/internal/events"
+)
+
+func TestViewOrderMerge(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	created := OrderFact{Amount: 100, Status: events.OrderStatusPending, At: at}
+	reserved := OrderFact{Amount: 100, Status: events.OrderStatusPending, CreditReserved: true, At: at.Add(time.Second)}
+	confirmed := OrderFact{Amount: 100, Status: events.OrderStatusConfirmed, At: at.Add(2 * time.Second)}
+	canceled := OrderFact{Amount: 100, Status: events.OrderStatusCanceled, At: at.Add(2 * time.Second)}
+	released := OrderFact{Amount: 100, Status: events.OrderStatusCanceled, CreditReleased: true, At: at.Add(3 * time.Second)}
+	tests := []struct {
+		name       string
+		facts      []OrderFact
+		wantStatus events.OrderStatus
+		wantDelta  int64
+	}{
+		{"created", []OrderFact{created}, events.OrderStatusPending, 0},
+		{"reserved", []OrderFact{created, reserved}, events.OrderStatusPending, -100},
+		{"reserved twice", []OrderFact{created, reserved, reserved}, events.OrderStatusPending, -100},
+		{"confirmed", []OrderFact{created, reserved, confirmed}, events.OrderStatusConfirmed, -100},
+		{"released", []OrderFact{created, reserved, canceled, released}, events.OrderStatusCanceled, 0},
+		{"released before reserved", []OrderFact{released, canceled, reserved, created}, events.OrderStatusCanceled, 0},
+		{"released twice", []OrderFact{reserved, released, released}, events.OrderStatusCanceled, 0},
+		// A release without a reservation gives nothing back.
+		{"released without reservation", []OrderFact{created, canceled, released}, events.OrderStatusCanceled, 0},
+		{"confirmed before created", []OrderFact{confirmed, created}, events.OrderStatusConfirmed, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var o ViewOrder
+			var delta int64
+			for _, f := range tt.facts {
+				delta += o.Merge(f)
+			}
+			if o.Status != tt.wantStatus || delta != tt.wantDelta {
+				t.Errorf("status %s, balance delta %d; want %s, %d", o.Status, delta, tt.wantStatus, tt.wantDelta)
+			}
+			first, last := tt.facts[0].At, tt.facts[0].At
+			for _, f := range tt.facts {
+				if f.At.Before(first) {
+					first = f.At
+				}
+				if f.At.After(last) {
+					last = f.At
+				}
+			}
+			if !o.CreatedAt.Equal(first) || !o.UpdatedAt.Equal(last) {
+				t.Errorf("CreatedAt %v, UpdatedAt %v; want %v, %v", o.CreatedAt, o.UpdatedAt, first, last)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserViewResponse is the denormalized user view API response. Balance is null until the
+// user's user.created event has been projected.
+type UserViewResponse struct {
+	UserID       uuid.UUID     `json:"userId"`
+	Username     string        `json:"username,omitempty"`
+	Balance      *int64        `json:"balance"`
+	Orders       OrderCounts   `json:"orders"`
+	Totals       OrderAmounts  `json:"totals"`
+	RecentOrders []RecentOrder `json:"recentOrders"`
+	UpdatedAt    time.Time     `json:"updatedAt"`
+}
+
+// OrderCounts counts a user's orders by status.
+type OrderCounts struct {
+	Total     int64 `json:"total"`
+	Pending   int64 `json:"pending"`
+	Confirmed int64 `json:"confirmed"`
+	Canceled  int64 `json:"canceled"`
+}
+
+// OrderAmounts sums a user's order amounts by status.
+type OrderAmounts struct {
+	Ordered   int64 `json:"ordered"`
+	Pending   int64 `json:"pending"`
+	Confirmed int64 `json:"confirmed"`
+	Canceled  int64 `json:"canceled"`
+}
+
+// RecentOrder is one of the latest orders of a user view.
+type RecentOrder struct {
+	ID        uuid.UUID `json:"id"`
+	Amount    int64     `json:"amount"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// RebuildResponse is returned when a view rebuild is started.
+type RebuildResponse struct {
+	Generation int64 `json:"generation"`
+}
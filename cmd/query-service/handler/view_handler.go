@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+
+	"go_example/cmd/query-service/dto"
+	"go_example/cmd/query-service/service"
+)
+
+// ViewHandler handles HTTP requests for user views.
+type ViewHandler struct {
+	svc *service.ViewService
+}
+
+// NewViewHandler creates a new ViewHandler.
+func NewViewHandler(svc *service.ViewService) *ViewHandler {
+	return &ViewHandler{svc: svc}
+}
+
+// GetUserView returns the denormalized view of a user. GET /views/users/:id
+func (h *ViewHandler) GetUserView(c fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user id"})
+	}
+	view, err := h.svc.GetUserView(c.Context(), id)
+	if err != nil {
+		if err == service.ErrUserNotFound {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "user view not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(view)
+}
+
+// Rebuild empties the views and projects every topic again from offset zero. POST /admin/rebuild
+func (h *ViewHandler) Rebuild(c fiber.Ctx) error {
+	gen, err := h.svc.Rebuild(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusAccepted).JSON(dto.RebuildResponse{Generation: gen})
+}
@@ -0,0 +1,128 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go_example/internal/bus"
+	"go_example/internal/events"
+	"go_example/internal/logging"
+	"go_example/cmd/query-service/config"
+	"go_example/cmd/query-service/domain"
+	"go_example/cmd/query-service/service"
+)
+
+// GroupPrefix prefixes the consumer group of each projection generation. A rebuild moves to a
+// new group, which has no committed offsets and so starts from offset zero.
+const GroupPrefix = "query-service-g"
+
+// Topics lists the topics projected into the user views.
+var Topics = append([]string{events.TopicUserCreated}, events.SagaTopics...)
+
+// Group returns the consumer group of projection generation gen.
+func Group(gen int64) string {
+	return fmt.Sprintf("%s%d", GroupPrefix, gen)
+}
+
+// Consumer projects user and saga events into the user views under the consumer group of the
+// current generation, and moves to the new group when a rebuild starts a new generation.
+type Consumer struct {
+	viewSvc *service.ViewService
+	sub     bus.Subscriber
+	cfg     config.ConsumerConfig
+}
+
+// NewConsumer creates a new Consumer.
+func NewConsumer(viewSvc *service.ViewService, sub bus.Subscriber, cfg config.ConsumerConfig) *Consumer {
+	return &Consumer{viewSvc: viewSvc, sub: sub, cfg: cfg}
+}
+
+// Run projects events of the current generation and blocks until ctx is canceled.
+func (c *Consumer) Run(ctx context.Context) {
+	for {
+		gen, err := c.viewSvc.Generation(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "read generation failed", logging.Err(err))
+			if !sleep(ctx, c.cfg.GenerationPoll) {
+				return
+			}
+			continue
+		}
+		slog.InfoContext(ctx, "projecting generation", "generation", gen, "group", Group(gen))
+		genCtx, cancel := context.WithCancel(ctx)
+		var wg sync.WaitGroup
+		for _, topic := range Topics {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				opts := c.cfg.Options(topic)
+				opts.RetryForever = true
+				if err := c.sub.Subscribe(genCtx, topic, Group(gen), c.project(gen), opts); err != nil {
+					slog.Error("subscribe failed", logging.KeyTopic, topic, logging.Err(err))
+				}
+			}()
+		}
+		c.awaitNewGeneration(genCtx, gen)
+		cancel()
+		wg.Wait()
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// awaitNewGeneration returns once the generation differs from gen or ctx is canceled.
+func (c *Consumer) awaitNewGeneration(ctx context.Context, gen int64) {
+	for sleep(ctx, c.cfg.GenerationPoll) {
+		cur, err := c.viewSvc.Generation(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "read generation failed", logging.Err(err))
+			continue
+		}
+		if cur != gen {
+			slog.InfoContext(ctx, "generation replaced, rebuilding views", "generation", gen, "new_generation", cur)
+			return
+		}
+	}
+}
+
+// project returns the handler projecting events under generation gen. The views are a
+// projection, so it retries until the database is available instead of dead-lettering into the
+// saga DLQs. Events consumed after a rebuild under the old generation are dropped.
+func (c *Consumer) project(gen int64) bus.Handler {
+	return func(ctx context.Context, msg bus.Message) error {
+		evt, err := events.Decode(msg.Topic, msg.Value)
+		if err != nil {
+			return bus.Skip(err)
+		}
+		at := msg.Time
+		if at.IsZero() {
+			at = time.Now()
+		}
+		if e, ok := evt.(*events.UserCreatedEvent); ok {
+			ctx = logging.With(ctx, logging.UserID(e.UserID))
+			return c.viewSvc.ApplyUserCreated(ctx, gen, *e, at)
+		}
+		f, ok := domain.OrderFactOf(evt, at)
+		if !ok {
+			return bus.Skip(fmt.Errorf("%s: not projected", msg.Topic))
+		}
+		ctx = logging.With(ctx, logging.OrderID(f.OrderID), logging.UserID(f.UserID))
+		return c.viewSvc.ApplyOrderFact(ctx, gen, f)
+	}
+}
+
+// sleep waits for d and reports false if ctx was canceled first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
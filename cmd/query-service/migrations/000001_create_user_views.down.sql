@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_view_orders_user_created;
+DROP TABLE IF EXISTS view_orders;
+DROP TABLE IF EXISTS user_views;
+DROP TABLE IF EXISTS view_meta;
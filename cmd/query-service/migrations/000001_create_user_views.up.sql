@@ -0,0 +1,36 @@
+-- view_meta holds the single projection generation; a rebuild bumps it and consumes again
+-- from offset zero under a new consumer group.
+CREATE TABLE IF NOT EXISTS view_meta (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    generation BIGINT NOT NULL,
+    rebuilt_at TIMESTAMP
+);
+
+INSERT INTO view_meta (id, generation) VALUES (TRUE, 1) ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS user_views (
+    user_id UUID PRIMARY KEY,
+    username VARCHAR(255),
+    initial_balance BIGINT,
+    balance_delta BIGINT NOT NULL DEFAULT 0,
+    pending_orders BIGINT NOT NULL DEFAULT 0,
+    confirmed_orders BIGINT NOT NULL DEFAULT 0,
+    canceled_orders BIGINT NOT NULL DEFAULT 0,
+    pending_amount BIGINT NOT NULL DEFAULT 0,
+    confirmed_amount BIGINT NOT NULL DEFAULT 0,
+    canceled_amount BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS view_orders (
+    order_id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    amount BIGINT NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    credit_reserved BOOLEAN NOT NULL DEFAULT FALSE,
+    credit_released BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_view_orders_user_created ON view_orders(user_id, created_at DESC);
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go_example/internal/events"
+	"go_example/cmd/query-service/domain"
+)
+
+// ViewRepository handles persistence of the user views and the projection generation.
+type ViewRepository struct {
+	db DBTX
+}
+
+// NewViewRepository creates a new ViewRepository.
+func NewViewRepository(pool *pgxpool.Pool) *ViewRepository {
+	return &ViewRepository{db: pool}
+}
+
+// Generation returns the current projection generation.
+func (r *ViewRepository) Generation(ctx context.Context) (int64, error) {
+	var gen int64
+	err := r.db.QueryRow(ctx, `SELECT generation FROM view_meta`).Scan(&gen)
+	return gen, err
+}
+
+// LockGeneration returns the current projection generation and holds it until the
+// transaction ends, so a rebuild waits for views being updated under the old generation.
+func (r *ViewRepository) LockGeneration(ctx context.Context) (int64, error) {
+	var gen int64
+	err := r.db.QueryRow(ctx, `SELECT generation FROM view_meta FOR SHARE`).Scan(&gen)
+	return gen, err
+}
+
+// Reset empties the views and starts a new projection generation, which it returns.
+func (r *ViewRepository) Reset(ctx context.Context) (int64, error) {
+	var gen int64
+	query := `UPDATE view_meta SET generation = generation + 1, rebuilt_at = $1 RETURNING generation`
+	if err := r.db.QueryRow(ctx, query, time.Now()).Scan(&gen); err != nil {
+		return 0, err
+	}
+	if _, err := r.db.Exec(ctx, `TRUNCATE user_views, view_orders`); err != nil {
+		return 0, err
+	}
+	return gen, nil
+}
+
+// UpsertUser records the username and initial balance of a user.
+func (r *ViewRepository) UpsertUser(ctx context.Context, id uuid.UUID, username string, balance int64, at time.Time) error {
+	query := `INSERT INTO user_views (user_id, username, initial_balance, updated_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET username = EXCLUDED.username, initial_balance = EXCLUDED.initial_balance,
+			updated_at = GREATEST(user_views.updated_at, EXCLUDED.updated_at)`
+	_, err := r.db.Exec(ctx, query, id, username, balance, at)
+	return err
+}
+
+// LockOrder returns the order of f locked for update, inserting an empty one (no status) if
+// the order has not been seen before.
+func (r *ViewRepository) LockOrder(ctx context.Context, f domain.OrderFact) (*domain.ViewOrder, error) {
+	insert := `INSERT INTO view_orders (order_id, user_id, amount, status, created_at, updated_at)
+		VALUES ($1, $2, $3, '', $4, $4) ON CONFLICT DO NOTHING`
+	if _, err := r.db.Exec(ctx, insert, f.OrderID, f.UserID, f.Amount, f.At); err != nil {
+		return nil, err
+	}
+	query := `SELECT order_id, user_id, amount, status, credit_reserved, credit_released, created_at, updated_at
+		FROM view_orders WHERE order_id = $1 FOR UPDATE`
+	var o domain.ViewOrder
+	var status string
+	err := r.db.QueryRow(ctx, query, f.OrderID).Scan(&o.OrderID, &o.UserID, &o.Amount, &status,
+		&o.CreditReserved, &o.CreditReleased, &o.CreatedAt, &o.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	o.Status = events.OrderStatus(status)
+	return &o, nil
+}
+
+// SaveOrder writes o back.
+func (r *ViewRepository) SaveOrder(ctx context.Context, o *domain.ViewOrder) error {
+	query := `UPDATE view_orders SET amount = $2, status = $3, credit_reserved = $4, credit_released = $5,
+		created_at = $6, updated_at = $7 WHERE order_id = $1`
+	_, err := r.db.Exec(ctx, query, o.OrderID, o.Amount, string(o.Status), o.CreditReserved, o.CreditReleased, o.CreatedAt, o.UpdatedAt)
+	return err
+}
+
+// AddToUser adds order totals and a balance change to the view of a user, creating it if needed.
+func (r *ViewRepository) AddToUser(ctx context.Context, id uuid.UUID, t domain.OrderTotals, balanceDelta int64, at time.Time) error {
+	query := `INSERT INTO user_views (user_id, balance_delta, pending_orders, confirmed_orders, canceled_orders,
+			pending_amount, confirmed_amount, canceled_amount, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (user_id) DO UPDATE SET
+			balance_delta = user_views.balance_delta + EXCLUDED.balance_delta,
+			pending_orders = user_views.pending_orders + EXCLUDED.pending_orders,
+			confirmed_orders = user_views.confirmed_orders + EXCLUDED.confirmed_orders,
+			canceled_orders = user_views.canceled_orders + EXCLUDED.canceled_orders,
+			pending_amount = user_views.pending_amount + EXCLUDED.pending_amount,
+			confirmed_amount = user_views.confirmed_amount + EXCLUDED.confirmed_amount,
+			canceled_amount = user_views.canceled_amount + EXCLUDED.canceled_amount,
+			updated_at = GREATEST(user_views.updated_at, EXCLUDED.updated_at)`
+	_, err := r.db.Exec(ctx, query, id, balanceDelta, t.Pending, t.Confirmed, t.Canceled,
+		t.PendingAmount, t.ConfirmedAmount, t.CanceledAmount, at)
+	return err
+}
+
+// GetUser returns the view of a user without recent orders.
+func (r *ViewRepository) GetUser(ctx context.Context, id uuid.UUID) (*domain.UserView, error) {
+	query := `SELECT user_id, COALESCE(username, ''), initial_balance, balance_delta,
+			pending_orders, confirmed_orders, canceled_orders, pending_amount, confirmed_amount, canceled_amount, updated_at
+		FROM user_views WHERE user_id = $1`
+	var v domain.UserView
+	err := r.db.QueryRow(ctx, query, id).Scan(&v.UserID, &v.Username, &v.InitialBalance, &v.BalanceDelta,
+		&v.Totals.Pending, &v.Totals.Confirmed, &v.Totals.Canceled,
+		&v.Totals.PendingAmount, &v.Totals.ConfirmedAmount, &v.Totals.CanceledAmount, &v.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// RecentOrders returns the latest limit orders of a user, newest first.
+func (r *ViewRepository) RecentOrders(ctx context.Context, userID uuid.UUID, limit int) ([]*domain.ViewOrder, error) {
+	query := `SELECT order_id, user_id, amount, status, credit_reserved, credit_released, created_at, updated_at
+		FROM view_orders WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`
+	rows, err := r.db.Query(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*domain.ViewOrder
+	for rows.Next() {
+		var o domain.ViewOrder
+		var status string
+		if err := rows.Scan(&o.OrderID, &o.UserID, &o.Amount, &status, &o.CreditReserved, &o.CreditReleased, &o.CreatedAt, &o.UpdatedAt); err != nil {
+			return nil, err
+		}
+		o.Status = events.OrderStatus(status)
+		list = append(list, &o)
+	}
+	return list, rows.Err()
+}
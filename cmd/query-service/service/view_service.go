@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"go_example/internal/events"
+	"go_example/cmd/query-service/domain"
+	"go_example/cmd/query-service/dto"
+	"go_example/cmd/query-service/repository"
+)
+
+var ErrUserNotFound = errors.New("user view not found")
+
+// ViewService maintains and serves the denormalized user views.
+type ViewService struct {
+	repo         *repository.ViewRepository
+	tx           *repository.TxManager
+	recentOrders int
+}
+
+// NewViewService creates a new ViewService that returns up to recentOrders orders per view.
+func NewViewService(repo *repository.ViewRepository, tx *repository.TxManager, recentOrders int) *ViewService {
+	return &ViewService{repo: repo, tx: tx, recentOrders: recentOrders}
+}
+
+// Generation returns the current projection generation. Events are projected under the
+// consumer group of the current generation.
+func (s *ViewService) Generation(ctx context.Context) (int64, error) {
+	return s.repo.Generation(ctx)
+}
+
+// Rebuild empties the views and starts a new generation, whose consumers project every topic
+// again from offset zero. Updates in flight under the old generation finish first.
+func (s *ViewService) Rebuild(ctx context.Context) (int64, error) {
+	var gen int64
+	err := s.tx.Run(ctx, func(views *repository.ViewRepository) error {
+		var err error
+		gen, err = views.Reset(ctx)
+		return err
+	})
+	return gen, err
+}
+
+// ApplyUserCreated projects a user.created event consumed under generation gen. It changes
+// nothing if gen is no longer current.
+func (s *ViewService) ApplyUserCreated(ctx context.Context, gen int64, evt events.UserCreatedEvent, at time.Time) error {
+	return s.tx.Run(ctx, func(views *repository.ViewRepository) error {
+		if cur, err := views.LockGeneration(ctx); err != nil || cur != gen {
+			return err
+		}
+		return views.UpsertUser(ctx, evt.UserID, evt.Username, evt.Balance, at)
+	})
+}
+
+// ApplyOrderFact merges an order fact consumed under generation gen into the order and its
+// user's view. It changes nothing if gen is no longer current.
+func (s *ViewService) ApplyOrderFact(ctx context.Context, gen int64, f domain.OrderFact) error {
+	return s.tx.Run(ctx, func(views *repository.ViewRepository) error {
+		if cur, err := views.LockGeneration(ctx); err != nil || cur != gen {
+			return err
+		}
+		o, err := views.LockOrder(ctx, f)
+		if err != nil {
+			return err
+		}
+		var totals domain.OrderTotals
+		totals.Add(o.Status, -1, o.Amount)
+		balanceDelta := o.Merge(f)
+		totals.Add(o.Status, 1, o.Amount)
+		if err := views.SaveOrder(ctx, o); err != nil {
+			return err
+		}
+		return views.AddToUser(ctx, o.UserID, totals, balanceDelta, f.At)
+	})
+}
+
+// GetUserView returns the view of a user with their latest orders.
+func (s *ViewService) GetUserView(ctx context.Context, id uuid.UUID) (*dto.UserViewResponse, error) {
+	v, err := s.repo.GetUser(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if v.RecentOrders, err = s.repo.RecentOrders(ctx, id, s.recentOrders); err != nil {
+		return nil, err
+	}
+	return toUserViewResponse(v), nil
+}
+
+func toUserViewResponse(v *domain.UserView) *dto.UserViewResponse {
+	t := v.Totals
+	resp := &dto.UserViewResponse{
+		UserID:   v.UserID,
+		Username: v.Username,
+		Orders: dto.OrderCounts{
+			Total:     t.Pending + t.Confirmed + t.Canceled,
+			Pending:   t.Pending,
+			Confirmed: t.Confirmed,
+			Canceled:  t.Canceled,
+		},
+		Totals: dto.OrderAmounts{
+			Ordered:   t.PendingAmount + t.ConfirmedAmount + t.CanceledAmount,
+			Pending:   t.PendingAmount,
+			Confirmed: t.ConfirmedAmount,
+			Canceled:  t.CanceledAmount,
+		},
+		RecentOrders: make([]dto.RecentOrder, 0, len(v.RecentOrders)),
+		UpdatedAt:    v.UpdatedAt,
+	}
+	if balance, ok := v.Balance(); ok {
+		resp.Balance = &balance
+	}
+	for _, o := range v.RecentOrders {
+		resp.RecentOrders = append(resp.RecentOrders, dto.RecentOrder{
+			ID:        o.OrderID,
+			Amount:    o.Amount,
+			Status:    string(o.Status),
+			CreatedAt: o.CreatedAt,
+			UpdatedAt: o.UpdatedAt,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,192 @@
+// Package scan reads a topic partition by partition, from an offset or a timestamp up to the
+// end offset observed at start, and filters saga events by order or user ID.
+package scan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+
+	"go_example/internal/bus"
+	"go_example/internal/events"
+	"go_example/internal/kafkaconn"
+)
+
+// Query selects the messages to read.
+type Query struct {
+	Conn      *kafkaconn.Conn
+	Topic     string
+	Partition int   // -1 reads all partitions
+	Offset    int64 // start offset, ignored when Since is set; kafka.FirstOffset for the beginning
+	Since     time.Time
+	Until     time.Time
+	OrderID   uuid.UUID
+	UserID    uuid.UUID
+	Limit     int
+}
+
+// Record is a message read from the topic with its decoded saga event.
+type Record struct {
+	bus.Message
+	// OriginalTopic is the topic the event was first published to: the x-original-topic
+	// header for dead-lettered messages, otherwise the topic it was read from.
+	OriginalTopic string
+	Event         any
+	DecodeErr     error
+}
+
+// Run calls fn for each message matching q, in offset order within each partition.
+func Run(ctx context.Context, q Query, fn func(Record) error) error {
+	client := q.Conn.Client(10 * time.Second)
+	partitions, err := partitionsOf(ctx, client, q.Topic)
+	if err != nil {
+		return err
+	}
+	if q.Partition >= 0 {
+		partitions = []int{q.Partition}
+	}
+	bounds, err := offsetsOf(ctx, client, q.Topic, partitions)
+	if err != nil {
+		return err
+	}
+	matched := 0
+	for _, p := range partitions {
+		b := bounds[p]
+		if b.Error != nil {
+			return fmt.Errorf("scan: %s[%d] offsets: %w", q.Topic, p, b.Error)
+		}
+		n, err := readPartition(ctx, q, p, b, q.Limit-matched, fn)
+		if err != nil {
+			return err
+		}
+		matched += n
+		if q.Limit > 0 && matched >= q.Limit {
+			break
+		}
+	}
+	return nil
+}
+
+func readPartition(ctx context.Context, q Query, partition int, bounds kafka.PartitionOffsets, limit int, fn func(Record) error) (int, error) {
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   q.Conn.Brokers,
+		Dialer:    q.Conn.Dialer,
+		Topic:     q.Topic,
+		Partition: partition,
+		MinBytes:  1,
+		MaxBytes:  10e6,
+	})
+	defer r.Close()
+	start := max(q.Offset, bounds.FirstOffset)
+	if !q.Since.IsZero() {
+		if err := r.SetOffsetAt(ctx, q.Since); err != nil {
+			return 0, fmt.Errorf("scan: %s[%d] seek to %s: %w", q.Topic, partition, q.Since, err)
+		}
+		// A negative offset means no message at or after Since.
+		if start = r.Offset(); start < 0 {
+			return 0, nil
+		}
+	} else if err := r.SetOffset(start); err != nil {
+		return 0, fmt.Errorf("scan: %s[%d] seek to %d: %w", q.Topic, partition, start, err)
+	}
+	matched := 0
+	for next := start; next < bounds.LastOffset; {
+		msg, err := r.ReadMessage(ctx)
+		if err != nil {
+			return matched, fmt.Errorf("scan: %s[%d] read: %w", q.Topic, partition, err)
+		}
+		next = msg.Offset + 1
+		if !q.Until.IsZero() && msg.Time.After(q.Until) {
+			break
+		}
+		rec := decode(msg)
+		if !q.matches(rec) {
+			continue
+		}
+		if err := fn(rec); err != nil {
+			return matched, err
+		}
+		matched++
+		if limit > 0 && matched >= limit {
+			break
+		}
+	}
+	return matched, nil
+}
+
+func (q Query) matches(rec Record) bool {
+	if q.OrderID == uuid.Nil && q.UserID == uuid.Nil {
+		return true
+	}
+	var env events.Envelope
+	if err := json.Unmarshal(rec.Value, &env); err != nil {
+		return false
+	}
+	return (q.OrderID == uuid.Nil || env.OrderID == q.OrderID) && (q.UserID == uuid.Nil || env.UserID == q.UserID)
+}
+
+func decode(msg kafka.Message) Record {
+	rec := Record{
+		Message: bus.Message{
+			Topic:     msg.Topic,
+			Key:       msg.Key,
+			Value:     msg.Value,
+			Partition: msg.Partition,
+			Offset:    msg.Offset,
+			Time:      msg.Time,
+		},
+		OriginalTopic: msg.Topic,
+	}
+	if len(msg.Headers) > 0 {
+		rec.Headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			rec.Headers[h.Key] = string(h.Value)
+		}
+	}
+	if orig := rec.Headers[bus.HeaderOriginalTopic]; orig != "" {
+		rec.OriginalTopic = orig
+	}
+	rec.Event, rec.DecodeErr = events.Decode(rec.OriginalTopic, msg.Value)
+	return rec
+}
+
+func partitionsOf(ctx context.Context, client *kafka.Client, topic string) ([]int, error) {
+	meta, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, fmt.Errorf("scan: metadata: %w", err)
+	}
+	for _, t := range meta.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if t.Error != nil {
+			return nil, fmt.Errorf("scan: %s: %w", topic, t.Error)
+		}
+		out := make([]int, len(t.Partitions))
+		for i, p := range t.Partitions {
+			out[i] = p.ID
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("scan: topic %s not found", topic)
+}
+
+func offsetsOf(ctx context.Context, client *kafka.Client, topic string, partitions []int) (map[int]kafka.PartitionOffsets, error) {
+	reqs := make([]kafka.OffsetRequest, 0, 2*len(partitions))
+	for _, p := range partitions {
+		reqs = append(reqs, kafka.FirstOffsetOf(p), kafka.LastOffsetOf(p))
+	}
+	res, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: reqs}})
+	if err != nil {
+		return nil, fmt.Errorf("scan: list offsets: %w", err)
+	}
+	out := make(map[int]kafka.PartitionOffsets, len(partitions))
+	for _, po := range res.Topics[topic] {
+		out[po.Partition] = po
+	}
+	return out, nil
+}
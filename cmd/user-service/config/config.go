@@ -3,15 +3,46 @@ package config
 import (
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"go_example/internal/bus"
+	"go_example/internal/kafkaconn"
+	"go_example/internal/logging"
+	"go_example/internal/tracing"
+	"go_example/cmd/user-service/password"
 )
 
-// Config holds user-service configuration.
+// Config holds user-service configuration. AdminPort serves GET|PUT /admin/log-level, kept off
+// the public ServerPort; empty disables it.
 type Config struct {
 	ServerPort      string
+	AdminPort       string
 	DB              DBConfig
+	Bus             string
 	Kafka           KafkaConfig
+	Consumer        ConsumerConfig
+	Outbox          OutboxConfig
 	OrderServiceURL string
+	Tracing         tracing.Config
+	Logging         logging.Config
+	ShutdownGrace   time.Duration
+	ShutdownDrain   time.Duration
+	Auth            AuthConfig
+}
+
+// AuthConfig holds credential and token settings. Access tokens are signed by RS256 with the
+// key in PrivateKeyFile or, without one, a key generated once and kept in the database so every
+// instance signs with it. Users flagged admin in the database get AdminScope.
+type AuthConfig struct {
+	Issuer         string
+	Audience       string
+	AccessTTL      time.Duration
+	RefreshTTL     time.Duration
+	PrivateKeyFile string
+	AdminScope     string
+	Password       password.Params
 }
 
 // DBConfig holds PostgreSQL configuration.
@@ -23,15 +54,41 @@ type DBConfig struct {
 	Password string
 }
 
-// KafkaConfig holds Kafka broker configuration.
+// KafkaConfig holds Kafka connection (brokers, TLS, SASL) and topic configuration.
 type KafkaConfig struct {
-	Brokers []string
+	kafkaconn.Config
+	ReplicationFactor int
+}
+
+// ConsumerConfig holds per-topic consumer processing configuration. OffsetRetention is how
+// long processed-delivery records are kept for deduplication; keep it at least the topic retention.
+type ConsumerConfig struct {
+	Concurrency        map[string]int
+	DefaultConcurrency int
+	MaxInFlight        int
+	OffsetRetention    time.Duration
+}
+
+// OutboxConfig holds outbox relay configuration.
+type OutboxConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// Options returns the processing options for topic.
+func (c ConsumerConfig) Options(topic string) bus.Options {
+	n, ok := c.Concurrency[topic]
+	if !ok {
+		n = c.DefaultConcurrency
+	}
+	return bus.Options{Concurrency: n, MaxInFlight: c.MaxInFlight}
 }
 
 // Load reads configuration from environment.
 func Load() *Config {
 	return &Config{
 		ServerPort: getEnv("SERVER_PORT", "8081"),
+		AdminPort:  getEnv("ADMIN_PORT", "9080"),
 		DB: DBConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
 			Port:     getEnv("DB_PORT", "5432"),
@@ -39,10 +96,41 @@ func Load() *Config {
 			User:     getEnv("DB_USER", "user"),
 			Password: getEnv("DB_PASSWORD", "password"),
 		},
+		Bus: getEnv("BUS", "kafka"),
 		Kafka: KafkaConfig{
-			Brokers: getEnvSlice("KAFKA_BOOTSTRAP_SERVERS", []string{"localhost:9092"}),
+			Config:            kafkaconn.FromEnv(getEnvSlice("KAFKA_BOOTSTRAP_SERVERS", []string{"localhost:9092"})),
+			ReplicationFactor: getEnvInt("KAFKA_TOPIC_REPLICATION_FACTOR", 1),
+		},
+		Consumer: ConsumerConfig{
+			Concurrency:        getEnvIntMap("CONSUMER_CONCURRENCY"),
+			DefaultConcurrency: getEnvInt("CONSUMER_DEFAULT_CONCURRENCY", 4),
+			MaxInFlight:        getEnvInt("CONSUMER_MAX_IN_FLIGHT", bus.DefaultMaxInFlight),
+			OffsetRetention:    getEnvDuration("CONSUMER_OFFSET_RETENTION", 7*24*time.Hour),
+		},
+		Outbox: OutboxConfig{
+			PollInterval: getEnvDuration("OUTBOX_POLL_INTERVAL", 200*time.Millisecond),
+			BatchSize:    getEnvInt("OUTBOX_BATCH_SIZE", 100),
 		},
 		OrderServiceURL: getEnv("ORDER_SERVICE_URL", "http://localhost:8091"),
+		Tracing:         tracing.FromEnv(),
+		Logging:         logging.FromEnv(),
+		ShutdownGrace:   getEnvDuration("SHUTDOWN_GRACE_PERIOD", 20*time.Second),
+		ShutdownDrain:   getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+		Auth: AuthConfig{
+			Issuer:         getEnv("AUTH_ISSUER", "user-service"),
+			Audience:       getEnv("AUTH_AUDIENCE", ""),
+			AccessTTL:      getEnvDuration("AUTH_ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTTL:     getEnvDuration("AUTH_REFRESH_TOKEN_TTL", 30*24*time.Hour),
+			PrivateKeyFile: getEnv("AUTH_PRIVATE_KEY_FILE", ""),
+			AdminScope:     getEnv("AUTH_ADMIN_SCOPE", "admin"),
+			Password: password.Params{
+				Algorithm:  getEnv("PASSWORD_HASH", password.DefaultParams.Algorithm),
+				Memory:     uint32(getEnvInt("PASSWORD_ARGON2_MEMORY_KIB", int(password.DefaultParams.Memory))),
+				Iterations: uint32(getEnvInt("PASSWORD_ARGON2_ITERATIONS", int(password.DefaultParams.Iterations))),
+				Threads:    uint8(getEnvInt("PASSWORD_ARGON2_THREADS", int(password.DefaultParams.Threads))),
+				BcryptCost: getEnvInt("PASSWORD_BCRYPT_COST", password.DefaultParams.BcryptCost),
+			},
+		},
 	}
 }
 
@@ -53,6 +141,24 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
+func getEnvInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
 func getEnvSlice(key string, fallback []string) []string {
 	if v := os.Getenv(key); v != "" {
 		parts := strings.Split(v, ",")
@@ -69,6 +175,21 @@ func getEnvSlice(key string, fallback []string) []string {
 	return fallback
 }
 
+// getEnvIntMap parses key=value pairs such as "order.created=8,order.canceled=2".
+func getEnvIntMap(key string) map[string]int {
+	out := map[string]int{}
+	for _, pair := range getEnvSlice(key, nil) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			out[strings.TrimSpace(k)] = n
+		}
+	}
+	return out
+}
+
 // DSN returns PostgreSQL connection string (password URL-escaped).
 func (c *DBConfig) DSN() string {
 	user := url.UserPassword(c.User, c.Password)
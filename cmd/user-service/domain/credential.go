@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is an issued refresh token; only the SHA-256 of the token is stored. Refreshing
+// revokes it and issues its replacement in the same family, which is the session started by
+// one login.
+type RefreshToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	FamilyID   uuid.UUID
+	TokenHash  []byte
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	RevokedAt  *time.Time
+	ReplacedBy *uuid.UUID
+}
+
+// SigningKey is an RSA private key, PEM-encoded, that signs access tokens under KID.
+type SigningKey struct {
+	KID        string
+	PrivateKey string
+	CreatedAt  time.Time
+}
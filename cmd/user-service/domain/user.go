@@ -6,10 +6,13 @@ import (
 	"github.com/google/uuid"
 )
 
-// User represents a user entity.
+// User represents a user entity. PasswordHash is empty for users created before credentials,
+// who cannot log in. IsAdmin is only set in the database, never through signup.
 type User struct {
-	ID        uuid.UUID
-	Username  string
-	Balance   int64
-	CreatedAt time.Time
+	ID           uuid.UUID
+	Username     string
+	PasswordHash string
+	Balance      int64
+	IsAdmin      bool
+	CreatedAt    time.Time
 }
@@ -9,6 +9,7 @@ import (
 // CreateUserRequest is the request body for creating a user.
 type CreateUserRequest struct {
 	Username       string `json:"username"`
+	Password       string `json:"password"`
 	InitialBalance int64  `json:"initialBalance"`
 }
 
@@ -19,3 +20,24 @@ type UserResponse struct {
 	Balance   int64     `json:"balance"`
 	CreatedAt time.Time `json:"createdAt"`
 }
+
+// LoginRequest is the request body for logging in.
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// RefreshRequest is the request body for refreshing tokens and for logging out.
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// TokenResponse carries a bearer access token and the refresh token that replaces it. Lifetimes
+// are in seconds.
+type TokenResponse struct {
+	AccessToken      string `json:"accessToken"`
+	TokenType        string `json:"tokenType"`
+	ExpiresIn        int64  `json:"expiresIn"`
+	RefreshToken     string `json:"refreshToken"`
+	RefreshExpiresIn int64  `json:"refreshExpiresIn"`
+}
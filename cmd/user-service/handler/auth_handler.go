@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+
+	"go_example/cmd/user-service/dto"
+	"go_example/cmd/user-service/service"
+)
+
+// AuthHandler handles login, token refresh and logout, and serves the token signing keys.
+type AuthHandler struct {
+	svc *service.AuthService
+}
+
+// NewAuthHandler creates a new AuthHandler.
+func NewAuthHandler(svc *service.AuthService) *AuthHandler {
+	return &AuthHandler{svc: svc}
+}
+
+// Login issues tokens for a username and password. POST /auth/login
+func (h *AuthHandler) Login(c fiber.Ctx) error {
+	var req dto.LoginRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+	}
+	if req.Username == "" || req.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "username and password are required"})
+	}
+	tokens, err := h.svc.Login(c.Context(), req)
+	if err != nil {
+		return authError(c, err)
+	}
+	return c.JSON(tokens)
+}
+
+// Refresh exchanges a refresh token for new tokens. POST /auth/refresh
+func (h *AuthHandler) Refresh(c fiber.Ctx) error {
+	var req dto.RefreshRequest
+	if err := c.Bind().Body(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "refreshToken is required"})
+	}
+	tokens, err := h.svc.Refresh(c.Context(), req.RefreshToken)
+	if err != nil {
+		return authError(c, err)
+	}
+	return c.JSON(tokens)
+}
+
+// Logout revokes the session of a refresh token. POST /auth/logout
+func (h *AuthHandler) Logout(c fiber.Ctx) error {
+	var req dto.RefreshRequest
+	if err := c.Bind().Body(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "refreshToken is required"})
+	}
+	if err := h.svc.Logout(c.Context(), req.RefreshToken); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// JWKS serves the public keys that verify access tokens. GET /.well-known/jwks.json
+func (h *AuthHandler) JWKS(c fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(h.svc.JWKS())
+}
+
+func authError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrInvalidCredentials), errors.Is(err, service.ErrInvalidRefreshToken):
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
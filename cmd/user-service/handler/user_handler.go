@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"fmt"
+
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
 
@@ -9,6 +11,9 @@ import (
 	"go_example/cmd/user-service/service"
 )
 
+// minPasswordLength is the shortest password accepted for a new user.
+const minPasswordLength = 8
+
 // UserHandler handles HTTP requests for users.
 type UserHandler struct {
 	svc            *service.UserService
@@ -29,6 +34,9 @@ func (h *UserHandler) CreateUser(c fiber.Ctx) error {
 	if req.Username == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "username is required"})
 	}
+	if len(req.Password) < minPasswordLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("password must have at least %d characters", minPasswordLength)})
+	}
 	if req.InitialBalance < 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "initialBalance must be non-negative"})
 	}
@@ -3,162 +3,133 @@ package kafka
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"sync"
+	"time"
 
-	"github.com/google/uuid"
-	"github.com/segmentio/kafka-go"
-
+	"go_example/internal/bus"
 	"go_example/internal/events"
+	"go_example/internal/logging"
+	"go_example/cmd/user-service/config"
 	"go_example/cmd/user-service/service"
 )
 
-// Consumer runs Kafka consumers for user-service (order.created, order.canceled topics).
+// Group is the consumer group user-service subscribes with.
+const Group = "user-service-group"
+
+// pruneInterval is how often expired processed-delivery records are deleted.
+const pruneInterval = time.Hour
+
+// Consumer runs consumers for user-service (order.created, order.canceled topics). Credit
+// replies are written to the outbox with the balance change and published by the outbox relay.
 type Consumer struct {
 	userSvc *service.UserService
-	brokers []string
-	writer  *kafka.Writer
+	sub     bus.Subscriber
+	cfg     config.ConsumerConfig
 }
 
 // NewConsumer creates a new Consumer.
-func NewConsumer(userSvc *service.UserService, brokers []string) *Consumer {
-	return &Consumer{
-		userSvc: userSvc,
-		brokers: brokers,
-		writer: &kafka.Writer{
-			Addr:     kafka.TCP(brokers[0]),
-			Topic:    "", // set per message
-			Balancer: &kafka.LeastBytes{},
-		},
-	}
+func NewConsumer(userSvc *service.UserService, sub bus.Subscriber, cfg config.ConsumerConfig) *Consumer {
+	return &Consumer{userSvc: userSvc, sub: sub, cfg: cfg}
 }
 
-// Close closes the Kafka writer.
-func (c *Consumer) Close() error {
-	return c.writer.Close()
-}
-
-// Run starts consuming order.created and order.canceled topics.
+// Run starts consuming order.created and order.canceled topics and blocks until ctx is canceled.
 func (c *Consumer) Run(ctx context.Context) {
 	var wg sync.WaitGroup
-	wg.Add(2)
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		c.subscribe(ctx, events.TopicOrderCreated, c.handleOrderCreated)
+	}()
 	go func() {
 		defer wg.Done()
-		c.consumeOrderCreated(ctx)
+		c.subscribe(ctx, events.TopicOrderCanceled, c.handleOrderCanceled)
 	}()
 	go func() {
 		defer wg.Done()
-		c.consumeOrderCanceled(ctx)
+		c.pruneDeliveries(ctx)
 	}()
 	wg.Wait()
 }
 
-func (c *Consumer) consumeOrderCreated(ctx context.Context) {
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  c.brokers,
-		Topic:    "order.created",
-		GroupID:  "user-service-group",
-		MinBytes: 1,
-		MaxBytes: 10e6,
-	})
-	defer r.Close()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			msg, err := r.ReadMessage(ctx)
-			if err != nil {
-				if ctx.Err() != nil {
-					return
-				}
-				log.Printf("[user-service] order.created read error: %v", err)
-				continue
-			}
-			var evt events.OrderCreatedEvent
-			if err := json.Unmarshal(msg.Value, &evt); err != nil {
-				log.Printf("[user-service] order.created unmarshal error: %v", err)
-				continue
-			}
-			log.Printf("[user-service] Received OrderCreatedEvent: orderId=%s userId=%s amount=%d", evt.OrderID, evt.UserID, evt.Amount)
-			reserved, err := c.userSvc.ReserveCredit(ctx, evt.UserID, evt.Amount)
-			if err != nil {
-				log.Printf("[user-service] reserve credit error: %v", err)
-				c.publishCreditReservationFailed(ctx, evt.OrderID, evt.UserID, evt.Amount, err.Error())
-				continue
-			}
-			if reserved {
-				log.Printf("[user-service] Credit reserved for orderId=%s", evt.OrderID)
-				c.publishCreditReserved(ctx, evt.OrderID, evt.UserID, evt.Amount)
-			} else {
-				log.Printf("[user-service] Insufficient balance for orderId=%s", evt.OrderID)
-				c.publishCreditReservationFailed(ctx, evt.OrderID, evt.UserID, evt.Amount, "Insufficient balance")
-			}
-		}
+func (c *Consumer) subscribe(ctx context.Context, topic string, h bus.Handler) {
+	if err := c.sub.Subscribe(ctx, topic, Group, h, c.cfg.Options(topic)); err != nil {
+		slog.Error("subscribe failed", logging.KeyTopic, topic, logging.Err(err))
 	}
 }
 
-func (c *Consumer) consumeOrderCanceled(ctx context.Context) {
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  c.brokers,
-		Topic:    "order.canceled",
-		GroupID:  "user-service-group",
-		MinBytes: 1,
-		MaxBytes: 10e6,
-	})
-	defer r.Close()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-			msg, err := r.ReadMessage(ctx)
-			if err != nil {
-				if ctx.Err() != nil {
-					return
-				}
-				log.Printf("[user-service] order.canceled read error: %v", err)
-				continue
-			}
-			var evt events.OrderCanceledEvent
-			if err := json.Unmarshal(msg.Value, &evt); err != nil {
-				log.Printf("[user-service] order.canceled unmarshal error: %v", err)
-				continue
-			}
-			log.Printf("[user-service] Received OrderCanceledEvent: orderId=%s userId=%s amount=%d", evt.OrderID, evt.UserID, evt.Amount)
-			if err := c.userSvc.ReleaseCredit(ctx, evt.UserID, evt.Amount); err != nil {
-				log.Printf("[user-service] release credit error: %v", err)
-				continue
-			}
-			log.Printf("[user-service] Credit released for orderId=%s", evt.OrderID)
-		}
+func (c *Consumer) handleOrderCreated(ctx context.Context, msg bus.Message) error {
+	var evt events.OrderCreatedEvent
+	if err := json.Unmarshal(msg.Value, &evt); err != nil {
+		slog.ErrorContext(ctx, "unmarshal failed", logging.Err(err))
+		return bus.Permanent(err)
 	}
-}
-
-func (c *Consumer) publishCreditReserved(ctx context.Context, orderID, userID uuid.UUID, amount int64) {
-	evt := events.UserCreditReservedEvent{OrderID: orderID, UserID: userID, Amount: amount}
-	body, err := json.Marshal(evt)
+	ctx = logging.With(ctx, logging.OrderID(evt.OrderID), logging.UserID(evt.UserID))
+	slog.InfoContext(ctx, "received OrderCreatedEvent", "amount", evt.Amount)
+	reserved, processed, err := c.userSvc.ReserveCredit(ctx, delivery(msg), evt)
 	if err != nil {
-		log.Printf("[user-service] marshal UserCreditReservedEvent: %v", err)
-		return
+		slog.ErrorContext(ctx, "reserve credit failed", logging.Err(err))
+		return err
 	}
-	if err := c.writeMessage(ctx, "user.credit-reserved", body); err != nil {
-		log.Printf("[user-service] write user.credit-reserved: %v", err)
+	switch {
+	case !processed:
+		slog.InfoContext(ctx, "order.created already processed")
+	case reserved:
+		slog.InfoContext(ctx, "credit reserved")
+	default:
+		slog.InfoContext(ctx, "credit reservation failed")
 	}
+	return nil
 }
 
-func (c *Consumer) publishCreditReservationFailed(ctx context.Context, orderID, userID uuid.UUID, amount int64, reason string) {
-	evt := events.UserCreditReservationFailedEvent{OrderID: orderID, UserID: userID, Amount: amount, Reason: reason}
-	body, err := json.Marshal(evt)
+func (c *Consumer) handleOrderCanceled(ctx context.Context, msg bus.Message) error {
+	var evt events.OrderCanceledEvent
+	if err := json.Unmarshal(msg.Value, &evt); err != nil {
+		slog.ErrorContext(ctx, "unmarshal failed", logging.Err(err))
+		return bus.Permanent(err)
+	}
+	ctx = logging.With(ctx, logging.OrderID(evt.OrderID), logging.UserID(evt.UserID))
+	slog.InfoContext(ctx, "received OrderCanceledEvent", "amount", evt.Amount)
+	released, processed, err := c.userSvc.ReleaseCredit(ctx, delivery(msg), evt)
 	if err != nil {
-		log.Printf("[user-service] marshal UserCreditReservationFailedEvent: %v", err)
-		return
+		slog.ErrorContext(ctx, "release credit failed", logging.Err(err))
+		if errors.Is(err, service.ErrUserNotFound) {
+			return bus.Skip(err)
+		}
+		return err
 	}
-	if err := c.writeMessage(ctx, "user.credit-reservation-failed", body); err != nil {
-		log.Printf("[user-service] write user.credit-reservation-failed: %v", err)
+	switch {
+	case !processed:
+		slog.InfoContext(ctx, "order.canceled already processed")
+	case released:
+		slog.InfoContext(ctx, "credit released")
+	default:
+		slog.InfoContext(ctx, "no credit reserved for order, nothing to release")
+	}
+	return nil
+}
+
+// pruneDeliveries periodically drops processed-delivery records older than the configured
+// retention until ctx is canceled.
+func (c *Consumer) pruneDeliveries(ctx context.Context) {
+	tick := time.NewTicker(pruneInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		n, err := c.userSvc.ForgetDeliveriesBefore(ctx, time.Now().Add(-c.cfg.OffsetRetention))
+		if err != nil {
+			slog.ErrorContext(ctx, "prune consumer offsets failed", logging.Err(err))
+		} else if n > 0 {
+			slog.InfoContext(ctx, "pruned consumer offsets", "offsets", n)
+		}
 	}
 }
 
-func (c *Consumer) writeMessage(ctx context.Context, topic string, value []byte) error {
-	return c.writer.WriteMessages(ctx, kafka.Message{Topic: topic, Value: value})
-}
\ No newline at end of file
+func delivery(msg bus.Message) service.Delivery {
+	return service.Delivery{Group: Group, Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset}
+}
@@ -0,0 +1,59 @@
+package kafka
+
+import (
+	"time"
+
+	"go_example/internal/bus"
+	"go_example/internal/events"
+	"go_example/internal/topics"
+)
+
+// Topics declares the topics user-service owns: the ones it publishes to and the
+// dead-letter topics of the ones it consumes.
+func Topics(replicationFactor int) []topics.Spec {
+	return []topics.Spec{
+		{
+			Name:              events.TopicUserCreditReserved,
+			Partitions:        3,
+			ReplicationFactor: replicationFactor,
+			Retention:         7 * 24 * time.Hour,
+			CleanupPolicy:     topics.CleanupDelete,
+		},
+		{
+			Name:              events.TopicUserCreditReservationFailed,
+			Partitions:        3,
+			ReplicationFactor: replicationFactor,
+			Retention:         7 * 24 * time.Hour,
+			CleanupPolicy:     topics.CleanupDelete,
+		},
+		{
+			Name:              events.TopicUserCreditReleased,
+			Partitions:        3,
+			ReplicationFactor: replicationFactor,
+			Retention:         7 * 24 * time.Hour,
+			CleanupPolicy:     topics.CleanupDelete,
+		},
+		{
+			// Compacted on user ID, so a read model rebuilt from offset zero still sees every user.
+			Name:              events.TopicUserCreated,
+			Partitions:        3,
+			ReplicationFactor: replicationFactor,
+			Retention:         7 * 24 * time.Hour,
+			CleanupPolicy:     topics.CleanupCompact,
+		},
+		{
+			Name:              bus.DeadLetterTopic(events.TopicOrderCreated),
+			Partitions:        1,
+			ReplicationFactor: replicationFactor,
+			Retention:         30 * 24 * time.Hour,
+			CleanupPolicy:     topics.CleanupDelete,
+		},
+		{
+			Name:              bus.DeadLetterTopic(events.TopicOrderCanceled),
+			Partitions:        1,
+			ReplicationFactor: replicationFactor,
+			Retention:         30 * 24 * time.Hour,
+			CleanupPolicy:     topics.CleanupDelete,
+		},
+	}
+}
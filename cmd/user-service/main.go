@@ -3,99 +3,68 @@ package main
 
 import (
 	"context"
-	"embed"
+	"flag"
 	"log"
-	"net/http"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
-	"github.com/gofiber/fiber/v3"
-	"github.com/gofiber/fiber/v3/middleware/logger"
-	"github.com/gofiber/fiber/v3/middleware/recover"
-	"github.com/jackc/pgx/v5/pgxpool"
-
-	"go_example/internal/metrics"
+	"go_example/internal/bus"
+	"go_example/internal/lifecycle"
+	"go_example/internal/logging"
+	"go_example/internal/topics"
+	"go_example/internal/tracing"
+	"go_example/cmd/user-service/app"
 	"go_example/cmd/user-service/config"
-	"go_example/cmd/user-service/handler"
 	"go_example/cmd/user-service/kafka"
-	"go_example/cmd/user-service/repository"
-	"go_example/cmd/user-service/service"
 )
 
-//go:embed migrations/*.sql
-var migrationsFS embed.FS
-
 func main() {
-	cfg := config.Load()
+	checkTopics := flag.Bool("check-topics", false, "report Kafka topic drift and exit without changing anything")
+	flag.Parse()
 
-	pool, err := pgxpool.New(context.Background(), cfg.DB.DSN())
-	if err != nil {
-		log.Fatalf("db: %v", err)
+	cfg := config.Load()
+	if err := logging.Setup("user-service", cfg.Logging); err != nil {
+		log.Fatalf("user-service: %v", err)
 	}
-	defer pool.Close()
-
-	if err := runMigrations(pool); err != nil {
-		log.Fatalf("migrations: %v", err)
+	topicSpecs := kafka.Topics(cfg.Kafka.ReplicationFactor)
+	if *checkTopics {
+		if err := topics.Run(context.Background(), cfg.Kafka.Config, topicSpecs, true); err != nil {
+			logging.Fatal("topic check failed", logging.Err(err))
+		}
+		return
 	}
 
-	userRepo := repository.NewUserRepository(pool)
-	userSvc := service.NewUserService(userRepo)
-	userHandler := handler.NewUserHandler(userSvc, cfg.OrderServiceURL)
-
-	consumer := kafka.NewConsumer(userSvc, cfg.Kafka.Brokers)
-	defer consumer.Close()
-
-	metrics.RegisterHTTPMetrics("user-service")
-
-	app := fiber.New()
-	app.Use(recover.New())
-	app.Use(logger.New())
-	app.Use(metrics.HTTPMiddleware())
-	app.Get("/metrics", metrics.MetricsHandler())
-	app.Get("/health", func(c fiber.Ctx) error { return c.JSON(fiber.Map{"status": "UP"}) })
-	app.Post("/users", userHandler.CreateUser)
-	app.Get("/users/:id/orders", userHandler.GetUserWithOrders)
-	app.Get("/users/:id", userHandler.GetByID)
-
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
-
-	go func() {
-		if err := app.Listen(":"+cfg.ServerPort, fiber.ListenConfig{DisableStartupMessage: true}); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("http: %v", err)
+	if cfg.Bus == bus.KindKafka {
+		if err := topics.Run(context.Background(), cfg.Kafka.Config, topicSpecs, false); err != nil {
+			logging.Fatal("topic setup failed", logging.Err(err))
 		}
-	}()
-
-	go consumer.Run(ctx)
+	}
 
-	<-ctx.Done()
-	log.Println("user-service shutting down")
-	if err := app.ShutdownWithContext(ctx); err != nil {
-		log.Printf("shutdown: %v", err)
+	b, err := bus.New(cfg.Bus, cfg.Kafka.Config)
+	if err != nil {
+		logging.Fatal("bus setup failed", logging.Err(err))
 	}
-}
 
-// advisoryLockID ensures only one instance runs migrations when multiple share the same DB.
-const advisoryLockID int64 = 0x75736572 // "user"
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-func runMigrations(pool *pgxpool.Pool) error {
-	ctx := context.Background()
-	conn, err := pool.Acquire(ctx)
+	lc := lifecycle.New()
+	shutdownTracing, err := tracing.Setup(context.Background(), "user-service", cfg.Tracing)
 	if err != nil {
-		return err
+		logging.Fatal("tracing setup failed", logging.Err(err))
 	}
-	defer conn.Release()
-	// Single migration runner when multiple instances share the same DB.
-	_, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", advisoryLockID)
-	if err != nil {
-		return err
+	lc.OnShutdown(lifecycle.PhaseTelemetry, "tracing", shutdownTracing)
+	lc.OnShutdown(lifecycle.PhaseProducers, "bus", func(context.Context) error { return b.Close() })
+	if err := app.Start(cfg, b, lc); err != nil {
+		logging.Fatal("start failed", logging.Err(err))
 	}
-	defer conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", advisoryLockID)
-	data, err := migrationsFS.ReadFile("migrations/000001_create_users_table.up.sql")
-	if err != nil {
-		return err
+	lc.SetReady()
+
+	<-ctx.Done()
+	slog.Info("shutting down")
+	if err := lc.Shutdown(cfg.ShutdownDrain, cfg.ShutdownGrace); err != nil {
+		slog.Error("shutdown failed", logging.Err(err))
 	}
-	_, err = conn.Exec(ctx, string(data))
-	return err
 }
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS processed_events;
+DROP INDEX IF EXISTS idx_consumer_offsets_processed_at;
+DROP TABLE IF EXISTS consumer_offsets;
+DROP TABLE IF EXISTS outbox;
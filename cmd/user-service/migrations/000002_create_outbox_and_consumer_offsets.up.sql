@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    topic VARCHAR(255) NOT NULL,
+    message_key VARCHAR(255) NOT NULL,
+    payload BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS consumer_offsets (
+    consumer_group VARCHAR(255) NOT NULL,
+    topic VARCHAR(255) NOT NULL,
+    partition INT NOT NULL,
+    message_offset BIGINT NOT NULL,
+    processed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (consumer_group, topic, partition, message_offset)
+);
+
+CREATE INDEX IF NOT EXISTS idx_consumer_offsets_processed_at ON consumer_offsets(processed_at);
+
+-- Saga events applied per order, and credit-reserved records, whatever offset an event was
+-- consumed at: a republished event has a new offset.
+CREATE TABLE IF NOT EXISTS processed_events (
+    order_id UUID NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    processed_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (order_id, event_type)
+);
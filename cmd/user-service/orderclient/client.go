@@ -9,10 +9,14 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"go_example/internal/requestid"
 )
 
-// DefaultHTTPClient is used for outbound calls to order-service (10s timeout).
-var DefaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
+// DefaultHTTPClient is used for outbound calls to order-service (10s timeout). Each call is a
+// client span whose trace context order-service receives.
+var DefaultHTTPClient = &http.Client{Timeout: 10 * time.Second, Transport: otelhttp.NewTransport(http.DefaultTransport)}
 
 // OrderSummary is the order payload from order-service GET /orders?userId=.
 type OrderSummary struct {
@@ -23,7 +27,7 @@ type OrderSummary struct {
 	CreatedAt string    `json:"createdAt"`
 }
 
-// ListByUserID fetches orders for the user from order-service.
+// ListByUserID fetches orders for the user from order-service, forwarding the request ID of ctx.
 func ListByUserID(ctx context.Context, baseURL string, userID uuid.UUID) ([]OrderSummary, error) {
 	u, err := url.Parse(baseURL)
 	if err != nil {
@@ -35,6 +39,9 @@ func ListByUserID(ctx context.Context, baseURL string, userID uuid.UUID) ([]Orde
 	if err != nil {
 		return nil, err
 	}
+	if id := requestid.FromContext(ctx); id != "" {
+		req.Header.Set(requestid.Header, id)
+	}
 	resp, err := DefaultHTTPClient.Do(req)
 	if err != nil {
 		return nil, err
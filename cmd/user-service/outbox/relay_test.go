@@ -0,0 +1,179 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"go_example/internal/bus"
+	"go_example/internal/requestid"
+	"go_example/cmd/user-service/domain"
+	"go_example/cmd/user-service/repository"
+)
+
+// fakeOutbox is an outbox table whose transactions see a copy of it, kept only on commit.
+type fakeOutbox struct {
+	rows       []domain.OutboxMessage
+	lockedElse bool // another instance holds the relay lock
+	lockIDs    []any
+}
+
+func (f *fakeOutbox) Run(ctx context.Context, fn func(tx repository.Tx) error) error {
+	db := &fakeDB{outbox: f, rows: slices.Clone(f.rows)}
+	if err := fn(repository.NewTx(db)); err != nil {
+		return err
+	}
+	f.rows = db.rows
+	return nil
+}
+
+type fakeDB struct {
+	outbox *fakeOutbox
+	rows   []domain.OutboxMessage
+}
+
+func (db *fakeDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	if !strings.HasPrefix(sql, "DELETE FROM outbox") {
+		return pgconn.CommandTag{}, errors.New("unexpected exec: " + sql)
+	}
+	ids := args[0].([]int64)
+	db.rows = slices.DeleteFunc(db.rows, func(m domain.OutboxMessage) bool { return slices.Contains(ids, m.ID) })
+	return pgconn.CommandTag{}, nil
+}
+
+func (db *fakeDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	if !strings.HasPrefix(sql, "SELECT id, topic") {
+		return nil, errors.New("unexpected query: " + sql)
+	}
+	return &fakeRows{rows: db.rows[:min(args[0].(int), len(db.rows))]}, nil
+}
+
+func (db *fakeDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return rowFunc(func(dest ...any) error {
+		if !strings.Contains(sql, "pg_try_advisory_xact_lock") {
+			return errors.New("unexpected query: " + sql)
+		}
+		db.outbox.lockIDs = append(db.outbox.lockIDs, args[0])
+		*dest[0].(*bool) = !db.outbox.lockedElse
+		return nil
+	})
+}
+
+type rowFunc func(dest ...any) error
+
+func (f rowFunc) Scan(dest ...any) error { return f(dest...) }
+
+type fakeRows struct {
+	pgx.Rows
+	rows []domain.OutboxMessage
+	cur  domain.OutboxMessage
+}
+
+func (r *fakeRows) Next() bool {
+	if len(r.rows) == 0 {
+		return false
+	}
+	r.cur, r.rows = r.rows[0], r.rows[1:]
+	return true
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	m := r.cur
+	for i, v := range []any{m.ID, m.Topic, m.Key, m.Payload, m.RequestID, m.TraceContext, m.CreatedAt} {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+func (r *fakeRows) Close()     {}
+func (r *fakeRows) Err() error { return nil }
+
+type fakePublisher struct {
+	published []bus.Message
+	err       error
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, msgs ...bus.Message) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.published = append(p.published, msgs...)
+	return nil
+}
+
+func (p *fakePublisher) Close() error { return nil }
+
+func TestRelayDrain(t *testing.T) {
+	pending := func(n int) []domain.OutboxMessage {
+		rows := make([]domain.OutboxMessage, n)
+		for i := range rows {
+			rows[i] = domain.OutboxMessage{ID: int64(i + 1), Topic: "user.credit-reserved", Key: "o" + string(rune('1'+i)), Payload: []byte(`{}`)}
+		}
+		return rows
+	}
+	tests := []struct {
+		name          string
+		rows          int
+		batchSize     int
+		lockedElse    bool
+		publishErr    error
+		wantPublished []string
+		wantLeft      int
+	}{
+		{"empty", 0, 2, false, nil, nil, 0},
+		{"one batch", 2, 5, false, nil, []string{"o1", "o2"}, 0},
+		{"several batches in order", 5, 2, false, nil, []string{"o1", "o2", "o3", "o4", "o5"}, 0},
+		{"lock held by another instance", 3, 2, true, nil, nil, 3},
+		{"publish fails", 3, 2, false, errors.New("broker down"), nil, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeOutbox{rows: pending(tt.rows), lockedElse: tt.lockedElse}
+			pub := &fakePublisher{err: tt.publishErr}
+			NewRelay(db, pub, time.Minute, tt.batchSize).drain(context.Background())
+
+			var keys []string
+			for _, m := range pub.published {
+				keys = append(keys, string(m.Key))
+			}
+			if !slices.Equal(keys, tt.wantPublished) {
+				t.Errorf("published %v, want %v", keys, tt.wantPublished)
+			}
+			if len(db.rows) != tt.wantLeft {
+				t.Errorf("%d events left in the outbox, want %d", len(db.rows), tt.wantLeft)
+			}
+			for _, id := range db.lockIDs {
+				if id != db.lockIDs[0] {
+					t.Errorf("relay lock IDs %v differ", db.lockIDs)
+				}
+			}
+			if len(db.lockIDs) == 0 {
+				t.Error("relay published without taking the lock")
+			}
+		})
+	}
+}
+
+func TestRelayHeaders(t *testing.T) {
+	db := &fakeOutbox{rows: []domain.OutboxMessage{{
+		ID: 1, Topic: "user.credit-reserved", Key: "o1", Payload: []byte(`{"orderId":"o1"}`),
+		RequestID: "req-1", TraceContext: map[string]string{"traceparent": "00-abc-def-01"},
+	}}}
+	pub := &fakePublisher{}
+	NewRelay(db, pub, time.Minute, 10).drain(context.Background())
+	if len(pub.published) != 1 {
+		t.Fatalf("published %d messages, want 1", len(pub.published))
+	}
+	m := pub.published[0]
+	want := map[string]string{"traceparent": "00-abc-def-01", requestid.MessageHeader: "req-1"}
+	if m.Topic != "user.credit-reserved" || string(m.Key) != "o1" || string(m.Value) != `{"orderId":"o1"}` || !reflect.DeepEqual(m.Headers, want) {
+		t.Errorf("published %+v", m)
+	}
+}
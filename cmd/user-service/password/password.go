@@ -0,0 +1,109 @@
+// Package password hashes and verifies user passwords with argon2id or bcrypt. Hashes are
+// self-describing (PHC string format for argon2id, modular crypt format for bcrypt), so either
+// kind verifies regardless of the algorithm currently used for new hashes.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Hashing algorithms.
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+// ErrMismatch is returned by Verify when the password does not match the hash.
+var ErrMismatch = errors.New("password does not match")
+
+// Params are the cost parameters for new hashes. Memory is in KiB.
+type Params struct {
+	Algorithm  string
+	Memory     uint32
+	Iterations uint32
+	Threads    uint8
+	BcryptCost int
+}
+
+// DefaultParams follow the OWASP recommendation for argon2id (19 MiB, 2 iterations).
+var DefaultParams = Params{Algorithm: Argon2id, Memory: 19 * 1024, Iterations: 2, Threads: 1, BcryptCost: 12}
+
+const (
+	saltLen = 16
+	keyLen  = 32
+)
+
+var b64 = base64.RawStdEncoding
+
+// Hash returns the hash of password with p.
+func Hash(password string, p Params) (string, error) {
+	switch p.Algorithm {
+	case Argon2id:
+		salt := make([]byte, saltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Threads, keyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, p.Memory, p.Iterations, p.Threads, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+	case Bcrypt:
+		h, err := bcrypt.GenerateFromPassword([]byte(password), p.BcryptCost)
+		return string(h), err
+	default:
+		return "", fmt.Errorf("password: unknown algorithm %q", p.Algorithm)
+	}
+}
+
+// Verify checks password against hash. It reports whether the hash should be replaced by one
+// made with p, because it uses another algorithm or weaker parameters.
+func Verify(hash, password string, p Params) (rehash bool, err error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		var version int
+		var memory, iterations uint32
+		var threads uint8
+		parts := strings.Split(hash, "$")
+		if len(parts) != 6 {
+			return false, errors.New("password: malformed argon2id hash")
+		}
+		if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+			return false, errors.New("password: unsupported argon2 version")
+		}
+		if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil ||
+			iterations < 1 || threads < 1 {
+			return false, errors.New("password: malformed argon2id parameters")
+		}
+		salt, err := b64.DecodeString(parts[4])
+		if err != nil {
+			return false, errors.New("password: malformed argon2id salt")
+		}
+		want, err := b64.DecodeString(parts[5])
+		if err != nil || len(want) == 0 {
+			return false, errors.New("password: malformed argon2id key")
+		}
+		got := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(want)))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			return false, ErrMismatch
+		}
+		return p.Algorithm != Argon2id || memory < p.Memory || iterations < p.Iterations || threads < p.Threads, nil
+	case strings.HasPrefix(hash, "$2"):
+		if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, ErrMismatch
+			}
+			return false, fmt.Errorf("password: %w", err)
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return p.Algorithm != Bcrypt || err != nil || cost < p.BcryptCost, nil
+	default:
+		return false, ErrMismatch
+	}
+}
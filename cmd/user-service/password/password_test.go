@@ -0,0 +1,102 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap keeps the tests fast; the algorithms are the same at any cost.
+var (
+	cheapArgon  = Params{Algorithm: Argon2id, Memory: 64, Iterations: 1, Threads: 1, BcryptCost: bcrypt.MinCost}
+	cheapBcrypt = Params{Algorithm: Bcrypt, Memory: 64, Iterations: 1, Threads: 1, BcryptCost: bcrypt.MinCost}
+)
+
+func mustHash(t *testing.T, password string, p Params) string {
+	t.Helper()
+	h, err := Hash(password, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestHashVerify(t *testing.T) {
+	argonHash := mustHash(t, "hunter2", cheapArgon)
+	bcryptHash := mustHash(t, "hunter2", cheapBcrypt)
+	stronger := cheapArgon
+	stronger.Memory, stronger.Iterations = 128, 2
+	moreThreads := cheapArgon
+	moreThreads.Threads = 2
+	costlier := cheapBcrypt
+	costlier.BcryptCost = bcrypt.MinCost + 1
+	tests := []struct {
+		name       string
+		hash       string
+		password   string
+		params     Params
+		wantRehash bool
+		wantErr    error // nil: match
+	}{
+		{name: "argon2id", hash: argonHash, password: "hunter2", params: cheapArgon},
+		{name: "argon2id mismatch", hash: argonHash, password: "hunter3", params: cheapArgon, wantErr: ErrMismatch},
+		{name: "argon2id empty password", hash: argonHash, password: "", params: cheapArgon, wantErr: ErrMismatch},
+		{name: "argon2id weaker than params", hash: argonHash, password: "hunter2", params: stronger, wantRehash: true},
+		{name: "argon2id fewer threads than params", hash: argonHash, password: "hunter2", params: moreThreads, wantRehash: true},
+		{name: "argon2id stronger than params", hash: mustHash(t, "hunter2", stronger), password: "hunter2", params: cheapArgon},
+		{name: "argon2id to bcrypt", hash: argonHash, password: "hunter2", params: cheapBcrypt, wantRehash: true},
+		{name: "bcrypt", hash: bcryptHash, password: "hunter2", params: cheapBcrypt},
+		{name: "bcrypt mismatch", hash: bcryptHash, password: "hunter3", params: cheapBcrypt, wantErr: ErrMismatch},
+		{name: "bcrypt cheaper than params", hash: bcryptHash, password: "hunter2", params: costlier, wantRehash: true},
+		{name: "bcrypt to argon2id", hash: bcryptHash, password: "hunter2", params: cheapArgon, wantRehash: true},
+		{name: "unknown format", hash: "hunter2", password: "hunter2", params: cheapArgon, wantErr: ErrMismatch},
+		{name: "empty hash", hash: "", password: "", params: cheapArgon, wantErr: ErrMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rehash, err := Verify(tt.hash, tt.password, tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+			if rehash != tt.wantRehash {
+				t.Errorf("rehash = %v, want %v", rehash, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestHashSalted(t *testing.T) {
+	a, b := mustHash(t, "hunter2", cheapArgon), mustHash(t, "hunter2", cheapArgon)
+	if a == b {
+		t.Error("two hashes of the same password are equal")
+	}
+	if !strings.HasPrefix(a, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Errorf("hash %q is not in PHC format", a)
+	}
+	if _, err := Hash("hunter2", Params{Algorithm: "md5"}); err == nil {
+		t.Error("Hash accepted an unknown algorithm")
+	}
+}
+
+func TestVerifyMalformedArgon2id(t *testing.T) {
+	good := strings.Split(mustHash(t, "hunter2", cheapArgon), "$")
+	tests := map[string]string{
+		"missing part":  strings.Join(good[:5], "$"),
+		"other version": strings.Join([]string{"", "argon2id", "v=16", good[3], good[4], good[5]}, "$"),
+		"bad params":    strings.Join([]string{"", "argon2id", good[2], "m=x", good[4], good[5]}, "$"),
+		"zero threads":  strings.Join([]string{"", "argon2id", good[2], "m=64,t=1,p=0", good[4], good[5]}, "$"),
+		"zero passes":   strings.Join([]string{"", "argon2id", good[2], "m=64,t=0,p=1", good[4], good[5]}, "$"),
+		"bad salt":      strings.Join([]string{"", "argon2id", good[2], good[3], "!", good[5]}, "$"),
+		"empty key":     strings.Join([]string{"", "argon2id", good[2], good[3], good[4], ""}, "$"),
+	}
+	for name, hash := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Verify(hash, "hunter2", cheapArgon)
+			if err == nil || errors.Is(err, ErrMismatch) {
+				t.Errorf("Verify error = %v, want a malformed hash error", err)
+			}
+		})
+	}
+}
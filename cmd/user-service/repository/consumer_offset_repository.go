@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// ConsumerOffsetRepository records which consumed messages have been processed, so a
+// redelivered message can be recognized in the same transaction as its business change.
+type ConsumerOffsetRepository struct {
+	db DBTX
+}
+
+// MarkProcessed records the message at offset of topic/partition as processed by group.
+// It returns false if it was already recorded. A concurrent transaction recording the same
+// message blocks this one until it commits or rolls back.
+func (r *ConsumerOffsetRepository) MarkProcessed(ctx context.Context, group, topic string, partition int, offset int64) (bool, error) {
+	query := `INSERT INTO consumer_offsets (consumer_group, topic, partition, message_offset, processed_at)
+		VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING`
+	tag, err := r.db.Exec(ctx, query, group, topic, partition, offset, time.Now())
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// DeleteProcessedBefore removes records older than t and returns how many were removed.
+func (r *ConsumerOffsetRepository) DeleteProcessedBefore(ctx context.Context, t time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM consumer_offsets WHERE processed_at < $1`, t)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProcessedEventRepository records which saga events have been applied per order and whether
+// credit was reserved for it. Unlike consumer offsets, this also recognizes an event
+// republished at another offset, such as by sagactl redrive.
+type ProcessedEventRepository struct {
+	db DBTX
+}
+
+// MarkProcessed records eventType as processed for orderID. It returns false if it was already
+// recorded. A concurrent transaction recording the same event blocks this one until it commits
+// or rolls back.
+func (r *ProcessedEventRepository) MarkProcessed(ctx context.Context, orderID uuid.UUID, eventType string) (bool, error) {
+	query := `INSERT INTO processed_events (order_id, event_type, processed_at)
+		VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+	tag, err := r.db.Exec(ctx, query, orderID, eventType, time.Now())
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// LockOrder serializes the credit changes of orderID until the transaction ends, so an
+// order.created and an order.canceled of the same order, consumed concurrently from their own
+// topics, see each other's records.
+func (r *ProcessedEventRepository) LockOrder(ctx context.Context, orderID uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1::text, 0))`, orderID)
+	return err
+}
+
+// IsProcessed reports whether eventType has been recorded as processed for orderID.
+func (r *ProcessedEventRepository) IsProcessed(ctx context.Context, orderID uuid.UUID, eventType string) (bool, error) {
+	var ok bool
+	query := `SELECT EXISTS (SELECT 1 FROM processed_events WHERE order_id = $1 AND event_type = $2)`
+	err := r.db.QueryRow(ctx, query, orderID, eventType).Scan(&ok)
+	return ok, err
+}
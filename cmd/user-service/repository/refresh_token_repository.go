@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go_example/cmd/user-service/domain"
+)
+
+// RefreshTokenRepository stores refresh tokens by their hash.
+type RefreshTokenRepository struct {
+	db DBTX
+}
+
+// Create inserts a refresh token.
+func (r *RefreshTokenRepository) Create(ctx context.Context, t *domain.RefreshToken) error {
+	query := `INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := r.db.Exec(ctx, query, t.ID, t.UserID, t.FamilyID, t.TokenHash, t.ExpiresAt, t.CreatedAt)
+	return err
+}
+
+// GetByHashForUpdate returns the token with hash, locked until the transaction ends.
+func (r *RefreshTokenRepository) GetByHashForUpdate(ctx context.Context, hash []byte) (*domain.RefreshToken, error) {
+	query := `SELECT id, user_id, family_id, token_hash, expires_at, created_at, revoked_at, replaced_by
+		FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`
+	var t domain.RefreshToken
+	err := r.db.QueryRow(ctx, query, hash).Scan(&t.ID, &t.UserID, &t.FamilyID, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt, &t.RevokedAt, &t.ReplacedBy)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Revoke marks a token revoked at at, replaced by the token replacedBy when it was rotated.
+func (r *RefreshTokenRepository) Revoke(ctx context.Context, id uuid.UUID, at time.Time, replacedBy *uuid.UUID) error {
+	query := `UPDATE refresh_tokens SET revoked_at = $1, replaced_by = $2 WHERE id = $3 AND revoked_at IS NULL`
+	_, err := r.db.Exec(ctx, query, at, replacedBy, id)
+	return err
+}
+
+// RevokeFamily revokes every token of a session not revoked yet.
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID, at time.Time) (int64, error) {
+	query := `UPDATE refresh_tokens SET revoked_at = $1 WHERE family_id = $2 AND revoked_at IS NULL`
+	tag, err := r.db.Exec(ctx, query, at, familyID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DeleteExpiredByUser drops the tokens of a user that expired before t.
+func (r *RefreshTokenRepository) DeleteExpiredByUser(ctx context.Context, userID uuid.UUID, t time.Time) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM refresh_tokens WHERE user_id = $1 AND expires_at < $2`, userID, t)
+	return err
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"go_example/cmd/user-service/domain"
+)
+
+// signingKeyLockID serializes instances creating the first signing key.
+const signingKeyLockID int64 = 0x7369676e6b6579 // "signkey"
+
+// SigningKeyRepository stores the keys that sign access tokens.
+type SigningKeyRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewSigningKeyRepository creates a new SigningKeyRepository.
+func NewSigningKeyRepository(pool *pgxpool.Pool) *SigningKeyRepository {
+	return &SigningKeyRepository{pool: pool}
+}
+
+// FirstOrCreate returns the oldest key, storing the one made by create if there is none.
+// Instances starting together are serialized, so they all get the same key.
+func (r *SigningKeyRepository) FirstOrCreate(ctx context.Context, create func() (*domain.SigningKey, error)) (*domain.SigningKey, error) {
+	var k domain.SigningKey
+	err := pgx.BeginFunc(ctx, r.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, signingKeyLockID); err != nil {
+			return err
+		}
+		query := `SELECT kid, private_key, created_at FROM signing_keys ORDER BY created_at, kid LIMIT 1`
+		err := tx.QueryRow(ctx, query).Scan(&k.KID, &k.PrivateKey, &k.CreatedAt)
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		created, err := create()
+		if err != nil {
+			return err
+		}
+		k = *created
+		query = `INSERT INTO signing_keys (kid, private_key, created_at) VALUES ($1, $2, $3)`
+		_, err = tx.Exec(ctx, query, k.KID, k.PrivateKey, k.CreatedAt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
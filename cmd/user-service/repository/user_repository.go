@@ -11,35 +11,68 @@ import (
 
 // UserRepository handles user persistence.
 type UserRepository struct {
-	pool *pgxpool.Pool
+	db DBTX
 }
 
 // NewUserRepository creates a new UserRepository.
 func NewUserRepository(pool *pgxpool.Pool) *UserRepository {
-	return &UserRepository{pool: pool}
+	return &UserRepository{db: pool}
 }
 
 // Create inserts a new user.
 func (r *UserRepository) Create(ctx context.Context, u *domain.User) error {
-	query := `INSERT INTO users (id, username, balance, created_at) VALUES ($1, $2, $3, $4)`
-	_, err := r.pool.Exec(ctx, query, u.ID, u.Username, u.Balance, u.CreatedAt)
+	query := `INSERT INTO users (id, username, password_hash, balance, created_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err := r.db.Exec(ctx, query, u.ID, u.Username, u.PasswordHash, u.Balance, u.CreatedAt)
 	return err
 }
 
 // GetByID returns a user by ID.
 func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.User, error) {
-	query := `SELECT id, username, balance, created_at FROM users WHERE id = $1`
+	query := `SELECT id, username, password_hash, balance, is_admin, created_at FROM users WHERE id = $1`
 	var u domain.User
-	err := r.pool.QueryRow(ctx, query, id).Scan(&u.ID, &u.Username, &u.Balance, &u.CreatedAt)
+	err := r.db.QueryRow(ctx, query, id).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Balance, &u.IsAdmin, &u.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
 
-// UpdateBalance updates user balance (reserve/release credit).
-func (r *UserRepository) UpdateBalance(ctx context.Context, id uuid.UUID, balance int64) error {
-	query := `UPDATE users SET balance = $1 WHERE id = $2`
-	_, err := r.pool.Exec(ctx, query, balance, id)
+// GetByUsername returns a user by username.
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*domain.User, error) {
+	query := `SELECT id, username, password_hash, balance, is_admin, created_at FROM users WHERE username = $1`
+	var u domain.User
+	err := r.db.QueryRow(ctx, query, username).Scan(&u.ID, &u.Username, &u.PasswordHash, &u.Balance, &u.IsAdmin, &u.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// SetPasswordHash replaces the password hash of a user, e.g. when it is upgraded to current
+// hashing parameters.
+func (r *UserRepository) SetPasswordHash(ctx context.Context, id uuid.UUID, hash string) error {
+	_, err := r.db.Exec(ctx, `UPDATE users SET password_hash = $1 WHERE id = $2`, hash, id)
 	return err
 }
+
+// DebitBalance atomically deducts amount if the balance covers it (reserve credit).
+// Returns false if the user does not exist or the balance is insufficient.
+func (r *UserRepository) DebitBalance(ctx context.Context, id uuid.UUID, amount int64) (bool, error) {
+	query := `UPDATE users SET balance = balance - $1 WHERE id = $2 AND balance >= $1`
+	tag, err := r.db.Exec(ctx, query, amount, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// CreditBalance atomically adds amount to the balance (release credit).
+// Returns false if the user does not exist.
+func (r *UserRepository) CreditBalance(ctx context.Context, id uuid.UUID, amount int64) (bool, error) {
+	query := `UPDATE users SET balance = balance + $1 WHERE id = $2`
+	tag, err := r.db.Exec(ctx, query, amount, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"go_example/internal/jwt"
+	"go_example/internal/logging"
+	"go_example/cmd/user-service/config"
+	"go_example/cmd/user-service/domain"
+	"go_example/cmd/user-service/dto"
+	"go_example/cmd/user-service/password"
+	"go_example/cmd/user-service/repository"
+)
+
+var (
+	ErrInvalidCredentials  = errors.New("invalid username or password")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+)
+
+// AuthService logs users in with their password and issues RS256 access tokens and rotating
+// refresh tokens. A refresh token is single-use: refreshing revokes it and issues the next one
+// of its session. Presenting a revoked token that was already rotated means it leaked, so the
+// whole session is revoked.
+type AuthService struct {
+	users     *repository.UserRepository
+	tx        *repository.TxManager
+	cfg       config.AuthConfig
+	key       jwt.Key
+	jwks      jwt.JWKS
+	dummyHash string
+}
+
+// NewAuthService creates an AuthService signing with the key of cfg.PrivateKeyFile or, without
+// one, the key kept in the database, generated on first use.
+func NewAuthService(ctx context.Context, users *repository.UserRepository, keys *repository.SigningKeyRepository, tx *repository.TxManager, cfg config.AuthConfig) (*AuthService, error) {
+	priv, err := loadSigningKey(ctx, keys, cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("signing key: %w", err)
+	}
+	jwk := jwt.PublicJWK("", &priv.PublicKey)
+	jwk.Kid = jwk.Thumbprint()
+	// Unknown usernames are checked against a hash too, so that response times do not reveal
+	// which usernames exist.
+	dummy, err := password.Hash("not a password", cfg.Password)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthService{
+		users:     users,
+		tx:        tx,
+		cfg:       cfg,
+		key:       jwt.Key{ID: jwk.Kid, Private: priv},
+		jwks:      jwt.JWKS{Keys: []jwt.JWK{jwk}},
+		dummyHash: dummy,
+	}, nil
+}
+
+// JWKS returns the public keys access tokens are verified with.
+func (s *AuthService) JWKS() jwt.JWKS {
+	return s.jwks
+}
+
+// Login checks the password of a user and starts a session. A hash made with other parameters
+// than the configured ones is replaced.
+func (s *AuthService) Login(ctx context.Context, req dto.LoginRequest) (*dto.TokenResponse, error) {
+	u, err := s.users.GetByUsername(ctx, req.Username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		password.Verify(s.dummyHash, req.Password, s.cfg.Password)
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	rehash, err := password.Verify(u.PasswordHash, req.Password, s.cfg.Password)
+	if errors.Is(err, password.ErrMismatch) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rehash {
+		if hash, err := password.Hash(req.Password, s.cfg.Password); err == nil {
+			if err := s.users.SetPasswordHash(ctx, u.ID, hash); err != nil {
+				slog.ErrorContext(ctx, "rehash password failed", logging.UserID(u.ID), logging.Err(err))
+			}
+		}
+	}
+	now := time.Now().UTC()
+	var resp *issued
+	err = s.tx.Run(ctx, func(tx repository.Tx) error {
+		if err := tx.RefreshTokens.DeleteExpiredByUser(ctx, u.ID, now); err != nil {
+			return err
+		}
+		var err error
+		resp, err = s.issue(ctx, tx, u, uuid.New(), now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.TokenResponse, nil
+}
+
+// Refresh rotates a refresh token: it is revoked and a new access and refresh token issued.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*dto.TokenResponse, error) {
+	now := time.Now().UTC()
+	var resp *issued
+	reused := false
+	err := s.tx.Run(ctx, func(tx repository.Tx) error {
+		t, err := tx.RefreshTokens.GetByHashForUpdate(ctx, hashToken(refreshToken))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+		if t.RevokedAt != nil {
+			if t.ReplacedBy == nil {
+				return ErrInvalidRefreshToken
+			}
+			reused = true
+			_, err := tx.RefreshTokens.RevokeFamily(ctx, t.FamilyID, now)
+			return err
+		}
+		if !now.Before(t.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+		u, err := tx.Users.GetByID(ctx, t.UserID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+		if resp, err = s.issue(ctx, tx, u, t.FamilyID, now); err != nil {
+			return err
+		}
+		next := resp.refreshID
+		return tx.RefreshTokens.Revoke(ctx, t.ID, now, &next)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		slog.WarnContext(ctx, "rotated refresh token reused: session revoked")
+		return nil, ErrInvalidRefreshToken
+	}
+	return resp.TokenResponse, nil
+}
+
+// Logout revokes the session of a refresh token. Unknown tokens are ignored.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	now := time.Now().UTC()
+	return s.tx.Run(ctx, func(tx repository.Tx) error {
+		t, err := tx.RefreshTokens.GetByHashForUpdate(ctx, hashToken(refreshToken))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.RefreshTokens.RevokeFamily(ctx, t.FamilyID, now)
+		return err
+	})
+}
+
+// issued is a token response with the ID of its refresh token.
+type issued struct {
+	*dto.TokenResponse
+	refreshID uuid.UUID
+}
+
+// issue signs an access token for u and records a new refresh token in family.
+func (s *AuthService) issue(ctx context.Context, tx repository.Tx, u *domain.User, family uuid.UUID, now time.Time) (*issued, error) {
+	scope := "user"
+	if u.IsAdmin {
+		scope += " " + s.cfg.AdminScope
+	}
+	claims := jwt.Claims{
+		Issuer:    s.cfg.Issuer,
+		Subject:   u.ID.String(),
+		ExpiresAt: now.Add(s.cfg.AccessTTL).Unix(),
+		IssuedAt:  now.Unix(),
+		ID:        uuid.NewString(),
+		Scope:     scope,
+		Username:  u.Username,
+	}
+	if s.cfg.Audience != "" {
+		claims.Audience = jwt.Audience{s.cfg.Audience}
+	}
+	access, err := jwt.Sign(claims, s.key)
+	if err != nil {
+		return nil, err
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	refresh := base64.RawURLEncoding.EncodeToString(raw)
+	t := &domain.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    u.ID,
+		FamilyID:  family,
+		TokenHash: hashToken(refresh),
+		ExpiresAt: now.Add(s.cfg.RefreshTTL),
+		CreatedAt: now,
+	}
+	if err := tx.RefreshTokens.Create(ctx, t); err != nil {
+		return nil, err
+	}
+	return &issued{
+		TokenResponse: &dto.TokenResponse{
+			AccessToken:      access,
+			TokenType:        "Bearer",
+			ExpiresIn:        int64(s.cfg.AccessTTL.Seconds()),
+			RefreshToken:     refresh,
+			RefreshExpiresIn: int64(s.cfg.RefreshTTL.Seconds()),
+		},
+		refreshID: t.ID,
+	}, nil
+}
+
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// loadSigningKey reads a PEM RSA private key (PKCS #1 or #8) from file or, without one, returns
+// the key in the database, generating it if there is none.
+func loadSigningKey(ctx context.Context, keys *repository.SigningKeyRepository, file string) (*rsa.PrivateKey, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return parsePrivateKey(data)
+	}
+	k, err := keys.FirstOrCreate(ctx, newSigningKey)
+	if err != nil {
+		return nil, err
+	}
+	return parsePrivateKey([]byte(k.PrivateKey))
+}
+
+// newSigningKey generates a 2048-bit RSA key.
+func newSigningKey() (*domain.SigningKey, error) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	jwk := jwt.PublicJWK("", &priv.PublicKey)
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)}
+	return &domain.SigningKey{KID: jwk.Thumbprint(), PrivateKey: string(pem.EncodeToMemory(block)), CreatedAt: time.Now().UTC()}, nil
+}
+
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	if priv, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return priv, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	priv, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return priv, nil
+}
@@ -2,14 +2,17 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 
+	"go_example/internal/events"
 	"go_example/cmd/user-service/domain"
 	"go_example/cmd/user-service/dto"
+	"go_example/cmd/user-service/password"
 	"go_example/cmd/user-service/repository"
 )
 
@@ -17,23 +20,41 @@ var ErrUserNotFound = errors.New("user not found")
 
 // UserService implements user business logic.
 type UserService struct {
-	repo *repository.UserRepository
+	repo      *repository.UserRepository
+	tx        *repository.TxManager
+	passwords password.Params
 }
 
-// NewUserService creates a new UserService.
-func NewUserService(repo *repository.UserRepository) *UserService {
-	return &UserService{repo: repo}
+// NewUserService creates a new UserService hashing passwords with passwords.
+func NewUserService(repo *repository.UserRepository, tx *repository.TxManager, passwords password.Params) *UserService {
+	return &UserService{repo: repo, tx: tx, passwords: passwords}
 }
 
-// CreateUser creates a new user.
+// CreateUser creates a new user with the hash of its password and records user.created in the
+// outbox in the same transaction.
 func (s *UserService) CreateUser(ctx context.Context, req dto.CreateUserRequest) (*dto.UserResponse, error) {
+	hash, err := password.Hash(req.Password, s.passwords)
+	if err != nil {
+		return nil, err
+	}
 	u := &domain.User{
-		ID:        uuid.New(),
-		Username:  req.Username,
-		Balance:   req.InitialBalance,
-		CreatedAt: time.Now(),
+		ID:           uuid.New(),
+		Username:     req.Username,
+		PasswordHash: hash,
+		Balance:      req.InitialBalance,
+		CreatedAt:    time.Now(),
 	}
-	if err := s.repo.Create(ctx, u); err != nil {
+	err = s.tx.Run(ctx, func(tx repository.Tx) error {
+		if err := tx.Users.Create(ctx, u); err != nil {
+			return err
+		}
+		body, err := json.Marshal(events.UserCreatedEvent{UserID: u.ID, Username: u.Username, Balance: u.Balance})
+		if err != nil {
+			return err
+		}
+		return tx.Outbox.Add(ctx, events.TopicUserCreated, u.ID.String(), body)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return toUserResponse(u), nil
@@ -51,25 +72,123 @@ func (s *UserService) GetByID(ctx context.Context, id uuid.UUID) (*dto.UserRespo
 	return toUserResponse(u), nil
 }
 
-// ReserveCredit deducts amount from balance. Returns true if successful.
-func (s *UserService) ReserveCredit(ctx context.Context, userID uuid.UUID, amount int64) (bool, error) {
-	u, err := s.repo.GetByID(ctx, userID)
-	if err != nil {
-		return false, ErrUserNotFound
+// Delivery identifies a consumed message, recorded as processed with the change it causes.
+type Delivery struct {
+	Group     string
+	Topic     string
+	Partition int
+	Offset    int64
+}
+
+// creditReserved is the processed_events type recording that credit was reserved for an
+// order, so that only a reservation that was made is released.
+const creditReserved = events.TopicUserCreditReserved
+
+// ReserveCredit deducts the order amount from the user's balance and records the
+// user.credit-reserved or user.credit-reservation-failed reply in the outbox, in one
+// transaction that also marks d and the order's order.created processed. It reports whether
+// credit was reserved and whether the event was processed now; a redelivered d, or an
+// order.created already processed for the order at another offset, changes nothing and
+// reports processed false. An order already canceled is not charged and gets no reply.
+// The deduction is a single conditional update, so concurrent reservations cannot overdraw.
+func (s *UserService) ReserveCredit(ctx context.Context, d Delivery, evt events.OrderCreatedEvent) (reserved, processed bool, err error) {
+	err = s.tx.Run(ctx, func(tx repository.Tx) error {
+		reserved, processed = false, false
+		fresh, err := markProcessed(ctx, tx, d, evt.OrderID)
+		if err != nil || !fresh {
+			return err
+		}
+		processed = true
+		canceled, err := tx.Processed.IsProcessed(ctx, evt.OrderID, events.TopicOrderCanceled)
+		if err != nil || canceled {
+			return err
+		}
+		if reserved, err = tx.Users.DebitBalance(ctx, evt.UserID, evt.Amount); err != nil {
+			return err
+		}
+		if reserved {
+			if _, err := tx.Processed.MarkProcessed(ctx, evt.OrderID, creditReserved); err != nil {
+				return err
+			}
+			reply := events.UserCreditReservedEvent{OrderID: evt.OrderID, UserID: evt.UserID, Amount: evt.Amount}
+			return addReply(ctx, tx, events.TopicUserCreditReserved, evt.OrderID, reply)
+		}
+		reason := "Insufficient balance"
+		if _, err := tx.Users.GetByID(ctx, evt.UserID); errors.Is(err, pgx.ErrNoRows) {
+			reason = ErrUserNotFound.Error()
+		} else if err != nil {
+			return err
+		}
+		reply := events.UserCreditReservationFailedEvent{OrderID: evt.OrderID, UserID: evt.UserID, Amount: evt.Amount, Reason: reason}
+		return addReply(ctx, tx, events.TopicUserCreditReservationFailed, evt.OrderID, reply)
+	})
+	return reserved, processed, err
+}
+
+// ReleaseCredit restores the amount reserved for the order to the user's balance
+// (compensation) and records user.credit-released in the outbox, in one transaction that also
+// marks d and the order's order.canceled processed. It reports whether credit was released and
+// whether the event was processed now. An order whose reservation failed or has not been
+// processed yet releases nothing; a redelivered or republished order.canceled changes nothing.
+func (s *UserService) ReleaseCredit(ctx context.Context, d Delivery, evt events.OrderCanceledEvent) (released, processed bool, err error) {
+	err = s.tx.Run(ctx, func(tx repository.Tx) error {
+		released, processed = false, false
+		fresh, err := markProcessed(ctx, tx, d, evt.OrderID)
+		if err != nil || !fresh {
+			return err
+		}
+		processed = true
+		reserved, err := tx.Processed.IsProcessed(ctx, evt.OrderID, creditReserved)
+		if err != nil || !reserved {
+			return err
+		}
+		ok, err := tx.Users.CreditBalance(ctx, evt.UserID, evt.Amount)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrUserNotFound
+		}
+		released = true
+		reply := events.UserCreditReleasedEvent{OrderID: evt.OrderID, UserID: evt.UserID, Amount: evt.Amount}
+		return addReply(ctx, tx, events.TopicUserCreditReleased, evt.OrderID, reply)
+	})
+	return released, processed, err
+}
+
+// ForgetDeliveriesBefore drops processed-delivery records older than t. Use a t older than the
+// topic retention, after which a message can no longer be redelivered.
+func (s *UserService) ForgetDeliveriesBefore(ctx context.Context, t time.Time) (int64, error) {
+	var n int64
+	err := s.tx.Run(ctx, func(tx repository.Tx) error {
+		var err error
+		n, err = tx.Offsets.DeleteProcessedBefore(ctx, t)
+		return err
+	})
+	return n, err
+}
+
+// markProcessed records d in the consumer offsets and its topic as processed for orderID, with
+// the order's credit changes locked until the transaction ends. It returns false if either was
+// already recorded.
+func markProcessed(ctx context.Context, tx repository.Tx, d Delivery, orderID uuid.UUID) (bool, error) {
+	fresh, err := tx.Offsets.MarkProcessed(ctx, d.Group, d.Topic, d.Partition, d.Offset)
+	if err != nil || !fresh {
+		return false, err
 	}
-	if u.Balance < amount {
-		return false, nil
+	if err := tx.Processed.LockOrder(ctx, orderID); err != nil {
+		return false, err
 	}
-	return true, s.repo.UpdateBalance(ctx, userID, u.Balance-amount)
+	return tx.Processed.MarkProcessed(ctx, orderID, d.Topic)
 }
 
-// ReleaseCredit restores amount to balance (compensation).
-func (s *UserService) ReleaseCredit(ctx context.Context, userID uuid.UUID, amount int64) error {
-	u, err := s.repo.GetByID(ctx, userID)
+// addReply records a saga reply keyed by order ID in the outbox.
+func addReply(ctx context.Context, tx repository.Tx, topic string, orderID uuid.UUID, evt any) error {
+	body, err := json.Marshal(evt)
 	if err != nil {
-		return ErrUserNotFound
+		return err
 	}
-	return s.repo.UpdateBalance(ctx, userID, u.Balance+amount)
+	return tx.Outbox.Add(ctx, topic, orderID.String(), body)
 }
 
 func toUserResponse(u *domain.User) *dto.UserResponse {
@@ -0,0 +1,202 @@
+// Package bus defines the publish/subscribe interface used by the saga participants,
+// with a Kafka implementation and an in-memory implementation for tests and local runs.
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"go_example/internal/kafkaconn"
+	"go_example/internal/logging"
+	"go_example/internal/metrics"
+	"go_example/internal/requestid"
+	"go_example/internal/tracing"
+)
+
+// Bus kinds accepted by New (BUS environment variable).
+const (
+	KindKafka  = "kafka"
+	KindMemory = "memory"
+)
+
+// Headers set on messages moved to a dead-letter topic.
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderError             = "x-error"
+)
+
+const (
+	// MaxAttempts is how many times a handler is called for a message before it is dead-lettered.
+	MaxAttempts = 3
+	// retryBackoff is multiplied by the attempt number between handler retries.
+	retryBackoff = 200 * time.Millisecond
+)
+
+// Message is a record published to or consumed from a topic.
+// Partition, Offset and Time are set on consumed messages only.
+type Message struct {
+	Topic     string
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+	Partition int
+	Offset    int64
+	Time      time.Time
+}
+
+// Handler processes a consumed message. A non-nil error causes redelivery of the same
+// message (up to MaxAttempts) before it is moved to the dead-letter topic.
+type Handler func(ctx context.Context, msg Message) error
+
+// Publisher publishes messages. Messages with the same key keep their order.
+type Publisher interface {
+	Publish(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// Subscriber consumes a topic as a member of a consumer group.
+type Subscriber interface {
+	// Subscribe calls h for each message of topic assigned to this member of group, processing
+	// as configured by opts. It blocks until ctx is canceled and in-flight handlers have
+	// finished. Offsets are committed once h has settled a message and every earlier message
+	// of its partition.
+	Subscribe(ctx context.Context, topic, group string, h Handler, opts Options) error
+}
+
+// Bus publishes and subscribes.
+type Bus interface {
+	Publisher
+	Subscriber
+}
+
+// New returns a Bus of the given kind; cfg is used by the Kafka kind only.
+func New(kind string, cfg kafkaconn.Config) (Bus, error) {
+	switch kind {
+	case KindKafka, "":
+		conn, err := kafkaconn.New(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewKafka(conn), nil
+	case KindMemory:
+		return NewMemory(DefaultPartitions), nil
+	default:
+		return nil, fmt.Errorf("bus: unknown kind %q", kind)
+	}
+}
+
+// DeadLetterTopic returns the dead-letter topic for topic.
+func DeadLetterTopic(topic string) string {
+	return topic + ".dlq"
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not retryable: the message is dead-lettered without redelivery.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+type skipError struct{ err error }
+
+func (e skipError) Error() string { return e.err.Error() }
+func (e skipError) Unwrap() error { return e.err }
+
+// Skip marks err as expected and not worth retrying: the message is committed without
+// redelivery or dead-lettering, and the error is only logged and counted.
+func Skip(err error) error {
+	return skipError{err: err}
+}
+
+// handlerContext returns ctx carrying the request ID of msg, or a new one when msg has none,
+// so that the handler's logs and messages continue the producer's, and the topic, partition
+// and offset of msg for its logs.
+func handlerContext(ctx context.Context, msg Message) context.Context {
+	id := msg.Headers[requestid.MessageHeader]
+	if !requestid.Valid(id) {
+		id = requestid.New()
+	}
+	return logging.With(requestid.NewContext(ctx, id), messageAttrs(msg)...)
+}
+
+// messageAttrs returns the log attributes locating msg.
+func messageAttrs(msg Message) []slog.Attr {
+	return []slog.Attr{
+		slog.String(logging.KeyTopic, msg.Topic),
+		slog.Int(logging.KeyPartition, msg.Partition),
+		slog.Int64(logging.KeyOffset, msg.Offset),
+	}
+}
+
+// deliver calls h for msg, retrying on error and dead-lettering once attempts are exhausted
+// (unless opts.RetryForever). It reports whether msg is settled and its offset may be committed.
+// The attempts share one consumer span, which fails when msg is dead-lettered.
+func deliver(ctx context.Context, pub Publisher, h Handler, group string, opts Options, msg Message) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	ctx, span := startProcess(handlerContext(ctx, msg), group, msg)
+	defer span.End()
+	var err error
+	for attempt := 1; attempt <= MaxAttempts; attempt++ {
+		start := time.Now()
+		err = h(ctx, msg)
+		metrics.ObserveHandler(msg.Topic, group, time.Since(start))
+		if err == nil {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		if errors.As(err, new(skipError)) || (opts.RetryForever && errors.As(err, new(permanentError))) {
+			metrics.ObserveHandlerError(msg.Topic, group, metrics.OutcomeSkipped)
+			skipEvent(span, err)
+			slog.InfoContext(ctx, "message skipped", "group", group, logging.Err(err))
+			return true
+		}
+		if errors.As(err, new(permanentError)) {
+			break
+		}
+		slog.WarnContext(ctx, "handler failed", "group", group, "attempt", attempt, "max_attempts", MaxAttempts, logging.Err(err))
+		retryEvent(span, attempt, err)
+		if attempt < MaxAttempts || opts.RetryForever {
+			metrics.ObserveHandlerError(msg.Topic, group, metrics.OutcomeRetry)
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(time.Duration(attempt) * retryBackoff):
+			}
+		}
+	}
+	if opts.RetryForever {
+		return false
+	}
+	tracing.Fail(span, err)
+	headers := make(map[string]string, len(msg.Headers)+4)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	// The dead letter is published in the trace of this span, not the original producer's.
+	dropTraceContext(headers)
+	headers[HeaderOriginalTopic] = msg.Topic
+	headers[HeaderOriginalPartition] = strconv.Itoa(msg.Partition)
+	headers[HeaderOriginalOffset] = strconv.FormatInt(msg.Offset, 10)
+	headers[HeaderError] = err.Error()
+	dead := Message{Topic: DeadLetterTopic(msg.Topic), Key: msg.Key, Value: msg.Value, Headers: headers}
+	if perr := pub.Publish(ctx, dead); perr != nil {
+		slog.ErrorContext(ctx, "dead-letter publish failed", "group", group, logging.Err(perr))
+		return false
+	}
+	metrics.ObserveHandlerError(msg.Topic, group, metrics.OutcomeDLQ)
+	slog.ErrorContext(ctx, "message dead-lettered", "group", group, "dead_letter_topic", dead.Topic, logging.Err(err))
+	return true
+}
@@ -0,0 +1,117 @@
+package bus
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"go_example/internal/kafkaconn"
+	"go_example/internal/metrics"
+)
+
+// Kafka is a Bus backed by segmentio/kafka-go.
+type Kafka struct {
+	conn   *kafkaconn.Conn
+	writer *kafka.Writer
+}
+
+// NewKafka creates a new Kafka bus on conn.
+func NewKafka(conn *kafkaconn.Conn) *Kafka {
+	return &Kafka{
+		conn: conn,
+		writer: &kafka.Writer{
+			Addr:      kafka.TCP(conn.Brokers...),
+			Balancer:  &kafka.Hash{},
+			Transport: conn.Transport,
+		},
+	}
+}
+
+// Close closes the Kafka writer.
+func (k *Kafka) Close() error {
+	return k.writer.Close()
+}
+
+// Publish writes msgs; messages with the same key go to the same partition.
+func (k *Kafka) Publish(ctx context.Context, msgs ...Message) error {
+	msgs, end := startPublish(ctx, msgs)
+	out := make([]kafka.Message, len(msgs))
+	for i, m := range msgs {
+		out[i] = kafka.Message{Topic: m.Topic, Key: m.Key, Value: m.Value}
+		for key, value := range m.Headers {
+			out[i].Headers = append(out[i].Headers, kafka.Header{Key: key, Value: []byte(value)})
+		}
+	}
+	start := time.Now()
+	err := k.writer.WriteMessages(ctx, out...)
+	observeWrite(msgs, time.Since(start), err)
+	end(err)
+	return err
+}
+
+// observeWrite records one write per distinct topic in msgs.
+func observeWrite(msgs []Message, d time.Duration, err error) {
+	seen := make(map[string]bool, 1)
+	for _, m := range msgs {
+		if !seen[m.Topic] {
+			seen[m.Topic] = true
+			metrics.ObserveProducerWrite(m.Topic, d, err)
+		}
+	}
+}
+
+// Subscribe reads topic as a member of the consumer group.
+func (k *Kafka) Subscribe(ctx context.Context, topic, group string, h Handler, opts Options) error {
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  k.conn.Brokers,
+		Dialer:   k.conn.Dialer,
+		Topic:    topic,
+		GroupID:  group,
+		MinBytes: 1,
+		MaxBytes: 10e6,
+	})
+	defer r.Close()
+	consume(ctx, &kafkaSource{r: r}, k, topic, group, h, opts)
+	return nil
+}
+
+// kafkaSource fetches from and commits to a consumer group reader.
+type kafkaSource struct {
+	r *kafka.Reader
+}
+
+func (s *kafkaSource) fetch(ctx context.Context) (Message, error) {
+	msg, err := s.r.FetchMessage(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+	return fromKafka(msg), nil
+}
+
+func (s *kafkaSource) commit(ctx context.Context, msg Message) error {
+	return s.r.CommitMessages(ctx, kafka.Message{Topic: msg.Topic, Partition: msg.Partition, Offset: msg.Offset})
+}
+
+// lag reports the reader's lag as of its last fetch.
+func (s *kafkaSource) lag() int64 {
+	return s.r.Stats().Lag
+}
+
+func fromKafka(msg kafka.Message) Message {
+	m := Message{
+		Topic:     msg.Topic,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Time:      msg.Time,
+	}
+	if len(msg.Headers) > 0 {
+		m.Headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			m.Headers[h.Key] = string(h.Value)
+		}
+	}
+	return m
+}
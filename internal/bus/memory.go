@@ -0,0 +1,231 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// DefaultPartitions is the number of partitions per topic on a Memory bus.
+const DefaultPartitions = 3
+
+// ErrClosed is returned when publishing to a closed Memory bus.
+var ErrClosed = errors.New("bus: closed")
+
+// Memory is an in-process Bus. Topics are split into partitions by key hash; each consumer
+// group tracks its own offsets and spreads partitions over its members, so messages with the
+// same key are handled in order by one member. Handler errors cause redelivery.
+type Memory struct {
+	partitions int
+
+	mu      sync.Mutex
+	topics  map[string]*memTopic
+	next    int
+	members int
+	closed  bool
+	changed chan struct{}
+}
+
+type memTopic struct {
+	log    [][]Message
+	groups map[string]*memGroup
+}
+
+type memGroup struct {
+	committed []int64
+	fetched   []int64
+	members   []int
+}
+
+// NewMemory creates a new Memory bus with the given number of partitions per topic.
+func NewMemory(partitions int) *Memory {
+	if partitions < 1 {
+		partitions = 1
+	}
+	return &Memory{
+		partitions: partitions,
+		topics:     map[string]*memTopic{},
+		changed:    make(chan struct{}),
+	}
+}
+
+// Close stops accepting messages and wakes all subscribers.
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.closed {
+		m.closed = true
+		m.notifyLocked()
+	}
+	return nil
+}
+
+// Publish appends msgs to their topic partitions.
+func (m *Memory) Publish(ctx context.Context, msgs ...Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	msgs, end := startPublish(ctx, msgs)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		end(ErrClosed)
+		return ErrClosed
+	}
+	defer end(nil)
+	for _, msg := range msgs {
+		t := m.topicLocked(msg.Topic)
+		p := m.partitionLocked(msg.Key)
+		msg.Partition = p
+		msg.Offset = int64(len(t.log[p]))
+		msg.Time = time.Now()
+		t.log[p] = append(t.log[p], msg)
+	}
+	m.notifyLocked()
+	return nil
+}
+
+// Subscribe joins group on topic and processes its assigned partitions until ctx is canceled.
+// Group offsets start at the beginning of the topic. When members join or leave, partitions
+// are reassigned and resume from the committed offset, so uncommitted messages are redelivered.
+func (m *Memory) Subscribe(ctx context.Context, topic, group string, h Handler, opts Options) error {
+	m.mu.Lock()
+	m.members++
+	src := &memSource{m: m, topic: topic, group: m.groupLocked(topic, group), member: m.members}
+	src.group.join(src.member)
+	m.notifyLocked()
+	m.mu.Unlock()
+
+	defer func() {
+		m.mu.Lock()
+		src.group.leave(src.member)
+		m.notifyLocked()
+		m.mu.Unlock()
+	}()
+
+	consume(ctx, src, m, topic, group, h, opts)
+	return nil
+}
+
+// memSource is one group member's view of a Memory topic.
+type memSource struct {
+	m      *Memory
+	topic  string
+	group  *memGroup
+	member int
+}
+
+func (s *memSource) fetch(ctx context.Context) (Message, error) {
+	m := s.m
+	for {
+		m.mu.Lock()
+		if m.closed {
+			m.mu.Unlock()
+			return Message{}, errSourceClosed
+		}
+		t, g := m.topics[s.topic], s.group
+		for p := 0; p < m.partitions; p++ {
+			if !g.owns(s.member, p) {
+				continue
+			}
+			if off := g.fetched[p]; off < int64(len(t.log[p])) {
+				g.fetched[p]++
+				msg := t.log[p][off]
+				m.mu.Unlock()
+				return msg, nil
+			}
+		}
+		wait := m.changed
+		m.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+func (s *memSource) commit(_ context.Context, msg Message) error {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	g := s.group
+	if g.owns(s.member, msg.Partition) && msg.Offset+1 > g.committed[msg.Partition] {
+		g.committed[msg.Partition] = msg.Offset + 1
+	}
+	return nil
+}
+
+// lag sums the uncommitted messages of the partitions this member owns.
+func (s *memSource) lag() int64 {
+	s.m.mu.Lock()
+	defer s.m.mu.Unlock()
+	t, g := s.m.topics[s.topic], s.group
+	var n int64
+	for p := 0; p < s.m.partitions; p++ {
+		if g.owns(s.member, p) {
+			n += int64(len(t.log[p])) - g.committed[p]
+		}
+	}
+	return n
+}
+
+func (g *memGroup) owns(member, partition int) bool {
+	return len(g.members) > 0 && g.members[partition%len(g.members)] == member
+}
+
+func (g *memGroup) join(member int) {
+	g.members = append(g.members, member)
+	g.rebalance()
+}
+
+func (g *memGroup) leave(member int) {
+	for i, mem := range g.members {
+		if mem == member {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			break
+		}
+	}
+	g.rebalance()
+}
+
+// rebalance rewinds every partition to its committed offset after a membership change.
+func (g *memGroup) rebalance() {
+	copy(g.fetched, g.committed)
+}
+
+func (m *Memory) topicLocked(name string) *memTopic {
+	t, ok := m.topics[name]
+	if !ok {
+		t = &memTopic{log: make([][]Message, m.partitions), groups: map[string]*memGroup{}}
+		m.topics[name] = t
+	}
+	return t
+}
+
+func (m *Memory) groupLocked(topic, group string) *memGroup {
+	t := m.topicLocked(topic)
+	g, ok := t.groups[group]
+	if !ok {
+		g = &memGroup{committed: make([]int64, m.partitions), fetched: make([]int64, m.partitions)}
+		t.groups[group] = g
+	}
+	return g
+}
+
+func (m *Memory) partitionLocked(key []byte) int {
+	if len(key) == 0 {
+		m.next++
+		return m.next % m.partitions
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(m.partitions))
+}
+
+// notifyLocked wakes subscribers waiting for new messages or membership changes.
+func (m *Memory) notifyLocked() {
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
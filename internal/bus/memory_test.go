@@ -0,0 +1,87 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// joinGroup adds a member to group on topic, as Subscribe does, and returns its source.
+func joinGroup(m *Memory, topic, group string) *memSource {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.members++
+	src := &memSource{m: m, topic: topic, group: m.groupLocked(topic, group), member: m.members}
+	src.group.join(src.member)
+	m.notifyLocked()
+	return src
+}
+
+func leaveGroup(src *memSource) {
+	src.m.mu.Lock()
+	defer src.m.mu.Unlock()
+	src.group.leave(src.member)
+	src.m.notifyLocked()
+}
+
+func fetchOffset(t *testing.T, src *memSource) int64 {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, err := src.fetch(ctx)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	return msg.Offset
+}
+
+func TestMemoryRebalanceRewindsToCommitted(t *testing.T) {
+	m := NewMemory(1)
+	ctx := context.Background()
+	for range 3 {
+		if err := m.Publish(ctx, Message{Topic: "t", Key: []byte("k")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := joinGroup(m, "t", "g")
+	for want := range int64(3) {
+		if got := fetchOffset(t, a); got != want {
+			t.Fatalf("fetched %d, want %d", got, want)
+		}
+	}
+	a.commit(ctx, Message{Partition: 0, Offset: 0})
+
+	// A member joining rewinds the uncommitted messages; a keeps the only partition.
+	b := joinGroup(m, "t", "g")
+	if got := fetchOffset(t, a); got != 1 {
+		t.Errorf("after join: a fetched %d, want 1", got)
+	}
+
+	// a leaving revokes the partition; b resumes from the committed offset.
+	leaveGroup(a)
+	if got := fetchOffset(t, b); got != 1 {
+		t.Errorf("after leave: b fetched %d, want 1", got)
+	}
+	// A commit from a member that no longer owns the partition is ignored.
+	a.commit(ctx, Message{Partition: 0, Offset: 2})
+	if got := m.topics["t"].groups["g"].committed[0]; got != 1 {
+		t.Errorf("committed %d after revoked commit, want 1", got)
+	}
+}
+
+func TestMemoryGroupsKeepOwnOffsets(t *testing.T) {
+	m := NewMemory(1)
+	ctx := context.Background()
+	for range 2 {
+		m.Publish(ctx, Message{Topic: "t"})
+	}
+	a, b := joinGroup(m, "t", "g1"), joinGroup(m, "t", "g2")
+	fetchOffset(t, a)
+	a.commit(ctx, Message{Offset: 0})
+	if got := fetchOffset(t, b); got != 0 {
+		t.Errorf("g2 fetched %d, want 0", got)
+	}
+	if got := a.lag(); got != 1 {
+		t.Errorf("g1 lag %d, want 1", got)
+	}
+}
@@ -0,0 +1,222 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go_example/internal/logging"
+	"go_example/internal/metrics"
+)
+
+const (
+	// DefaultMaxInFlight bounds fetched but uncommitted messages when Options.MaxInFlight is unset.
+	DefaultMaxInFlight = 100
+	// lagInterval is how often consumer lag is sampled for metrics.
+	lagInterval = 5 * time.Second
+)
+
+// errSourceClosed is returned by a source whose underlying bus was closed.
+var errSourceClosed = errors.New("bus: source closed")
+
+// Options configures how a subscription processes messages.
+type Options struct {
+	// Concurrency is the number of workers. Messages with the same key always go to the
+	// same worker, so they are handled in order; different keys run in parallel. Default 1.
+	Concurrency int
+	// MaxInFlight bounds messages fetched but not yet handled; fetching blocks when it is
+	// reached (backpressure). Default DefaultMaxInFlight.
+	MaxInFlight int
+	// RetryForever redelivers a failing message until it succeeds instead of dead-lettering
+	// it, blocking its key meanwhile; Permanent errors are skipped. Use it for projections
+	// that must not write to a dead-letter topic owned by the topic's main consumer.
+	RetryForever bool
+}
+
+func (o Options) withDefaults() Options {
+	if o.Concurrency < 1 {
+		o.Concurrency = 1
+	}
+	if o.MaxInFlight < 1 {
+		o.MaxInFlight = DefaultMaxInFlight
+	}
+	if o.MaxInFlight < o.Concurrency {
+		o.MaxInFlight = o.Concurrency
+	}
+	return o
+}
+
+// source is the fetch/commit side of a subscription.
+type source interface {
+	// fetch returns the next message, blocking until one is available.
+	fetch(ctx context.Context) (Message, error)
+	// commit marks msg and every earlier message of its partition as processed.
+	commit(ctx context.Context, msg Message) error
+}
+
+// lagSource is a source that can report how far behind the end of its partitions it is.
+type lagSource interface {
+	lag() int64
+}
+
+// consume fetches from src and processes messages with a key-ordered worker pool until ctx
+// is canceled, then drains in-flight handlers and flushes commits before returning. Offsets are committed per partition only up to the highest offset below which
+// every message has been settled, so out-of-order completion never skips a message.
+func consume(ctx context.Context, src source, pub Publisher, topic, group string, h Handler, opts Options) {
+	opts = opts.withDefaults()
+	p := &processor{
+		src:        src,
+		slots:      make(chan struct{}, opts.MaxInFlight),
+		commits:    make(chan Message, opts.MaxInFlight),
+		partitions: map[int]*partitionTracker{},
+	}
+
+	var committer sync.WaitGroup
+	committer.Add(1)
+	go func() {
+		defer committer.Done()
+		p.runCommitter(context.WithoutCancel(ctx))
+	}()
+
+	if ls, ok := src.(lagSource); ok {
+		go reportLag(ctx, ls, topic, group)
+	}
+
+	// Handlers already running when ctx is canceled finish with an uncanceled context, so
+	// in-flight work drains; messages still queued are skipped, stay uncommitted and are
+	// redelivered to whichever member owns the partition next.
+	hctx := context.WithoutCancel(ctx)
+	var workers sync.WaitGroup
+	queues := make([]chan Message, opts.Concurrency)
+	for i := range queues {
+		queues[i] = make(chan Message, opts.MaxInFlight)
+		workers.Add(1)
+		go func(q <-chan Message) {
+			defer workers.Done()
+			for msg := range q {
+				settled := false
+				for !settled && ctx.Err() == nil {
+					settled = deliver(hctx, pub, h, group, opts, msg)
+				}
+				if settled {
+					p.done(msg)
+				}
+				<-p.slots
+			}
+		}(queues[i])
+	}
+
+	for {
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		msg, err := src.fetch(ctx)
+		if err != nil {
+			<-p.slots
+			if ctx.Err() != nil || errors.Is(err, errSourceClosed) {
+				break
+			}
+			slog.Error("fetch failed", "group", group, logging.Err(err))
+			continue
+		}
+		metrics.ObserveConsumed(msg.Topic, group)
+		p.track(msg)
+		queues[workerFor(msg.Key, len(queues))] <- msg
+	}
+
+	for _, q := range queues {
+		close(q)
+	}
+	workers.Wait()
+	close(p.commits)
+	committer.Wait()
+}
+
+// reportLag samples the lag of src until ctx is canceled.
+func reportLag(ctx context.Context, src lagSource, topic, group string) {
+	tick := time.NewTicker(lagInterval)
+	defer tick.Stop()
+	for {
+		metrics.SetConsumerLag(topic, group, src.lag())
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// workerFor returns the worker index for key.
+func workerFor(key []byte, n int) int {
+	if n == 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(n))
+}
+
+type processor struct {
+	src     source
+	slots   chan struct{}
+	commits chan Message
+
+	mu         sync.Mutex
+	partitions map[int]*partitionTracker
+}
+
+// partitionTracker holds fetched offsets of one partition in order, and which are settled.
+type partitionTracker struct {
+	pending []Message
+	done    map[int64]bool
+}
+
+// track registers a fetched message before it is dispatched.
+func (p *processor) track(msg Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t, ok := p.partitions[msg.Partition]
+	if !ok || (len(t.pending) > 0 && msg.Offset <= t.pending[len(t.pending)-1].Offset) {
+		// New partition, or the source rewound it after a rebalance: start over.
+		t = &partitionTracker{done: map[int64]bool{}}
+		p.partitions[msg.Partition] = t
+	}
+	t.pending = append(t.pending, msg)
+}
+
+// done marks msg settled and queues a commit for the contiguous settled prefix of its partition.
+func (p *processor) done(msg Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t, ok := p.partitions[msg.Partition]
+	if !ok {
+		return
+	}
+	t.done[msg.Offset] = true
+	var last *Message
+	for len(t.pending) > 0 && t.done[t.pending[0].Offset] {
+		head := t.pending[0]
+		delete(t.done, head.Offset)
+		t.pending = t.pending[1:]
+		last = &head
+	}
+	if last != nil {
+		p.commits <- *last
+	}
+}
+
+// runCommitter commits queued offsets in the order they were settled.
+func (p *processor) runCommitter(ctx context.Context) {
+	for msg := range p.commits {
+		if err := p.src.commit(ctx, msg); err != nil {
+			slog.LogAttrs(ctx, slog.LevelError, "commit failed", append(messageAttrs(msg), logging.Err(err))...)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// done is one settled message of a processor test: partition and offset.
+type done struct {
+	partition int
+	offset    int64
+}
+
+func TestProcessorCommitsContiguousPrefix(t *testing.T) {
+	tests := []struct {
+		name    string
+		fetched map[int]int // partition → offsets 0..n-1 fetched
+		done    []done
+		want    []done // commits, in order
+	}{
+		{
+			name:    "in order",
+			fetched: map[int]int{0: 3},
+			done:    []done{{0, 0}, {0, 1}, {0, 2}},
+			want:    []done{{0, 0}, {0, 1}, {0, 2}},
+		},
+		{
+			name:    "reverse order commits once at the end",
+			fetched: map[int]int{0: 3},
+			done:    []done{{0, 2}, {0, 1}, {0, 0}},
+			want:    []done{{0, 2}},
+		},
+		{
+			name:    "gap holds back later offsets",
+			fetched: map[int]int{0: 4},
+			done:    []done{{0, 0}, {0, 2}, {0, 3}},
+			want:    []done{{0, 0}},
+		},
+		{
+			name:    "gap filled commits through",
+			fetched: map[int]int{0: 4},
+			done:    []done{{0, 0}, {0, 2}, {0, 3}, {0, 1}},
+			want:    []done{{0, 0}, {0, 3}},
+		},
+		{
+			name:    "partitions are independent",
+			fetched: map[int]int{0: 2, 1: 2},
+			done:    []done{{1, 1}, {0, 0}, {1, 0}},
+			want:    []done{{0, 0}, {1, 1}},
+		},
+		{
+			name:    "first offset pending commits nothing",
+			fetched: map[int]int{0: 3},
+			done:    []done{{0, 1}, {0, 2}},
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &processor{commits: make(chan Message, 16), partitions: map[int]*partitionTracker{}}
+			for _, part := range slices.Sorted(maps.Keys(tt.fetched)) {
+				for off := range tt.fetched[part] {
+					p.track(Message{Partition: part, Offset: int64(off)})
+				}
+			}
+			for _, d := range tt.done {
+				p.done(Message{Partition: d.partition, Offset: d.offset})
+			}
+			close(p.commits)
+			var got []done
+			for msg := range p.commits {
+				got = append(got, done{msg.Partition, msg.Offset})
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("commits %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessorRewind(t *testing.T) {
+	p := &processor{commits: make(chan Message, 16), partitions: map[int]*partitionTracker{}}
+	for off := range 3 {
+		p.track(Message{Offset: int64(off)})
+	}
+	p.done(Message{Offset: 0})
+	// The partition is revoked and reassigned: the source fetches from the committed offset.
+	p.track(Message{Offset: 1})
+	p.track(Message{Offset: 2})
+	p.done(Message{Offset: 1})
+	p.done(Message{Offset: 2})
+	close(p.commits)
+	var got []int64
+	for msg := range p.commits {
+		got = append(got, msg.Offset)
+	}
+	if want := []int64{0, 1, 2}; !slices.Equal(got, want) {
+		t.Errorf("commits %v, want %v", got, want)
+	}
+}
+
+func TestConsumeKeyOrder(t *testing.T) {
+	const keys, perKey = 8, 25
+	m := NewMemory(3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := range perKey {
+		for k := range keys {
+			msg := Message{Topic: "t", Key: []byte("key-" + strconv.Itoa(k)), Value: []byte(strconv.Itoa(i))}
+			if err := m.Publish(ctx, msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		inFlight = map[string]bool{}
+		last     = map[string]int{}
+		errs     []string
+		handled  atomic.Int32
+	)
+	h := func(_ context.Context, msg Message) error {
+		key := string(msg.Key)
+		seq, _ := strconv.Atoi(string(msg.Value))
+		mu.Lock()
+		if inFlight[key] {
+			errs = append(errs, key+" handled concurrently")
+		}
+		if prev, ok := last[key]; ok && seq != prev+1 {
+			errs = append(errs, fmt.Sprintf("%s: %d after %d", key, seq, prev))
+		}
+		inFlight[key], last[key] = true, seq
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		inFlight[key] = false
+		mu.Unlock()
+		if handled.Add(1) == keys*perKey {
+			cancel()
+		}
+		return nil
+	}
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		m.Subscribe(ctx, "t", "g", h, Options{Concurrency: 4, MaxInFlight: 16})
+	}()
+	select {
+	case <-finished:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("handled %d of %d messages", handled.Load(), keys*perKey)
+	}
+
+	if len(errs) > 0 {
+		t.Errorf("%d violations, first: %s", len(errs), errs[0])
+	}
+	if got := handled.Load(); got != keys*perKey {
+		t.Errorf("handled %d, want %d", got, keys*perKey)
+	}
+	g := m.topics["t"].groups["g"]
+	var committed int64
+	for _, off := range g.committed {
+		committed += off
+	}
+	if committed != keys*perKey {
+		t.Errorf("committed %d messages, want %d", committed, keys*perKey)
+	}
+}
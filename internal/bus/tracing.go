@@ -0,0 +1,87 @@
+package bus
+
+import (
+	"context"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"go_example/internal/requestid"
+	"go_example/internal/tracing"
+)
+
+// startPublish returns msgs ready to be written, each with a producer span whose trace context,
+// and the request ID of ctx, are added to a copy of its headers. A message already carrying trace
+// context or a request ID, such as an outbox event, keeps them and continues that trace. The
+// returned function ends the spans with the outcome of the write.
+func startPublish(ctx context.Context, msgs []Message) ([]Message, func(error)) {
+	prop := otel.GetTextMapPropagator()
+	id := requestid.FromContext(ctx)
+	out := make([]Message, len(msgs))
+	spans := make([]trace.Span, len(msgs))
+	for i, m := range msgs {
+		headers := make(map[string]string, len(m.Headers)+len(prop.Fields())+1)
+		for k, v := range m.Headers {
+			headers[k] = v
+		}
+		if _, ok := headers[requestid.MessageHeader]; !ok && id != "" {
+			headers[requestid.MessageHeader] = id
+		}
+		pctx, span := tracing.Tracer().Start(prop.Extract(ctx, propagation.MapCarrier(headers)), "send "+m.Topic,
+			trace.WithSpanKind(trace.SpanKindProducer),
+			trace.WithAttributes(
+				semconv.MessagingSystemKafka,
+				semconv.MessagingOperationTypeSend,
+				semconv.MessagingDestinationName(m.Topic),
+				semconv.MessagingKafkaMessageKey(string(m.Key)),
+			))
+		prop.Inject(pctx, propagation.MapCarrier(headers))
+		m.Headers = headers
+		out[i], spans[i] = m, span
+	}
+	return out, func(err error) {
+		for _, span := range spans {
+			if err != nil {
+				tracing.Fail(span, err)
+			}
+			span.End()
+		}
+	}
+}
+
+// startProcess starts the consumer span of msg, continuing the trace of its headers.
+func startProcess(ctx context.Context, group string, msg Message) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(msg.Headers))
+	return tracing.Tracer().Start(ctx, "process "+msg.Topic,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationTypeProcess,
+			semconv.MessagingDestinationName(msg.Topic),
+			semconv.MessagingConsumerGroupName(group),
+			semconv.MessagingDestinationPartitionID(strconv.Itoa(msg.Partition)),
+			semconv.MessagingKafkaOffset(int(msg.Offset)),
+			semconv.MessagingKafkaMessageKey(string(msg.Key)),
+		))
+}
+
+// retryEvent records a failed attempt on the span of a message.
+func retryEvent(span trace.Span, attempt int, err error) {
+	span.AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt), attribute.String("error", err.Error())))
+}
+
+// skipEvent records a skipped message on its span.
+func skipEvent(span trace.Span, err error) {
+	span.AddEvent("skipped", trace.WithAttributes(attribute.String("error", err.Error())))
+}
+
+// dropTraceContext removes the trace context fields from headers.
+func dropTraceContext(headers map[string]string) {
+	for _, f := range otel.GetTextMapPropagator().Fields() {
+		delete(headers, f)
+	}
+}
@@ -1,7 +1,57 @@
 // Package events defines shared Kafka event DTOs for the saga choreography.
 package events
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Saga topic names.
+const (
+	TopicOrderCreated                = "order.created"
+	TopicOrderCanceled               = "order.canceled"
+	TopicUserCreditReserved          = "user.credit-reserved"
+	TopicUserCreditReservationFailed = "user.credit-reservation-failed"
+	TopicUserCreditReleased          = "user.credit-released"
+	TopicPaymentSucceeded            = "payment.succeeded"
+	TopicPaymentFailed               = "payment.failed"
+	TopicPaymentRefunded             = "payment.refunded"
+)
+
+// TopicUserCreated carries user registrations. It is keyed by user ID, not part of any saga.
+const TopicUserCreated = "user.created"
+
+// SagaTopics lists every saga topic.
+var SagaTopics = []string{
+	TopicOrderCreated,
+	TopicOrderCanceled,
+	TopicUserCreditReserved,
+	TopicUserCreditReservationFailed,
+	TopicUserCreditReleased,
+	TopicPaymentSucceeded,
+	TopicPaymentFailed,
+	TopicPaymentRefunded,
+}
+
+// topicProducers maps each event topic to the service that publishes it.
+var topicProducers = map[string]string{
+	TopicOrderCreated:                "order-service",
+	TopicOrderCanceled:               "order-service",
+	TopicUserCreditReserved:          "user-service",
+	TopicUserCreditReservationFailed: "user-service",
+	TopicUserCreditReleased:          "user-service",
+	TopicUserCreated:                 "user-service",
+	TopicPaymentSucceeded:            "payment-service",
+	TopicPaymentFailed:               "payment-service",
+	TopicPaymentRefunded:             "payment-service",
+}
+
+// ProducerOf returns the service that publishes topic, or "" for an unknown topic.
+func ProducerOf(topic string) string {
+	return topicProducers[topic]
+}
 
 // OrderStatus represents order status in the saga.
 type OrderStatus string
@@ -26,7 +76,7 @@ type OrderCanceledEvent struct {
 	Amount  int64     `json:"amount"`
 }
 
-// UserCreditReservedEvent is published when credit is reserved. Order-service confirms the order.
+// UserCreditReservedEvent is published when credit is reserved. Payment-service charges the order.
 type UserCreditReservedEvent struct {
 	OrderID uuid.UUID `json:"orderId"`
 	UserID  uuid.UUID `json:"userId"`
@@ -40,3 +90,83 @@ type UserCreditReservationFailedEvent struct {
 	Amount  int64     `json:"amount"`
 	Reason  string    `json:"reason"`
 }
+
+// UserCreditReleasedEvent is published when credit of a canceled order is restored (compensation done).
+type UserCreditReleasedEvent struct {
+	OrderID uuid.UUID `json:"orderId"`
+	UserID  uuid.UUID `json:"userId"`
+	Amount  int64     `json:"amount"`
+}
+
+// PaymentSucceededEvent is published when an order is charged. Order-service confirms the order.
+type PaymentSucceededEvent struct {
+	OrderID     uuid.UUID `json:"orderId"`
+	UserID      uuid.UUID `json:"userId"`
+	Amount      int64     `json:"amount"`
+	PaymentID   uuid.UUID `json:"paymentId"`
+	Provider    string    `json:"provider"`
+	ProviderRef string    `json:"providerRef"`
+}
+
+// PaymentFailedEvent is published when an order cannot be charged. Order-service cancels the order.
+type PaymentFailedEvent struct {
+	OrderID   uuid.UUID `json:"orderId"`
+	UserID    uuid.UUID `json:"userId"`
+	Amount    int64     `json:"amount"`
+	PaymentID uuid.UUID `json:"paymentId"`
+	Reason    string    `json:"reason"`
+}
+
+// PaymentRefundedEvent is published when the charge of a canceled order is refunded (compensation done).
+type PaymentRefundedEvent struct {
+	OrderID     uuid.UUID `json:"orderId"`
+	UserID      uuid.UUID `json:"userId"`
+	Amount      int64     `json:"amount"`
+	PaymentID   uuid.UUID `json:"paymentId"`
+	Provider    string    `json:"provider"`
+	ProviderRef string    `json:"providerRef"`
+}
+
+// UserCreatedEvent is published when a user is created, with the initial balance.
+type UserCreatedEvent struct {
+	UserID   uuid.UUID `json:"userId"`
+	Username string    `json:"username"`
+	Balance  int64     `json:"balance"`
+}
+
+// Envelope holds the IDs common to every saga event.
+type Envelope struct {
+	OrderID uuid.UUID `json:"orderId"`
+	UserID  uuid.UUID `json:"userId"`
+}
+
+// Decode unmarshals value into the event type published on topic.
+func Decode(topic string, value []byte) (any, error) {
+	var evt any
+	switch topic {
+	case TopicOrderCreated:
+		evt = &OrderCreatedEvent{}
+	case TopicOrderCanceled:
+		evt = &OrderCanceledEvent{}
+	case TopicUserCreditReserved:
+		evt = &UserCreditReservedEvent{}
+	case TopicUserCreditReservationFailed:
+		evt = &UserCreditReservationFailedEvent{}
+	case TopicUserCreditReleased:
+		evt = &UserCreditReleasedEvent{}
+	case TopicUserCreated:
+		evt = &UserCreatedEvent{}
+	case TopicPaymentSucceeded:
+		evt = &PaymentSucceededEvent{}
+	case TopicPaymentFailed:
+		evt = &PaymentFailedEvent{}
+	case TopicPaymentRefunded:
+		evt = &PaymentRefundedEvent{}
+	default:
+		return nil, fmt.Errorf("events: unknown topic %q", topic)
+	}
+	if err := json.Unmarshal(value, evt); err != nil {
+		return nil, err
+	}
+	return evt, nil
+}
@@ -0,0 +1,280 @@
+// Package jwt signs and verifies JSON Web Tokens with HS256 or RS256 and encodes RSA public
+// keys as a JWKS. Only the compact serialization and these two algorithms are supported.
+package jwt
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Signing algorithms.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+)
+
+// Errors returned by Parse and Claims.Valid.
+var (
+	ErrMalformed   = errors.New("malformed token")
+	ErrAlgorithm   = errors.New("unsupported signing algorithm")
+	ErrUnknownKey  = errors.New("unknown signing key")
+	ErrSignature   = errors.New("invalid signature")
+	ErrExpired     = errors.New("token expired")
+	ErrNotYetValid = errors.New("token not valid yet")
+)
+
+// Claims are the registered claims and the scope and username claims used between the
+// services. Times are seconds since the Unix epoch.
+type Claims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	Username  string   `json:"preferred_username,omitempty"`
+}
+
+// Scopes returns the space-separated scopes of the scope claim.
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// HasScope reports whether the token carries scope.
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes(), scope)
+}
+
+// Valid checks the expiry and not-before times against now, allowing leeway for clock skew. A
+// token without an expiry is rejected.
+func (c *Claims) Valid(now time.Time, leeway time.Duration) error {
+	if c.ExpiresAt == 0 || now.Add(-leeway).Unix() >= c.ExpiresAt {
+		return ErrExpired
+	}
+	if c.NotBefore != 0 && now.Add(leeway).Unix() < c.NotBefore {
+		return ErrNotYetValid
+	}
+	return nil
+}
+
+// Audience is the aud claim, a single string or an array of them.
+type Audience []string
+
+// MarshalJSON encodes a single audience as a string.
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// UnmarshalJSON accepts a string or an array of strings.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = Audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+// Key signs tokens: with Secret by HS256, else with Private by RS256. ID is written as the kid
+// header so verifiers can pick the public key.
+type Key struct {
+	ID      string
+	Secret  []byte
+	Private *rsa.PrivateKey
+}
+
+// Keys verify tokens. HS256 tokens are checked against Secret only and RS256 tokens against
+// Public only, looked up by kid; a token without kid is tried against every public key.
+type Keys struct {
+	Secret []byte
+	Public map[string]*rsa.PublicKey
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+var enc = base64.RawURLEncoding
+
+// Sign returns the compact serialization of claims signed with k.
+func Sign(claims Claims, k Key) (string, error) {
+	h := header{Alg: HS256, Typ: "JWT", Kid: k.ID}
+	if len(k.Secret) == 0 {
+		if k.Private == nil {
+			return "", errors.New("jwt: key has neither secret nor private key")
+		}
+		h.Alg = RS256
+	}
+	hb, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	cb, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := enc.EncodeToString(hb) + "." + enc.EncodeToString(cb)
+	var sig []byte
+	if h.Alg == HS256 {
+		sig = hmacSHA256(k.Secret, signed)
+	} else {
+		digest := sha256.Sum256([]byte(signed))
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k.Private, crypto.SHA256, digest[:]); err != nil {
+			return "", err
+		}
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
+
+// Parse verifies the signature of token against keys and returns its claims. It does not check
+// times, issuer or audience; see Claims.Valid.
+func Parse(token string, keys Keys) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	var h header
+	if err := decode(parts[0], &h); err != nil {
+		return nil, ErrMalformed
+	}
+	sig, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	signed := parts[0] + "." + parts[1]
+	switch h.Alg {
+	case HS256:
+		if len(keys.Secret) == 0 {
+			return nil, ErrUnknownKey
+		}
+		if !hmac.Equal(sig, hmacSHA256(keys.Secret, signed)) {
+			return nil, ErrSignature
+		}
+	case RS256:
+		candidates := keys.Public
+		if h.Kid != "" {
+			pub, ok := keys.Public[h.Kid]
+			if !ok {
+				return nil, ErrUnknownKey
+			}
+			candidates = map[string]*rsa.PublicKey{h.Kid: pub}
+		}
+		if len(candidates) == 0 {
+			return nil, ErrUnknownKey
+		}
+		digest := sha256.Sum256([]byte(signed))
+		verified := false
+		for _, pub := range candidates {
+			if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) == nil {
+				verified = true
+				break
+			}
+		}
+		if !verified {
+			return nil, ErrSignature
+		}
+	default:
+		return nil, fmt.Errorf("%w %q", ErrAlgorithm, h.Alg)
+	}
+	var c Claims
+	if err := decode(parts[1], &c); err != nil {
+		return nil, ErrMalformed
+	}
+	return &c, nil
+}
+
+func decode(part string, v any) error {
+	b, err := enc.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func hmacSHA256(secret []byte, s string) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(s))
+	return m.Sum(nil)
+}
+
+// JWK is an RSA public key in JSON Web Key form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWK returns pub as an RS256 signing key with id kid.
+func PublicJWK(kid string, pub *rsa.PublicKey) JWK {
+	return JWK{
+		Kty: "RSA",
+		Use: "sig",
+		Alg: RS256,
+		Kid: kid,
+		N:   enc.EncodeToString(pub.N.Bytes()),
+		E:   enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+// RSAKeys returns the RSA signing keys of the set by kid. Keys of other types or uses are
+// skipped.
+func (s JWKS) RSAKeys() (map[string]*rsa.PublicKey, error) {
+	out := make(map[string]*rsa.PublicKey, len(s.Keys))
+	for _, k := range s.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") || (k.Alg != "" && k.Alg != RS256) {
+			continue
+		}
+		n, err := enc.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: n: %w", k.Kid, err)
+		}
+		e, err := enc.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: key %q: e: %w", k.Kid, err)
+		}
+		exp := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exp.IsInt64() || exp.Int64() < 3 || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("jwks: key %q: invalid modulus or exponent", k.Kid)
+		}
+		out[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}
+	}
+	return out, nil
+}
+
+// Thumbprint returns the RFC 7638 thumbprint of an RSA key, base64url-encoded, which makes a
+// stable key ID.
+func (k JWK) Thumbprint() string {
+	sum := sha256.Sum256([]byte(`{"e":"` + k.E + `","kty":"RSA","n":"` + k.N + `"}`))
+	return enc.EncodeToString(sum[:])
+}
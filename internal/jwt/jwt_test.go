@@ -0,0 +1,169 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("secret")
+	pubDER := x509.MarshalPKCS1PublicKey(&priv.PublicKey)
+	claims := Claims{Subject: "alice", ExpiresAt: time.Now().Add(time.Minute).Unix()}
+	sign := func(k Key) string {
+		tok, err := Sign(claims, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	// forge returns a token with header h, signed by HMAC with key.
+	forge := func(h string, key []byte) string {
+		cb, _ := json.Marshal(claims)
+		signed := enc.EncodeToString([]byte(h)) + "." + enc.EncodeToString(cb)
+		return signed + "." + enc.EncodeToString(hmacSHA256(key, signed))
+	}
+	rsOnly := Keys{Public: map[string]*rsa.PublicKey{"k1": &priv.PublicKey}}
+	tests := []struct {
+		name  string
+		token string
+		keys  Keys
+		want  error // nil: valid
+	}{
+		{"HS256", sign(Key{Secret: secret}), Keys{Secret: secret}, nil},
+		{"HS256 wrong secret", sign(Key{Secret: []byte("other")}), Keys{Secret: secret}, ErrSignature},
+		{"HS256 without secret", sign(Key{Secret: secret}), rsOnly, ErrUnknownKey},
+		{"RS256 by kid", sign(Key{ID: "k1", Private: priv}), rsOnly, nil},
+		{"RS256 without kid", sign(Key{Private: priv}), rsOnly, nil},
+		{"RS256 unknown kid", sign(Key{ID: "k2", Private: priv}), rsOnly, ErrUnknownKey},
+		{"RS256 wrong key", sign(Key{ID: "k1", Private: other}), rsOnly, ErrSignature},
+		{"RS256 without public keys", sign(Key{Private: priv}), Keys{Secret: secret}, ErrUnknownKey},
+		{"HS256 signed with the public key", forge(`{"alg":"HS256","kid":"k1"}`, pubDER), rsOnly, ErrUnknownKey},
+		{
+			"HS256 signed with the public key, secret set", forge(`{"alg":"HS256","kid":"k1"}`, pubDER),
+			Keys{Secret: secret, Public: rsOnly.Public}, ErrSignature,
+		},
+		{"alg none", forge(`{"alg":"none"}`, nil), Keys{Secret: secret}, ErrAlgorithm},
+		{"alg lowercase", forge(`{"alg":"hs256"}`, secret), Keys{Secret: secret}, ErrAlgorithm},
+		{"two parts", "a.b", Keys{Secret: secret}, ErrMalformed},
+		{"bad header", "!." + strings.SplitN(sign(Key{Secret: secret}), ".", 2)[1], Keys{Secret: secret}, ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := Parse(tt.token, tt.keys)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Parse error = %v, want %v", err, tt.want)
+			}
+			if err == nil && c.Subject != claims.Subject {
+				t.Errorf("subject %q, want %q", c.Subject, claims.Subject)
+			}
+		})
+	}
+}
+
+func TestParseTamperedClaims(t *testing.T) {
+	secret := []byte("secret")
+	tok, err := Sign(Claims{Subject: "alice", Scope: "orders"}, Key{Secret: secret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(tok, ".")
+	parts[1] = enc.EncodeToString([]byte(`{"sub":"alice","scope":"admin"}`))
+	if _, err := Parse(strings.Join(parts, "."), Keys{Secret: secret}); !errors.Is(err, ErrSignature) {
+		t.Errorf("Parse error = %v, want %v", err, ErrSignature)
+	}
+}
+
+func TestClaimsValid(t *testing.T) {
+	now := time.Unix(1_000_000, 0)
+	sec := func(d time.Duration) int64 { return now.Add(d).Unix() }
+	tests := []struct {
+		name   string
+		claims Claims
+		leeway time.Duration
+		want   error
+	}{
+		{"valid", Claims{ExpiresAt: sec(time.Minute)}, 0, nil},
+		{"no expiry", Claims{}, time.Hour, ErrExpired},
+		{"expired", Claims{ExpiresAt: sec(-time.Second)}, 0, ErrExpired},
+		{"expires now", Claims{ExpiresAt: sec(0)}, 0, ErrExpired},
+		{"expired within leeway", Claims{ExpiresAt: sec(-time.Second)}, 5 * time.Second, nil},
+		{"expired beyond leeway", Claims{ExpiresAt: sec(-10 * time.Second)}, 5 * time.Second, ErrExpired},
+		{"not yet valid", Claims{ExpiresAt: sec(time.Hour), NotBefore: sec(time.Minute)}, 0, ErrNotYetValid},
+		{"not before within leeway", Claims{ExpiresAt: sec(time.Hour), NotBefore: sec(time.Second)}, 5 * time.Second, nil},
+		{"not before passed", Claims{ExpiresAt: sec(time.Hour), NotBefore: sec(-time.Second)}, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.claims.Valid(now, tt.leeway); !errors.Is(err, tt.want) {
+				t.Errorf("Valid = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudienceJSON(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    Audience
+		wantErr bool
+	}{
+		{json: `"api"`, want: Audience{"api"}},
+		{json: `["api","admin"]`, want: Audience{"api", "admin"}},
+		{json: `[]`, want: Audience{}},
+		{json: `1`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var a Audience
+			err := json.Unmarshal([]byte(tt.json), &a)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !slices.Equal(a, tt.want) {
+				t.Errorf("got %q, want %q", a, tt.want)
+			}
+		})
+	}
+	b, err := json.Marshal(Audience{"api"})
+	if err != nil || string(b) != `"api"` {
+		t.Errorf("Marshal single audience = %s, %v", b, err)
+	}
+}
+
+func TestJWKSRoundTrip(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := JWKS{Keys: []JWK{
+		PublicJWK("k1", &priv.PublicKey),
+		{Kty: "EC", Kid: "ec"},
+		{Kty: "RSA", Use: "enc", Kid: "enc", N: "AQAB", E: "AQAB"},
+	}}
+	keys, err := set.RSAKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || !keys["k1"].Equal(&priv.PublicKey) {
+		t.Fatalf("RSAKeys = %v, want only k1", keys)
+	}
+	bad := JWKS{Keys: []JWK{{Kty: "RSA", Kid: "k", N: "AQAB", E: "AQ"}}}
+	if _, err := bad.RSAKeys(); err == nil {
+		t.Error("RSAKeys accepted exponent 1")
+	}
+}
@@ -0,0 +1,158 @@
+// Package kafkaconn builds the kafka-go dialer and transport shared by readers, writers and
+// admin clients from broker, TLS and SASL settings.
+package kafkaconn
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// SASL mechanisms accepted in SASLConfig.Mechanism.
+const (
+	MechanismPlain       = "PLAIN"
+	MechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	MechanismSCRAMSHA512 = "SCRAM-SHA-512"
+)
+
+// dialTimeout bounds connection setup, including the TLS and SASL handshakes.
+const dialTimeout = 10 * time.Second
+
+// Config holds how to reach and authenticate to the Kafka cluster.
+type Config struct {
+	Brokers []string
+	TLS     TLSConfig
+	SASL    SASLConfig
+}
+
+// TLSConfig enables TLS. CAFile verifies the brokers (system roots when empty); CertFile and
+// KeyFile, set together, present a client certificate. Setting any file implies Enabled.
+type TLSConfig struct {
+	Enabled  bool
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// SASLConfig enables SASL authentication when Mechanism is set.
+type SASLConfig struct {
+	Mechanism string
+	Username  string
+	Password  string
+}
+
+// FromEnv returns a Config for brokers with TLS and SASL settings from the environment:
+// KAFKA_TLS_ENABLED, KAFKA_TLS_CA_FILE, KAFKA_TLS_CERT_FILE, KAFKA_TLS_KEY_FILE,
+// KAFKA_SASL_MECHANISM, KAFKA_SASL_USERNAME and KAFKA_SASL_PASSWORD.
+func FromEnv(brokers []string) Config {
+	enabled, _ := strconv.ParseBool(os.Getenv("KAFKA_TLS_ENABLED"))
+	return Config{
+		Brokers: brokers,
+		TLS: TLSConfig{
+			Enabled:  enabled,
+			CAFile:   os.Getenv("KAFKA_TLS_CA_FILE"),
+			CertFile: os.Getenv("KAFKA_TLS_CERT_FILE"),
+			KeyFile:  os.Getenv("KAFKA_TLS_KEY_FILE"),
+		},
+		SASL: SASLConfig{
+			Mechanism: strings.ToUpper(os.Getenv("KAFKA_SASL_MECHANISM")),
+			Username:  os.Getenv("KAFKA_SASL_USERNAME"),
+			Password:  os.Getenv("KAFKA_SASL_PASSWORD"),
+		},
+	}
+}
+
+// Conn is a built Config: use Dialer for readers and Transport for writers and clients.
+type Conn struct {
+	Brokers   []string
+	Dialer    *kafka.Dialer
+	Transport *kafka.Transport
+}
+
+// New loads certificates and builds the SASL mechanism for cfg.
+func New(cfg Config) (*Conn, error) {
+	tlsCfg, err := cfg.TLS.build()
+	if err != nil {
+		return nil, fmt.Errorf("kafka tls: %w", err)
+	}
+	mech, err := cfg.SASL.build()
+	if err != nil {
+		return nil, fmt.Errorf("kafka sasl: %w", err)
+	}
+	return &Conn{
+		Brokers: cfg.Brokers,
+		Dialer: &kafka.Dialer{
+			Timeout:       dialTimeout,
+			DualStack:     true,
+			TLS:           tlsCfg,
+			SASLMechanism: mech,
+		},
+		Transport: &kafka.Transport{
+			DialTimeout: dialTimeout,
+			TLS:         tlsCfg,
+			SASL:        mech,
+		},
+	}, nil
+}
+
+// Client returns an admin client for the brokers.
+func (c *Conn) Client(timeout time.Duration) *kafka.Client {
+	return &kafka.Client{
+		Addr:      kafka.TCP(c.Brokers...),
+		Timeout:   timeout,
+		Transport: c.Transport,
+	}
+}
+
+func (t TLSConfig) build() (*tls.Config, error) {
+	if !t.Enabled && t.CAFile == "" && t.CertFile == "" && t.KeyFile == "" {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in %s", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		if t.CertFile == "" || t.KeyFile == "" {
+			return nil, fmt.Errorf("client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+func (s SASLConfig) build() (sasl.Mechanism, error) {
+	switch s.Mechanism {
+	case "":
+		return nil, nil
+	case MechanismPlain:
+		return plain.Mechanism{Username: s.Username, Password: s.Password}, nil
+	case MechanismSCRAMSHA256:
+		return scram.Mechanism(scram.SHA256, s.Username, s.Password)
+	case MechanismSCRAMSHA512:
+		return scram.Mechanism(scram.SHA512, s.Username, s.Password)
+	default:
+		return nil, fmt.Errorf("unknown mechanism %q", s.Mechanism)
+	}
+}
@@ -0,0 +1,126 @@
+// Package lifecycle coordinates readiness and ordered, time-bounded shutdown of a service.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+
+	"go_example/internal/logging"
+)
+
+// Phase orders shutdown hooks. Phases run in ascending order; hooks within a phase run concurrently.
+type Phase int
+
+const (
+	// PhaseHTTP stops accepting requests and waits for in-flight ones.
+	PhaseHTTP Phase = iota
+	// PhaseConsumers stops fetching, waits for in-flight handlers and commits offsets.
+	PhaseConsumers
+	// PhaseProducers flushes and closes publishers.
+	PhaseProducers
+	// PhaseStorage closes database pools.
+	PhaseStorage
+	// PhaseTelemetry flushes spans recorded during the earlier phases.
+	PhaseTelemetry
+)
+
+var phaseNames = map[Phase]string{
+	PhaseHTTP:      "http",
+	PhaseConsumers: "consumers",
+	PhaseProducers: "producers",
+	PhaseStorage:   "storage",
+	PhaseTelemetry: "telemetry",
+}
+
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Manager tracks readiness and runs shutdown hooks.
+type Manager struct {
+	ready atomic.Bool
+
+	mu    sync.Mutex
+	hooks map[Phase][]hook
+}
+
+// New creates a Manager. It reports not-ready until SetReady is called.
+func New() *Manager {
+	return &Manager{hooks: map[Phase][]hook{}}
+}
+
+// SetReady marks the service ready to receive traffic.
+func (m *Manager) SetReady() {
+	m.ready.Store(true)
+}
+
+// Ready reports whether the service is ready to receive traffic.
+func (m *Manager) Ready() bool {
+	return m.ready.Load()
+}
+
+// OnShutdown registers fn to run during phase.
+func (m *Manager) OnShutdown(phase Phase, name string, fn func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks[phase] = append(m.hooks[phase], hook{name: name, fn: fn})
+}
+
+// Shutdown flips readiness to not-ready and keeps serving for drain, so that load balancers and
+// health checks see the flip and stop sending traffic before the listener closes. It then runs
+// each phase in order. All phases share one deadline of grace; phases still run after it
+// expires so resources are released, but hooks waiting on the context return early.
+func (m *Manager) Shutdown(drain, grace time.Duration) error {
+	m.ready.Store(false)
+	if drain > 0 {
+		slog.Info("not ready, draining before shutdown", "drain_ms", drain.Milliseconds())
+		time.Sleep(drain)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	m.mu.Lock()
+	hooks := m.hooks
+	m.mu.Unlock()
+
+	var errs []error
+	for phase := PhaseHTTP; phase <= PhaseTelemetry; phase++ {
+		start := time.Now()
+		var wg sync.WaitGroup
+		phaseErrs := make([]error, len(hooks[phase]))
+		for i, h := range hooks[phase] {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := h.fn(ctx); err != nil {
+					phaseErrs[i] = fmt.Errorf("%s: %w", h.name, err)
+				}
+			}()
+		}
+		wg.Wait()
+		if err := errors.Join(phaseErrs...); err != nil {
+			slog.Error("shutdown phase failed", "phase", phaseNames[phase], logging.Err(err))
+			errs = append(errs, err)
+		}
+		slog.Info("shutdown phase done", "phase", phaseNames[phase], "duration_ms", time.Since(start).Milliseconds())
+	}
+	return errors.Join(errs...)
+}
+
+// ReadinessHandler serves 200 when ready and 503 otherwise. GET /ready
+func (m *Manager) ReadinessHandler() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if !m.Ready() {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "NOT_READY"})
+		}
+		return c.JSON(fiber.Map{"status": "READY"})
+	}
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/recover"
+)
+
+// AccessLog logs each request once served: method, path, status, latency, client IP and
+// response size, with the request ID and trace of its context. It must run after the request
+// ID and tracing middleware. Server errors log at error level; health checks and metrics
+// scrapes at debug.
+func AccessLog() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The error handler sets the status after the middleware returned.
+			status = fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+		}
+		lvl := slog.LevelInfo
+		switch path := c.Path(); {
+		case status >= fiber.StatusInternalServerError:
+			lvl = slog.LevelError
+		case path == "/health" || path == "/ready" || path == "/metrics":
+			lvl = slog.LevelDebug
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", c.IP()),
+			slog.Int("bytes", len(c.Response().Body())),
+		}
+		if err != nil {
+			attrs = append(attrs, Err(err))
+		}
+		slog.LogAttrs(c.Context(), lvl, "request", attrs...)
+		return err
+	}
+}
+
+// LevelHandler serves the log level: GET returns it, PUT changes it at runtime from
+// {"level":"debug"}. GET|PUT /admin/log-level
+func LevelHandler() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if c.Method() == fiber.MethodPut {
+			var req struct {
+				Level string `json:"level"`
+			}
+			if err := c.Bind().Body(&req); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request body"})
+			}
+			l, err := ParseLevel(req.Level)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "level must be debug, info, warn or error"})
+			}
+			prev := Level()
+			SetLevel(l)
+			slog.WarnContext(c.Context(), "log level changed", "from", prev.String(), "to", l.String())
+		}
+		return c.JSON(fiber.Map{"level": strings.ToLower(Level().String())})
+	}
+}
+
+// AdminApp builds the admin endpoints of a service, LevelHandler at /admin/log-level, to be
+// served on their own port so that they are not reachable through the public one.
+func AdminApp() *fiber.App {
+	app := fiber.New()
+	app.Use(recover.New())
+	app.Add([]string{fiber.MethodGet, fiber.MethodPut}, "/admin/log-level", LevelHandler())
+	return app
+}
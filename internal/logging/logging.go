@@ -0,0 +1,156 @@
+// Package logging sets up log/slog for the binaries: a JSON or text handler with a level that
+// can be changed at runtime, adding to every record the request ID, the trace and the
+// attributes its context carries.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"go_example/internal/requestid"
+)
+
+// Formats accepted in Config.Format (LOG_FORMAT).
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Attribute keys shared by the binaries' log records.
+const (
+	KeyService   = "service"
+	KeyInstance  = "instance"
+	KeyRequestID = "request_id"
+	KeyTraceID   = "trace_id"
+	KeySpanID    = "span_id"
+	KeyOrderID   = "order_id"
+	KeyUserID    = "user_id"
+	KeyTopic     = "topic"
+	KeyPartition = "partition"
+	KeyOffset    = "offset"
+	KeyError     = "error"
+)
+
+// level is the level of the default logger, shared by every logger Setup builds.
+var level = new(slog.LevelVar)
+
+// Config selects the handler: Level is debug, info, warn or error, Format json or text.
+type Config struct {
+	Level  string
+	Format string
+}
+
+// FromEnv returns a Config from LOG_LEVEL (default info) and LOG_FORMAT (default json).
+func FromEnv() Config {
+	cfg := Config{Level: os.Getenv("LOG_LEVEL"), Format: strings.ToLower(os.Getenv("LOG_FORMAT"))}
+	if cfg.Level == "" {
+		cfg.Level = "info"
+	}
+	if cfg.Format == "" {
+		cfg.Format = FormatJSON
+	}
+	return cfg
+}
+
+// Setup makes the default logger, and the standard log package, write records of service at
+// cfg.Level or above to stderr, each with the service and instance (host name).
+func Setup(service string, cfg Config) error {
+	l, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	level.Set(l)
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch cfg.Format {
+	case FormatJSON:
+		h = slog.NewJSONHandler(os.Stderr, opts)
+	case FormatText:
+		h = slog.NewTextHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("logging: unknown format %q", cfg.Format)
+	}
+	host, _ := os.Hostname()
+	slog.SetDefault(slog.New(contextHandler{h}).With(KeyService, service, KeyInstance, host))
+	return nil
+}
+
+// ParseLevel parses a level name such as debug or WARN.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("logging: unknown level %q", s)
+	}
+	return l, nil
+}
+
+// Level returns the current level.
+func Level() slog.Level {
+	return level.Level()
+}
+
+// SetLevel changes the level of every logger Setup built.
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// contextHandler adds the request ID, trace and attributes of the record's context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestid.FromContext(ctx); id != "" {
+		r.AddAttrs(slog.String(KeyRequestID, id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String(KeyTraceID, sc.TraceID().String()), slog.String(KeySpanID, sc.SpanID().String()))
+	}
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type attrsKey struct{}
+
+// With returns ctx carrying attrs, added to the records logged with it.
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	prev, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return context.WithValue(ctx, attrsKey{}, append(slices.Clip(prev), attrs...))
+}
+
+// OrderID returns the order ID attribute.
+func OrderID(id fmt.Stringer) slog.Attr {
+	return slog.String(KeyOrderID, id.String())
+}
+
+// UserID returns the user ID attribute.
+func UserID(id fmt.Stringer) slog.Attr {
+	return slog.String(KeyUserID, id.String())
+}
+
+// Err returns the error attribute.
+func Err(err error) slog.Attr {
+	return slog.String(KeyError, err.Error())
+}
+
+// Fatal logs msg at error level and exits.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
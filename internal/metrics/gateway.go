@@ -0,0 +1,147 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	upstreamAvailable *prometheus.GaugeVec
+	upstreamEjections *prometheus.CounterVec
+	rateLimited       *prometheus.CounterVec
+	rateLimitErrors   prometheus.Counter
+	breakerState      *prometheus.GaugeVec
+	breakerChanges    *prometheus.CounterVec
+	upstreamRetries   *prometheus.CounterVec
+)
+
+// breakerStates are the values of gateway_circuit_breaker_state.
+var breakerStates = map[string]float64{"closed": 0, "half-open": 1, "open": 2}
+
+// RegisterGatewayMetrics registers upstream pool, circuit breaker and rate limit metrics. Safe to call more than once.
+func RegisterGatewayMetrics() {
+	if upstreamAvailable != nil {
+		return
+	}
+	upstreamAvailable = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_upstream_available",
+			Help: "1 if the upstream is healthy and not ejected, else 0.",
+		},
+		[]string{"pool", "upstream"},
+	)
+	upstreamEjections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_upstream_ejections_total",
+			Help: "Upstream ejections after consecutive failed requests.",
+		},
+		[]string{"pool", "upstream"},
+	)
+	rateLimited = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_rate_limited_total",
+			Help: "Requests rejected by a route's rate limit.",
+		},
+		[]string{"route"},
+	)
+	rateLimitErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "gateway_rate_limit_store_errors_total",
+			Help: "Rate limit store failures; the requests were let through.",
+		},
+	)
+	breakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "gateway_circuit_breaker_state",
+			Help: "Circuit breaker state of the upstream: 0 closed, 1 half-open, 2 open.",
+		},
+		[]string{"pool", "upstream"},
+	)
+	breakerChanges = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_circuit_breaker_transitions_total",
+			Help: "Circuit breaker state changes by the state entered.",
+		},
+		[]string{"pool", "upstream", "state"},
+	)
+	upstreamRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "gateway_upstream_retries_total",
+			Help: "Requests sent to the pool again after a failed attempt.",
+		},
+		[]string{"pool"},
+	)
+	prometheus.MustRegister(upstreamAvailable, upstreamEjections, rateLimited, rateLimitErrors, breakerState, breakerChanges, upstreamRetries)
+}
+
+// SetUpstreamAvailable records whether an upstream receives requests. No-op until RegisterGatewayMetrics.
+func SetUpstreamAvailable(pool, upstream string, available bool) {
+	if upstreamAvailable == nil {
+		return
+	}
+	v := 0.0
+	if available {
+		v = 1
+	}
+	upstreamAvailable.WithLabelValues(pool, upstream).Set(v)
+}
+
+// ObserveUpstreamEjection counts an ejection of an upstream.
+func ObserveUpstreamEjection(pool, upstream string) {
+	if upstreamEjections != nil {
+		upstreamEjections.WithLabelValues(pool, upstream).Inc()
+	}
+}
+
+// ForgetUpstream removes the series of an upstream no longer routed to.
+func ForgetUpstream(pool, upstream string) {
+	if upstreamAvailable == nil {
+		return
+	}
+	upstreamAvailable.DeleteLabelValues(pool, upstream)
+	upstreamEjections.DeleteLabelValues(pool, upstream)
+	breakerState.DeleteLabelValues(pool, upstream)
+	breakerChanges.DeletePartialMatch(prometheus.Labels{"pool": pool, "upstream": upstream})
+}
+
+// SetBreakerState records the state of an upstream's circuit breaker.
+func SetBreakerState(pool, upstream, state string) {
+	if breakerState != nil {
+		breakerState.WithLabelValues(pool, upstream).Set(breakerStates[state])
+	}
+}
+
+// ObserveBreakerTransition counts an upstream's circuit breaker entering state.
+func ObserveBreakerTransition(pool, upstream, state string) {
+	if breakerChanges != nil {
+		breakerChanges.WithLabelValues(pool, upstream, state).Inc()
+	}
+}
+
+// ObserveUpstreamRetry counts a retried request to the pool.
+func ObserveUpstreamRetry(pool string) {
+	if upstreamRetries != nil {
+		upstreamRetries.WithLabelValues(pool).Inc()
+	}
+}
+
+// ForgetPool removes the series of a pool no longer routed to.
+func ForgetPool(pool string) {
+	if breakerState == nil {
+		return
+	}
+	breakerState.DeletePartialMatch(prometheus.Labels{"pool": pool})
+	breakerChanges.DeletePartialMatch(prometheus.Labels{"pool": pool})
+	upstreamRetries.DeleteLabelValues(pool)
+}
+
+// ObserveRateLimited counts a request rejected by the rate limit of route.
+func ObserveRateLimited(route string) {
+	if rateLimited != nil {
+		rateLimited.WithLabelValues(route).Inc()
+	}
+}
+
+// ObserveRateLimitStoreError counts a failure of the rate limit store.
+func ObserveRateLimitStoreError() {
+	if rateLimitErrors != nil {
+		rateLimitErrors.Inc()
+	}
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Handler error outcomes recorded by ObserveHandlerError.
+const (
+	OutcomeRetry   = "retry"
+	OutcomeDLQ     = "dlq"
+	OutcomeSkipped = "skipped"
+)
+
+var (
+	kafkaConsumed        *prometheus.CounterVec
+	kafkaHandlerDuration *prometheus.HistogramVec
+	kafkaHandlerErrors   *prometheus.CounterVec
+	kafkaConsumerLag     *prometheus.GaugeVec
+	kafkaWriteDuration   *prometheus.HistogramVec
+	kafkaWriteFailures   *prometheus.CounterVec
+)
+
+// RegisterKafkaMetrics registers consumer and producer metrics. Safe to call more than once
+// (services sharing a process register once).
+func RegisterKafkaMetrics() {
+	if kafkaConsumed != nil {
+		return
+	}
+	kafkaConsumed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_consumer_messages_total",
+			Help: "Messages fetched by consumers.",
+		},
+		[]string{"topic", "group"},
+	)
+	kafkaHandlerDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "kafka_consumer_handler_duration_seconds",
+			Help:    "Message handler duration in seconds, per attempt.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"topic", "group"},
+	)
+	kafkaHandlerErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_consumer_handler_errors_total",
+			Help: "Message handler errors by outcome (retry, dlq, skipped).",
+		},
+		[]string{"topic", "group", "outcome"},
+	)
+	kafkaConsumerLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kafka_consumer_lag",
+			Help: "Messages behind the end of the assigned partitions.",
+		},
+		[]string{"topic", "group"},
+	)
+	kafkaWriteDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "kafka_producer_write_duration_seconds",
+			Help:    "Producer write latency in seconds.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"topic"},
+	)
+	kafkaWriteFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_producer_write_failures_total",
+			Help: "Failed producer writes.",
+		},
+		[]string{"topic"},
+	)
+	prometheus.MustRegister(kafkaConsumed, kafkaHandlerDuration, kafkaHandlerErrors, kafkaConsumerLag, kafkaWriteDuration, kafkaWriteFailures)
+}
+
+// ObserveConsumed counts a fetched message. No-op until RegisterKafkaMetrics.
+func ObserveConsumed(topic, group string) {
+	if kafkaConsumed != nil {
+		kafkaConsumed.WithLabelValues(topic, group).Inc()
+	}
+}
+
+// ObserveHandler records one handler attempt.
+func ObserveHandler(topic, group string, d time.Duration) {
+	if kafkaHandlerDuration != nil {
+		kafkaHandlerDuration.WithLabelValues(topic, group).Observe(d.Seconds())
+	}
+}
+
+// ObserveHandlerError counts a handler error with its outcome (OutcomeRetry, OutcomeDLQ, OutcomeSkipped).
+func ObserveHandlerError(topic, group, outcome string) {
+	if kafkaHandlerErrors != nil {
+		kafkaHandlerErrors.WithLabelValues(topic, group, outcome).Inc()
+	}
+}
+
+// SetConsumerLag sets the current lag of a consumer.
+func SetConsumerLag(topic, group string, lag int64) {
+	if kafkaConsumerLag != nil {
+		kafkaConsumerLag.WithLabelValues(topic, group).Set(float64(lag))
+	}
+}
+
+// ObserveProducerWrite records a write to topic and counts it as failed when err is non-nil.
+func ObserveProducerWrite(topic string, d time.Duration, err error) {
+	if kafkaWriteDuration == nil {
+		return
+	}
+	kafkaWriteDuration.WithLabelValues(topic).Observe(d.Seconds())
+	if err != nil {
+		kafkaWriteFailures.WithLabelValues(topic).Inc()
+	}
+}
@@ -1,4 +1,4 @@
-// Package metrics provides Prometheus metrics (go_example_up, http_requests_total, http_request_duration_seconds) and Fiber middleware.
+// Package metrics provides Prometheus metrics (go_example_up, HTTP request and Kafka consumer/producer metrics) and Fiber middleware.
 package metrics
 
 import (
@@ -0,0 +1,67 @@
+// Package requestid carries a correlation ID through HTTP requests, outbound calls and Kafka
+// messages, so the log lines of one checkout can be matched across services.
+package requestid
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+const (
+	// Header is the HTTP header carrying the request ID.
+	Header = "X-Request-ID"
+	// MessageHeader is the Kafka message header carrying the request ID.
+	MessageHeader = "x-request-id"
+	// maxLen bounds accepted request IDs.
+	maxLen = 128
+)
+
+type ctxKey struct{}
+
+// New returns a new request ID.
+func New() string {
+	return uuid.NewString()
+}
+
+// NewContext returns ctx carrying id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the request ID of ctx, or "" without one.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Valid reports whether id may be used as a request ID: 1 to 128 printable ASCII characters
+// without spaces, so that it cannot break log lines or headers.
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware accepts a valid X-Request-ID from the client or replaces it with a new one. The ID
+// is set on the request, so that it is forwarded and logged, on the response and in the
+// request context.
+func Middleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		id := c.Get(Header)
+		if !Valid(id) {
+			id = New()
+			c.Request().Header.Set(Header, id)
+		}
+		c.Set(Header, id)
+		c.SetContext(NewContext(c.Context(), id))
+		return c.Next()
+	}
+}
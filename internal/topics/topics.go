@@ -0,0 +1,290 @@
+// Package topics declares Kafka topic specs and reconciles them with the cluster through the kafka-go admin API.
+package topics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"go_example/internal/kafkaconn"
+	"go_example/internal/logging"
+)
+
+// Cleanup policies supported by Spec.CleanupPolicy.
+const (
+	CleanupDelete  = "delete"
+	CleanupCompact = "compact"
+)
+
+// Spec declares the desired state of a topic.
+type Spec struct {
+	Name              string
+	Partitions        int
+	ReplicationFactor int
+	Retention         time.Duration
+	CleanupPolicy     string
+}
+
+// managedConfigs lists the topic-level configs that Spec controls.
+var managedConfigs = []string{"retention.ms", "cleanup.policy"}
+
+// configs returns the topic-level config values for Spec.
+func (s Spec) configs() map[string]string {
+	return map[string]string{
+		"retention.ms":   strconv.FormatInt(s.Retention.Milliseconds(), 10),
+		"cleanup.policy": s.CleanupPolicy,
+	}
+}
+
+// Drift describes a difference between a Spec and the topic on the cluster.
+type Drift struct {
+	Topic string
+	Field string
+	Want  string
+	Got   string
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s: %s want=%s got=%s", d.Topic, d.Field, d.Want, d.Got)
+}
+
+// Admin checks and reconciles topics against the cluster.
+type Admin struct {
+	client adminClient
+}
+
+// adminClient is the part of *kafka.Client that Admin uses.
+type adminClient interface {
+	Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error)
+	DescribeConfigs(ctx context.Context, req *kafka.DescribeConfigsRequest) (*kafka.DescribeConfigsResponse, error)
+	CreateTopics(ctx context.Context, req *kafka.CreateTopicsRequest) (*kafka.CreateTopicsResponse, error)
+	CreatePartitions(ctx context.Context, req *kafka.CreatePartitionsRequest) (*kafka.CreatePartitionsResponse, error)
+	IncrementalAlterConfigs(ctx context.Context, req *kafka.IncrementalAlterConfigsRequest) (*kafka.IncrementalAlterConfigsResponse, error)
+}
+
+// NewAdmin creates a new Admin on conn.
+func NewAdmin(conn *kafkaconn.Conn) *Admin {
+	return &Admin{client: conn.Client(10 * time.Second)}
+}
+
+// Check reports drift between specs and the cluster without changing anything.
+func (a *Admin) Check(ctx context.Context, specs []Spec) ([]Drift, error) {
+	existing, err := a.describe(ctx, specs)
+	if err != nil {
+		return nil, err
+	}
+	var drift []Drift
+	for _, s := range specs {
+		t, ok := existing[s.Name]
+		if !ok {
+			drift = append(drift, Drift{Topic: s.Name, Field: "exists", Want: "true", Got: "false"})
+			continue
+		}
+		drift = append(drift, t.diff(s)...)
+	}
+	return drift, nil
+}
+
+// Reconcile creates missing topics, adds partitions and updates configs to match specs.
+// Replication factor changes and partition decreases cannot be applied online and are returned as errors.
+func (a *Admin) Reconcile(ctx context.Context, specs []Spec) error {
+	existing, err := a.describe(ctx, specs)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range specs {
+		t, ok := existing[s.Name]
+		if !ok {
+			if err := a.create(ctx, s); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			slog.InfoContext(ctx, "topic created", logging.KeyTopic, s.Name, "partitions", s.Partitions, "replication_factor", s.ReplicationFactor)
+			continue
+		}
+		for _, d := range t.diff(s) {
+			switch d.Field {
+			case "partitions":
+				if t.partitions > s.Partitions {
+					errs = append(errs, fmt.Errorf("topics: %s has %d partitions, cannot shrink to %d", s.Name, t.partitions, s.Partitions))
+					continue
+				}
+				if err := a.addPartitions(ctx, s); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+			case "replicationFactor":
+				errs = append(errs, fmt.Errorf("topics: %s replication factor is %s, want %s (reassign partitions manually)", s.Name, d.Got, d.Want))
+				continue
+			default:
+				if err := a.alterConfig(ctx, s.Name, d.Field, d.Want); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+			}
+			slog.InfoContext(ctx, "topic reconciled", logging.KeyTopic, s.Name, "drift", d.String())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// topicState is the observed state of a topic on the cluster.
+type topicState struct {
+	partitions        int
+	replicationFactor int
+	configs           map[string]string
+}
+
+func (t topicState) diff(s Spec) []Drift {
+	var drift []Drift
+	if t.partitions != s.Partitions {
+		drift = append(drift, Drift{Topic: s.Name, Field: "partitions", Want: strconv.Itoa(s.Partitions), Got: strconv.Itoa(t.partitions)})
+	}
+	if t.replicationFactor != s.ReplicationFactor {
+		drift = append(drift, Drift{Topic: s.Name, Field: "replicationFactor", Want: strconv.Itoa(s.ReplicationFactor), Got: strconv.Itoa(t.replicationFactor)})
+	}
+	want := s.configs()
+	for _, name := range managedConfigs {
+		if got := t.configs[name]; got != want[name] {
+			drift = append(drift, Drift{Topic: s.Name, Field: name, Want: want[name], Got: got})
+		}
+	}
+	return drift
+}
+
+// describe returns the observed state of the topics in specs that exist on the cluster.
+func (a *Admin) describe(ctx context.Context, specs []Spec) (map[string]topicState, error) {
+	names := make([]string, len(specs))
+	for i, s := range specs {
+		names[i] = s.Name
+	}
+	meta, err := a.client.Metadata(ctx, &kafka.MetadataRequest{Topics: names})
+	if err != nil {
+		return nil, fmt.Errorf("topics: metadata: %w", err)
+	}
+	out := make(map[string]topicState, len(specs))
+	var resources []kafka.DescribeConfigRequestResource
+	for _, t := range meta.Topics {
+		if t.Error != nil {
+			if errors.Is(t.Error, kafka.UnknownTopicOrPartition) {
+				continue
+			}
+			return nil, fmt.Errorf("topics: metadata %s: %w", t.Name, t.Error)
+		}
+		st := topicState{partitions: len(t.Partitions), configs: map[string]string{}}
+		if len(t.Partitions) > 0 {
+			st.replicationFactor = len(t.Partitions[0].Replicas)
+		}
+		out[t.Name] = st
+		resources = append(resources, kafka.DescribeConfigRequestResource{
+			ResourceType: kafka.ResourceTypeTopic,
+			ResourceName: t.Name,
+			ConfigNames:  managedConfigs,
+		})
+	}
+	if len(resources) == 0 {
+		return out, nil
+	}
+	res, err := a.client.DescribeConfigs(ctx, &kafka.DescribeConfigsRequest{Resources: resources})
+	if err != nil {
+		return nil, fmt.Errorf("topics: describe configs: %w", err)
+	}
+	for _, r := range res.Resources {
+		if r.Error != nil {
+			return nil, fmt.Errorf("topics: describe configs %s: %w", r.ResourceName, r.Error)
+		}
+		st := out[r.ResourceName]
+		for _, e := range r.ConfigEntries {
+			st.configs[e.ConfigName] = e.ConfigValue
+		}
+	}
+	return out, nil
+}
+
+func (a *Admin) create(ctx context.Context, s Spec) error {
+	var entries []kafka.ConfigEntry
+	for name, value := range s.configs() {
+		entries = append(entries, kafka.ConfigEntry{ConfigName: name, ConfigValue: value})
+	}
+	res, err := a.client.CreateTopics(ctx, &kafka.CreateTopicsRequest{
+		Topics: []kafka.TopicConfig{{
+			Topic:             s.Name,
+			NumPartitions:     s.Partitions,
+			ReplicationFactor: s.ReplicationFactor,
+			ConfigEntries:     entries,
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("topics: create %s: %w", s.Name, err)
+	}
+	if err := res.Errors[s.Name]; err != nil && !errors.Is(err, kafka.TopicAlreadyExists) {
+		return fmt.Errorf("topics: create %s: %w", s.Name, err)
+	}
+	return nil
+}
+
+func (a *Admin) addPartitions(ctx context.Context, s Spec) error {
+	res, err := a.client.CreatePartitions(ctx, &kafka.CreatePartitionsRequest{
+		Topics: []kafka.TopicPartitionsConfig{{Name: s.Name, Count: int32(s.Partitions)}},
+	})
+	if err != nil {
+		return fmt.Errorf("topics: add partitions %s: %w", s.Name, err)
+	}
+	if err := res.Errors[s.Name]; err != nil {
+		return fmt.Errorf("topics: add partitions %s: %w", s.Name, err)
+	}
+	return nil
+}
+
+func (a *Admin) alterConfig(ctx context.Context, topic, name, value string) error {
+	res, err := a.client.IncrementalAlterConfigs(ctx, &kafka.IncrementalAlterConfigsRequest{
+		Resources: []kafka.IncrementalAlterConfigsRequestResource{{
+			ResourceType: kafka.ResourceTypeTopic,
+			ResourceName: topic,
+			Configs: []kafka.IncrementalAlterConfigsRequestConfig{{
+				Name:            name,
+				Value:           value,
+				ConfigOperation: kafka.ConfigOperationSet,
+			}},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("topics: alter %s %s: %w", topic, name, err)
+	}
+	for _, r := range res.Resources {
+		if r.Error != nil {
+			return fmt.Errorf("topics: alter %s %s: %w", topic, name, r.Error)
+		}
+	}
+	return nil
+}
+
+// Run reconciles specs, or with checkOnly reports drift and returns an error if any is found.
+func Run(ctx context.Context, cfg kafkaconn.Config, specs []Spec, checkOnly bool) error {
+	conn, err := kafkaconn.New(cfg)
+	if err != nil {
+		return err
+	}
+	admin := NewAdmin(conn)
+	if !checkOnly {
+		return admin.Reconcile(ctx, specs)
+	}
+	drift, err := admin.Check(ctx, specs)
+	if err != nil {
+		return err
+	}
+	for _, d := range drift {
+		fmt.Println(d)
+	}
+	if len(drift) > 0 {
+		return fmt.Errorf("topics: %d drifted setting(s)", len(drift))
+	}
+	fmt.Println("topics: no drift")
+	return nil
+}
@@ -0,0 +1,192 @@
+package topics
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeCluster keeps topics in memory and serves the admin requests Admin sends.
+type fakeCluster struct {
+	topics map[string]topicState
+	// altered counts IncrementalAlterConfigs and CreatePartitions calls.
+	altered int
+}
+
+func (c *fakeCluster) Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error) {
+	res := &kafka.MetadataResponse{}
+	for _, name := range req.Topics {
+		t, ok := c.topics[name]
+		if !ok {
+			res.Topics = append(res.Topics, kafka.Topic{Name: name, Error: kafka.UnknownTopicOrPartition})
+			continue
+		}
+		kt := kafka.Topic{Name: name}
+		for i := range t.partitions {
+			kt.Partitions = append(kt.Partitions, kafka.Partition{Topic: name, ID: i, Replicas: make([]kafka.Broker, t.replicationFactor)})
+		}
+		res.Topics = append(res.Topics, kt)
+	}
+	return res, nil
+}
+
+func (c *fakeCluster) DescribeConfigs(ctx context.Context, req *kafka.DescribeConfigsRequest) (*kafka.DescribeConfigsResponse, error) {
+	res := &kafka.DescribeConfigsResponse{}
+	for _, r := range req.Resources {
+		rr := kafka.DescribeConfigResponseResource{ResourceType: int8(r.ResourceType), ResourceName: r.ResourceName}
+		for _, name := range r.ConfigNames {
+			rr.ConfigEntries = append(rr.ConfigEntries, kafka.DescribeConfigResponseConfigEntry{ConfigName: name, ConfigValue: c.topics[r.ResourceName].configs[name]})
+		}
+		res.Resources = append(res.Resources, rr)
+	}
+	return res, nil
+}
+
+func (c *fakeCluster) CreateTopics(ctx context.Context, req *kafka.CreateTopicsRequest) (*kafka.CreateTopicsResponse, error) {
+	res := &kafka.CreateTopicsResponse{Errors: map[string]error{}}
+	for _, t := range req.Topics {
+		if _, ok := c.topics[t.Topic]; ok {
+			res.Errors[t.Topic] = kafka.TopicAlreadyExists
+			continue
+		}
+		st := topicState{partitions: t.NumPartitions, replicationFactor: t.ReplicationFactor, configs: map[string]string{}}
+		for _, e := range t.ConfigEntries {
+			st.configs[e.ConfigName] = e.ConfigValue
+		}
+		c.topics[t.Topic] = st
+	}
+	return res, nil
+}
+
+func (c *fakeCluster) CreatePartitions(ctx context.Context, req *kafka.CreatePartitionsRequest) (*kafka.CreatePartitionsResponse, error) {
+	res := &kafka.CreatePartitionsResponse{Errors: map[string]error{}}
+	for _, t := range req.Topics {
+		st := c.topics[t.Name]
+		if int(t.Count) <= st.partitions {
+			res.Errors[t.Name] = kafka.InvalidPartitionNumber
+			continue
+		}
+		st.partitions = int(t.Count)
+		c.topics[t.Name] = st
+		c.altered++
+	}
+	return res, nil
+}
+
+func (c *fakeCluster) IncrementalAlterConfigs(ctx context.Context, req *kafka.IncrementalAlterConfigsRequest) (*kafka.IncrementalAlterConfigsResponse, error) {
+	res := &kafka.IncrementalAlterConfigsResponse{}
+	for _, r := range req.Resources {
+		for _, cfg := range r.Configs {
+			c.topics[r.ResourceName].configs[cfg.Name] = cfg.Value
+		}
+		res.Resources = append(res.Resources, kafka.IncrementalAlterConfigsResponseResource{ResourceType: r.ResourceType, ResourceName: r.ResourceName})
+		c.altered++
+	}
+	return res, nil
+}
+
+var spec = Spec{Name: "order.created", Partitions: 6, ReplicationFactor: 3, Retention: 7 * 24 * time.Hour, CleanupPolicy: CleanupDelete}
+
+// matching returns the cluster state of a topic created from spec.
+func matching() topicState {
+	return topicState{partitions: 6, replicationFactor: 3, configs: map[string]string{"retention.ms": "604800000", "cleanup.policy": "delete"}}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(*topicState)
+		absent bool
+		want   []string
+	}{
+		{"matching", nil, false, nil},
+		{"missing", nil, true, []string{"order.created: exists want=true got=false"}},
+		{"fewer partitions", func(s *topicState) { s.partitions = 3 }, false, []string{"order.created: partitions want=6 got=3"}},
+		{"replication factor", func(s *topicState) { s.replicationFactor = 1 }, false, []string{"order.created: replicationFactor want=3 got=1"}},
+		{"retention", func(s *topicState) { s.configs["retention.ms"] = "86400000" }, false, []string{"order.created: retention.ms want=604800000 got=86400000"}},
+		{"compacted", func(s *topicState) { s.configs["cleanup.policy"] = "compact" }, false, []string{"order.created: cleanup.policy want=delete got=compact"}},
+		{"several", func(s *topicState) { s.partitions, s.configs["cleanup.policy"] = 12, "" }, false, []string{
+			"order.created: partitions want=6 got=12",
+			"order.created: cleanup.policy want=delete got=",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &fakeCluster{topics: map[string]topicState{}}
+			if !tt.absent {
+				st := matching()
+				if tt.change != nil {
+					tt.change(&st)
+				}
+				c.topics[spec.Name] = st
+			}
+			drift, err := (&Admin{client: c}).Check(context.Background(), []Spec{spec})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, d := range drift {
+				got = append(got, d.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("drift %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name        string
+		change      func(*topicState)
+		absent      bool
+		wantErr     string
+		wantAltered int
+	}{
+		{"matching", nil, false, "", 0},
+		{"missing", nil, true, "", 0},
+		{"fewer partitions", func(s *topicState) { s.partitions = 3 }, false, "", 1},
+		{"configs", func(s *topicState) { s.configs["retention.ms"], s.configs["cleanup.policy"] = "1", "compact" }, false, "", 2},
+		{"more partitions", func(s *topicState) { s.partitions = 12 }, false, "cannot shrink to 6", 0},
+		{"replication factor", func(s *topicState) { s.replicationFactor = 1 }, false, "reassign partitions manually", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &fakeCluster{topics: map[string]topicState{}}
+			if !tt.absent {
+				st := matching()
+				if tt.change != nil {
+					tt.change(&st)
+				}
+				c.topics[spec.Name] = st
+			}
+			before := c.topics[spec.Name]
+			before.configs = maps.Clone(before.configs)
+			admin := &Admin{client: c}
+			err := admin.Reconcile(context.Background(), []Spec{spec})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Reconcile error = %v, want %q", err, tt.wantErr)
+				}
+				if got := c.topics[spec.Name]; got.partitions != before.partitions || got.replicationFactor != before.replicationFactor || !maps.Equal(got.configs, before.configs) {
+					t.Errorf("topic changed to %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Reconcile: %v", err)
+			}
+			if c.altered != tt.wantAltered {
+				t.Errorf("%d changes applied, want %d", c.altered, tt.wantAltered)
+			}
+			if drift, err := admin.Check(context.Background(), []Spec{spec}); err != nil || len(drift) > 0 {
+				t.Errorf("drift after Reconcile: %v, %v", drift, err)
+			}
+		})
+	}
+}
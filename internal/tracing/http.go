@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderCarrier adapts fasthttp request headers to the propagators.
+type HeaderCarrier struct {
+	*fasthttp.RequestHeader
+}
+
+// Get returns the value of key.
+func (h HeaderCarrier) Get(key string) string {
+	return string(h.Peek(key))
+}
+
+// Set sets key to value.
+func (h HeaderCarrier) Set(key, value string) {
+	h.RequestHeader.Set(key, value)
+}
+
+// Keys returns the header names.
+func (h HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, h.Len())
+	for k := range h.All() {
+		keys = append(keys, string(k))
+	}
+	return keys
+}
+
+// Inject writes the trace context of ctx to h, replacing what it carried.
+func Inject(ctx context.Context, h *fasthttp.RequestHeader) {
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier{h})
+}
+
+// Middleware starts a server span for each request, continuing the trace of its traceparent
+// header, and puts it in the request context. The span is named after the method and the
+// matched route; catch-all middleware, which Fiber reports as route "/", leaves the method.
+func Middleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.Context(), HeaderCarrier{&c.Request().Header})
+		method := c.Method()
+		ctx, span := Tracer().Start(ctx, method, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(method),
+			semconv.URLPath(c.Path()),
+			semconv.ClientAddress(c.IP()),
+		))
+		defer span.End()
+		c.SetContext(ctx)
+
+		err := c.Next()
+		if route := c.Route(); route != nil && route.Path != "/" {
+			span.SetName(method + " " + route.Path)
+			span.SetAttributes(semconv.HTTPRoute(route.Path))
+		}
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The error handler sets the status after the middleware returned.
+			status = fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= fiber.StatusInternalServerError {
+			if err != nil {
+				Fail(span, err)
+			} else {
+				span.SetStatus(codes.Error, "")
+			}
+		}
+		return err
+	}
+}
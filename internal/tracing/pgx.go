@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryTracer traces pgx queries as client spans named after their SQL operation, with the
+// query text (its parameters left out) as an attribute.
+type QueryTracer struct{}
+
+// TraceQueryStart starts the span of a query.
+func (QueryTracer) TraceQueryStart(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	op := operation(data.SQL)
+	ctx, _ = Tracer().Start(ctx, op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemNamePostgreSQL,
+		semconv.DBNamespace(conn.Config().Database),
+		semconv.DBOperationName(op),
+		semconv.DBQueryText(data.SQL),
+	))
+	return ctx
+}
+
+// TraceQueryEnd ends the span of a query, failed unless it succeeded or found no rows.
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil && !errors.Is(data.Err, pgx.ErrNoRows) {
+		Fail(span, data.Err)
+	}
+	span.End()
+}
+
+// operation returns the first keyword of sql, such as SELECT or INSERT.
+func operation(sql string) string {
+	op, _, _ := strings.Cut(strings.TrimSpace(sql), " ")
+	if op == "" {
+		return "query"
+	}
+	return strings.ToUpper(op)
+}
+
+// OpenPool opens a pgx pool on dsn whose queries are traced.
+func OpenPool(ctx context.Context, dsn string) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ConnConfig.Tracer = QueryTracer{}
+	return pgxpool.NewWithConfig(ctx, cfg)
+}
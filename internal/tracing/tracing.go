@@ -0,0 +1,159 @@
+// Package tracing sets up OpenTelemetry tracing and instruments Fiber, fasthttp headers and pgx.
+// Trace context travels in W3C traceparent and tracestate headers, over HTTP and Kafka alike.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters accepted in Config.Exporter (OTEL_TRACES_EXPORTER).
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// OTLP protocols accepted in Config.Protocol (OTEL_EXPORTER_OTLP_PROTOCOL).
+const (
+	ProtocolGRPC = "grpc"
+	ProtocolHTTP = "http/protobuf"
+)
+
+// ScopeName is the instrumentation scope of the spans started here.
+const ScopeName = "go_example"
+
+// Config selects where spans go. The OTLP exporters read their endpoint, headers and TLS
+// settings from the standard OTEL_EXPORTER_OTLP_* variables, and the SDK its sampler from
+// OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG.
+type Config struct {
+	Exporter string
+	Protocol string
+	File     string
+}
+
+// FromEnv returns a Config from OTEL_TRACES_EXPORTER (default none),
+// OTEL_EXPORTER_OTLP_TRACES_PROTOCOL or OTEL_EXPORTER_OTLP_PROTOCOL (default http/protobuf) and
+// OTEL_TRACES_FILE (default traces.jsonl).
+func FromEnv() Config {
+	cfg := Config{
+		Exporter: strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")),
+		Protocol: os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"),
+		File:     os.Getenv("OTEL_TRACES_FILE"),
+	}
+	if cfg.Exporter == "" {
+		cfg.Exporter = ExporterNone
+	}
+	if cfg.Protocol == "" {
+		cfg.Protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	if cfg.Protocol == "" {
+		cfg.Protocol = ProtocolHTTP
+	}
+	if cfg.File == "" {
+		cfg.File = "traces.jsonl"
+	}
+	return cfg
+}
+
+// Setup installs the W3C trace context and baggage propagators and, unless cfg.Exporter is
+// none, a tracer provider exporting the spans of service in batches. OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES override the resource. The returned function flushes pending spans
+// and stops the exporter.
+func Setup(ctx context.Context, service string, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	exporter, closeFile, err := newExporter(ctx, cfg)
+	if err != nil || exporter == nil {
+		return func(context.Context) error { return nil }, err
+	}
+	host, _ := os.Hostname()
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(service), semconv.ServiceInstanceID(host)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: resource: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	return func(ctx context.Context) error {
+		return errors.Join(tp.Shutdown(ctx), closeFile())
+	}, nil
+}
+
+// newExporter returns the exporter of cfg, nil for none, and a function closing its file.
+func newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, func() error, error) {
+	noFile := func() error { return nil }
+	switch cfg.Exporter {
+	case ExporterNone:
+		return nil, noFile, nil
+	case ExporterOTLP:
+		var (
+			exporter sdktrace.SpanExporter
+			err      error
+		)
+		switch cfg.Protocol {
+		case ProtocolGRPC:
+			exporter, err = otlptracegrpc.New(ctx)
+		case ProtocolHTTP:
+			exporter, err = otlptracehttp.New(ctx)
+		default:
+			return nil, noFile, fmt.Errorf("tracing: unknown OTLP protocol %q", cfg.Protocol)
+		}
+		if err != nil {
+			return nil, noFile, fmt.Errorf("tracing: otlp: %w", err)
+		}
+		return exporter, noFile, nil
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		return exporter, noFile, err
+	case ExporterFile:
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, noFile, fmt.Errorf("tracing: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, noFile, err
+		}
+		return exporter, f.Close, nil
+	default:
+		return nil, noFile, fmt.Errorf("tracing: unknown exporter %q", cfg.Exporter)
+	}
+}
+
+// Tracer returns the tracer of the services' own spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(ScopeName)
+}
+
+// Fail records err on span and marks it failed.
+func Fail(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Fields returns the trace context of ctx as header fields, to be stored with work done later
+// such as an outbox event.
+func Fields(ctx context.Context) map[string]string {
+	fields := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, fields)
+	return fields
+}
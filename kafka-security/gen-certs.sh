@@ -0,0 +1,31 @@
+#!/usr/bin/env sh
+# Generates a self-signed CA, a broker certificate (kafka, localhost, 127.0.0.1) and a client
+# certificate into kafka-security/secrets for docker-compose.kafka-security.yml.
+set -eu
+cd "$(dirname "$0")"
+mkdir -p secrets
+cd secrets
+
+PASS=changeit
+DAYS=365
+
+openssl req -x509 -newkey rsa:2048 -nodes -days "$DAYS" -subj "/CN=go_example-dev-ca" \
+  -keyout ca.key -out ca.pem
+
+openssl req -newkey rsa:2048 -nodes -subj "/CN=kafka" -keyout broker.key -out broker.csr
+printf 'subjectAltName=DNS:kafka,DNS:localhost,IP:127.0.0.1\nextendedKeyUsage=serverAuth\n' > broker.ext
+openssl x509 -req -in broker.csr -CA ca.pem -CAkey ca.key -CAcreateserial -days "$DAYS" \
+  -extfile broker.ext -out broker.pem
+openssl pkcs12 -export -in broker.pem -inkey broker.key -certfile ca.pem -name kafka \
+  -passout "pass:$PASS" -out kafka.keystore.p12
+printf %s "$PASS" > keystore_creds
+
+openssl req -newkey rsa:2048 -nodes -subj "/CN=app" -keyout client.key -out client.csr
+printf 'extendedKeyUsage=clientAuth\n' > client.ext
+openssl x509 -req -in client.csr -CA ca.pem -CAkey ca.key -CAcreateserial -days "$DAYS" \
+  -extfile client.ext -out client.pem
+
+rm -f ./*.csr ./*.ext ./*.srl
+# The broker runs as a non-root user.
+chmod 644 ./*
+echo "certificates written to $(pwd)"